@@ -0,0 +1,44 @@
+// Package auditstore writes the audit trail of changes made to
+// application data. Audit events are written using the caller's
+// transaction so that the change and its audit record are
+// committed (or rolled back) together.
+package auditstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Create inserts the audit event using the given transaction
+func Create(ctx context.Context, tx *sql.Tx, e audit.Event) error {
+	detail, err := json.Marshal(e.Detail)
+	if err != nil {
+		return errs.E(errs.Internal, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`insert into demo.audit_event (audit_event_id,
+		                               action,
+		                               entity,
+		                               entity_id,
+		                               actor_username,
+		                               event_timestamp,
+		                               detail)
+		      values ($1, $2, $3, $4, $5, $6, $7)`,
+		e.ID,          //$1
+		e.Action,      //$2
+		e.Entity,      //$3
+		e.EntityID,    //$4
+		e.Actor.Email, //$5
+		e.Time,        //$6
+		detail)        //$7
+	if err != nil {
+		return errs.E(errs.Database, err)
+	}
+
+	return nil
+}
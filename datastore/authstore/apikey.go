@@ -0,0 +1,242 @@
+package authstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/auditstore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// apiKeyEntity is the entity name used for API key audit events
+const apiKeyEntity string = "api_key"
+
+// apiKeyColumns are the api_key and app_user columns selected for
+// an APIKey, in the order they are scanned by scanAPIKey
+const apiKeyColumns string = `k.api_key_id,
+				k.key_name,
+				k.key_prefix,
+				k.key_hash,
+				k.scopes,
+				k.create_timestamp,
+				k.revoke_timestamp,
+				u.user_id,
+				u.email,
+				coalesce(u.first_name, ''),
+				coalesce(u.last_name, ''),
+				coalesce(u.full_name, '')`
+
+// apiKeyAuditDetail is the audit detail for an API key. The key
+// hash is deliberately left out.
+func apiKeyAuditDetail(k *auth.APIKey) map[string]string {
+	return map[string]string{
+		"name":            k.Name,
+		"prefix":          k.Prefix,
+		"service_account": k.User.Email,
+		"scopes":          k.Scopes.String(),
+	}
+}
+
+// CreateAPIKey inserts the API key. The key's service account user
+// must already be stored.
+func (dt DefaultTransactor) CreateAPIKey(ctx context.Context, k *auth.APIKey, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`insert into demo.api_key (api_key_id,
+		                           key_name,
+		                           key_prefix,
+		                           key_hash,
+		                           scopes,
+		                           user_id,
+		                           create_username,
+		                           create_timestamp)
+		      values ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		k.ID,                         //$1
+		k.Name,                       //$2
+		k.Prefix,                     //$3
+		k.Hash,                       //$4
+		pq.Array([]string(k.Scopes)), //$5
+		k.User.ID,                    //$6
+		actor.Email,                  //$7
+		k.CreateTime)                 //$8
+	if datastore.IsUniqueViolation(err) {
+		// the prefix is generated, not given by the caller, so a
+		// collision is ours and the caller should just try again
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Internal, errs.Code("key_prefix_collision"), errors.New("generated API key prefix already exists, try again")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Create, apiKeyEntity, k.ID.String(), actor, apiKeyAuditDetail(k)))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// RevokeAPIKey revokes the API key. Revoked keys are kept for the
+// audit trail but can no longer be used to authenticate.
+func (dt DefaultTransactor) RevokeAPIKey(ctx context.Context, k *auth.APIKey, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`update demo.api_key
+		    set revoke_username = $1,
+		        revoke_timestamp = $2
+		  where api_key_id = $3
+		    and revoke_timestamp is null`,
+		actor.Email,  //$1
+		k.RevokeTime, //$2
+		k.ID)         //$3
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = datastore.OneRowAffected(result)
+	if err != nil {
+		return dt.datastorer.RollbackTx(tx, err)
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Revoke, apiKeyEntity, k.ID.String(), actor, apiKeyAuditDetail(k)))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// scanner is satisfied by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanAPIKey scans the apiKeyColumns into an APIKey
+func scanAPIKey(s scanner) (*auth.APIKey, error) {
+	k := new(auth.APIKey)
+	var (
+		scopes     []string
+		revokeTime sql.NullTime
+	)
+	err := s.Scan(
+		&k.ID,
+		&k.Name,
+		&k.Prefix,
+		&k.Hash,
+		pq.Array(&scopes),
+		&k.CreateTime,
+		&revokeTime,
+		&k.User.ID,
+		&k.User.Email,
+		&k.User.FirstName,
+		&k.User.LastName,
+		&k.User.FullName)
+	if err != nil {
+		return nil, err
+	}
+	k.Scopes = scopes
+	if revokeTime.Valid {
+		k.RevokeTime = revokeTime.Time
+	}
+	return k, nil
+}
+
+// FindAPIKeyByID returns the API key for the given ID
+func (d DefaultSelector) FindAPIKeyByID(ctx context.Context, id uuid.UUID) (*auth.APIKey, error) {
+	db := d.Datastorer.DB()
+
+	row := db.QueryRowContext(ctx,
+		`select `+apiKeyColumns+`
+		   from demo.api_key k
+		   join demo.app_user u on u.user_id = k.user_id
+		  where k.api_key_id = $1`, id)
+
+	k, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, errs.E(errs.NotExist, "No record found for given ID")
+	} else if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return k, nil
+}
+
+// FindAPIKeyByPrefix returns the API key for the given prefix,
+// including revoked keys
+func (d DefaultSelector) FindAPIKeyByPrefix(ctx context.Context, prefix string) (*auth.APIKey, error) {
+	db := d.Datastorer.DB()
+
+	row := db.QueryRowContext(ctx,
+		`select `+apiKeyColumns+`
+		   from demo.api_key k
+		   join demo.app_user u on u.user_id = k.user_id
+		  where k.key_prefix = $1`, prefix)
+
+	k, err := scanAPIKey(row)
+	if err == sql.ErrNoRows {
+		return nil, errs.E(errs.NotExist, "No record found for given prefix")
+	} else if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return k, nil
+}
+
+// FindAllAPIKeys returns all API keys, including revoked keys,
+// ordered by service account and create time
+func (d DefaultSelector) FindAllAPIKeys(ctx context.Context) ([]*auth.APIKey, error) {
+	db := d.Datastorer.DB()
+
+	rows, err := db.QueryContext(ctx,
+		`select `+apiKeyColumns+`
+		   from demo.api_key k
+		   join demo.app_user u on u.user_id = k.user_id
+		  order by u.email, k.create_timestamp`)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	s := make([]*auth.APIKey, 0)
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		s = append(s, k)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	err = rows.Err()
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
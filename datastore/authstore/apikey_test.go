@@ -0,0 +1,30 @@
+package authstore
+
+import (
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+)
+
+// countingScanner records the number of destinations it is given
+type countingScanner struct {
+	n int
+}
+
+func (s *countingScanner) Scan(dest ...interface{}) error {
+	s.n = len(dest)
+	return nil
+}
+
+func TestAPIKeyColumns_matchScan(t *testing.T) {
+	s := new(countingScanner)
+	_, err := scanAPIKey(s)
+	qt.Assert(t, err, qt.IsNil)
+
+	// columns are separated by a comma at the end of the line, as
+	// some columns hold commas themselves
+	columns := strings.Split(apiKeyColumns, ",\n")
+	qt.Assert(t, len(columns), qt.Equals, s.n)
+	qt.Assert(t, strings.TrimSpace(columns[4]), qt.Equals, "k.scopes")
+}
@@ -0,0 +1,162 @@
+// Package authstoretest provides testing helper functions for the
+// authstore package
+package authstoretest
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// RoleID is the ID of the role returned by MockSelector
+var RoleID = uuid.MustParse("0a9e5a8e-1b8e-4d4e-9a64-3a6f1e1c7d01")
+
+// APIKeyID is the ID of the API key returned by MockSelector
+var APIKeyID = uuid.MustParse("7c1f0e2a-5b9d-4c3e-8f6a-2d4b1e9c0a53")
+
+// ServiceAccountID is the ID of the service account user of the
+// API key returned by MockSelector
+var ServiceAccountID = uuid.MustParse("3e5d7a9b-1c2f-4e6a-8b0d-9f1e3c5a7b20")
+
+// MockAPIKey is the full key of the API key returned by MockSelector
+const MockAPIKey string = "mockpfx1.mockSecret"
+
+// NewAPIKey provides an APIKey for testing which matches MockAPIKey
+func NewAPIKey(t *testing.T) *auth.APIKey {
+	t.Helper()
+
+	sa := auth.NewServiceAccount("nightly-batch")
+	sa.ID = ServiceAccountID
+
+	return &auth.APIKey{
+		ID:         APIKeyID,
+		Name:       "nightly-batch",
+		Prefix:     "mockpfx1",
+		Hash:       auth.HashAPIKey(MockAPIKey),
+		User:       sa,
+		Scopes:     auth.Scopes{auth.MoviesReadScope, auth.MoviesWriteScope},
+		CreateTime: time.Date(2021, time.March, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+// NewRole provides a Role for testing
+func NewRole(t *testing.T) *auth.Role {
+	t.Helper()
+
+	return &auth.Role{
+		ID:          RoleID,
+		Name:        "movie-admin",
+		Description: "Maintain movies",
+		Permissions: []auth.Permission{
+			{Effect: auth.Allow, Path: "/api/v1/movies/**", Method: "*"},
+			{Effect: auth.Deny, Path: "/api/v1/movies/*", Method: http.MethodDelete},
+		},
+	}
+}
+
+// NewMockTransactor is an initializer for MockTransactor
+func NewMockTransactor(t *testing.T) MockTransactor {
+	return MockTransactor{t: t}
+}
+
+// MockTransactor is a mock which satisfies the authstore.Transactor
+// interface
+type MockTransactor struct {
+	t *testing.T
+}
+
+// CreateRole mocks creating a role
+func (mt MockTransactor) CreateRole(ctx context.Context, r *auth.Role, actor user.User) error {
+	return nil
+}
+
+// UpdateRole mocks updating a role
+func (mt MockTransactor) UpdateRole(ctx context.Context, r *auth.Role, actor user.User) error {
+	return nil
+}
+
+// DeleteRole mocks deleting a role
+func (mt MockTransactor) DeleteRole(ctx context.Context, r *auth.Role, actor user.User) error {
+	return nil
+}
+
+// CreateRoleAssignment mocks assigning a role to a user
+func (mt MockTransactor) CreateRoleAssignment(ctx context.Context, ra auth.RoleAssignment, actor user.User) error {
+	return nil
+}
+
+// DeleteRoleAssignment mocks removing a role from a user
+func (mt MockTransactor) DeleteRoleAssignment(ctx context.Context, ra auth.RoleAssignment, actor user.User) error {
+	return nil
+}
+
+// CreateAPIKey mocks creating an API key
+func (mt MockTransactor) CreateAPIKey(ctx context.Context, k *auth.APIKey, actor user.User) error {
+	return nil
+}
+
+// RevokeAPIKey mocks revoking an API key
+func (mt MockTransactor) RevokeAPIKey(ctx context.Context, k *auth.APIKey, actor user.User) error {
+	return nil
+}
+
+// NewMockSelector is an initializer for MockSelector
+func NewMockSelector(t *testing.T) MockSelector {
+	return MockSelector{t: t}
+}
+
+// MockSelector is a mock which satisfies the authstore.Selector,
+// auth.RoleFinder and auth.APIKeyFinder interfaces
+type MockSelector struct {
+	t *testing.T
+}
+
+// FindRoleByID mocks finding a role by ID
+func (ms MockSelector) FindRoleByID(ctx context.Context, id uuid.UUID) (*auth.Role, error) {
+	return NewRole(ms.t), nil
+}
+
+// FindAllRoles mocks finding all roles
+func (ms MockSelector) FindAllRoles(ctx context.Context) ([]*auth.Role, error) {
+	return []*auth.Role{NewRole(ms.t)}, nil
+}
+
+// FindRolesByUserEmail mocks finding the roles assigned to a user
+func (ms MockSelector) FindRolesByUserEmail(ctx context.Context, email string) ([]*auth.Role, error) {
+	return []*auth.Role{NewRole(ms.t)}, nil
+}
+
+// FindAllRoleAssignments mocks finding all role assignments
+func (ms MockSelector) FindAllRoleAssignments(ctx context.Context) ([]auth.RoleAssignment, error) {
+	u := usertest.NewUser(ms.t)
+	u.ID = uuid.MustParse("a4d08ba5-d8a7-4b83-8ad8-2bbc72d5b3d4")
+	return []auth.RoleAssignment{{Role: *NewRole(ms.t), User: u}}, nil
+}
+
+// FindAPIKeyByID mocks finding an API key by ID
+func (ms MockSelector) FindAPIKeyByID(ctx context.Context, id uuid.UUID) (*auth.APIKey, error) {
+	return NewAPIKey(ms.t), nil
+}
+
+// FindAPIKeyByPrefix mocks finding an API key by prefix. Only the
+// prefix of MockAPIKey is found.
+func (ms MockSelector) FindAPIKeyByPrefix(ctx context.Context, prefix string) (*auth.APIKey, error) {
+	k := NewAPIKey(ms.t)
+	if prefix != k.Prefix {
+		return nil, errs.E(errs.NotExist, "No record found for given prefix")
+	}
+	return k, nil
+}
+
+// FindAllAPIKeys mocks finding all API keys
+func (ms MockSelector) FindAllAPIKeys(ctx context.Context) ([]*auth.APIKey, error) {
+	return []*auth.APIKey{NewAPIKey(ms.t)}, nil
+}
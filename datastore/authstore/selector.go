@@ -0,0 +1,235 @@
+// Package authstore performs all DML and select operations for
+// authentication and authorization data: roles, their permissions,
+// the assignment of roles to users and API keys
+package authstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Selector reads records from the db
+type Selector interface {
+	FindRoleByID(ctx context.Context, id uuid.UUID) (*auth.Role, error)
+	FindAllRoles(ctx context.Context) ([]*auth.Role, error)
+	FindRolesByUserEmail(ctx context.Context, email string) ([]*auth.Role, error)
+	FindAllRoleAssignments(ctx context.Context) ([]auth.RoleAssignment, error)
+	FindAPIKeyByID(ctx context.Context, id uuid.UUID) (*auth.APIKey, error)
+	FindAPIKeyByPrefix(ctx context.Context, prefix string) (*auth.APIKey, error)
+	FindAllAPIKeys(ctx context.Context) ([]*auth.APIKey, error)
+}
+
+// NewDefaultSelector is an initializer for DefaultSelector
+func NewDefaultSelector(ds datastore.Datastorer) DefaultSelector {
+	return DefaultSelector{ds}
+}
+
+// DefaultSelector is the database implementation for READ operations
+// for roles and role assignments
+type DefaultSelector struct {
+	datastore.Datastorer
+}
+
+// FindRoleByID returns the Role (including its permissions) for the given ID
+func (d DefaultSelector) FindRoleByID(ctx context.Context, id uuid.UUID) (*auth.Role, error) {
+	db := d.Datastorer.DB()
+
+	row := db.QueryRowContext(ctx,
+		`select role_id,
+				role_name,
+				description
+		   from demo.app_role
+		  where role_id = $1`, id)
+
+	r := new(auth.Role)
+	err := row.Scan(&r.ID, &r.Name, &r.Description)
+	if err == sql.ErrNoRows {
+		return nil, errs.E(errs.NotExist, "No record found for given ID")
+	} else if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	err = d.addPermissions(ctx, []*auth.Role{r})
+	if err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// FindAllRoles returns all roles (including their permissions)
+// ordered by name
+func (d DefaultSelector) FindAllRoles(ctx context.Context) ([]*auth.Role, error) {
+	db := d.Datastorer.DB()
+
+	rows, err := db.QueryContext(ctx,
+		`select role_id,
+				role_name,
+				description
+		   from demo.app_role
+		  order by role_name`)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return d.scanRoles(ctx, rows)
+}
+
+// FindRolesByUserEmail returns the roles (including their
+// permissions) assigned to the user with the given email. It
+// satisfies the auth.RoleFinder interface.
+func (d DefaultSelector) FindRolesByUserEmail(ctx context.Context, email string) ([]*auth.Role, error) {
+	db := d.Datastorer.DB()
+
+	rows, err := db.QueryContext(ctx,
+		`select r.role_id,
+				r.role_name,
+				r.description
+		   from demo.app_role r
+		   join demo.app_role_assignment ra on ra.role_id = r.role_id
+		   join demo.app_user u on u.user_id = ra.user_id
+		  where lower(u.email) = lower($1)
+		  order by r.role_name`, email)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return d.scanRoles(ctx, rows)
+}
+
+// FindAllRoleAssignments returns all role assignments ordered by
+// role name and user email. The permissions of the assigned
+// roles are not populated.
+func (d DefaultSelector) FindAllRoleAssignments(ctx context.Context) ([]auth.RoleAssignment, error) {
+	db := d.Datastorer.DB()
+
+	rows, err := db.QueryContext(ctx,
+		`select r.role_id,
+				r.role_name,
+				r.description,
+				u.user_id,
+				u.email,
+				u.first_name,
+				u.last_name,
+				u.full_name
+		   from demo.app_role_assignment ra
+		   join demo.app_role r on r.role_id = ra.role_id
+		   join demo.app_user u on u.user_id = ra.user_id
+		  order by r.role_name, u.email`)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	s := make([]auth.RoleAssignment, 0)
+	for rows.Next() {
+		var ra auth.RoleAssignment
+		err = rows.Scan(
+			&ra.Role.ID,
+			&ra.Role.Name,
+			&ra.Role.Description,
+			&ra.User.ID,
+			&ra.User.Email,
+			&ra.User.FirstName,
+			&ra.User.LastName,
+			&ra.User.FullName)
+		if err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		s = append(s, ra)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	err = rows.Err()
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
+
+// scanRoles scans role_id, role_name and description from rows into
+// a slice of roles, closes rows and then adds the role permissions
+func (d DefaultSelector) scanRoles(ctx context.Context, rows *sql.Rows) ([]*auth.Role, error) {
+	defer rows.Close()
+
+	s := make([]*auth.Role, 0)
+	for rows.Next() {
+		r := new(auth.Role)
+		err := rows.Scan(&r.ID, &r.Name, &r.Description)
+		if err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		s = append(s, r)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	err := rows.Err()
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	err = d.addPermissions(ctx, s)
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// addPermissions selects the permissions for all roles and adds
+// them to their role
+func (d DefaultSelector) addPermissions(ctx context.Context, roles []*auth.Role) error {
+	if len(roles) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(roles))
+	byID := make(map[uuid.UUID]*auth.Role, len(roles))
+	for _, r := range roles {
+		ids = append(ids, r.ID.String())
+		byID[r.ID] = r
+	}
+
+	rows, err := d.Datastorer.DB().QueryContext(ctx,
+		`select role_id,
+				effect,
+				path_pattern,
+				http_method
+		   from demo.app_role_permission
+		  where role_id = any($1::uuid[])
+		  order by role_id, path_pattern, http_method`, pq.Array(ids))
+	if err != nil {
+		return errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			roleID uuid.UUID
+			p      auth.Permission
+		)
+		err = rows.Scan(&roleID, &p.Effect, &p.Path, &p.Method)
+		if err != nil {
+			return errs.E(errs.Database, err)
+		}
+		if r, ok := byID[roleID]; ok {
+			r.Permissions = append(r.Permissions, p)
+		}
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	err = rows.Err()
+	if err != nil {
+		return errs.E(errs.Database, err)
+	}
+
+	return nil
+}
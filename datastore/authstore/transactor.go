@@ -0,0 +1,275 @@
+package authstore
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/auditstore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// Audit entity names
+const (
+	roleEntity           string = "role"
+	roleAssignmentEntity string = "role_assignment"
+)
+
+// Transactor performs DML actions against the DB. The actor is
+// the user making the change and is recorded in the audit trail.
+type Transactor interface {
+	CreateRole(ctx context.Context, r *auth.Role, actor user.User) error
+	UpdateRole(ctx context.Context, r *auth.Role, actor user.User) error
+	DeleteRole(ctx context.Context, r *auth.Role, actor user.User) error
+	CreateRoleAssignment(ctx context.Context, ra auth.RoleAssignment, actor user.User) error
+	DeleteRoleAssignment(ctx context.Context, ra auth.RoleAssignment, actor user.User) error
+	CreateAPIKey(ctx context.Context, k *auth.APIKey, actor user.User) error
+	RevokeAPIKey(ctx context.Context, k *auth.APIKey, actor user.User) error
+}
+
+// NewDefaultTransactor is an initializer for DefaultTransactor
+func NewDefaultTransactor(ds datastore.Datastorer) DefaultTransactor {
+	return DefaultTransactor{ds}
+}
+
+// DefaultTransactor is the default database implementation
+// for DML operations for roles, role assignments and API keys
+type DefaultTransactor struct {
+	datastorer datastore.Datastorer
+}
+
+// CreateRole inserts the role and its permissions
+func (dt DefaultTransactor) CreateRole(ctx context.Context, r *auth.Role, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`insert into demo.app_role (role_id,
+		                            role_name,
+		                            description,
+		                            create_username,
+		                            create_timestamp,
+		                            update_username,
+		                            update_timestamp)
+		      values ($1, $2, $3, $4, now(), $4, now())`,
+		r.ID,          //$1
+		r.Name,        //$2
+		r.Description, //$3
+		actor.Email)   //$4
+	if datastore.IsUniqueViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Exist, errs.Parameter("name"), errors.New("a role with this name already exists")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = createPermissions(ctx, tx, r)
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Create, roleEntity, r.ID.String(), actor, r))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// UpdateRole updates the role and replaces its permissions
+func (dt DefaultTransactor) UpdateRole(ctx context.Context, r *auth.Role, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`update demo.app_role
+		    set role_name = $1,
+		        description = $2,
+		        update_username = $3,
+		        update_timestamp = now()
+		  where role_id = $4`,
+		r.Name,        //$1
+		r.Description, //$2
+		actor.Email,   //$3
+		r.ID)          //$4
+	if datastore.IsUniqueViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Exist, errs.Parameter("name"), errors.New("a role with this name already exists")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = datastore.OneRowAffected(result)
+	if err != nil {
+		return dt.datastorer.RollbackTx(tx, err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`delete from demo.app_role_permission
+		  where role_id = $1`, r.ID)
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = createPermissions(ctx, tx, r)
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Update, roleEntity, r.ID.String(), actor, r))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// DeleteRole removes the role. Its permissions and assignments
+// are removed by the database as well.
+func (dt DefaultTransactor) DeleteRole(ctx context.Context, r *auth.Role, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`delete from demo.app_role
+		  where role_id = $1`, r.ID)
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = datastore.OneRowAffected(result)
+	if err != nil {
+		return dt.datastorer.RollbackTx(tx, err)
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Delete, roleEntity, r.ID.String(), actor, r))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// CreateRoleAssignment assigns the role to the user
+func (dt DefaultTransactor) CreateRoleAssignment(ctx context.Context, ra auth.RoleAssignment, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`insert into demo.app_role_assignment (role_id,
+		                                       user_id,
+		                                       create_username,
+		                                       create_timestamp)
+		      values ($1, $2, $3, now())`,
+		ra.Role.ID,  //$1
+		ra.User.ID,  //$2
+		actor.Email) //$3
+	if datastore.IsUniqueViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Exist, errors.New("role is already assigned to user")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Create, roleAssignmentEntity, roleAssignmentID(ra), actor, ra))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// DeleteRoleAssignment removes the role from the user
+func (dt DefaultTransactor) DeleteRoleAssignment(ctx context.Context, ra auth.RoleAssignment, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`delete from demo.app_role_assignment
+		  where role_id = $1
+		    and user_id = $2`, ra.Role.ID, ra.User.ID)
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = datastore.OneRowAffected(result)
+	if err != nil {
+		return dt.datastorer.RollbackTx(tx, err)
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Delete, roleAssignmentEntity, roleAssignmentID(ra), actor, ra))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// createPermissions inserts all permissions for the role
+func createPermissions(ctx context.Context, tx *sql.Tx, r *auth.Role) error {
+	for _, p := range r.Permissions {
+		_, err := tx.ExecContext(ctx,
+			`insert into demo.app_role_permission (permission_id,
+			                                       role_id,
+			                                       effect,
+			                                       path_pattern,
+			                                       http_method)
+			      values ($1, $2, $3, $4, $5)`,
+			uuid.New(), //$1
+			r.ID,       //$2
+			p.Effect,   //$3
+			p.Path,     //$4
+			p.Method)   //$5
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// roleAssignmentID is the audit entity ID for a role assignment
+func roleAssignmentID(ra auth.RoleAssignment) string {
+	return fmt.Sprintf("%s:%s", ra.Role.ID, ra.User.ID)
+}
@@ -0,0 +1,114 @@
+// Package clientstoretest provides testing helper functions for the
+// clientstore package
+package clientstoretest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/userstore/userstoretest"
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// ClientID is the ID of the active client returned by MockSelector
+var ClientID = uuid.MustParse("0d3b7f0e-3c4e-4d2a-9d8c-7b1f3e5a6c21")
+
+// DisabledClientID is the ID of the disabled client returned by
+// MockSelector
+var DisabledClientID = uuid.MustParse("5e8a1c2d-7f4b-4a9e-b3d6-2c9f8e1a4b70")
+
+// SigningSecret is the signing secret of the active client returned
+// by MockSelector. The disabled client has no signing secret.
+var SigningSecret = []byte("shhh-its-a-secret")
+
+// NewClient returns the client returned by MockSelector for the ID
+func NewClient(t *testing.T, id uuid.UUID) *client.Client {
+	t.Helper()
+
+	owner := usertest.NewUser(t)
+	owner.ID = userstoretest.UserID
+
+	// mock create/update timestamp
+	cuTime := time.Date(2008, 1, 8, 06, 54, 0, 0, time.UTC)
+
+	c := &client.Client{
+		ID:         id,
+		Name:       "Helping Hand Movie App",
+		Owner:      owner,
+		Status:     client.Active,
+		Scopes:     []string{"movies:read", "movies:write"},
+		CreateTime: cuTime,
+		UpdateTime: cuTime,
+	}
+	if id == DisabledClientID {
+		c.Name = "Retired Movie App"
+		c.Status = client.Disabled
+	}
+	return c
+}
+
+// NewMockTransactor is an initializer for MockTransactor
+func NewMockTransactor(t *testing.T) MockTransactor {
+	return MockTransactor{t: t}
+}
+
+// MockTransactor is a mock which satisfies the clientstore.Transactor
+// interface
+type MockTransactor struct {
+	t *testing.T
+}
+
+// Create mocks creating a client
+func (mt MockTransactor) Create(ctx context.Context, c *client.Client, actor user.User) error {
+	return nil
+}
+
+// Update mocks updating a client
+func (mt MockTransactor) Update(ctx context.Context, c *client.Client, actor user.User) error {
+	return nil
+}
+
+// SetSigningSecret mocks setting a client's signing secret
+func (mt MockTransactor) SetSigningSecret(ctx context.Context, c *client.Client, secret []byte, actor user.User) error {
+	return nil
+}
+
+// NewMockSelector is an initializer for MockSelector
+func NewMockSelector(t *testing.T) MockSelector {
+	return MockSelector{t: t}
+}
+
+// MockSelector is a mock which satisfies the clientstore.Selector,
+// client.Finder and auth.SigningSecretFinder interfaces
+type MockSelector struct {
+	t *testing.T
+}
+
+// FindByID mocks finding a client by ID. Only ClientID and
+// DisabledClientID are found.
+func (ms MockSelector) FindByID(ctx context.Context, id uuid.UUID) (*client.Client, error) {
+	if id != ClientID && id != DisabledClientID {
+		return nil, errs.E(errs.NotExist, "No record found for given ID")
+	}
+	return NewClient(ms.t, id), nil
+}
+
+// FindAll mocks finding all clients
+func (ms MockSelector) FindAll(ctx context.Context) ([]*client.Client, error) {
+	return []*client.Client{NewClient(ms.t, ClientID), NewClient(ms.t, DisabledClientID)}, nil
+}
+
+// FindSigningSecret mocks finding a client's signing secret. Only
+// ClientID has a signing secret.
+func (ms MockSelector) FindSigningSecret(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	if id != ClientID {
+		return nil, errs.E(errs.NotExist, "No signing secret found for given ID")
+	}
+	return SigningSecret, nil
+}
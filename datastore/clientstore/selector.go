@@ -0,0 +1,145 @@
+// Package clientstore performs all DML and select operations for a
+// registered client
+package clientstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// selectColumns are the app_client and owning app_user columns
+// selected for a Client, in the order they are scanned by scanClient
+const selectColumns string = `c.client_id,
+				c.client_name,
+				c.status,
+				c.scopes,
+				c.create_timestamp,
+				c.update_timestamp,
+				u.user_id,
+				u.email,
+				coalesce(u.first_name, ''),
+				coalesce(u.last_name, ''),
+				coalesce(u.full_name, '')`
+
+// Selector reads records from the db
+type Selector interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*client.Client, error)
+	FindAll(ctx context.Context) ([]*client.Client, error)
+}
+
+// NewDefaultSelector is an initializer for DefaultSelector
+func NewDefaultSelector(ds datastore.Datastorer) DefaultSelector {
+	return DefaultSelector{ds}
+}
+
+// DefaultSelector is the database implementation for READ operations for a client
+type DefaultSelector struct {
+	datastore.Datastorer
+}
+
+// scanner is satisfied by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanClient scans the selectColumns into a Client
+func scanClient(s scanner) (*client.Client, error) {
+	c := new(client.Client)
+	var scopes []string
+	err := s.Scan(
+		&c.ID,
+		&c.Name,
+		&c.Status,
+		pq.Array(&scopes),
+		&c.CreateTime,
+		&c.UpdateTime,
+		&c.Owner.ID,
+		&c.Owner.Email,
+		&c.Owner.FirstName,
+		&c.Owner.LastName,
+		&c.Owner.FullName)
+	if err != nil {
+		return nil, err
+	}
+	c.Scopes = scopes
+	return c, nil
+}
+
+// FindByID returns the Client for the given ID
+func (d DefaultSelector) FindByID(ctx context.Context, id uuid.UUID) (*client.Client, error) {
+	db := d.Datastorer.DB()
+
+	row := db.QueryRowContext(ctx,
+		`select `+selectColumns+`
+		   from demo.app_client c
+		   join demo.app_user u on u.user_id = c.owner_user_id
+		  where c.client_id = $1`, id)
+
+	c, err := scanClient(row)
+	if err == sql.ErrNoRows {
+		return nil, errs.E(errs.NotExist, "No record found for given ID")
+	} else if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return c, nil
+}
+
+// FindSigningSecret returns the secret the client signs requests
+// with. A NotExist error is returned if the client is not registered
+// or has not been issued a signing secret.
+func (d DefaultSelector) FindSigningSecret(ctx context.Context, id uuid.UUID) ([]byte, error) {
+	db := d.Datastorer.DB()
+
+	var secret sql.NullString
+	err := db.QueryRowContext(ctx,
+		`select signing_secret
+		   from demo.app_client
+		  where client_id = $1`, id).Scan(&secret)
+	if err == sql.ErrNoRows || (err == nil && !secret.Valid) {
+		return nil, errs.E(errs.NotExist, "No signing secret found for given ID")
+	} else if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return []byte(secret.String), nil
+}
+
+// FindAll returns all clients ordered by name
+func (d DefaultSelector) FindAll(ctx context.Context) ([]*client.Client, error) {
+	db := d.Datastorer.DB()
+
+	rows, err := db.QueryContext(ctx,
+		`select `+selectColumns+`
+		   from demo.app_client c
+		   join demo.app_user u on u.user_id = c.owner_user_id
+		  order by c.client_name`)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	s := make([]*client.Client, 0)
+	for rows.Next() {
+		c, err := scanClient(rows)
+		if err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		s = append(s, c)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	err = rows.Err()
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,185 @@
+package clientstore
+
+import (
+	"context"
+
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/auditstore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// auditEntity is the entity name used for client audit events
+const auditEntity string = "client"
+
+// Transactor performs DML actions against the DB. The actor is
+// the user making the change and is recorded in the audit trail.
+type Transactor interface {
+	Create(ctx context.Context, c *client.Client, actor user.User) error
+	Update(ctx context.Context, c *client.Client, actor user.User) error
+	SetSigningSecret(ctx context.Context, c *client.Client, secret []byte, actor user.User) error
+}
+
+// NewDefaultTransactor is an initializer for DefaultTransactor
+func NewDefaultTransactor(ds datastore.Datastorer) DefaultTransactor {
+	return DefaultTransactor{ds}
+}
+
+// DefaultTransactor is the default database implementation
+// for DML operations for a client
+type DefaultTransactor struct {
+	datastorer datastore.Datastorer
+}
+
+// auditDetail is the audit detail for a client
+func auditDetail(c *client.Client) map[string]interface{} {
+	return map[string]interface{}{
+		"name":   c.Name,
+		"owner":  c.Owner.Email,
+		"status": c.Status,
+		"scopes": c.Scopes,
+	}
+}
+
+// Create inserts a record in the app_client table
+func (dt DefaultTransactor) Create(ctx context.Context, c *client.Client, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`insert into demo.app_client (client_id,
+		                              client_name,
+		                              owner_user_id,
+		                              status,
+		                              scopes,
+		                              create_username,
+		                              create_timestamp,
+		                              update_username,
+		                              update_timestamp)
+		      values ($1, $2, $3, $4, $5, $6, $7, $6, $8)`,
+		c.ID,               //$1
+		c.Name,             //$2
+		c.Owner.ID,         //$3
+		c.Status,           //$4
+		pq.Array(c.Scopes), //$5
+		actor.Email,        //$6
+		c.CreateTime,       //$7
+		c.UpdateTime)       //$8
+	if datastore.IsUniqueViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Exist, errs.Parameter("name"), errors.New("a client with this name already exists")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Create, auditEntity, c.ID.String(), actor, auditDetail(c)))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// Update updates the app_client record for the client's ID
+func (dt DefaultTransactor) Update(ctx context.Context, c *client.Client, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`update demo.app_client
+		    set client_name = $1,
+		        owner_user_id = $2,
+		        status = $3,
+		        scopes = $4,
+		        update_username = $5,
+		        update_timestamp = $6
+		  where client_id = $7`,
+		c.Name,             //$1
+		c.Owner.ID,         //$2
+		c.Status,           //$3
+		pq.Array(c.Scopes), //$4
+		actor.Email,        //$5
+		c.UpdateTime,       //$6
+		c.ID)               //$7
+	if datastore.IsUniqueViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Exist, errs.Parameter("name"), errors.New("a client with this name already exists")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = datastore.OneRowAffected(result)
+	if err != nil {
+		return dt.datastorer.RollbackTx(tx, err)
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Update, auditEntity, c.ID.String(), actor, auditDetail(c)))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// SetSigningSecret sets the secret the client signs requests with,
+// replacing any previous secret. The secret is needed to verify
+// signatures, so unlike API keys it cannot be stored hashed, and it
+// is left out of the audit trail.
+func (dt DefaultTransactor) SetSigningSecret(ctx context.Context, c *client.Client, secret []byte, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`update demo.app_client
+		    set signing_secret = $1,
+		        update_username = $2,
+		        update_timestamp = $3
+		  where client_id = $4`,
+		string(secret), //$1
+		actor.Email,    //$2
+		c.UpdateTime,   //$3
+		c.ID)           //$4
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = datastore.OneRowAffected(result)
+	if err != nil {
+		return dt.datastorer.RollbackTx(tx, err)
+	}
+
+	detail := auditDetail(c)
+	detail["signing_secret"] = "rotated"
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Update, auditEntity, c.ID.String(), actor, detail))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
@@ -10,10 +10,23 @@ import (
 
 	"github.com/gilcrest/go-api-basic/domain/errs"
 
-	_ "github.com/lib/pq" // pq driver calls for blank identifier
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 )
 
+// pqUniqueViolation is the PostgreSQL error code for unique_violation
+const pqUniqueViolation pq.ErrorCode = "23505"
+
+// pqForeignKeyViolation is the PostgreSQL error code for
+// foreign_key_violation
+const pqForeignKeyViolation pq.ErrorCode = "23503"
+
+// tenantSetting is the PostgreSQL setting holding the organization
+// (tenant) for the current transaction. Row-level security policies
+// on tenant-scoped tables compare against it.
+const tenantSetting string = "app.current_org_id"
+
 // Datastorer is an interface for working with the Database
 type Datastorer interface {
 	// DB returns a sql.DB
@@ -116,6 +129,24 @@ func (ds DefaultDatastore) CommitTx(tx *sql.Tx) error {
 	return nil
 }
 
+// SetTenant sets the organization (tenant) for the remainder of the
+// transaction. The setting is local to the transaction, so it cannot
+// leak to other requests sharing the pooled connection. Queries must
+// still filter by tenant themselves; the row-level security policies
+// reading the setting are a second line of defense.
+func SetTenant(ctx context.Context, tx *sql.Tx, orgID uuid.UUID) error {
+	if orgID == uuid.Nil {
+		return errs.E(errs.Internal, errors.New("tenant org ID cannot be nil"))
+	}
+
+	_, err := tx.ExecContext(ctx, `select set_config($1, $2, true)`, tenantSetting, orgID.String())
+	if err != nil {
+		return errs.E(errs.Database, err)
+	}
+
+	return nil
+}
+
 // NewNullString returns a null if s is empty, otherwise it returns
 // the string which was input
 func NewNullString(s string) sql.NullString {
@@ -139,3 +170,52 @@ func NewNullInt64(i int64) sql.NullInt64 {
 		Valid: true,
 	}
 }
+
+// NewNullUUID returns a null if id is the nil UUID, otherwise it
+// returns the string form of id, which PostgreSQL will cast to uuid
+func NewNullUUID(id uuid.UUID) sql.NullString {
+	if id == uuid.Nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{
+		String: id.String(),
+		Valid:  true,
+	}
+}
+
+// IsUniqueViolation reports whether the error (or any error it wraps)
+// is a PostgreSQL unique_violation error
+func IsUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqUniqueViolation
+	}
+	return false
+}
+
+// IsForeignKeyViolation reports whether the error (or any error it
+// wraps) is a PostgreSQL foreign_key_violation error
+func IsForeignKeyViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == pqForeignKeyViolation
+	}
+	return false
+}
+
+// OneRowAffected returns an error unless exactly one row was
+// affected by the DML statement which returned the result. If no
+// rows were affected, a NotExist error is returned.
+func OneRowAffected(result sql.Result) error {
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return errs.E(errs.Database, err)
+	}
+	switch {
+	case rowsAffected == 0:
+		return errs.E(errs.NotExist, errors.New("No record found for given ID"))
+	case rowsAffected > 1:
+		return errs.E(errs.Database, errors.New("Too Many Rows Affected"))
+	}
+	return nil
+}
@@ -8,6 +8,8 @@ import (
 	"testing"
 
 	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
 	"github.com/pkg/errors"
 
 	"github.com/gilcrest/go-api-basic/domain/errs"
@@ -95,6 +97,16 @@ func TestNewDatastore(t *testing.T) {
 	c.Assert(got, qt.Equals, want)
 }
 
+func TestIsForeignKeyViolation(t *testing.T) {
+	fkErr := &pq.Error{Code: "23503", Message: `update or delete on table "app_user" violates foreign key constraint "app_client_app_user_fk"`}
+
+	qt.Assert(t, IsForeignKeyViolation(fkErr), qt.IsTrue)
+	qt.Assert(t, IsForeignKeyViolation(errors.Wrap(fkErr, "delete")), qt.IsTrue)
+	qt.Assert(t, IsForeignKeyViolation(&pq.Error{Code: "23505"}), qt.IsFalse)
+	qt.Assert(t, IsForeignKeyViolation(errors.New("some error")), qt.IsFalse)
+	qt.Assert(t, IsForeignKeyViolation(nil), qt.IsFalse)
+}
+
 func TestNewNullInt64(t *testing.T) {
 	type args struct {
 		i int64
@@ -137,6 +149,25 @@ func TestNewNullString(t *testing.T) {
 	}
 }
 
+func TestNewNullUUID(t *testing.T) {
+	id := uuid.New()
+	tests := []struct {
+		name string
+		id   uuid.UUID
+		want sql.NullString
+	}{
+		{"has value", id, sql.NullString{String: id.String(), Valid: true}},
+		{"nil uuid", uuid.Nil, sql.NullString{String: "", Valid: false}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NewNullUUID(tt.id); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("NewNullUUID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestDatastore_BeginTx(t *testing.T) {
 	type fields struct {
 		db      *sql.DB
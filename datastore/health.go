@@ -0,0 +1,170 @@
+package datastore
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// SchemaTable is a table of the schema and the columns of it the
+// application uses
+type SchemaTable struct {
+	Schema  string
+	Table   string
+	Columns []string
+}
+
+// String returns the schema qualified name of the table
+func (t SchemaTable) String() string {
+	return t.Schema + "." + t.Table
+}
+
+// SchemaTables are the tables created by the DDL scripts in
+// scripts/ddl, with the columns which must exist for the
+// application to run. Columns added to a table by a later change are
+// listed too, so a database migrated only part of the way is not
+// reported ready.
+var SchemaTables = []SchemaTable{
+	{Schema: "demo", Table: "movie", Columns: []string{
+		"movie_id", "org_id", "extl_id", "title", "rated", "released",
+		"run_time", "director", "writer",
+		"create_client_id", "create_user_id", "create_username", "create_timestamp",
+		"update_client_id", "update_user_id", "update_username", "update_timestamp",
+	}},
+	{Schema: "demo", Table: "app_user", Columns: []string{
+		"user_id", "email", "first_name", "last_name", "full_name",
+		"hosted_domain", "picture_url", "profile_link",
+		"create_username", "create_timestamp", "update_username", "update_timestamp",
+	}},
+	{Schema: "demo", Table: "app_role", Columns: []string{
+		"role_id", "role_name", "description",
+		"create_username", "create_timestamp", "update_username", "update_timestamp",
+	}},
+	{Schema: "demo", Table: "app_role_permission", Columns: []string{
+		"permission_id", "role_id", "effect", "path_pattern", "http_method",
+	}},
+	{Schema: "demo", Table: "app_role_assignment", Columns: []string{
+		"role_id", "user_id", "create_username", "create_timestamp",
+	}},
+	{Schema: "demo", Table: "audit_event", Columns: []string{
+		"audit_event_id", "action", "entity", "entity_id",
+		"actor_username", "event_timestamp", "detail",
+	}},
+	{Schema: "demo", Table: "api_key", Columns: []string{
+		"api_key_id", "key_name", "key_prefix", "key_hash", "scopes", "user_id",
+		"create_username", "create_timestamp", "revoke_username", "revoke_timestamp",
+	}},
+	{Schema: "demo", Table: "app_client", Columns: []string{
+		"client_id", "client_name", "owner_user_id", "status", "scopes", "signing_secret",
+		"create_username", "create_timestamp", "update_username", "update_timestamp",
+	}},
+	{Schema: "demo", Table: "org", Columns: []string{
+		"org_id", "org_name",
+		"create_username", "create_timestamp", "update_username", "update_timestamp",
+	}},
+	{Schema: "demo", Table: "org_member", Columns: []string{
+		"org_id", "user_id", "create_username", "create_timestamp",
+	}},
+}
+
+// healthCheckTimeout is how long the queries of a health check may take
+const healthCheckTimeout time.Duration = 2 * time.Second
+
+// PingChecker is a health.Checker which pings the database
+type PingChecker struct {
+	Datastorer Datastorer
+}
+
+// Name returns the name of the check
+func (c PingChecker) Name() string {
+	return "database"
+}
+
+// CheckHealth pings the database
+func (c PingChecker) CheckHealth() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	if err := c.Datastorer.DB().PingContext(ctx); err != nil {
+		return errs.E(errs.Database, err)
+	}
+	return nil
+}
+
+// SchemaChecker is a health.Checker which checks the tables of the
+// schema and their columns exist, i.e. the DDL scripts were run in
+// full
+type SchemaChecker struct {
+	Datastorer Datastorer
+	// Tables are the tables, and their columns, which must exist
+	Tables []SchemaTable
+}
+
+// Name returns the name of the check
+func (c SchemaChecker) Name() string {
+	return "migrations"
+}
+
+// CheckHealth returns an error naming the first missing table or the
+// missing columns of the first table missing any
+func (c SchemaChecker) CheckHealth() error {
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckTimeout)
+	defer cancel()
+
+	for _, t := range c.Tables {
+		have, err := c.columns(ctx, t)
+		if err != nil {
+			return errs.E(errs.Database, err)
+		}
+		if len(have) == 0 {
+			return errs.E(errs.Database, errors.Errorf("table %s does not exist", t))
+		}
+		if missing := missingColumns(t.Columns, have); len(missing) > 0 {
+			return errs.E(errs.Database, errors.Errorf("table %s is missing columns %s", t, strings.Join(missing, ", ")))
+		}
+	}
+
+	return nil
+}
+
+// columns returns the names of the columns of the table, which are
+// none if the table does not exist
+func (c SchemaChecker) columns(ctx context.Context, t SchemaTable) (map[string]bool, error) {
+	rows, err := c.Datastorer.DB().QueryContext(ctx,
+		`select column_name from information_schema.columns where table_schema = $1 and table_name = $2`,
+		t.Schema, t.Table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	have := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		have[name] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return have, nil
+}
+
+// missingColumns returns the columns of want not in have, in the
+// order of want
+func missingColumns(want []string, have map[string]bool) []string {
+	var missing []string
+	for _, col := range want {
+		if !have[col] {
+			missing = append(missing, col)
+		}
+	}
+	return missing
+}
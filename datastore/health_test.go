@@ -0,0 +1,70 @@
+package datastore
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestHealthCheckers_Unreachable(t *testing.T) {
+	c := qt.New(t)
+
+	// nothing listens on port 1, so the checks fail without a
+	// database
+	db, err := sql.Open("postgres", "host=127.0.0.1 port=1 dbname=none sslmode=disable connect_timeout=1")
+	c.Assert(err, qt.IsNil)
+	defer db.Close()
+	ds := NewDefaultDatastore(db)
+
+	pc := PingChecker{Datastorer: ds}
+	c.Assert(pc.Name(), qt.Equals, "database")
+	c.Assert(errs.KindIs(errs.Database, pc.CheckHealth()), qt.IsTrue)
+
+	sc := SchemaChecker{Datastorer: ds, Tables: SchemaTables}
+	c.Assert(sc.Name(), qt.Equals, "migrations")
+	c.Assert(errs.KindIs(errs.Database, sc.CheckHealth()), qt.IsTrue)
+}
+
+func Test_missingColumns(t *testing.T) {
+	want := []string{"movie_id", "org_id", "extl_id"}
+
+	tests := []struct {
+		name string
+		have map[string]bool
+		want []string
+	}{
+		{"all present", map[string]bool{"movie_id": true, "org_id": true, "extl_id": true, "title": true}, nil},
+		{"pre-migration", map[string]bool{"movie_id": true, "extl_id": true}, []string{"org_id"}},
+		{"none", map[string]bool{}, want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt.Assert(t, missingColumns(want, tt.have), qt.DeepEquals, tt.want)
+		})
+	}
+}
+
+func TestSchemaTables_matchDDL(t *testing.T) {
+	c := qt.New(t)
+
+	b, err := ioutil.ReadFile("../scripts/ddl/demo_ddl.sql")
+	c.Assert(err, qt.IsNil)
+	ddl := string(b)
+
+	// each column must be defined in the create table statement of
+	// its table
+	for _, tbl := range SchemaTables {
+		start := strings.Index(ddl, "create table "+tbl.String()+"\n")
+		c.Assert(start >= 0, qt.IsTrue, qt.Commentf("table %s", tbl))
+		stmt := ddl[start:]
+		stmt = stmt[:strings.Index(stmt, "\n);")]
+		for _, col := range tbl.Columns {
+			c.Assert(strings.Contains(stmt, "\n    "+col+" "), qt.IsTrue, qt.Commentf("column %s.%s", tbl, col))
+		}
+	}
+}
@@ -47,8 +47,9 @@ type MockSelector struct {
 	t *testing.T
 }
 
-// FindByID mocks finding a movie by External ID
-func (ms MockSelector) FindByID(ctx context.Context, s string) (*movie.Movie, error) {
+// FindByID mocks finding a movie by External ID in the catalog
+// of the given org
+func (ms MockSelector) FindByID(ctx context.Context, orgID uuid.UUID, s string) (*movie.Movie, error) {
 
 	// get test user
 	u := usertest.NewUser(ms.t)
@@ -58,6 +59,7 @@ func (ms MockSelector) FindByID(ctx context.Context, s string) (*movie.Movie, er
 
 	return &movie.Movie{
 		ID:         uuid.MustParse("f118f4bb-b345-4517-b463-f237630b1a07"),
+		OrgID:      orgID,
 		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
 		Title:      "Repo Man",
 		Rated:      "R",
@@ -72,8 +74,8 @@ func (ms MockSelector) FindByID(ctx context.Context, s string) (*movie.Movie, er
 	}, nil
 }
 
-// FindAll mocks finding multiple movies by External ID
-func (ms MockSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
+// FindAll mocks finding all movies in the catalog of the given org
+func (ms MockSelector) FindAll(ctx context.Context, orgID uuid.UUID) ([]*movie.Movie, error) {
 	// get test user
 	u := usertest.NewUser(ms.t)
 
@@ -82,6 +84,7 @@ func (ms MockSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
 
 	m1 := &movie.Movie{
 		ID:         uuid.MustParse("f118f4bb-b345-4517-b463-f237630b1a07"),
+		OrgID:      orgID,
 		ExternalID: "kCBqDtyAkZIfdWjRDXQG",
 		Title:      "Repo Man",
 		Rated:      "R",
@@ -97,6 +100,7 @@ func (ms MockSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
 
 	m2 := &movie.Movie{
 		ID:         uuid.MustParse("e883ebbb-c021-423b-954a-e94edb8b85b8"),
+		OrgID:      orgID,
 		ExternalID: "RWn8zcaTA1gk3ybrBdQV",
 		Title:      "The Return of the Living Dead",
 		Rated:      "R",
@@ -112,3 +116,15 @@ func (ms MockSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
 
 	return []*movie.Movie{m1, m2}, nil
 }
+
+// FindAllByClientID mocks finding the movies created through a client
+func (ms MockSelector) FindAllByClientID(ctx context.Context, orgID uuid.UUID, clientID uuid.UUID) ([]*movie.Movie, error) {
+	movies, err := ms.FindAll(ctx, orgID)
+	if err != nil {
+		return nil, err
+	}
+	for _, m := range movies {
+		m.SetCreateClientID(clientID).SetUpdateClientID(clientID)
+	}
+	return movies, nil
+}
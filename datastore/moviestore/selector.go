@@ -8,14 +8,38 @@ import (
 	"github.com/gilcrest/go-api-basic/datastore"
 	"github.com/gilcrest/go-api-basic/domain/errs"
 	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/tracing"
+	"github.com/google/uuid"
 
 	"github.com/pkg/errors"
 )
 
-// Selector reads records from the db
+// selectColumns are the movie columns selected for a Movie, in the
+// order they are scanned by scanMovie
+const selectColumns string = `movie_id,
+				org_id,
+				extl_id,
+				title,
+				rated,
+				released,
+				run_time,
+				director,
+				writer,
+				create_client_id,
+				create_user_id,
+				create_username,
+				create_timestamp,
+				update_client_id,
+				update_user_id,
+				update_username,
+				update_timestamp`
+
+// Selector reads records from the db. Every method is scoped to the
+// catalog of the given organization (tenant).
 type Selector interface {
-	FindByID(context.Context, string) (*movie.Movie, error)
-	FindAll(context.Context) ([]*movie.Movie, error)
+	FindByID(ctx context.Context, orgID uuid.UUID, extlID string) (*movie.Movie, error)
+	FindAll(ctx context.Context, orgID uuid.UUID) ([]*movie.Movie, error)
+	FindAllByClientID(ctx context.Context, orgID uuid.UUID, clientID uuid.UUID) ([]*movie.Movie, error)
 }
 
 // NewDefaultSelector is an initializer for DefaultSelector
@@ -28,30 +52,18 @@ type DefaultSelector struct {
 	datastore.Datastorer
 }
 
-// FindByID returns a Movie struct to populate the response
-func (d DefaultSelector) FindByID(ctx context.Context, extlID string) (*movie.Movie, error) {
-	db := d.Datastorer.DB()
-
-	// Prepare the sql statement using bind variables
-	row := db.QueryRowContext(ctx,
-		`select movie_id,
-				extl_id,
-				title,
-				rated,
-				released,
-				run_time,
-				director,
-				writer,
-				create_username,
-				create_timestamp,
-				update_username,
-				update_timestamp
-		   from demo.movie m
-		  where extl_id = $1`, extlID)
+// scanner is satisfied by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
 
+// scanMovie scans the selectColumns into a Movie. The client and
+// user ID columns are nullable and scan to uuid.Nil when null.
+func scanMovie(s scanner) (*movie.Movie, error) {
 	m := new(movie.Movie)
-	err := row.Scan(
+	err := s.Scan(
 		&m.ID,
+		&m.OrgID,
 		&m.ExternalID,
 		&m.Title,
 		&m.Rated,
@@ -59,42 +71,116 @@ func (d DefaultSelector) FindByID(ctx context.Context, extlID string) (*movie.Mo
 		&m.RunTime,
 		&m.Director,
 		&m.Writer,
+		&m.CreateClientID,
+		&m.CreateUser.ID,
 		&m.CreateUser.Email,
 		&m.CreateTime,
+		&m.UpdateClientID,
+		&m.UpdateUser.ID,
 		&m.UpdateUser.Email,
 		&m.UpdateTime)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// FindByID returns a Movie struct to populate the response
+func (d DefaultSelector) FindByID(ctx context.Context, orgID uuid.UUID, extlID string) (m *movie.Movie, err error) {
+	ctx, span := datastore.StartSpan(ctx, "select_movie_by_extl_id")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	tx, err := beginTenantTx(ctx, d.Datastorer, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare the sql statement using bind variables
+	row := tx.QueryRowContext(ctx,
+		`select `+selectColumns+`
+		   from demo.movie m
+		  where org_id = $1
+		    and extl_id = $2`, orgID, extlID)
 
+	m, err = scanMovie(row)
 	if err == sql.ErrNoRows {
-		return nil, errs.E(errs.NotExist, "No record found for given ID")
+		return nil, d.Datastorer.RollbackTx(tx, errs.E(errs.NotExist, "No record found for given ID"))
 	} else if err != nil {
-		return nil, errs.E(errs.Database, err)
+		return nil, dbError(ctx, d.Datastorer.RollbackTx(tx, err))
+	}
+
+	if err := d.Datastorer.CommitTx(tx); err != nil {
+		return nil, dbError(ctx, err)
 	}
 
 	return m, nil
 }
 
 // FindAll returns a slice of Movie structs to populate the response
-func (d DefaultSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
-	db := d.Datastorer.DB()
+func (d DefaultSelector) FindAll(ctx context.Context, orgID uuid.UUID) (s []*movie.Movie, err error) {
+	ctx, span := datastore.StartSpan(ctx, "select_movies")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	s, err = d.findMovies(ctx, orgID,
+		`select `+selectColumns+`
+		   from demo.movie m
+		  where org_id = $1`, orgID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Determine if slice has not been populated. In this case, return
+	// an error as we should receive rows
+	if len(s) == 0 {
+		return nil, errs.E(errs.Validation, errors.New("No rows returned"))
+	}
+
+	// return the slice
+	return s, nil
+}
+
+// FindAllByClientID returns the movies created through the given
+// client. Unlike FindAll, an empty slice is returned if the client
+// has not created any movies.
+func (d DefaultSelector) FindAllByClientID(ctx context.Context, orgID uuid.UUID, clientID uuid.UUID) (s []*movie.Movie, err error) {
+	ctx, span := datastore.StartSpan(ctx, "select_movies_by_client_id")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	return d.findMovies(ctx, orgID,
+		`select `+selectColumns+`
+		   from demo.movie m
+		  where org_id = $1
+		    and create_client_id = $2`, orgID, clientID)
+}
+
+// findMovies runs the query in a transaction for the organization
+// (tenant) and scans the resulting rows into Movies
+func (d DefaultSelector) findMovies(ctx context.Context, orgID uuid.UUID, query string, args ...interface{}) ([]*movie.Movie, error) {
+	tx, err := beginTenantTx(ctx, d.Datastorer, orgID)
+	if err != nil {
+		return nil, err
+	}
 
 	// use QueryContext to get back sql.Rows
-	rows, err := db.QueryContext(ctx,
-		`select movie_id,
-					  extl_id,
-					  title,
-					  rated,
-					  released,
-					  run_time,
-					  director,
-					  writer,
-					  create_username,
-					  create_timestamp,
-					  update_username,
-					  update_timestamp
-				 from demo.movie m`)
+	rows, err := tx.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, errs.E(errs.Database, err)
+		return nil, dbError(ctx, d.Datastorer.RollbackTx(tx, err))
+	}
+
+	s, err := scanMovies(rows)
+	if err != nil {
+		return nil, dbError(ctx, d.Datastorer.RollbackTx(tx, err))
 	}
+
+	if err := d.Datastorer.CommitTx(tx); err != nil {
+		return nil, dbError(ctx, err)
+	}
+
+	return s, nil
+}
+
+// scanMovies scans each of the rows into a Movie and closes the rows
+func scanMovies(rows *sql.Rows) ([]*movie.Movie, error) {
 	defer rows.Close()
 	// declare a slice of pointers to movie.Movie
 	// var s []*movie.Movie
@@ -104,21 +190,7 @@ func (d DefaultSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
 	// a movie.Movie. Append movie.Movie to the slice
 	// defined above
 	for rows.Next() {
-		m := new(movie.Movie)
-		err = rows.Scan(
-			&m.ID,
-			&m.ExternalID,
-			&m.Title,
-			&m.Rated,
-			&m.Released,
-			&m.RunTime,
-			&m.Director,
-			&m.Writer,
-			&m.CreateUser.Email,
-			&m.CreateTime,
-			&m.UpdateUser.Email,
-			&m.UpdateTime)
-
+		m, err := scanMovie(rows)
 		if err != nil {
 			return nil, errs.E(errs.Database, err)
 		}
@@ -131,21 +203,14 @@ func (d DefaultSelector) FindAll(ctx context.Context) ([]*movie.Movie, error) {
 	// encounter an auto-commit error and be forced to rollback changes.
 	rerr := rows.Close()
 	if rerr != nil {
-		return nil, errs.E(errs.Database, err)
+		return nil, errs.E(errs.Database, rerr)
 	}
 
 	// Rows.Err will report the last error encountered by Rows.Scan.
-	err = rows.Err()
+	err := rows.Err()
 	if err != nil {
 		return nil, errs.E(errs.Database, err)
 	}
 
-	// Determine if slice has not been populated. In this case, return
-	// an error as we should receive rows
-	if len(s) == 0 {
-		return nil, errs.E(errs.Validation, errors.New("No rows returned"))
-	}
-
-	// return the slice
 	return s, nil
 }
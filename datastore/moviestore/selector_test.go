@@ -14,6 +14,7 @@ import (
 
 	"github.com/gilcrest/go-api-basic/datastore"
 	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/datastore/orgstore/orgstoretest"
 	"github.com/gilcrest/go-api-basic/domain/logger"
 )
 
@@ -82,7 +83,7 @@ func TestDefaultSelector_FindAll(t *testing.T) {
 			d := &DefaultSelector{
 				Datastorer: tt.fields.Datastorer,
 			}
-			got, err := d.FindAll(tt.args.ctx)
+			got, err := d.FindAll(tt.args.ctx, orgstoretest.OrgID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("FindAll() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -128,7 +129,7 @@ func TestDefaultSelector_FindByID(t *testing.T) {
 			d := &DefaultSelector{
 				Datastorer: tt.fields.Datastorer,
 			}
-			got, err := d.FindByID(tt.args.ctx, tt.args.extlID)
+			got, err := d.FindByID(tt.args.ctx, orgstoretest.OrgID, tt.args.extlID)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("FindByID() error = %v, wantErr %v", err, tt.wantErr)
 				return
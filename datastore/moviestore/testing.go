@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/orgstore/orgstoretest"
 	"github.com/gilcrest/go-api-basic/domain/movie"
 	"github.com/gilcrest/go-api-basic/domain/random"
 	"github.com/gilcrest/go-api-basic/domain/user/usertest"
@@ -50,5 +51,7 @@ func newMovie(t *testing.T) *movie.Movie {
 	if err != nil {
 		t.Fatalf("movie.NewMovie() error = %v", err)
 	}
+	// movies are created in the demo org seeded by the DDL script
+	m.SetOrgID(orgstoretest.OrgID)
 	return m
 }
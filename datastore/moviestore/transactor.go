@@ -2,6 +2,7 @@ package moviestore
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
@@ -9,6 +10,7 @@ import (
 	"github.com/gilcrest/go-api-basic/datastore"
 	"github.com/gilcrest/go-api-basic/domain/errs"
 	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/tracing"
 )
 
 // Transactor performs DML actions against the DB
@@ -29,9 +31,61 @@ type DefaultTransactor struct {
 	datastorer datastore.Datastorer
 }
 
-// Create inserts a record in the user table using a stored function
-func (dt DefaultTransactor) Create(ctx context.Context, m *movie.Movie) error {
-	tx, err := dt.datastorer.BeginTx(ctx)
+// beginTenantTx starts a transaction with the organization (tenant)
+// set for the row-level security policies on the movie table
+func beginTenantTx(ctx context.Context, ds datastore.Datastorer, orgID uuid.UUID) (*sql.Tx, error) {
+	tx, err := ds.BeginTx(ctx)
+	if err != nil {
+		return nil, dbError(ctx, err)
+	}
+
+	err = datastore.SetTenant(ctx, tx, orgID)
+	if err != nil {
+		return nil, dbError(ctx, ds.RollbackTx(tx, err))
+	}
+
+	return tx, nil
+}
+
+// createError returns err from creating a movie as a Database error,
+// with an external ID already used in the org's catalog reported as
+// an Internal error instead. The external ID is generated, not given
+// by the caller, so the caller should just try again. The database
+// error is left out, as it names the constraint and values.
+func createError(err error) error {
+	if datastore.IsUniqueViolation(err) {
+		return errs.E(errs.Internal, errs.Code("external_id_collision"), errors.New("generated external ID already exists, try again"))
+	}
+	return errs.E(errs.Database, err)
+}
+
+// dbError returns err from a statement run with ctx as a Database
+// error. If ctx is done, the statement was cut off by the request
+// deadline or the request was canceled, so err is returned as a
+// Timeout or Canceled error instead. ctx is checked rather than err,
+// as lib/pq reports a canceled statement as a Postgres error
+// (query_canceled), not as a context error.
+func dbError(ctx context.Context, err error) error {
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		return errs.E(errs.Timeout, errs.Code("deadline_exceeded"), err)
+	case context.Canceled:
+		return errs.E(errs.Canceled, errs.Code("canceled"), err)
+	}
+
+	if e, ok := err.(*errs.Error); ok {
+		return e
+	}
+	return errs.E(errs.Database, err)
+}
+
+// Create inserts a record in the movie table using a stored function.
+// The movie is created in the catalog of its organization.
+func (dt DefaultTransactor) Create(ctx context.Context, m *movie.Movie) (err error) {
+	ctx, span := datastore.StartSpan(ctx, "create_movie")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	tx, err := beginTenantTx(ctx, dt.datastorer, m.OrgID)
 	if err != nil {
 		return err
 	}
@@ -42,69 +96,73 @@ func (dt DefaultTransactor) Create(ctx context.Context, m *movie.Movie) error {
 		   o_update_timestamp
 	  from demo.create_movie (
 		p_id => $1,
-		p_extl_id => $2,
-		p_title => $3,
-		p_rated => $4,
-		p_released => $5,
-		p_run_time => $6,
-		p_director => $7,
-		p_writer => $8,
-		p_create_client_id => $9,
-		p_create_username => $10)`)
+		p_org_id => $2,
+		p_extl_id => $3,
+		p_title => $4,
+		p_rated => $5,
+		p_released => $6,
+		p_run_time => $7,
+		p_director => $8,
+		p_writer => $9,
+		p_create_client_id => $10,
+		p_create_user_id => $11,
+		p_create_username => $12)`)
 
 	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 	}
 	defer stmt.Close()
 
-	// At some point, I will add a whole client flow, but for now
-	// faking a client uuid....
-	fakeClientID := uuid.New()
-
 	// Execute stored function that returns the create_date timestamp,
 	// hence the use of QueryContext instead of Exec
 	rows, err := stmt.QueryContext(ctx,
-		m.ID,               //$1
-		m.ExternalID,       //$2
-		m.Title,            //$3
-		m.Rated,            //$4
-		m.Released,         //$5
-		m.RunTime,          //$6
-		m.Director,         //$7
-		m.Writer,           //$8
-		fakeClientID,       //$9
-		m.CreateUser.Email) //$10
+		m.ID,                                    //$1
+		m.OrgID,                                 //$2
+		m.ExternalID,                            //$3
+		m.Title,                                 //$4
+		m.Rated,                                 //$5
+		m.Released,                              //$6
+		m.RunTime,                               //$7
+		m.Director,                              //$8
+		m.Writer,                                //$9
+		datastore.NewNullUUID(m.CreateClientID), //$10
+		datastore.NewNullUUID(m.CreateUser.ID),  //$11
+		m.CreateUser.Email)                      //$12
 
 	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, createError(err)))
 	}
 	defer rows.Close()
 
 	// Iterate through the returned record(s)
 	for rows.Next() {
 		if err := rows.Scan(&m.CreateTime, &m.UpdateTime); err != nil {
-			return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+			return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 		}
 	}
 
 	// If any error was encountered while iterating through rows.Next above
 	// it will be returned here
 	if err := rows.Err(); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, createError(err)))
 	}
 
 	// Commit the Transaction
 	if err := dt.datastorer.CommitTx(tx); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 	}
 
 	return nil
 }
 
 // Update updates a record in the database using the external ID of
-// the Movie
-func (dt DefaultTransactor) Update(ctx context.Context, m *movie.Movie) error {
-	tx, err := dt.datastorer.BeginTx(ctx)
+// the Movie. Only a movie in the catalog of the Movie's organization
+// is updated.
+func (dt DefaultTransactor) Update(ctx context.Context, m *movie.Movie) (err error) {
+	ctx, span := datastore.StartSpan(ctx, "update_movie")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	tx, err := beginTenantTx(ctx, dt.datastorer, m.OrgID)
 	if err != nil {
 		return err
 	}
@@ -118,45 +176,51 @@ func (dt DefaultTransactor) Update(ctx context.Context, m *movie.Movie) error {
 		   run_time = $4,
 		   director = $5,
 		   writer = $6,
-		   update_username = $7,
-		   update_timestamp = $8
-	 where extl_id = $9
-returning movie_id, create_username, create_timestamp`)
+		   update_client_id = $7,
+		   update_user_id = $8,
+		   update_username = $9,
+		   update_timestamp = $10
+	 where org_id = $11
+	   and extl_id = $12
+returning movie_id, create_client_id, create_user_id, create_username, create_timestamp`)
 
 	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 	}
 	defer stmt.Close()
 
 	// Execute stored function that returns the create_date timestamp,
 	// hence the use of QueryContext instead of Exec
 	rows, err := stmt.QueryContext(ctx,
-		m.Title,            //$1
-		m.Rated,            //$2
-		m.Released,         //$3
-		m.RunTime,          //$4
-		m.Director,         //$5
-		m.Writer,           //$6
-		m.UpdateUser.Email, //$7
-		m.UpdateTime,       //$8
-		m.ExternalID)       //$9
+		m.Title,                                 //$1
+		m.Rated,                                 //$2
+		m.Released,                              //$3
+		m.RunTime,                               //$4
+		m.Director,                              //$5
+		m.Writer,                                //$6
+		datastore.NewNullUUID(m.UpdateClientID), //$7
+		datastore.NewNullUUID(m.UpdateUser.ID),  //$8
+		m.UpdateUser.Email,                      //$9
+		m.UpdateTime,                            //$10
+		m.OrgID,                                 //$11
+		m.ExternalID)                            //$12
 
 	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 	}
 	defer rows.Close()
 
 	// Iterate through the returned record(s)
 	for rows.Next() {
-		if err := rows.Scan(&m.ID, &m.CreateUser.Email, &m.CreateTime); err != nil {
-			return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		if err := rows.Scan(&m.ID, &m.CreateClientID, &m.CreateUser.ID, &m.CreateUser.Email, &m.CreateTime); err != nil {
+			return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 		}
 	}
 
 	// If any error was encountered while iterating through rows.Next above
 	// it will be returned here
 	if err := rows.Err(); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 	}
 
 	// If the table's primary key is not returned as part of the
@@ -173,32 +237,37 @@ returning movie_id, create_username, create_timestamp`)
 
 	// Commit the Transaction
 	if err := dt.datastorer.CommitTx(tx); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 	}
 
 	return nil
 }
 
-// Delete removes the Movie record from the table
-func (dt DefaultTransactor) Delete(ctx context.Context, m *movie.Movie) error {
-	tx, err := dt.datastorer.BeginTx(ctx)
+// Delete removes the Movie record from the table. Only a movie in the
+// catalog of the Movie's organization is deleted.
+func (dt DefaultTransactor) Delete(ctx context.Context, m *movie.Movie) (err error) {
+	ctx, span := datastore.StartSpan(ctx, "delete_movie")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	tx, err := beginTenantTx(ctx, dt.datastorer, m.OrgID)
 	if err != nil {
 		return err
 	}
 
 	result, execErr := tx.ExecContext(ctx,
 		`DELETE from demo.movie
-		        WHERE movie_id = $1`, m.ID)
+		        WHERE org_id = $1
+		          AND movie_id = $2`, m.OrgID, m.ID)
 
 	if execErr != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, execErr))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, execErr))
 	}
 
 	// Only 1 row should be deleted, check the result count to
 	// ensure this is correct
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 	}
 	if rowsAffected == 0 {
 		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, errors.New("No Rows Deleted")))
@@ -208,7 +277,7 @@ func (dt DefaultTransactor) Delete(ctx context.Context, m *movie.Movie) error {
 
 	// Commit the Transaction
 	if err := dt.datastorer.CommitTx(tx); err != nil {
-		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		return dbError(ctx, dt.datastorer.RollbackTx(tx, err))
 	}
 
 	return nil
@@ -5,14 +5,65 @@ import (
 	"os"
 	"reflect"
 	"testing"
+	"time"
 
 	"github.com/gilcrest/go-api-basic/datastore"
 	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
+	"github.com/gilcrest/go-api-basic/datastore/orgstore"
+	"github.com/gilcrest/go-api-basic/domain/errs"
 	"github.com/gilcrest/go-api-basic/domain/logger"
 	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/org"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
 	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
 )
 
+func Test_createError(t *testing.T) {
+	err := createError(&pq.Error{Code: "23505", Message: `duplicate key value violates unique constraint "movie_org_id_extl_id_uindex"`})
+	if !errs.KindIs(errs.Internal, err) {
+		t.Errorf("createError() = %v, want Kind %v", err, errs.Internal)
+	}
+	if e, ok := err.(*errs.Error); !ok || e.Code != "external_id_collision" {
+		t.Errorf("createError() = %v, want Code external_id_collision", err)
+	}
+
+	if err := createError(errors.New("some error")); !errs.KindIs(errs.Database, err) {
+		t.Errorf("createError() = %v, want Kind %v", err, errs.Database)
+	}
+}
+
+func Test_dbError(t *testing.T) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	queryErr := errors.New("pq: canceling statement due to user request")
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want errs.Kind
+	}{
+		{"query error", context.Background(), queryErr, errs.Database},
+		{"deadline exceeded", expired, queryErr, errs.Timeout},
+		{"canceled", canceled, queryErr, errs.Canceled},
+		{"keeps kind", context.Background(), errs.E(errs.Internal, queryErr), errs.Internal},
+		{"deadline exceeded overrides kind", expired, errs.E(errs.Database, queryErr), errs.Timeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := dbError(tt.ctx, tt.err)
+			if !errs.KindIs(tt.want, err) {
+				t.Errorf("dbError() = %v, want Kind %v", err, tt.want)
+			}
+		})
+	}
+}
+
 func TestNewDefaultTransactor(t *testing.T) {
 	type args struct {
 		ds datastore.Datastorer
@@ -85,6 +136,56 @@ func TestDefaultTransactor_Create(t *testing.T) {
 	}
 }
 
+func TestDefaultTransactor_Create_ExternalIDPerOrg(t *testing.T) {
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	db, _ := datastoretest.NewDB(t, lgr)
+	defaultDatastore := datastore.NewDefaultDatastore(db)
+	defaultTransactor := NewDefaultTransactor(defaultDatastore)
+	ctx := context.Background()
+
+	// the org is kept, as orgs cannot be deleted
+	o, err := org.NewOrg("Extl ID Test " + uuid.New().String())
+	if err != nil {
+		t.Fatalf("org.NewOrg() error = %v", err)
+	}
+	err = orgstore.NewDefaultTransactor(defaultDatastore).Create(ctx, o, usertest.NewUser(t))
+	if err != nil {
+		t.Fatalf("orgstore Create() error = %v", err)
+	}
+
+	m := newMovie(t)
+	if err := defaultTransactor.Create(ctx, m); err != nil {
+		t.Fatalf("DefaultTransactor.Create() error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := defaultTransactor.Delete(ctx, m); err != nil {
+			t.Fatalf("defaultTransactor.Delete error = %v", err)
+		}
+	})
+
+	// the same external ID may be used in the catalog of another org
+	other := newMovie(t)
+	other.SetExternalID(m.ExternalID)
+	other.SetOrgID(o.ID)
+	if err := defaultTransactor.Create(ctx, other); err != nil {
+		t.Fatalf("DefaultTransactor.Create() in another org error = %v", err)
+	}
+	t.Cleanup(func() {
+		if err := defaultTransactor.Delete(ctx, other); err != nil {
+			t.Fatalf("defaultTransactor.Delete error = %v", err)
+		}
+	})
+
+	// but not twice in the same org
+	dup := newMovie(t)
+	dup.SetExternalID(m.ExternalID)
+	err = defaultTransactor.Create(ctx, dup)
+	if !errs.KindIs(errs.Internal, err) {
+		t.Errorf("DefaultTransactor.Create() duplicate error = %v, want Kind %v", err, errs.Internal)
+	}
+}
+
 func TestDefaultTransactor_Update(t *testing.T) {
 	type fields struct {
 		datastorer datastore.Datastorer
@@ -0,0 +1,91 @@
+// Package orgstoretest provides testing helper functions for the
+// orgstore package
+package orgstoretest
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/org"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// OrgID is the ID of the org returned by MockSelector, which every
+// user is a member of. It is also the ID of the demo org seeded by
+// the DDL script, so it can be used in tests against the database.
+var OrgID = uuid.MustParse("7c1e4b2a-9f3d-4e8a-b6c5-1d2e3f4a5b60")
+
+// NewOrg returns the org returned by MockSelector
+func NewOrg(t *testing.T) *org.Org {
+	t.Helper()
+
+	// mock create/update timestamp
+	cuTime := time.Date(2008, 1, 8, 06, 54, 0, 0, time.UTC)
+
+	return &org.Org{
+		ID:         OrgID,
+		Name:       "Helping Hand Video",
+		CreateTime: cuTime,
+		UpdateTime: cuTime,
+	}
+}
+
+// NewMockTransactor is an initializer for MockTransactor
+func NewMockTransactor(t *testing.T) MockTransactor {
+	return MockTransactor{t: t}
+}
+
+// MockTransactor is a mock which satisfies the orgstore.Transactor
+// interface
+type MockTransactor struct {
+	t *testing.T
+}
+
+// Create mocks creating an org
+func (mt MockTransactor) Create(ctx context.Context, o *org.Org, actor user.User) error {
+	return nil
+}
+
+// AddMember mocks adding a user to an org
+func (mt MockTransactor) AddMember(ctx context.Context, o *org.Org, u user.User, actor user.User) error {
+	return nil
+}
+
+// RemoveMember mocks removing a user from an org
+func (mt MockTransactor) RemoveMember(ctx context.Context, o *org.Org, u user.User, actor user.User) error {
+	return nil
+}
+
+// NewMockSelector is an initializer for MockSelector
+func NewMockSelector(t *testing.T) MockSelector {
+	return MockSelector{t: t}
+}
+
+// MockSelector is a mock which satisfies the orgstore.Selector
+// and org.Finder interfaces
+type MockSelector struct {
+	t *testing.T
+}
+
+// FindByID mocks finding an org by ID. Only OrgID is found.
+func (ms MockSelector) FindByID(ctx context.Context, id uuid.UUID) (*org.Org, error) {
+	if id != OrgID {
+		return nil, errs.E(errs.NotExist, "No record found for given ID")
+	}
+	return NewOrg(ms.t), nil
+}
+
+// FindAll mocks finding all orgs
+func (ms MockSelector) FindAll(ctx context.Context) ([]*org.Org, error) {
+	return []*org.Org{NewOrg(ms.t)}, nil
+}
+
+// FindAllByUserID mocks finding the orgs a user is a member of.
+// Every user is a member of the org with OrgID.
+func (ms MockSelector) FindAllByUserID(ctx context.Context, userID uuid.UUID) ([]*org.Org, error) {
+	return []*org.Org{NewOrg(ms.t)}, nil
+}
@@ -0,0 +1,132 @@
+// Package orgstore performs all DML and select operations for an
+// organization and its members
+package orgstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/org"
+)
+
+// selectColumns are the org columns selected for an Org, in the
+// order they are scanned by scanOrg
+const selectColumns string = `o.org_id,
+				o.org_name,
+				o.create_timestamp,
+				o.update_timestamp`
+
+// Selector reads records from the db
+type Selector interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*org.Org, error)
+	FindAll(ctx context.Context) ([]*org.Org, error)
+	FindAllByUserID(ctx context.Context, userID uuid.UUID) ([]*org.Org, error)
+}
+
+// NewDefaultSelector is an initializer for DefaultSelector
+func NewDefaultSelector(ds datastore.Datastorer) DefaultSelector {
+	return DefaultSelector{ds}
+}
+
+// DefaultSelector is the database implementation for READ operations for an org
+type DefaultSelector struct {
+	datastore.Datastorer
+}
+
+// scanner is satisfied by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanOrg scans the selectColumns into an Org
+func scanOrg(s scanner) (*org.Org, error) {
+	o := new(org.Org)
+	err := s.Scan(
+		&o.ID,
+		&o.Name,
+		&o.CreateTime,
+		&o.UpdateTime)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// FindByID returns the Org for the given ID
+func (d DefaultSelector) FindByID(ctx context.Context, id uuid.UUID) (*org.Org, error) {
+	db := d.Datastorer.DB()
+
+	row := db.QueryRowContext(ctx,
+		`select `+selectColumns+`
+		   from demo.org o
+		  where o.org_id = $1`, id)
+
+	o, err := scanOrg(row)
+	if err == sql.ErrNoRows {
+		return nil, errs.E(errs.NotExist, "No record found for given ID")
+	} else if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return o, nil
+}
+
+// FindAll returns all orgs ordered by name
+func (d DefaultSelector) FindAll(ctx context.Context) ([]*org.Org, error) {
+	db := d.Datastorer.DB()
+
+	rows, err := db.QueryContext(ctx,
+		`select `+selectColumns+`
+		   from demo.org o
+		  order by o.org_name`)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return scanOrgs(rows)
+}
+
+// FindAllByUserID returns the orgs the user is a member of, ordered
+// by name. An empty slice is returned if the user is not a member of
+// any org.
+func (d DefaultSelector) FindAllByUserID(ctx context.Context, userID uuid.UUID) ([]*org.Org, error) {
+	db := d.Datastorer.DB()
+
+	rows, err := db.QueryContext(ctx,
+		`select `+selectColumns+`
+		   from demo.org o
+		   join demo.org_member m on m.org_id = o.org_id
+		  where m.user_id = $1
+		  order by o.org_name`, userID)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return scanOrgs(rows)
+}
+
+// scanOrgs scans each of the rows into an Org and closes the rows
+func scanOrgs(rows *sql.Rows) ([]*org.Org, error) {
+	defer rows.Close()
+
+	s := make([]*org.Org, 0)
+	for rows.Next() {
+		o, err := scanOrg(rows)
+		if err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		s = append(s, o)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	err := rows.Err()
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
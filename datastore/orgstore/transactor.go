@@ -0,0 +1,162 @@
+package orgstore
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/auditstore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/org"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+const (
+	// orgEntity is the entity name used for org audit events
+	orgEntity string = "org"
+	// memberEntity is the entity name used for org member audit events
+	memberEntity string = "org_member"
+)
+
+// Transactor performs DML actions against the DB. The actor is
+// the user making the change and is recorded in the audit trail.
+type Transactor interface {
+	Create(ctx context.Context, o *org.Org, actor user.User) error
+	AddMember(ctx context.Context, o *org.Org, u user.User, actor user.User) error
+	RemoveMember(ctx context.Context, o *org.Org, u user.User, actor user.User) error
+}
+
+// NewDefaultTransactor is an initializer for DefaultTransactor
+func NewDefaultTransactor(ds datastore.Datastorer) DefaultTransactor {
+	return DefaultTransactor{ds}
+}
+
+// DefaultTransactor is the default database implementation
+// for DML operations for an org
+type DefaultTransactor struct {
+	datastorer datastore.Datastorer
+}
+
+// memberID is the audit entity ID for an org member
+func memberID(o *org.Org, u user.User) string {
+	return o.ID.String() + "/" + u.ID.String()
+}
+
+// memberDetail is the audit detail for an org member
+func memberDetail(o *org.Org, u user.User) map[string]string {
+	return map[string]string{
+		"org":  o.Name,
+		"user": u.Email,
+	}
+}
+
+// Create inserts a record in the org table
+func (dt DefaultTransactor) Create(ctx context.Context, o *org.Org, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`insert into demo.org (org_id,
+		                       org_name,
+		                       create_username,
+		                       create_timestamp,
+		                       update_username,
+		                       update_timestamp)
+		      values ($1, $2, $3, $4, $3, $5)`,
+		o.ID,         //$1
+		o.Name,       //$2
+		actor.Email,  //$3
+		o.CreateTime, //$4
+		o.UpdateTime) //$5
+	if datastore.IsUniqueViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Exist, errs.Parameter("name"), errors.New("an org with this name already exists")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Create, orgEntity, o.ID.String(), actor, o))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// AddMember adds the user to the org
+func (dt DefaultTransactor) AddMember(ctx context.Context, o *org.Org, u user.User, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`insert into demo.org_member (org_id,
+		                              user_id,
+		                              create_username,
+		                              create_timestamp)
+		      values ($1, $2, $3, now())`,
+		o.ID,        //$1
+		u.ID,        //$2
+		actor.Email) //$3
+	if datastore.IsUniqueViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Exist, errors.New("user is already a member of the org")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Create, memberEntity, memberID(o, u), actor, memberDetail(o, u)))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// RemoveMember removes the user from the org
+func (dt DefaultTransactor) RemoveMember(ctx context.Context, o *org.Org, u user.User, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`delete from demo.org_member
+		  where org_id = $1
+		    and user_id = $2`, o.ID, u.ID)
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = datastore.OneRowAffected(result)
+	if err != nil {
+		return dt.datastorer.RollbackTx(tx, err)
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Delete, memberEntity, memberID(o, u), actor, memberDetail(o, u)))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
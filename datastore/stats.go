@@ -0,0 +1,66 @@
+package datastore
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// statsCollector is a prometheus.Collector for the connection pool
+// stats of the sql.DB of a Datastorer
+type statsCollector struct {
+	ds Datastorer
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+// NewStatsCollector returns a prometheus.Collector for the
+// connection pool stats of the Datastorer's sql.DB, which are read
+// each time the metrics are collected
+func NewStatsCollector(ds Datastorer) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("db_"+name, help, nil, nil)
+	}
+	return statsCollector{
+		ds:                ds,
+		maxOpen:           desc("max_open_connections", "Maximum number of open connections to the database."),
+		open:              desc("open_connections", "Number of established connections, both in use and idle."),
+		inUse:             desc("in_use_connections", "Number of connections currently in use."),
+		idle:              desc("idle_connections", "Number of idle connections."),
+		waitCount:         desc("wait_count_total", "Total number of connections waited for."),
+		waitDuration:      desc("wait_duration_seconds_total", "Total time blocked waiting for a new connection."),
+		maxIdleClosed:     desc("max_idle_closed_total", "Total number of connections closed due to SetMaxIdleConns."),
+		maxLifetimeClosed: desc("max_lifetime_closed_total", "Total number of connections closed due to SetConnMaxLifetime."),
+	}
+}
+
+// Describe sends the descriptors of the pool stats
+func (c statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.maxOpen
+	ch <- c.open
+	ch <- c.inUse
+	ch <- c.idle
+	ch <- c.waitCount
+	ch <- c.waitDuration
+	ch <- c.maxIdleClosed
+	ch <- c.maxLifetimeClosed
+}
+
+// Collect sends the current pool stats
+func (c statsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.ds.DB().Stats()
+
+	ch <- prometheus.MustNewConstMetric(c.maxOpen, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.open, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(s.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(s.MaxLifetimeClosed))
+}
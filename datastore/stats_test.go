@@ -0,0 +1,32 @@
+package datastore
+
+import (
+	"database/sql"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNewStatsCollector(t *testing.T) {
+	c := qt.New(t)
+
+	// sql.Open does not connect, so no database is needed
+	db, err := sql.Open("postgres", "host=localhost dbname=none")
+	c.Assert(err, qt.IsNil)
+	defer db.Close()
+	db.SetMaxOpenConns(7)
+
+	want := `
+# HELP db_max_open_connections Maximum number of open connections to the database.
+# TYPE db_max_open_connections gauge
+db_max_open_connections 7
+# HELP db_open_connections Number of established connections, both in use and idle.
+# TYPE db_open_connections gauge
+db_open_connections 0
+`
+	err = testutil.CollectAndCompare(NewStatsCollector(NewDefaultDatastore(db)), strings.NewReader(want),
+		"db_max_open_connections", "db_open_connections")
+	c.Assert(err, qt.IsNil)
+}
@@ -0,0 +1,28 @@
+package datastore
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// statementNameKey is the span attribute holding the name of the SQL
+// statement
+const statementNameKey attribute.Key = "db.statement.name"
+
+// tracer is the tracer for the spans of SQL statements
+var tracer = otel.Tracer("github.com/gilcrest/go-api-basic/datastore")
+
+// StartSpan starts a span for the named SQL statement. The span
+// must be ended, e.g. with tracing.EndSpan to record any error.
+func StartSpan(ctx context.Context, statement string) (context.Context, trace.Span) {
+	return tracer.Start(ctx, statement,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.DBSystemPostgreSQL,
+			statementNameKey.String(statement),
+		))
+}
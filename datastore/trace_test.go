@@ -0,0 +1,32 @@
+package datastore
+
+import (
+	"context"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestStartSpan(t *testing.T) {
+	c := qt.New(t)
+
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+
+	_, span := StartSpan(context.Background(), "select_movies")
+	span.End()
+
+	spans := sr.Ended()
+	c.Assert(len(spans), qt.Equals, 1)
+	c.Assert(spans[0].Name(), qt.Equals, "select_movies")
+	c.Assert(spans[0].SpanKind(), qt.Equals, trace.SpanKindClient)
+	got := attribute.NewSet(spans[0].Attributes()...)
+	want := attribute.NewSet(semconv.DBSystemPostgreSQL, statementNameKey.String("select_movies"))
+	c.Assert(got.Equals(&want), qt.IsTrue)
+}
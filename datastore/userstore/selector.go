@@ -0,0 +1,132 @@
+// Package userstore performs all DML and select operations for an
+// application user
+package userstore
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// selectColumns are the app_user columns selected for a User,
+// in the order they are scanned by scanUser
+const selectColumns string = `user_id,
+				email,
+				coalesce(first_name, ''),
+				coalesce(last_name, ''),
+				coalesce(full_name, ''),
+				coalesce(hosted_domain, ''),
+				coalesce(picture_url, ''),
+				coalesce(profile_link, '')`
+
+// Selector reads records from the db
+type Selector interface {
+	FindByID(ctx context.Context, id uuid.UUID) (user.User, error)
+	FindByEmail(ctx context.Context, email string) (user.User, error)
+	FindAll(ctx context.Context) ([]user.User, error)
+}
+
+// NewDefaultSelector is an initializer for DefaultSelector
+func NewDefaultSelector(ds datastore.Datastorer) DefaultSelector {
+	return DefaultSelector{ds}
+}
+
+// DefaultSelector is the database implementation for READ operations for a user
+type DefaultSelector struct {
+	datastore.Datastorer
+}
+
+// scanner is satisfied by both sql.Row and sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanUser scans the selectColumns into a User
+func scanUser(s scanner) (user.User, error) {
+	var u user.User
+	err := s.Scan(
+		&u.ID,
+		&u.Email,
+		&u.FirstName,
+		&u.LastName,
+		&u.FullName,
+		&u.HostedDomain,
+		&u.PictureURL,
+		&u.ProfileLink)
+	return u, err
+}
+
+// FindByID returns the User for the given ID
+func (d DefaultSelector) FindByID(ctx context.Context, id uuid.UUID) (user.User, error) {
+	db := d.Datastorer.DB()
+
+	row := db.QueryRowContext(ctx,
+		`select `+selectColumns+`
+		   from demo.app_user
+		  where user_id = $1`, id)
+
+	u, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return user.User{}, errs.E(errs.NotExist, "No record found for given ID")
+	} else if err != nil {
+		return user.User{}, errs.E(errs.Database, err)
+	}
+
+	return u, nil
+}
+
+// FindByEmail returns the User for the given email. Emails are
+// matched without regard to case.
+func (d DefaultSelector) FindByEmail(ctx context.Context, email string) (user.User, error) {
+	db := d.Datastorer.DB()
+
+	row := db.QueryRowContext(ctx,
+		`select `+selectColumns+`
+		   from demo.app_user
+		  where lower(email) = lower($1)`, email)
+
+	u, err := scanUser(row)
+	if err == sql.ErrNoRows {
+		return user.User{}, errs.E(errs.NotExist, "No record found for given email")
+	} else if err != nil {
+		return user.User{}, errs.E(errs.Database, err)
+	}
+
+	return u, nil
+}
+
+// FindAll returns all users ordered by email
+func (d DefaultSelector) FindAll(ctx context.Context) ([]user.User, error) {
+	db := d.Datastorer.DB()
+
+	rows, err := db.QueryContext(ctx,
+		`select `+selectColumns+`
+		   from demo.app_user
+		  order by email`)
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+	defer rows.Close()
+
+	s := make([]user.User, 0)
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, errs.E(errs.Database, err)
+		}
+		s = append(s, u)
+	}
+
+	// Rows.Err will report the last error encountered by Rows.Scan.
+	err = rows.Err()
+	if err != nil {
+		return nil, errs.E(errs.Database, err)
+	}
+
+	return s, nil
+}
@@ -0,0 +1,257 @@
+package userstore
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/auditstore"
+	"github.com/gilcrest/go-api-basic/domain/audit"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// auditEntity is the entity name used for user audit events
+const auditEntity string = "user"
+
+// Transactor performs DML actions against the DB. The actor is
+// the user making the change and is recorded in the audit trail.
+type Transactor interface {
+	Upsert(ctx context.Context, u user.User) (user.User, error)
+	Create(ctx context.Context, u user.User, actor user.User) error
+	Update(ctx context.Context, u user.User, actor user.User) error
+	Delete(ctx context.Context, u user.User, actor user.User) error
+}
+
+// NewDefaultTransactor is an initializer for DefaultTransactor
+func NewDefaultTransactor(ds datastore.Datastorer) DefaultTransactor {
+	return DefaultTransactor{ds}
+}
+
+// DefaultTransactor is the default database implementation
+// for DML operations for a user
+type DefaultTransactor struct {
+	datastorer datastore.Datastorer
+}
+
+// Upsert inserts a record in the app_user table for the user if one
+// does not already exist for the user's email and returns the stored
+// User. An existing record is left as is, so profile changes made
+// by the user are not overwritten. The user is the actor for the
+// audit event when a record is created.
+func (dt DefaultTransactor) Upsert(ctx context.Context, u user.User) (user.User, error) {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	if u.ID == uuid.Nil {
+		u.ID = uuid.New()
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`insert into demo.app_user (user_id,
+		                            email,
+		                            first_name,
+		                            last_name,
+		                            full_name,
+		                            hosted_domain,
+		                            picture_url,
+		                            profile_link,
+		                            create_username,
+		                            create_timestamp,
+		                            update_username,
+		                            update_timestamp)
+		      values ($1, $2, $3, $4, $5, $6, $7, $8, $2, now(), $2, now())
+		 on conflict (lower(email)) do nothing`,
+		u.ID,           //$1
+		u.Email,        //$2
+		u.FirstName,    //$3
+		u.LastName,     //$4
+		u.FullName,     //$5
+		u.HostedDomain, //$6
+		u.PictureURL,   //$7
+		u.ProfileLink)  //$8
+	if err != nil {
+		return user.User{}, errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return user.User{}, errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// only audit the user being created, not found
+	if rowsAffected == 1 {
+		err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Create, auditEntity, u.ID.String(), u, u))
+		if err != nil {
+			return user.User{}, errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+		}
+	}
+
+	row := tx.QueryRowContext(ctx,
+		`select `+selectColumns+`
+		   from demo.app_user
+		  where lower(email) = lower($1)`, u.Email)
+
+	stored, err := scanUser(row)
+	if err != nil {
+		return user.User{}, errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return user.User{}, errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return stored, nil
+}
+
+// Create inserts a record in the app_user table
+func (dt DefaultTransactor) Create(ctx context.Context, u user.User, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`insert into demo.app_user (user_id,
+		                            email,
+		                            first_name,
+		                            last_name,
+		                            full_name,
+		                            create_username,
+		                            create_timestamp,
+		                            update_username,
+		                            update_timestamp)
+		      values ($1, $2, $3, $4, $5, $6, now(), $6, now())`,
+		u.ID,        //$1
+		u.Email,     //$2
+		u.FirstName, //$3
+		u.LastName,  //$4
+		u.FullName,  //$5
+		actor.Email) //$6
+	if datastore.IsUniqueViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Exist, errs.Parameter("email"), errors.New("a user with this email already exists")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Create, auditEntity, u.ID.String(), actor, u))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// Update updates the app_user record for the user's ID
+func (dt DefaultTransactor) Update(ctx context.Context, u user.User, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`update demo.app_user
+		    set email = $1,
+		        first_name = $2,
+		        last_name = $3,
+		        full_name = $4,
+		        update_username = $5,
+		        update_timestamp = now()
+		  where user_id = $6`,
+		u.Email,     //$1
+		u.FirstName, //$2
+		u.LastName,  //$3
+		u.FullName,  //$4
+		actor.Email, //$5
+		u.ID)        //$6
+	if datastore.IsUniqueViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errs.E(errs.Exist, errs.Parameter("email"), errors.New("a user with this email already exists")))
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = datastore.OneRowAffected(result)
+	if err != nil {
+		return dt.datastorer.RollbackTx(tx, err)
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Update, auditEntity, u.ID.String(), actor, u))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// Delete removes the app_user record. Role assignments for the
+// user are removed by the database as well. A user who owns clients
+// cannot be deleted until the clients are given another owner.
+func (dt DefaultTransactor) Delete(ctx context.Context, u user.User, actor user.User) error {
+	tx, err := dt.datastorer.BeginTx(ctx)
+	if err != nil {
+		return err
+	}
+
+	var ownsClients bool
+	err = tx.QueryRowContext(ctx,
+		`select exists (select 1
+		                  from demo.app_client
+		                 where owner_user_id = $1)`, u.ID).Scan(&ownsClients)
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+	if ownsClients {
+		return dt.datastorer.RollbackTx(tx, errOwnsClients())
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`delete from demo.app_user
+		  where user_id = $1`, u.ID)
+	// a client may have been given to the user since the check above
+	if datastore.IsForeignKeyViolation(err) {
+		return dt.datastorer.RollbackTx(tx, errOwnsClients())
+	}
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	err = datastore.OneRowAffected(result)
+	if err != nil {
+		return dt.datastorer.RollbackTx(tx, err)
+	}
+
+	err = auditstore.Create(ctx, tx, audit.NewEvent(audit.Delete, auditEntity, u.ID.String(), actor, u))
+	if err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	// Commit the Transaction
+	if err := dt.datastorer.CommitTx(tx); err != nil {
+		return errs.E(errs.Database, dt.datastorer.RollbackTx(tx, err))
+	}
+
+	return nil
+}
+
+// errOwnsClients returns the error for deleting a user who still
+// owns clients
+func errOwnsClients() error {
+	return errs.E(errs.Validation, errs.Code("user_owns_clients"), errors.New("user owns clients, give the clients another owner before deleting the user"))
+}
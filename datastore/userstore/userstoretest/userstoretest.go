@@ -0,0 +1,81 @@
+// Package userstoretest provides testing helper functions for the
+// userstore package
+package userstoretest
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// UserID is the ID of the user returned by MockSelector
+var UserID = uuid.MustParse("a4d08ba5-d8a7-4b83-8ad8-2bbc72d5b3d4")
+
+// NewMockTransactor is an initializer for MockTransactor
+func NewMockTransactor(t *testing.T) MockTransactor {
+	return MockTransactor{t: t}
+}
+
+// MockTransactor is a mock which satisfies the userstore.Transactor
+// interface
+type MockTransactor struct {
+	t *testing.T
+}
+
+// Upsert mocks storing a user, returning the user with UserID
+// as its ID
+func (mt MockTransactor) Upsert(ctx context.Context, u user.User) (user.User, error) {
+	u.ID = UserID
+	return u, nil
+}
+
+// Create mocks creating a user
+func (mt MockTransactor) Create(ctx context.Context, u user.User, actor user.User) error {
+	return nil
+}
+
+// Update mocks updating a user
+func (mt MockTransactor) Update(ctx context.Context, u user.User, actor user.User) error {
+	return nil
+}
+
+// Delete mocks deleting a user
+func (mt MockTransactor) Delete(ctx context.Context, u user.User, actor user.User) error {
+	return nil
+}
+
+// NewMockSelector is an initializer for MockSelector
+func NewMockSelector(t *testing.T) MockSelector {
+	return MockSelector{t: t}
+}
+
+// MockSelector is a mock which satisfies the userstore.Selector
+// interface
+type MockSelector struct {
+	t *testing.T
+}
+
+// FindByID mocks finding a user by ID
+func (ms MockSelector) FindByID(ctx context.Context, id uuid.UUID) (user.User, error) {
+	u := usertest.NewUser(ms.t)
+	u.ID = UserID
+	return u, nil
+}
+
+// FindByEmail mocks finding a user by email
+func (ms MockSelector) FindByEmail(ctx context.Context, email string) (user.User, error) {
+	u := usertest.NewUser(ms.t)
+	u.ID = UserID
+	return u, nil
+}
+
+// FindAll mocks finding all users
+func (ms MockSelector) FindAll(ctx context.Context) ([]user.User, error) {
+	u := usertest.NewUser(ms.t)
+	u.ID = UserID
+	return []user.User{u}, nil
+}
@@ -0,0 +1,54 @@
+// Package audit holds details about changes made to application data
+// so that there is a trail of who changed what and when
+package audit
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// Action is the type of change made
+type Action string
+
+// Audited actions
+const (
+	Create Action = "create"
+	Update Action = "update"
+	Delete Action = "delete"
+	Revoke Action = "revoke"
+)
+
+// Event records a single change made by a user to an entity
+type Event struct {
+	// ID is the unique ID of the event
+	ID uuid.UUID
+	// Action is the type of change made
+	Action Action
+	// Entity is the type of thing that was changed, e.g. "role"
+	Entity string
+	// EntityID is the identifier of the thing that was changed
+	EntityID string
+	// Actor is the user who made the change
+	Actor user.User
+	// Time is when the change was made
+	Time time.Time
+	// Detail holds the state of the entity after the change (or
+	// before it, for deletes). It is stored as JSON.
+	Detail interface{}
+}
+
+// NewEvent is an initializer for Event
+func NewEvent(action Action, entity string, entityID string, actor user.User, detail interface{}) Event {
+	return Event{
+		ID:       uuid.New(),
+		Action:   action,
+		Entity:   entity,
+		EntityID: entityID,
+		Actor:    actor,
+		Time:     time.Now().UTC(),
+		Detail:   detail,
+	}
+}
@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// APIKeyTokenType is the token type set to the AccessToken for
+// keys sent in the X-API-Key header
+const APIKeyTokenType string = "APIKey"
+
+// ServiceAccountDomain is the email domain given to the service
+// account users API keys are issued for
+const ServiceAccountDomain string = "service-account.local"
+
+const (
+	// apiKeyPrefixBytes is the number of random bytes in the key
+	// prefix, which is stored in plain text and used for lookup
+	apiKeyPrefixBytes int = 6
+	// apiKeySecretBytes is the number of random bytes in the key
+	// secret, which is only ever stored hashed
+	apiKeySecretBytes int = 24
+	// apiKeySeparator separates the prefix and secret of a key.
+	// It is not part of the URL-safe base64 alphabet.
+	apiKeySeparator string = "."
+)
+
+// APIKey is a key issued to a service account for service-to-service
+// calls which cannot use an interactive OAuth2 flow. The full key is
+// only known when it is issued; afterwards only the prefix and a hash
+// of the key are kept.
+type APIKey struct {
+	ID     uuid.UUID
+	Name   string
+	Prefix string
+	Hash   []byte
+	// User is the service account user the key authenticates as
+	User user.User
+	// Scopes are the scopes granted to requests made with the key
+	Scopes     Scopes
+	CreateTime time.Time
+	// RevokeTime is the zero time unless the key has been revoked
+	RevokeTime time.Time
+}
+
+// NewServiceAccount returns the service account user with the
+// given name
+func NewServiceAccount(name string) user.User {
+	return user.User{
+		Email:     name + "@" + ServiceAccountDomain,
+		FirstName: name,
+		LastName:  "Service Account",
+		FullName:  name + " Service Account",
+	}
+}
+
+// NewAPIKey generates a new APIKey for the service account with
+// the given scopes and returns it along with the full key, which
+// must be given to the client as it cannot be recovered later
+func NewAPIKey(g random.StringGenerator, name string, sa user.User, scopes Scopes) (*APIKey, string, error) {
+	switch {
+	case name == "":
+		return nil, "", errs.E(errs.Validation, errs.Parameter("name"), errs.MissingField("name"))
+	case len(scopes) == 0:
+		return nil, "", errs.E(errs.Validation, errs.Parameter("scopes"), errs.MissingField("scopes"))
+	}
+
+	prefix, err := g.CryptoString(apiKeyPrefixBytes)
+	if err != nil {
+		return nil, "", err
+	}
+	secret, err := g.CryptoString(apiKeySecretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := prefix + apiKeySeparator + secret
+
+	k := &APIKey{
+		ID:         uuid.New(),
+		Name:       name,
+		Prefix:     prefix,
+		Hash:       HashAPIKey(key),
+		User:       sa,
+		Scopes:     scopes,
+		CreateTime: time.Now().UTC(),
+	}
+
+	return k, key, nil
+}
+
+// HashAPIKey returns the SHA-256 hash of the full key
+func HashAPIKey(key string) []byte {
+	h := sha256.Sum256([]byte(key))
+	return h[:]
+}
+
+// APIKeyPrefix returns the prefix of the full key
+func APIKeyPrefix(key string) (string, error) {
+	i := strings.Index(key, apiKeySeparator)
+	if i < 1 || i == len(key)-1 {
+		return "", errs.E(errs.Unauthenticated, errs.InvalidToken("The API key is malformed"), errors.New("malformed API key"))
+	}
+	return key[:i], nil
+}
+
+// Matches reports whether the full key matches the stored hash
+func (k *APIKey) Matches(key string) bool {
+	return subtle.ConstantTimeCompare(HashAPIKey(key), k.Hash) == 1
+}
+
+// IsRevoked reports whether the key has been revoked
+func (k *APIKey) IsRevoked() bool {
+	return !k.RevokeTime.IsZero()
+}
+
+// APIKeyFinder finds an APIKey given its prefix
+type APIKeyFinder interface {
+	FindAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error)
+}
+
+// APIKeyConverter satisfies the AccessTokenConverter interface and
+// converts an API key to the service account User it was issued for
+type APIKeyConverter struct {
+	APIKeyFinder APIKeyFinder
+}
+
+// Convert finds the API key using its prefix, verifies the full key
+// against the stored hash and returns the key's service account and
+// scopes
+func (c APIKeyConverter) Convert(ctx context.Context, token AccessToken) (user.User, Scopes, error) {
+	prefix, err := APIKeyPrefix(token.Token)
+	if err != nil {
+		return user.User{}, nil, err
+	}
+
+	k, err := c.APIKeyFinder.FindAPIKeyByPrefix(ctx, prefix)
+	if errs.KindIs(errs.NotExist, err) {
+		return user.User{}, nil, errs.E(errs.Unauthenticated, errs.InvalidToken("The API key is invalid"), errors.New("API key not found"))
+	}
+	if err != nil {
+		return user.User{}, nil, err
+	}
+
+	switch {
+	case !k.Matches(token.Token):
+		return user.User{}, nil, errs.E(errs.Unauthenticated, errs.InvalidToken("The API key is invalid"), errors.New("invalid API key"))
+	case k.IsRevoked():
+		return user.User{}, nil, errs.E(errs.Unauthenticated, errs.InvalidToken("The API key has been revoked"), errors.New("API key has been revoked"))
+	}
+
+	return k.User, k.Scopes, nil
+}
+
+// TokenTypeConverter satisfies the AccessTokenConverter interface and
+// dispatches to the AccessTokenConverter registered for the token type
+type TokenTypeConverter map[string]AccessTokenConverter
+
+// Convert converts the token using the converter for its token type
+func (c TokenTypeConverter) Convert(ctx context.Context, token AccessToken) (user.User, Scopes, error) {
+	converter, ok := c[token.TokenType]
+	if !ok {
+		return user.User{}, nil, errs.E(errs.Unauthenticated,
+			errs.Challenge{Error: errs.InvalidRequestChallenge, Description: "Unsupported token type"},
+			errors.New("unsupported token type: "+token.TokenType))
+	}
+	return converter.Convert(ctx, token)
+}
@@ -0,0 +1,138 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// mockAPIKeyFinder finds the given key by its prefix
+type mockAPIKeyFinder struct {
+	k *APIKey
+}
+
+func (m mockAPIKeyFinder) FindAPIKeyByPrefix(ctx context.Context, prefix string) (*APIKey, error) {
+	if m.k == nil || prefix != m.k.Prefix {
+		return nil, errs.E(errs.NotExist, "No record found for given prefix")
+	}
+	return m.k, nil
+}
+
+// mockConverter returns a user with the given email
+type mockConverter string
+
+func (m mockConverter) Convert(ctx context.Context, token AccessToken) (user.User, Scopes, error) {
+	return user.User{Email: string(m)}, Scopes{MoviesReadScope}, nil
+}
+
+func TestNewAPIKey(t *testing.T) {
+	c := qt.New(t)
+
+	sa := NewServiceAccount("nightly-batch")
+	c.Assert(sa.Email, qt.Equals, "nightly-batch@"+ServiceAccountDomain)
+
+	k, key, err := NewAPIKey(random.DefaultStringGenerator{}, "nightly", sa, Scopes{MoviesReadScope})
+	c.Assert(err, qt.IsNil)
+
+	prefix, err := APIKeyPrefix(key)
+	c.Assert(err, qt.IsNil)
+	c.Assert(prefix, qt.Equals, k.Prefix)
+	c.Assert(k.Matches(key), qt.IsTrue)
+	c.Assert(k.Matches(key+"x"), qt.IsFalse)
+	c.Assert(k.IsRevoked(), qt.IsFalse)
+
+	_, _, err = NewAPIKey(random.DefaultStringGenerator{}, "", sa, Scopes{MoviesReadScope})
+	c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+
+	// keys must be granted at least one scope
+	_, _, err = NewAPIKey(random.DefaultStringGenerator{}, "nightly", sa, nil)
+	c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+}
+
+func TestAPIKeyPrefix(t *testing.T) {
+	tests := []struct {
+		key     string
+		want    string
+		wantErr bool
+	}{
+		{"abc.def", "abc", false},
+		{"abcdef", "", true},
+		{".def", "", true},
+		{"abc.", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			c := qt.New(t)
+			got, err := APIKeyPrefix(tt.key)
+			c.Assert(err != nil, qt.Equals, tt.wantErr)
+			c.Assert(got, qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestAPIKeyConverter_Convert(t *testing.T) {
+	sa := NewServiceAccount("nightly-batch")
+	k, key, err := NewAPIKey(random.DefaultStringGenerator{}, "nightly", sa, Scopes{MoviesReadScope})
+	if err != nil {
+		t.Fatalf("NewAPIKey() error = %v", err)
+	}
+	revoked := *k
+	revoked.RevokeTime = time.Now()
+
+	tests := []struct {
+		name    string
+		k       *APIKey
+		key     string
+		wantErr bool
+	}{
+		{"typical", k, key, false},
+		{"wrong secret", k, k.Prefix + ".wrong", true},
+		{"unknown prefix", k, "unknown.secret", true},
+		{"malformed", k, "nodot", true},
+		{"revoked", &revoked, key, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			conv := APIKeyConverter{APIKeyFinder: mockAPIKeyFinder{k: tt.k}}
+			got, scopes, err := conv.Convert(context.Background(), AccessToken{Token: tt.key, TokenType: APIKeyTokenType})
+			c.Assert(err != nil, qt.Equals, tt.wantErr)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+				// the client is told the key is invalid, not
+				// that it is missing
+				c.Assert(err.(*errs.Error).Challenge.Error, qt.Equals, errs.InvalidTokenChallenge)
+				return
+			}
+			c.Assert(got, qt.Equals, sa)
+			c.Assert(scopes, qt.DeepEquals, Scopes{MoviesReadScope})
+		})
+	}
+}
+
+func TestTokenTypeConverter_Convert(t *testing.T) {
+	c := qt.New(t)
+
+	conv := TokenTypeConverter{
+		BearerTokenType: mockConverter(usertest.NewUser(t).Email),
+		APIKeyTokenType: mockConverter("nightly-batch@" + ServiceAccountDomain),
+	}
+
+	u, _, err := conv.Convert(context.Background(), AccessToken{Token: "abc", TokenType: BearerTokenType})
+	c.Assert(err, qt.IsNil)
+	c.Assert(u.Email, qt.Equals, usertest.NewUser(t).Email)
+
+	u, _, err = conv.Convert(context.Background(), AccessToken{Token: "abc.def", TokenType: APIKeyTokenType})
+	c.Assert(err, qt.IsNil)
+	c.Assert(u.Email, qt.Equals, "nightly-batch@"+ServiceAccountDomain)
+
+	_, _, err = conv.Convert(context.Background(), AccessToken{Token: "abc", TokenType: "Basic"})
+	c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+}
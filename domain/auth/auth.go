@@ -30,9 +30,9 @@ func (at AccessToken) NewGoogleOauth2Token() *oauth2.Token {
 }
 
 // AccessTokenConverter interface is used to convert an access token
-// to a User
+// to a User and the Scopes granted to the token
 type AccessTokenConverter interface {
-	Convert(ctx context.Context, token AccessToken) (user.User, error)
+	Convert(ctx context.Context, token AccessToken) (user.User, Scopes, error)
 }
 
 // Authorizer interface authorizes access to a resource given
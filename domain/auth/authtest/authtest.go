@@ -40,8 +40,8 @@ type MockAccessTokenConverter struct {
 	t *testing.T
 }
 
-func (m MockAccessTokenConverter) Convert(ctx context.Context, token auth.AccessToken) (user.User, error) {
+func (m MockAccessTokenConverter) Convert(ctx context.Context, token auth.AccessToken) (user.User, auth.Scopes, error) {
 	m.t.Helper()
 
-	return usertest.NewUser(m.t), nil
+	return usertest.NewUser(m.t), auth.AllScopes, nil
 }
@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// CertificateTokenType is the token type set to the AccessToken for
+// requests authenticated with a verified TLS client certificate. The
+// token is the base64 encoded DER of the certificate.
+const CertificateTokenType string = "Certificate"
+
+// CertificateToken returns the token for a verified client certificate
+func CertificateToken(cert *x509.Certificate) string {
+	return base64.StdEncoding.EncodeToString(cert.Raw)
+}
+
+// CertificateConverter interface is used to convert a verified TLS
+// client certificate to a User and the Scopes granted to it
+type CertificateConverter interface {
+	ConvertCertificate(ctx context.Context, cert *x509.Certificate) (user.User, Scopes, error)
+}
+
+// CertificateTokenConverter satisfies the AccessTokenConverter
+// interface and converts the token set for a request authenticated
+// with a client certificate using the CertificateConverter
+type CertificateTokenConverter struct {
+	CertificateConverter CertificateConverter
+}
+
+// Convert parses the certificate from the token and converts it
+func (c CertificateTokenConverter) Convert(ctx context.Context, token AccessToken) (user.User, Scopes, error) {
+	der, err := base64.StdEncoding.DecodeString(token.Token)
+	if err != nil {
+		return user.User{}, nil, errs.E(errs.Unauthenticated, errors.Wrap(err, "invalid certificate token"))
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return user.User{}, nil, errs.E(errs.Unauthenticated, errors.Wrap(err, "invalid certificate token"))
+	}
+
+	return c.CertificateConverter.ConvertCertificate(ctx, cert)
+}
+
+// SubjectCertificateConverter satisfies the CertificateConverter
+// interface and maps a client certificate to a user by its subject.
+// The first email address SAN is used as the user's email. If the
+// certificate has no email address, its subject common name is used
+// as the name of a service account, as for API keys. The certificate
+// is granted all scopes, as it was issued by a CA trusted by the
+// server; authorization still decides what the user may do.
+type SubjectCertificateConverter struct{}
+
+// ConvertCertificate converts the certificate to a User
+func (SubjectCertificateConverter) ConvertCertificate(ctx context.Context, cert *x509.Certificate) (user.User, Scopes, error) {
+	if len(cert.EmailAddresses) > 0 {
+		return user.User{Email: cert.EmailAddresses[0], FullName: cert.Subject.CommonName}, AllScopes, nil
+	}
+
+	if cert.Subject.CommonName != "" {
+		return NewServiceAccount(cert.Subject.CommonName), AllScopes, nil
+	}
+
+	return user.User{}, nil, errs.E(errs.Unauthenticated, errors.New("client certificate has no email address or common name"))
+}
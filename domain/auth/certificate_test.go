@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// newTestCertificate returns a self-signed certificate with the given
+// common name and email addresses
+func newTestCertificate(t *testing.T, cn string, emails ...string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(1),
+		Subject:        pkix.Name{CommonName: cn},
+		EmailAddresses: emails,
+		NotBefore:      time.Now().Add(-time.Hour),
+		NotAfter:       time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestCertificateTokenConverter_Convert(t *testing.T) {
+	cv := CertificateTokenConverter{CertificateConverter: SubjectCertificateConverter{}}
+
+	tests := []struct {
+		name      string
+		token     string
+		wantEmail string
+		wantKind  errs.Kind
+	}{
+		{"email SAN", CertificateToken(newTestCertificate(t, "Otto Maddox", "otto.maddox711@gmail.com")), "otto.maddox711@gmail.com", errs.Other},
+		{"common name", CertificateToken(newTestCertificate(t, "nightly-batch")), "nightly-batch@" + ServiceAccountDomain, errs.Other},
+		{"no subject", CertificateToken(newTestCertificate(t, "")), "", errs.Unauthenticated},
+		{"not base64", "not-base64!", "", errs.Unauthenticated},
+		{"not a certificate", "bm90LWEtY2VydA==", "", errs.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			u, scopes, err := cv.Convert(context.Background(), AccessToken{Token: tt.token, TokenType: CertificateTokenType})
+			if tt.wantKind != errs.Other {
+				c.Assert(errs.KindIs(tt.wantKind, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(u.Email, qt.Equals, tt.wantEmail)
+			c.Assert(scopes, qt.DeepEquals, AllScopes)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestDevTokenIssuer(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	issuer := DevTokenIssuer{Key: []byte("dev-key"), Now: func() time.Time { return now }}
+
+	token, err := issuer.Issue("otto.maddox711@gmail.com", Scopes{MoviesReadScope}, 0)
+	qt.Assert(t, err, qt.IsNil)
+
+	tests := []struct {
+		name     string
+		issuer   DevTokenIssuer
+		token    string
+		wantErr  bool
+		wantUser string
+	}{
+		{"valid", issuer, token, false, "otto.maddox711@gmail.com"},
+		{"expired", DevTokenIssuer{Key: issuer.Key, Now: func() time.Time { return now.Add(DefaultDevTokenTTL) }}, token, true, ""},
+		{"other key", DevTokenIssuer{Key: []byte("other-key"), Now: issuer.Now}, token, true, ""},
+		{"no key", DevTokenIssuer{Now: issuer.Now}, token, true, ""},
+		{"tampered", issuer, strings.Replace(token, "dev.", "dev.x", 1), true, ""},
+		{"not a dev token", issuer, "ya29.a0AfH6SMB", true, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			u, scopes, err := tt.issuer.Convert(context.Background(), AccessToken{Token: tt.token, TokenType: BearerTokenType})
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(u.Email, qt.Equals, tt.wantUser)
+			c.Assert(scopes, qt.DeepEquals, Scopes{MoviesReadScope})
+		})
+	}
+}
+
+func TestDevTokenIssuer_Issue(t *testing.T) {
+	c := qt.New(t)
+
+	issuer := DevTokenIssuer{Key: []byte("dev-key")}
+
+	_, err := issuer.Issue("", AllScopes, 0)
+	c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+
+	_, err = issuer.Issue("otto.maddox711@gmail.com", AllScopes, MaxDevTokenTTL+time.Second)
+	c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+
+	_, err = DevTokenIssuer{}.Issue("otto.maddox711@gmail.com", AllScopes, 0)
+	c.Assert(errs.KindIs(errs.Internal, err), qt.IsTrue)
+}
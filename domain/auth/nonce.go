@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// nonceSweepInterval is how often a MemoryNonceCache drops its
+// expired nonces
+const nonceSweepInterval time.Duration = time.Minute
+
+// NonceCache remembers the nonces of signed requests, so a signed
+// request cannot be replayed within the replay window
+type NonceCache interface {
+	// Add adds the nonce, which is kept until expires. Add reports
+	// false if the nonce was already added and has not expired.
+	Add(ctx context.Context, nonce string, expires time.Time) (bool, error)
+}
+
+// MemoryNonceCache is a NonceCache keeping the nonces in memory. Each
+// instance of the server remembers the nonces it has seen on its
+// own, so a request can be replayed against another instance unless
+// a NonceCache backed by a shared cache is used instead. The zero
+// value is ready to use.
+type MemoryNonceCache struct {
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	nonces    map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryNonceCache returns an empty MemoryNonceCache
+func NewMemoryNonceCache() *MemoryNonceCache {
+	return &MemoryNonceCache{nonces: make(map[string]time.Time)}
+}
+
+// now returns the current time
+func (c *MemoryNonceCache) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
+
+// Add adds the nonce unless it was already added and has not expired
+func (c *MemoryNonceCache) Add(ctx context.Context, nonce string, expires time.Time) (bool, error) {
+	now := c.now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.nonces == nil {
+		c.nonces = make(map[string]time.Time)
+	}
+	c.sweep(now)
+
+	if exp, ok := c.nonces[nonce]; ok && now.Before(exp) {
+		return false, nil
+	}
+	c.nonces[nonce] = expires
+
+	return true, nil
+}
+
+// sweep drops the expired nonces. It runs at most once every
+// nonceSweepInterval.
+func (c *MemoryNonceCache) sweep(now time.Time) {
+	if now.Sub(c.lastSweep) < nonceSweepInterval {
+		return
+	}
+	c.lastSweep = now
+
+	for nonce, exp := range c.nonces {
+		if !now.Before(exp) {
+			delete(c.nonces, nonce)
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+)
+
+func TestMemoryNonceCache_Add(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	cache := NewMemoryNonceCache()
+	cache.Now = func() time.Time { return now }
+
+	added, err := cache.Add(ctx, "a", now.Add(5*time.Minute))
+	c.Assert(err, qt.IsNil)
+	c.Assert(added, qt.IsTrue)
+
+	// a nonce is only added once until it expires
+	added, _ = cache.Add(ctx, "a", now.Add(5*time.Minute))
+	c.Assert(added, qt.IsFalse)
+	added, _ = cache.Add(ctx, "b", now.Add(5*time.Minute))
+	c.Assert(added, qt.IsTrue)
+
+	now = now.Add(5 * time.Minute)
+	added, _ = cache.Add(ctx, "a", now.Add(5*time.Minute))
+	c.Assert(added, qt.IsTrue)
+
+	// expired nonces are swept
+	now = now.Add(10 * time.Minute)
+	_, _ = cache.Add(ctx, "c", now.Add(5*time.Minute))
+	c.Assert(len(cache.nonces), qt.Equals, 1)
+}
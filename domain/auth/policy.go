@@ -0,0 +1,383 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// Effect is the outcome of a policy Rule when the Rule matches
+// a request
+type Effect string
+
+const (
+	// Allow grants access to the resource
+	Allow Effect = "allow"
+	// Deny refuses access to the resource. A matching Deny rule
+	// always overrides any matching Allow rule.
+	Deny Effect = "deny"
+)
+
+// wildcard matches any subject, method or single path segment
+const wildcard string = "*"
+
+// Rule is a single statement in a Policy. A Rule matches a request
+// when the subject (or one of its groups), the path and the method
+// all match.
+//
+// Subjects are user emails, "*" matches any authenticated user.
+// Paths are URL path patterns, where "*" matches exactly one path
+// segment and a trailing "**" matches any number of remaining
+// segments, e.g. /api/v1/movies/* or /api/v1/**
+// Methods are HTTP methods, "*" matches any method.
+type Rule struct {
+	Effect   Effect   `json:"effect" yaml:"effect"`
+	Subjects []string `json:"subjects,omitempty" yaml:"subjects,omitempty"`
+	Groups   []string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Paths    []string `json:"paths" yaml:"paths"`
+	Methods  []string `json:"methods" yaml:"methods"`
+}
+
+// Policy is a declarative set of authorization rules. Groups maps
+// a group name to the emails of its members.
+type Policy struct {
+	Groups map[string][]string `json:"groups,omitempty" yaml:"groups,omitempty"`
+	Rules  []Rule              `json:"rules" yaml:"rules"`
+}
+
+// Decision is the result of evaluating a Policy
+type Decision struct {
+	// Allowed is true if access is granted
+	Allowed bool
+	// Rule is the index of the Rule which determined the decision.
+	// Rule is -1 when no rule matched and access was denied by default.
+	Rule int
+}
+
+// ParsePolicy decodes a JSON policy document from r and validates it
+func ParsePolicy(r io.Reader) (Policy, error) {
+	var p Policy
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&p)
+	if err != nil {
+		return Policy{}, errs.E(errs.Invalid, errs.Code("invalid_policy"), err)
+	}
+
+	err = p.Validate()
+	if err != nil {
+		return Policy{}, err
+	}
+
+	return p, nil
+}
+
+// ParsePolicyYAML decodes a YAML policy document from r and validates
+// it. The document has the same fields as a JSON policy document.
+func ParsePolicyYAML(r io.Reader) (Policy, error) {
+	var p Policy
+
+	dec := yaml.NewDecoder(r)
+	dec.KnownFields(true)
+	err := dec.Decode(&p)
+	if err != nil {
+		return Policy{}, errs.E(errs.Invalid, errs.Code("invalid_policy"), err)
+	}
+
+	err = p.Validate()
+	if err != nil {
+		return Policy{}, err
+	}
+
+	return p, nil
+}
+
+// parsePolicyFile decodes the policy document from the file at path,
+// as YAML if the file has a .yaml or .yml extension, else as JSON
+func parsePolicyFile(path string, r io.Reader) (Policy, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return ParsePolicyYAML(r)
+	default:
+		return ParsePolicy(r)
+	}
+}
+
+// Validate determines whether or not the Policy is well formed
+func (p Policy) Validate() error {
+	for i, rule := range p.Rules {
+		switch {
+		case rule.Effect != Allow && rule.Effect != Deny:
+			return errs.E(errs.Invalid, errs.Code("invalid_policy"), errors.New(fmt.Sprintf("rule %d: effect must be %q or %q", i, Allow, Deny)))
+		case len(rule.Subjects) == 0 && len(rule.Groups) == 0:
+			return errs.E(errs.Invalid, errs.Code("invalid_policy"), errors.New(fmt.Sprintf("rule %d: at least one subject or group is required", i)))
+		case len(rule.Paths) == 0:
+			return errs.E(errs.Invalid, errs.Code("invalid_policy"), errors.New(fmt.Sprintf("rule %d: at least one path is required", i)))
+		case len(rule.Methods) == 0:
+			return errs.E(errs.Invalid, errs.Code("invalid_policy"), errors.New(fmt.Sprintf("rule %d: at least one method is required", i)))
+		}
+		for _, g := range rule.Groups {
+			if _, ok := p.Groups[g]; !ok {
+				return errs.E(errs.Invalid, errs.Code("invalid_policy"), errors.New(fmt.Sprintf("rule %d: group %s is not defined", i, g)))
+			}
+		}
+	}
+	return nil
+}
+
+// Evaluate determines whether the subject (user) can perform the
+// action on the object using deny-overrides semantics: if any
+// matching rule denies access, access is denied. Otherwise, access
+// is granted if any matching rule allows it. If no rule matches,
+// access is denied.
+func (p Policy) Evaluate(sub user.User, obj string, act string) Decision {
+	d := Decision{Rule: -1}
+
+	for i, rule := range p.Rules {
+		if !p.matches(rule, sub, obj, act) {
+			continue
+		}
+		if rule.Effect == Deny {
+			return Decision{Allowed: false, Rule: i}
+		}
+		if !d.Allowed {
+			d = Decision{Allowed: true, Rule: i}
+		}
+	}
+
+	return d
+}
+
+// matches determines whether the Rule applies to the request
+func (p Policy) matches(rule Rule, sub user.User, obj string, act string) bool {
+	return p.subjectMatches(rule, sub) && pathMatches(rule.Paths, obj) && methodMatches(rule.Methods, act)
+}
+
+// subjectMatches determines whether the user is one of the rule's
+// subjects or is a member of one of the rule's groups
+func (p Policy) subjectMatches(rule Rule, sub user.User) bool {
+	if sub.Email == "" {
+		return false
+	}
+	for _, s := range rule.Subjects {
+		if s == wildcard || strings.EqualFold(s, sub.Email) {
+			return true
+		}
+	}
+	for _, g := range rule.Groups {
+		for _, member := range p.Groups[g] {
+			if strings.EqualFold(member, sub.Email) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// methodMatches determines whether act is one of the methods
+func methodMatches(methods []string, act string) bool {
+	for _, m := range methods {
+		if m == wildcard || strings.EqualFold(m, act) {
+			return true
+		}
+	}
+	return false
+}
+
+// pathMatches determines whether obj matches any of the path patterns
+func pathMatches(patterns []string, obj string) bool {
+	for _, pattern := range patterns {
+		if PathMatch(pattern, obj) {
+			return true
+		}
+	}
+	return false
+}
+
+// PathMatch reports whether the URL path matches the pattern. Each
+// "*" segment in the pattern matches exactly one path segment. A "**"
+// as the final segment of the pattern matches zero or more remaining
+// segments.
+func PathMatch(pattern, path string) bool {
+	ps := strings.Split(strings.Trim(pattern, "/"), "/")
+	ss := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i, p := range ps {
+		if p == "**" && i == len(ps)-1 {
+			return true
+		}
+		if i >= len(ss) {
+			return false
+		}
+		if p != wildcard && p != ss[i] {
+			return false
+		}
+	}
+
+	return len(ps) == len(ss)
+}
+
+// NewPolicyAuthorizer is an initializer for PolicyAuthorizer. The
+// policy file at path is loaded immediately, an error is returned
+// if it cannot be read or is invalid. Files with a .yaml or .yml
+// extension are read as YAML, any other file as JSON.
+func NewPolicyAuthorizer(path string) (*PolicyAuthorizer, error) {
+	a := &PolicyAuthorizer{path: path}
+
+	_, err := a.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// PolicyAuthorizer satisfies the Authorizer interface and authorizes
+// requests using a Policy read from a JSON or YAML file. The file can be
+// reloaded when it changes using the Reload or Watch methods.
+type PolicyAuthorizer struct {
+	path string
+
+	mu      sync.RWMutex
+	policy  Policy
+	modTime time.Time
+}
+
+// Policy returns the currently loaded Policy
+func (a *PolicyAuthorizer) Policy() Policy {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return a.policy
+}
+
+// Authorize authorizes a subject (user) can perform a particular
+// action on an object using the currently loaded Policy
+func (a *PolicyAuthorizer) Authorize(ctx context.Context, sub user.User, obj string, act string) error {
+	logger := *zerolog.Ctx(ctx)
+
+	d := a.Policy().Evaluate(sub, obj, act)
+
+	if d.Allowed {
+		logger.Info().Str("sub", sub.Email).Str("obj", obj).Str("act", act).Int("rule", d.Rule).Msgf("Authorization Granted")
+		return nil
+	}
+
+	logger.Info().Str("sub", sub.Email).Str("obj", obj).Str("act", act).Int("rule", d.Rule).Msgf("Authorization Denied")
+
+	// the user has been authenticated, but does not have access,
+	// thus they are Unauthorized (403)
+	return errs.E(errs.Unauthorized, errors.New(fmt.Sprintf("user %s does not have %s permission for %s", sub.Email, act, obj)))
+}
+
+// Reload reads the policy file if it has changed since it was last
+// loaded. Reload reports whether a new Policy was loaded. If the file
+// cannot be read or the policy is invalid, an error is returned and
+// the current Policy is kept.
+func (a *PolicyAuthorizer) Reload() (bool, error) {
+	fi, err := os.Stat(a.path)
+	if err != nil {
+		return false, errs.E(errs.IO, err)
+	}
+
+	a.mu.RLock()
+	unchanged := fi.ModTime().Equal(a.modTime)
+	a.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		return false, errs.E(errs.IO, err)
+	}
+	defer f.Close()
+
+	p, err := parsePolicyFile(a.path, f)
+	if err != nil {
+		return false, err
+	}
+
+	a.mu.Lock()
+	a.policy = p
+	a.modTime = fi.ModTime()
+	a.mu.Unlock()
+
+	return true, nil
+}
+
+// Watch checks the policy file for changes at every interval until
+// ctx is done, reloading the Policy when the file has changed.
+// Reload errors are logged and the previous Policy is kept.
+func (a *PolicyAuthorizer) Watch(ctx context.Context, logger zerolog.Logger, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := a.Reload()
+			if err != nil {
+				logger.Error().Err(err).Str("policy_file", a.path).Msg("policy reload failed, keeping previous policy")
+				continue
+			}
+			if reloaded {
+				logger.Info().Str("policy_file", a.path).Msg("policy reloaded")
+			}
+		}
+	}
+}
+
+// policyMethods is the set of HTTP methods accepted when testing
+// a policy from the command line
+var policyMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// ParsePolicyTest parses a comma separated (user, path, method)
+// triple, e.g. "otto.maddox711@gmail.com,/api/v1/movies,GET", as
+// used to test a policy from the command line
+func ParsePolicyTest(s string) (sub user.User, obj string, act string, err error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return user.User{}, "", "", errs.E(errs.Invalid, errors.New("policy test must be in the form user,path,method"))
+	}
+
+	sub = user.User{Email: strings.TrimSpace(parts[0])}
+	obj = strings.TrimSpace(parts[1])
+	act = strings.ToUpper(strings.TrimSpace(parts[2]))
+
+	switch {
+	case sub.Email == "":
+		return user.User{}, "", "", errs.E(errs.Invalid, errs.MissingField("user"))
+	case !strings.HasPrefix(obj, "/"):
+		return user.User{}, "", "", errs.E(errs.Invalid, errors.New("path must begin with /"))
+	case !policyMethods[act]:
+		return user.User{}, "", "", errs.E(errs.Invalid, errors.New(fmt.Sprintf("%s is not a valid HTTP method", act)))
+	}
+
+	return sub, obj, act, nil
+}
@@ -0,0 +1,274 @@
+package auth
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+const testPolicy string = `{
+  "groups": {
+    "admins": ["otto.maddox711@gmail.com"]
+  },
+  "rules": [
+    {"effect": "allow", "subjects": ["*"], "paths": ["/api/v1/movies", "/api/v1/movies/*"], "methods": ["GET"]},
+    {"effect": "allow", "groups": ["admins"], "paths": ["/api/v1/**"], "methods": ["*"]},
+    {"effect": "deny", "subjects": ["badactor@gmail.com"], "paths": ["/api/**"], "methods": ["*"]}
+  ]
+}`
+
+func TestPathMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/api/v1/movies", "/api/v1/movies", true},
+		{"/api/v1/movies", "/api/v1/movies/", true},
+		{"/api/v1/movies", "/api/v1/movies/abc", false},
+		{"/api/v1/movies/*", "/api/v1/movies/abc", true},
+		{"/api/v1/movies/*", "/api/v1/movies/{extlID}", true},
+		{"/api/v1/movies/*", "/api/v1/movies", false},
+		{"/api/v1/movies/*", "/api/v1/movies/abc/def", false},
+		{"/api/*/movies", "/api/v1/movies", true},
+		{"/api/**", "/api", true},
+		{"/api/**", "/api/v1/movies/abc", true},
+		{"/api/**", "/other/v1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			c := qt.New(t)
+			c.Assert(PathMatch(tt.pattern, tt.path), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr bool
+	}{
+		{"typical", testPolicy, false},
+		{"malformed", `{"rules": [`, true},
+		{"unknown field", `{"rulez": []}`, true},
+		{"bad effect", `{"rules": [{"effect": "maybe", "subjects": ["*"], "paths": ["/"], "methods": ["*"]}]}`, true},
+		{"no subject", `{"rules": [{"effect": "allow", "paths": ["/"], "methods": ["*"]}]}`, true},
+		{"no path", `{"rules": [{"effect": "allow", "subjects": ["*"], "methods": ["*"]}]}`, true},
+		{"no method", `{"rules": [{"effect": "allow", "subjects": ["*"], "paths": ["/"]}]}`, true},
+		{"undefined group", `{"rules": [{"effect": "allow", "groups": ["nope"], "paths": ["/"], "methods": ["*"]}]}`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			_, err := ParsePolicy(strings.NewReader(tt.policy))
+			c.Assert(err != nil, qt.Equals, tt.wantErr)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Invalid, err), qt.IsTrue)
+			}
+		})
+	}
+}
+
+const testPolicyYAML string = `groups:
+  admins: [otto.maddox711@gmail.com]
+rules:
+  - effect: allow
+    subjects: ["*"]
+    paths: [/api/v1/movies, /api/v1/movies/*]
+    methods: [GET]
+  - effect: allow
+    groups: [admins]
+    paths: [/api/v1/**]
+    methods: ["*"]
+  - effect: deny
+    subjects: [badactor@gmail.com]
+    paths: [/api/**]
+    methods: ["*"]
+`
+
+func TestParsePolicyYAML(t *testing.T) {
+	tests := []struct {
+		name    string
+		policy  string
+		wantErr bool
+	}{
+		{"typical", testPolicyYAML, false},
+		{"malformed", "rules: [", true},
+		{"unknown field", "rulez: []", true},
+		{"bad effect", `rules: [{effect: maybe, subjects: ["*"], paths: [/], methods: ["*"]}]`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			_, err := ParsePolicyYAML(strings.NewReader(tt.policy))
+			c.Assert(err != nil, qt.Equals, tt.wantErr)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Invalid, err), qt.IsTrue)
+			}
+		})
+	}
+
+	// a YAML policy is the same as its JSON equivalent
+	got, err := ParsePolicyYAML(strings.NewReader(testPolicyYAML))
+	qt.Assert(t, err, qt.IsNil)
+	want, err := ParsePolicy(strings.NewReader(testPolicy))
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, got, qt.DeepEquals, want)
+}
+
+func TestPolicy_Evaluate(t *testing.T) {
+	p, err := ParsePolicy(strings.NewReader(testPolicy))
+	if err != nil {
+		t.Fatalf("ParsePolicy() error = %v", err)
+	}
+
+	admin := usertest.NewUser(t)
+	reader := user.User{Email: "someone@example.com"}
+	badActor := user.User{Email: "badactor@gmail.com"}
+
+	tests := []struct {
+		name string
+		sub  user.User
+		obj  string
+		act  string
+		want Decision
+	}{
+		{"reader get", reader, "/api/v1/movies", http.MethodGet, Decision{Allowed: true, Rule: 0}},
+		{"reader get by id", reader, "/api/v1/movies/abc", http.MethodGet, Decision{Allowed: true, Rule: 0}},
+		{"reader post", reader, "/api/v1/movies", http.MethodPost, Decision{Allowed: false, Rule: -1}},
+		{"admin post", admin, "/api/v1/movies", http.MethodPost, Decision{Allowed: true, Rule: 1}},
+		{"admin delete", admin, "/api/v1/movies/abc", http.MethodDelete, Decision{Allowed: true, Rule: 1}},
+		{"deny overrides allow", badActor, "/api/v1/movies", http.MethodGet, Decision{Allowed: false, Rule: 2}},
+		{"empty user", user.User{}, "/api/v1/movies", http.MethodGet, Decision{Allowed: false, Rule: -1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			c.Assert(p.Evaluate(tt.sub, tt.obj, tt.act), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestPolicyAuthorizer_Authorize(t *testing.T) {
+	c := qt.New(t)
+
+	path := writePolicyFile(t, testPolicy)
+
+	a, err := NewPolicyAuthorizer(path)
+	c.Assert(err, qt.IsNil)
+
+	ctx := context.Background()
+
+	err = a.Authorize(ctx, usertest.NewUser(t), "/api/v1/movies", http.MethodPost)
+	c.Assert(err, qt.IsNil)
+
+	err = a.Authorize(ctx, user.User{Email: "someone@example.com"}, "/api/v1/movies", http.MethodPost)
+	c.Assert(errs.KindIs(errs.Unauthorized, err), qt.IsTrue)
+}
+
+func TestNewPolicyAuthorizer_yaml(t *testing.T) {
+	c := qt.New(t)
+
+	for _, name := range []string{"policy.yaml", "policy.yml"} {
+		path := filepath.Join(t.TempDir(), name)
+		err := ioutil.WriteFile(path, []byte(testPolicyYAML), 0600)
+		c.Assert(err, qt.IsNil)
+
+		a, err := NewPolicyAuthorizer(path)
+		c.Assert(err, qt.IsNil)
+		c.Assert(len(a.Policy().Rules), qt.Equals, 3)
+	}
+}
+
+func TestPolicyAuthorizer_Reload(t *testing.T) {
+	c := qt.New(t)
+
+	path := writePolicyFile(t, testPolicy)
+
+	a, err := NewPolicyAuthorizer(path)
+	c.Assert(err, qt.IsNil)
+
+	// unchanged file is not reloaded
+	reloaded, err := a.Reload()
+	c.Assert(err, qt.IsNil)
+	c.Assert(reloaded, qt.IsFalse)
+
+	// an invalid policy is rejected and the previous policy kept
+	err = ioutil.WriteFile(path, []byte(`{"rules": [`), 0600)
+	c.Assert(err, qt.IsNil)
+	touch(t, path, time.Now().Add(time.Minute))
+	_, err = a.Reload()
+	c.Assert(err, qt.Not(qt.IsNil))
+	c.Assert(len(a.Policy().Rules), qt.Equals, 3)
+
+	// a changed, valid policy is loaded
+	err = ioutil.WriteFile(path, []byte(`{"rules": [{"effect": "deny", "subjects": ["*"], "paths": ["/**"], "methods": ["*"]}]}`), 0600)
+	c.Assert(err, qt.IsNil)
+	touch(t, path, time.Now().Add(2*time.Minute))
+	reloaded, err = a.Reload()
+	c.Assert(err, qt.IsNil)
+	c.Assert(reloaded, qt.IsTrue)
+	c.Assert(len(a.Policy().Rules), qt.Equals, 1)
+}
+
+func TestParsePolicyTest(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		wantSub string
+		wantObj string
+		wantAct string
+		wantErr bool
+	}{
+		{"typical", "otto.maddox711@gmail.com, /api/v1/movies, get", "otto.maddox711@gmail.com", "/api/v1/movies", http.MethodGet, false},
+		{"too few parts", "otto.maddox711@gmail.com,/api/v1/movies", "", "", "", true},
+		{"no user", ",/api/v1/movies,GET", "", "", "", true},
+		{"relative path", "otto.maddox711@gmail.com,api/v1/movies,GET", "", "", "", true},
+		{"bad method", "otto.maddox711@gmail.com,/api/v1/movies,FETCH", "", "", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			sub, obj, act, err := ParsePolicyTest(tt.s)
+			c.Assert(err != nil, qt.Equals, tt.wantErr)
+			c.Assert(sub.Email, qt.Equals, tt.wantSub)
+			c.Assert(obj, qt.Equals, tt.wantObj)
+			c.Assert(act, qt.Equals, tt.wantAct)
+		})
+	}
+}
+
+// writePolicyFile writes the policy to a temporary file and
+// returns its path
+func writePolicyFile(t *testing.T, policy string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "policy.json")
+	err := ioutil.WriteFile(path, []byte(policy), 0600)
+	if err != nil {
+		t.Fatalf("ioutil.WriteFile() error = %v", err)
+	}
+	return path
+}
+
+// touch sets the modification time of the file at path
+func touch(t *testing.T, path string, mtime time.Time) {
+	t.Helper()
+
+	err := os.Chtimes(path, mtime, mtime)
+	if err != nil {
+		t.Fatalf("os.Chtimes() error = %v", err)
+	}
+}
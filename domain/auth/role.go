@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// Role is a named set of Permissions which can be assigned to users
+type Role struct {
+	ID          uuid.UUID
+	Name        string
+	Description string
+	Permissions []Permission
+}
+
+// Permission allows or denies a method on the resources matching
+// a path pattern. Path patterns follow the same rules as a policy
+// Rule path (see PathMatch).
+type Permission struct {
+	Effect Effect
+	Path   string
+	Method string
+}
+
+// IsValid performs validation of the Role
+func (r *Role) IsValid() error {
+	switch {
+	case r.ID == uuid.Nil:
+		return errs.E(errs.Validation, errs.Parameter("role_id"), errs.MissingField("role_id"))
+	case r.Name == "":
+		return errs.E(errs.Validation, errs.Parameter("name"), errs.MissingField("name"))
+	case strings.ContainsAny(r.Name, " \t\n"):
+		return errs.E(errs.Validation, errs.Parameter("name"), "name cannot contain whitespace")
+	}
+
+	for _, p := range r.Permissions {
+		switch {
+		case p.Effect != Allow && p.Effect != Deny:
+			return errs.E(errs.Validation, errs.Parameter("effect"), errors.New(fmt.Sprintf("effect must be %q or %q", Allow, Deny)))
+		case !strings.HasPrefix(p.Path, "/"):
+			return errs.E(errs.Validation, errs.Parameter("path"), "path must begin with /")
+		case p.Method == "":
+			return errs.E(errs.Validation, errs.Parameter("method"), errs.MissingField("method"))
+		case p.Method != wildcard && !policyMethods[p.Method]:
+			return errs.E(errs.Validation, errs.Parameter("method"), errors.New(fmt.Sprintf("%s is not a valid HTTP method", p.Method)))
+		}
+	}
+
+	return nil
+}
+
+// RoleAssignment assigns a Role to a User
+type RoleAssignment struct {
+	Role Role
+	User user.User
+}
+
+// IsValid performs validation of the RoleAssignment
+func (ra RoleAssignment) IsValid() error {
+	switch {
+	case ra.Role.ID == uuid.Nil:
+		return errs.E(errs.Validation, errs.Parameter("role_id"), errs.MissingField("role_id"))
+	case ra.User.ID == uuid.Nil:
+		return errs.E(errs.Validation, errs.Parameter("user_id"), errs.MissingField("user_id"))
+	}
+	return nil
+}
+
+// NewRolePolicy builds a Policy for the user given the roles assigned
+// to them. Each role becomes a policy group with the user as its only
+// member and each role permission becomes a Rule for that group.
+func NewRolePolicy(sub user.User, roles []*Role) Policy {
+	p := Policy{Groups: make(map[string][]string)}
+
+	for _, r := range roles {
+		p.Groups[r.Name] = []string{sub.Email}
+		for _, perm := range r.Permissions {
+			p.Rules = append(p.Rules, Rule{
+				Effect:  perm.Effect,
+				Groups:  []string{r.Name},
+				Paths:   []string{perm.Path},
+				Methods: []string{perm.Method},
+			})
+		}
+	}
+
+	return p
+}
+
+// RoleFinder finds the roles assigned to a user
+type RoleFinder interface {
+	FindRolesByUserEmail(ctx context.Context, email string) ([]*Role, error)
+}
+
+// RoleAuthorizer satisfies the Authorizer interface and authorizes
+// requests using the roles and permissions assigned to the user,
+// typically as maintained through the admin API
+type RoleAuthorizer struct {
+	RoleFinder RoleFinder
+}
+
+// Authorize authorizes a subject (user) can perform a particular
+// action on an object using the permissions of the roles assigned
+// to the user. Deny permissions override allow permissions.
+func (a RoleAuthorizer) Authorize(ctx context.Context, sub user.User, obj string, act string) error {
+	logger := *zerolog.Ctx(ctx)
+
+	roles, err := a.RoleFinder.FindRolesByUserEmail(ctx, sub.Email)
+	if err != nil {
+		return err
+	}
+
+	d := NewRolePolicy(sub, roles).Evaluate(sub, obj, act)
+
+	if d.Allowed {
+		logger.Info().Str("sub", sub.Email).Str("obj", obj).Str("act", act).Msgf("Authorization Granted")
+		return nil
+	}
+
+	logger.Info().Str("sub", sub.Email).Str("obj", obj).Str("act", act).Msgf("Authorization Denied")
+
+	return errs.E(errs.Unauthorized, errors.New(fmt.Sprintf("user %s does not have %s permission for %s", sub.Email, act, obj)))
+}
@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// mockRoleFinder satisfies the RoleFinder interface and returns
+// the given roles for any user
+type mockRoleFinder struct {
+	roles []*Role
+}
+
+func (m mockRoleFinder) FindRolesByUserEmail(ctx context.Context, email string) ([]*Role, error) {
+	return m.roles, nil
+}
+
+func newTestRole() *Role {
+	return &Role{
+		ID:   uuid.New(),
+		Name: "movie-admin",
+		Permissions: []Permission{
+			{Effect: Allow, Path: "/api/v1/movies/**", Method: "*"},
+			{Effect: Deny, Path: "/api/v1/movies/*", Method: http.MethodDelete},
+		},
+	}
+}
+
+func TestRole_IsValid(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(r *Role)
+		wantErr bool
+	}{
+		{"typical", func(r *Role) {}, false},
+		{"no id", func(r *Role) { r.ID = uuid.Nil }, true},
+		{"no name", func(r *Role) { r.Name = "" }, true},
+		{"name with space", func(r *Role) { r.Name = "movie admin" }, true},
+		{"bad effect", func(r *Role) { r.Permissions[0].Effect = "maybe" }, true},
+		{"relative path", func(r *Role) { r.Permissions[0].Path = "api/v1" }, true},
+		{"no method", func(r *Role) { r.Permissions[0].Method = "" }, true},
+		{"bad method", func(r *Role) { r.Permissions[0].Method = "FETCH" }, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			r := newTestRole()
+			tt.modify(r)
+			err := r.IsValid()
+			c.Assert(err != nil, qt.Equals, tt.wantErr)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+			}
+		})
+	}
+}
+
+func TestRoleAuthorizer_Authorize(t *testing.T) {
+	ctx := context.Background()
+	u := usertest.NewUser(t)
+
+	tests := []struct {
+		name    string
+		roles   []*Role
+		obj     string
+		act     string
+		wantErr bool
+	}{
+		{"allowed", []*Role{newTestRole()}, "/api/v1/movies/abc", http.MethodPut, false},
+		{"deny overrides", []*Role{newTestRole()}, "/api/v1/movies/abc", http.MethodDelete, true},
+		{"not covered", []*Role{newTestRole()}, "/api/v1/admin/roles", http.MethodGet, true},
+		{"no roles", nil, "/api/v1/movies/abc", http.MethodGet, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+			a := RoleAuthorizer{RoleFinder: mockRoleFinder{roles: tt.roles}}
+			err := a.Authorize(ctx, u, tt.obj, tt.act)
+			c.Assert(err != nil, qt.Equals, tt.wantErr)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Unauthorized, err), qt.IsTrue)
+			}
+		})
+	}
+}
+
+func TestNewRolePolicy(t *testing.T) {
+	c := qt.New(t)
+
+	sub := user.User{Email: "someone@example.com"}
+	p := NewRolePolicy(sub, []*Role{newTestRole()})
+
+	c.Assert(p.Groups, qt.DeepEquals, map[string][]string{"movie-admin": {"someone@example.com"}})
+	c.Assert(len(p.Rules), qt.Equals, 2)
+	c.Assert(p.Validate(), qt.IsNil)
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Scopes known to the API. Routes declare the scopes they require
+// when they are registered.
+const (
+	// MoviesReadScope allows reading movies
+	MoviesReadScope string = "movies:read"
+	// MoviesWriteScope allows creating, updating and deleting movies
+	MoviesWriteScope string = "movies:write"
+	// AdminScope allows use of the admin APIs
+	AdminScope string = "admin"
+)
+
+// AllScopes are all the scopes known to the API
+var AllScopes = Scopes{MoviesReadScope, MoviesWriteScope, AdminScope}
+
+// DefaultUserScopes are the scopes granted to tokens whose issuer
+// knows nothing of the API's scopes, e.g. Google. The admin scope is
+// not among them, it must be granted explicitly.
+var DefaultUserScopes = Scopes{MoviesReadScope, MoviesWriteScope}
+
+// Scopes are the OAuth2 scopes granted to an access token
+type Scopes []string
+
+// ParseScopes parses a space-delimited list of scopes, as used in
+// OAuth2 token responses and WWW-Authenticate challenges
+func ParseScopes(s string) Scopes {
+	return Scopes(strings.Fields(s))
+}
+
+// NewScopes validates that each of the given scopes is known to
+// the API and returns them as Scopes
+func NewScopes(s []string) (Scopes, error) {
+	for _, scope := range s {
+		if !AllScopes.Has(scope) {
+			return nil, errs.E(errs.Validation, errs.Parameter("scopes"), errors.Errorf("unknown scope %q", scope))
+		}
+	}
+	return Scopes(s), nil
+}
+
+// Has reports whether scope is one of the Scopes
+func (s Scopes) Has(scope string) bool {
+	for _, v := range s {
+		if v == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Intersect returns the Scopes which are also in allowed
+func (s Scopes) Intersect(allowed []string) Scopes {
+	a := Scopes(allowed)
+	i := make(Scopes, 0, len(s))
+	for _, v := range s {
+		if a.Has(v) {
+			i = append(i, v)
+		}
+	}
+	return i
+}
+
+// String returns the scopes as a space-delimited list
+func (s Scopes) String() string {
+	return strings.Join(s, " ")
+}
+
+const contextKeyScopes = contextKey("scopes")
+
+// CtxWithScopes sets the Scopes granted to the request's access
+// token to the given context
+func CtxWithScopes(ctx context.Context, s Scopes) context.Context {
+	return context.WithValue(ctx, contextKeyScopes, s)
+}
+
+// ScopesFromRequest gets the Scopes granted to the request's access
+// token from the request context. No scopes are returned if none
+// were set.
+func ScopesFromRequest(r *http.Request) Scopes {
+	s, _ := r.Context().Value(contextKeyScopes).(Scopes)
+	return s
+}
+
+// RequireScopes returns an Unauthorized error with an
+// insufficient_scope challenge unless all of the required scopes
+// are granted
+func RequireScopes(granted Scopes, required ...string) error {
+	for _, scope := range required {
+		if !granted.Has(scope) {
+			return errs.E(errs.Unauthorized,
+				errs.InsufficientScope(Scopes(required).String()),
+				errors.Errorf("access token does not have the %s scope", scope))
+		}
+	}
+	return nil
+}
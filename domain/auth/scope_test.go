@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestNewScopes(t *testing.T) {
+	c := qt.New(t)
+
+	s, err := NewScopes([]string{MoviesReadScope, AdminScope})
+	c.Assert(err, qt.IsNil)
+	c.Assert(s, qt.DeepEquals, Scopes{MoviesReadScope, AdminScope})
+
+	_, err = NewScopes([]string{MoviesReadScope, "movies:everything"})
+	c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+}
+
+func TestParseScopes(t *testing.T) {
+	c := qt.New(t)
+
+	s := ParseScopes(" movies:read  movies:write ")
+	c.Assert(s, qt.DeepEquals, Scopes{MoviesReadScope, MoviesWriteScope})
+	c.Assert(s.String(), qt.Equals, "movies:read movies:write")
+}
+
+func TestScopes_Intersect(t *testing.T) {
+	c := qt.New(t)
+
+	got := AllScopes.Intersect([]string{MoviesReadScope, "other"})
+	c.Assert(got, qt.DeepEquals, Scopes{MoviesReadScope})
+
+	got = AllScopes.Intersect(nil)
+	c.Assert(len(got), qt.Equals, 0)
+}
+
+func TestRequireScopes(t *testing.T) {
+	c := qt.New(t)
+
+	err := RequireScopes(Scopes{MoviesReadScope, MoviesWriteScope}, MoviesReadScope)
+	c.Assert(err, qt.IsNil)
+
+	err = RequireScopes(Scopes{MoviesReadScope}, MoviesWriteScope)
+	c.Assert(errs.KindIs(errs.Unauthorized, err), qt.IsTrue)
+	e, ok := err.(*errs.Error)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(e.Challenge.Error, qt.Equals, errs.InsufficientScopeChallenge)
+	c.Assert(e.Challenge.Scope, qt.Equals, MoviesWriteScope)
+}
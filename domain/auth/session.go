@@ -0,0 +1,134 @@
+package auth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/random"
+)
+
+// SessionKeySize is the size of the key a SessionCodec encrypts
+// with (AES-256)
+const SessionKeySize int = 32
+
+// DefaultSessionTTL is how long a session lasts if the access token
+// it holds has no expiry
+const DefaultSessionTTL time.Duration = time.Hour
+
+// Session is a browser session created by the OAuth2 login flow and
+// stored in an encrypted cookie. It holds the access token obtained
+// at login, which is converted to a user on every request like a
+// Bearer token, and the token requests authenticated by the session
+// must echo to protect against CSRF.
+type Session struct {
+	AccessToken string    `json:"at"`
+	Expiry      time.Time `json:"exp"`
+	CSRFToken   string    `json:"csrf"`
+}
+
+// IsExpired reports whether the session has expired
+func (s Session) IsExpired(now time.Time) bool {
+	return !now.Before(s.Expiry)
+}
+
+// LoginState is the state kept in an encrypted cookie between the
+// start of the OAuth2 login flow and the callback
+type LoginState struct {
+	State        string `json:"state"`
+	CodeVerifier string `json:"verifier"`
+}
+
+// SessionCodec encrypts and authenticates values stored in cookies
+// using AES-256-GCM, so they can be neither read nor forged by the
+// browser
+type SessionCodec struct {
+	aead cipher.AEAD
+}
+
+// NewSessionCodec returns a SessionCodec for the given key, which
+// must be SessionKeySize bytes
+func NewSessionCodec(key []byte) (SessionCodec, error) {
+	if len(key) != SessionKeySize {
+		return SessionCodec{}, errs.E(errs.Validation, errors.Errorf("session key must be %d bytes", SessionKeySize))
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return SessionCodec{}, errs.E(errs.Internal, err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return SessionCodec{}, errs.E(errs.Internal, err)
+	}
+
+	return SessionCodec{aead: aead}, nil
+}
+
+// Seal encodes v as JSON and returns it encrypted and base64url
+// encoded, for use as a cookie value
+func (c SessionCodec) Seal(v interface{}) (string, error) {
+	if c.aead == nil {
+		return "", errs.E(errs.Internal, errors.New("session codec has no key"))
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", errs.E(errs.Internal, err)
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", errs.E(errs.Internal, err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(c.aead.Seal(nonce, nonce, b, nil)), nil
+}
+
+// Open decrypts a value returned by Seal into v. Values which were
+// not sealed with the codec's key are rejected.
+func (c SessionCodec) Open(value string, v interface{}) error {
+	if c.aead == nil {
+		return errs.E(errs.Internal, errors.New("session codec has no key"))
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) < c.aead.NonceSize() {
+		return errs.E(errs.Unauthenticated, errors.New("invalid session cookie"))
+	}
+
+	nonce, sealed := b[:c.aead.NonceSize()], b[c.aead.NonceSize():]
+	plain, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return errs.E(errs.Unauthenticated, errors.New("invalid session cookie"))
+	}
+
+	if err := json.Unmarshal(plain, v); err != nil {
+		return errs.E(errs.Unauthenticated, errors.New("invalid session cookie"))
+	}
+
+	return nil
+}
+
+// NewCodeVerifier returns a random PKCE code verifier (RFC 7636)
+func NewCodeVerifier() (string, error) {
+	b, err := random.GenerateRandomBytes(32)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// CodeChallenge returns the S256 PKCE code challenge for the verifier
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestSessionCodec(t *testing.T) {
+	c := qt.New(t)
+
+	codec, err := NewSessionCodec(bytes.Repeat([]byte("k"), SessionKeySize))
+	c.Assert(err, qt.IsNil)
+
+	want := Session{AccessToken: "ya29.token", Expiry: time.Now().Add(time.Hour).Round(time.Second).UTC(), CSRFToken: "csrf"}
+	v, err := codec.Seal(want)
+	c.Assert(err, qt.IsNil)
+
+	var got Session
+	err = codec.Open(v, &got)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.DeepEquals, want)
+	c.Assert(got.IsExpired(time.Now()), qt.IsFalse)
+
+	// values sealed with another key are rejected
+	other, err := NewSessionCodec(bytes.Repeat([]byte("o"), SessionKeySize))
+	c.Assert(err, qt.IsNil)
+	err = other.Open(v, &got)
+	c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+
+	// as are tampered values
+	tampered := []byte(v)
+	tampered[len(tampered)/2] ^= 1
+	err = codec.Open(string(tampered), &got)
+	c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+	err = codec.Open("not a cookie", &got)
+	c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+
+	_, err = NewSessionCodec([]byte("too short"))
+	c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+}
+
+func TestCodeChallenge(t *testing.T) {
+	// example from RFC 7636 Appendix B
+	got := CodeChallenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	qt.Assert(t, got, qt.Equals, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM")
+
+	v, err := NewCodeVerifier()
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, len(v), qt.Equals, 43)
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// SignatureTokenType is the token type set to the AccessToken for
+// requests authenticated with a verified request signature. The
+// token is the ID of the client which signed the request.
+const SignatureTokenType string = "Signature"
+
+// DefaultSignatureWindow is the default replay window for signed
+// requests. Requests with a timestamp further than the window from
+// the current time, in either direction, are rejected.
+const DefaultSignatureWindow time.Duration = 5 * time.Minute
+
+// maxNonceLength is the longest nonce accepted for a signed request
+const maxNonceLength int = 128
+
+// SignedRequest holds the parts of a request covered by its signature
+type SignedRequest struct {
+	ClientID uuid.UUID
+	Method   string
+	Path     string
+	// Query is the raw query string, without the leading "?"
+	Query string
+	// Timestamp is the time the request was signed, in Unix seconds
+	Timestamp string
+	// Nonce is a value unique to the request, chosen by the client.
+	// A nonce is only accepted once within the replay window.
+	Nonce string
+	Body  []byte
+	// Signature is the hex encoded HMAC-SHA256 of the request
+	Signature string
+}
+
+// payload returns the bytes which are signed: the method, path,
+// query, timestamp and nonce on separate lines followed by the body
+func (sr SignedRequest) payload() []byte {
+	p := make([]byte, 0, len(sr.Method)+len(sr.Path)+len(sr.Query)+len(sr.Timestamp)+len(sr.Nonce)+len(sr.Body)+5)
+	p = append(p, sr.Method...)
+	p = append(p, '\n')
+	p = append(p, sr.Path...)
+	p = append(p, '\n')
+	p = append(p, sr.Query...)
+	p = append(p, '\n')
+	p = append(p, sr.Timestamp...)
+	p = append(p, '\n')
+	p = append(p, sr.Nonce...)
+	p = append(p, '\n')
+	return append(p, sr.Body...)
+}
+
+// mac returns the HMAC-SHA256 of the request using the given secret
+func (sr SignedRequest) mac(secret []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(sr.payload())
+	return mac.Sum(nil)
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of the request using
+// the given secret
+func (sr SignedRequest) Sign(secret []byte) string {
+	return hex.EncodeToString(sr.mac(secret))
+}
+
+// SigningSecretFinder finds the secret a client signs requests with
+type SigningSecretFinder interface {
+	FindSigningSecret(ctx context.Context, clientID uuid.UUID) ([]byte, error)
+}
+
+// SignatureVerifier verifies the signature of a request
+type SignatureVerifier interface {
+	Verify(ctx context.Context, sr SignedRequest) error
+}
+
+// HMACSignatureVerifier satisfies the SignatureVerifier interface and
+// verifies HMAC-SHA256 signatures made with per-client secrets
+type HMACSignatureVerifier struct {
+	SecretFinder SigningSecretFinder
+	// Nonces remembers the nonces of verified requests, so they
+	// cannot be replayed. Nonces is required.
+	Nonces NonceCache
+	// Window is the replay window. If zero, DefaultSignatureWindow
+	// is used.
+	Window time.Duration
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// Verify verifies the request timestamp is within the replay window,
+// the signature matches the one made with the client's secret and
+// the nonce has not been used by the client within the window
+func (v HMACSignatureVerifier) Verify(ctx context.Context, sr SignedRequest) error {
+	if v.Nonces == nil {
+		return errs.E(errs.Internal, errors.New("signature verifier has no nonce cache"))
+	}
+
+	signedAt, err := v.verifyTimestamp(sr.Timestamp)
+	if err != nil {
+		return err
+	}
+
+	if sr.Nonce == "" || len(sr.Nonce) > maxNonceLength {
+		return errs.E(errs.Unauthenticated, errors.Errorf("signature nonce must be 1 to %d characters", maxNonceLength))
+	}
+
+	secret, err := v.SecretFinder.FindSigningSecret(ctx, sr.ClientID)
+	if errs.KindIs(errs.NotExist, err) {
+		return errs.E(errs.Unauthenticated, errors.New("client has no signing secret"))
+	}
+	if err != nil {
+		return err
+	}
+
+	got, err := hex.DecodeString(sr.Signature)
+	if err != nil {
+		return errs.E(errs.Unauthenticated, errors.New("signature is not hex encoded"))
+	}
+	if !hmac.Equal(got, sr.mac(secret)) {
+		return errs.E(errs.Unauthenticated, errors.New("invalid signature"))
+	}
+
+	// the nonce is only added once the signature is verified, so
+	// others cannot use up a client's nonces. It is kept until the
+	// timestamp falls out of the window, after which the request is
+	// rejected anyway.
+	added, err := v.Nonces.Add(ctx, sr.ClientID.String()+" "+sr.Nonce, signedAt.Add(v.window()))
+	if err != nil {
+		return err
+	}
+	if !added {
+		return errs.E(errs.Unauthenticated, errors.New("signature nonce has already been used"))
+	}
+
+	return nil
+}
+
+// window returns the replay window
+func (v HMACSignatureVerifier) window() time.Duration {
+	if v.Window == 0 {
+		return DefaultSignatureWindow
+	}
+	return v.Window
+}
+
+// verifyTimestamp returns the time the request was signed, or an
+// error unless the timestamp is within the replay window of the
+// current time
+func (v HMACSignatureVerifier) verifyTimestamp(ts string) (time.Time, error) {
+	secs, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, errs.E(errs.Unauthenticated, errors.New("invalid signature timestamp"))
+	}
+
+	now := time.Now
+	if v.Now != nil {
+		now = v.Now
+	}
+
+	signedAt := time.Unix(secs, 0)
+	d := now().Sub(signedAt)
+	if d > v.window() || d < -v.window() {
+		return time.Time{}, errs.E(errs.Unauthenticated, errors.New("signature timestamp is outside the replay window"))
+	}
+
+	return signedAt, nil
+}
+
+// SignedClientConverter satisfies the AccessTokenConverter interface
+// and converts the token set for a verified signed request to the
+// owner of the client which signed it
+type SignedClientConverter struct {
+	ClientFinder client.Finder
+}
+
+// Convert finds the client which signed the request and returns its
+// owner and the scopes the client is allowed
+func (c SignedClientConverter) Convert(ctx context.Context, token AccessToken) (user.User, Scopes, error) {
+	id, err := uuid.Parse(token.Token)
+	if err != nil {
+		return user.User{}, nil, errs.E(errs.Unauthenticated, errors.New("invalid client ID"))
+	}
+
+	cl, err := c.ClientFinder.FindByID(ctx, id)
+	if errs.KindIs(errs.NotExist, err) {
+		return user.User{}, nil, errs.E(errs.Unauthenticated, errors.New("client not registered"))
+	}
+	if err != nil {
+		return user.User{}, nil, err
+	}
+	if !cl.IsActive() {
+		return user.User{}, nil, errs.E(errs.Unauthenticated, errors.New("client is not active"))
+	}
+
+	return cl.Owner, Scopes(cl.Scopes), nil
+}
@@ -0,0 +1,167 @@
+package auth
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// mockSecretFinder finds the secret for a single client
+type mockSecretFinder struct {
+	clientID uuid.UUID
+	secret   []byte
+}
+
+func (m mockSecretFinder) FindSigningSecret(ctx context.Context, clientID uuid.UUID) ([]byte, error) {
+	if clientID != m.clientID {
+		return nil, errs.E(errs.NotExist, "No signing secret found for given client ID")
+	}
+	return m.secret, nil
+}
+
+func TestHMACSignatureVerifier_Verify(t *testing.T) {
+	clientID := uuid.New()
+	secret := []byte("shhh-its-a-secret")
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	v := HMACSignatureVerifier{
+		SecretFinder: mockSecretFinder{clientID: clientID, secret: secret},
+		Nonces:       NewMemoryNonceCache(),
+		Now:          func() time.Time { return now },
+	}
+
+	var nonce int
+	newRequest := func(signedAt time.Time) SignedRequest {
+		nonce++
+		sr := SignedRequest{
+			ClientID:  clientID,
+			Method:    "POST",
+			Path:      "/api/v1/movies",
+			Query:     "dry_run=true",
+			Timestamp: strconv.FormatInt(signedAt.Unix(), 10),
+			Nonce:     "nonce-" + strconv.Itoa(nonce),
+			Body:      []byte(`{"title":"Repo Man"}`),
+		}
+		sr.Signature = sr.Sign(secret)
+		return sr
+	}
+
+	tests := []struct {
+		name    string
+		req     func() SignedRequest
+		wantErr bool
+	}{
+		{"valid", func() SignedRequest { return newRequest(now) }, false},
+		{"within window", func() SignedRequest { return newRequest(now.Add(-4 * time.Minute)) }, false},
+		{"stale", func() SignedRequest { return newRequest(now.Add(-6 * time.Minute)) }, true},
+		{"future", func() SignedRequest { return newRequest(now.Add(6 * time.Minute)) }, true},
+		{"invalid timestamp", func() SignedRequest {
+			sr := newRequest(now)
+			sr.Timestamp = "yesterday"
+			return sr
+		}, true},
+		{"tampered query", func() SignedRequest {
+			sr := newRequest(now)
+			sr.Query = "dry_run=false"
+			return sr
+		}, true},
+		{"tampered nonce", func() SignedRequest {
+			sr := newRequest(now)
+			sr.Nonce = "another-nonce"
+			return sr
+		}, true},
+		{"no nonce", func() SignedRequest {
+			sr := newRequest(now)
+			sr.Nonce = ""
+			sr.Signature = sr.Sign(secret)
+			return sr
+		}, true},
+		{"tampered body", func() SignedRequest {
+			sr := newRequest(now)
+			sr.Body = []byte(`{"title":"Alex Cox's Repo Man"}`)
+			return sr
+		}, true},
+		{"wrong secret", func() SignedRequest {
+			sr := newRequest(now)
+			sr.Signature = sr.Sign([]byte("not-the-secret"))
+			return sr
+		}, true},
+		{"not hex", func() SignedRequest {
+			sr := newRequest(now)
+			sr.Signature = "zzz"
+			return sr
+		}, true},
+		{"no secret", func() SignedRequest {
+			sr := newRequest(now)
+			sr.ClientID = uuid.New()
+			return sr
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			err := v.Verify(context.Background(), tt.req())
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+		})
+	}
+}
+
+func TestHMACSignatureVerifier_Verify_replay(t *testing.T) {
+	c := qt.New(t)
+
+	clientID := uuid.New()
+	otherID := uuid.New()
+	secret := []byte("shhh-its-a-secret")
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	nonces := NewMemoryNonceCache()
+	nonces.Now = func() time.Time { return now }
+	v := HMACSignatureVerifier{
+		SecretFinder: mockSecretFinder{clientID: clientID, secret: secret},
+		Nonces:       nonces,
+		Now:          func() time.Time { return now },
+	}
+
+	sr := SignedRequest{
+		ClientID:  clientID,
+		Method:    "POST",
+		Path:      "/api/v1/movies",
+		Timestamp: strconv.FormatInt(now.Unix(), 10),
+		Nonce:     "6f1c0e2a9b7d4c35",
+		Body:      []byte(`{"title":"Repo Man"}`),
+	}
+	sr.Signature = sr.Sign(secret)
+
+	c.Assert(v.Verify(context.Background(), sr), qt.IsNil)
+
+	// the same request is rejected when replayed within the window
+	err := v.Verify(context.Background(), sr)
+	c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+	c.Assert(err, qt.ErrorMatches, "signature nonce has already been used")
+
+	// a request with a bad signature does not use up the nonce of
+	// another client
+	forged := sr
+	forged.ClientID = otherID
+	forged.Nonce = "unused-nonce"
+	c.Assert(v.Verify(context.Background(), forged), qt.Not(qt.IsNil))
+	fresh := sr
+	fresh.Nonce = "unused-nonce"
+	fresh.Signature = fresh.Sign(secret)
+	c.Assert(v.Verify(context.Background(), fresh), qt.IsNil)
+
+	// a verifier without a nonce cache refuses to verify
+	err = HMACSignatureVerifier{SecretFinder: v.SecretFinder}.Verify(context.Background(), sr)
+	c.Assert(errs.KindIs(errs.Internal, err), qt.IsTrue)
+}
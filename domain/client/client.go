@@ -0,0 +1,106 @@
+// Package client contains the business logic for the registered
+// clients (applications) which call the API on behalf of users
+package client
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// Status is the status of a registered client
+type Status string
+
+const (
+	// Active clients may call the API
+	Active Status = "active"
+	// Disabled clients are kept for the records they created,
+	// but may no longer call the API
+	Disabled Status = "disabled"
+)
+
+// Client is an application registered to call the API
+type Client struct {
+	ID     uuid.UUID
+	Name   string
+	Owner  user.User
+	Status Status
+	// Scopes are the scopes the client is allowed to request
+	Scopes     []string
+	CreateTime time.Time
+	UpdateTime time.Time
+}
+
+// NewClient initializes an active Client owned by the given user
+func NewClient(name string, owner user.User, scopes []string) (*Client, error) {
+	now := time.Now().UTC()
+
+	c := &Client{
+		ID:         uuid.New(),
+		Name:       name,
+		Owner:      owner,
+		Status:     Active,
+		Scopes:     scopes,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+
+	err := c.IsValid()
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// IsValid performs validation of the Client
+func (c *Client) IsValid() error {
+	switch {
+	case c.Name == "":
+		return errs.E(errs.Validation, errs.Parameter("name"), errs.MissingField("name"))
+	case c.Owner.ID == uuid.Nil:
+		return errs.E(errs.Validation, errs.Parameter("owner"), errs.MissingField("owner"))
+	case c.Status != Active && c.Status != Disabled:
+		return errs.E(errs.Validation, errs.Parameter("status"), errors.Errorf("status must be %s or %s", Active, Disabled))
+	}
+	for _, s := range c.Scopes {
+		if s == "" || strings.ContainsAny(s, " \t\n") {
+			return errs.E(errs.Validation, errs.Parameter("scopes"), errors.Errorf("invalid scope %q", s))
+		}
+	}
+	return nil
+}
+
+// IsActive reports whether the Client may call the API
+func (c *Client) IsActive() bool {
+	return c.Status == Active
+}
+
+// Finder finds a Client given its ID
+type Finder interface {
+	FindByID(ctx context.Context, id uuid.UUID) (*Client, error)
+}
+
+type contextKey string
+
+const contextKeyClient = contextKey("client")
+
+// CtxWithClient sets the Client to the given context
+func CtxWithClient(ctx context.Context, c Client) context.Context {
+	return context.WithValue(ctx, contextKeyClient, c)
+}
+
+// FromRequest gets the Client from the request context. Identifying
+// the client is optional, so ok is false if the request was not
+// made by a registered client.
+func FromRequest(r *http.Request) (c Client, ok bool) {
+	c, ok = r.Context().Value(contextKeyClient).(Client)
+	return c, ok
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+func TestNewClient(t *testing.T) {
+	owner := usertest.NewUser(t)
+	owner.ID = uuid.New()
+
+	tests := []struct {
+		name    string
+		cname   string
+		owner   user.User
+		scopes  []string
+		wantErr bool
+	}{
+		{"typical", "Helping Hand Movie App", owner, []string{"movies:read", "movies:write"}, false},
+		{"no scopes", "Helping Hand Movie App", owner, nil, false},
+		{"missing name", "", owner, nil, true},
+		{"unstored owner", "Helping Hand Movie App", usertest.NewUser(t), nil, true},
+		{"invalid scope", "Helping Hand Movie App", owner, []string{"movies:read movies:write"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := NewClient(tt.cname, tt.owner, tt.scopes)
+			if tt.wantErr {
+				c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got.ID, qt.Not(qt.Equals), uuid.Nil)
+			c.Assert(got.IsActive(), qt.IsTrue)
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	c := qt.New(t)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	_, ok := FromRequest(req)
+	c.Assert(ok, qt.IsFalse)
+
+	want := Client{ID: uuid.New(), Name: "Helping Hand Movie App", Status: Active}
+	req = req.WithContext(CtxWithClient(req.Context(), want))
+	got, ok := FromRequest(req)
+	c.Assert(ok, qt.IsTrue)
+	c.Assert(got.ID, qt.Equals, want.ID)
+}
@@ -0,0 +1,100 @@
+package errs
+
+import (
+	"strings"
+	"sync/atomic"
+)
+
+// DefaultRealm is the realm sent in WWW-Authenticate challenges
+// unless another realm is set with SetRealm
+const DefaultRealm string = "go-api-basic"
+
+// Error codes for Bearer token challenges, as defined in
+// RFC 6750, section 3.1
+const (
+	// InvalidRequestChallenge is used when the request is
+	// malformed, e.g. an unsupported authentication scheme
+	InvalidRequestChallenge string = "invalid_request"
+	// InvalidTokenChallenge is used when the access token is
+	// expired, revoked, malformed or otherwise invalid
+	InvalidTokenChallenge string = "invalid_token"
+	// InsufficientScopeChallenge is used when the access token
+	// does not have the scope required for the request
+	InsufficientScopeChallenge string = "insufficient_scope"
+)
+
+// Challenge is the detail of an RFC 6750 Bearer challenge sent to
+// the client in the WWW-Authenticate header of 401 and 403 responses.
+// Per RFC 6750, requests without any authentication information
+// should get a challenge with no error code, so the zero Challenge
+// is a valid challenge with only the realm.
+type Challenge struct {
+	// Error is the RFC 6750 error code, e.g. invalid_token
+	Error string
+	// Description is a human-readable explanation of the error
+	// which is safe to send to the client
+	Description string
+	// Scope is the space-delimited list of scopes required
+	Scope string
+}
+
+// InvalidToken returns an invalid_token Challenge with the
+// given description
+func InvalidToken(description string) Challenge {
+	return Challenge{Error: InvalidTokenChallenge, Description: description}
+}
+
+// InsufficientScope returns an insufficient_scope Challenge for
+// the given space-delimited list of required scopes
+func InsufficientScope(scope string) Challenge {
+	return Challenge{
+		Error:       InsufficientScopeChallenge,
+		Description: "The access token does not have the required scope",
+		Scope:       scope,
+	}
+}
+
+var realm atomic.Value
+
+// SetRealm sets the realm sent in WWW-Authenticate challenges
+func SetRealm(r string) {
+	realm.Store(r)
+}
+
+// Realm returns the realm sent in WWW-Authenticate challenges
+func Realm() string {
+	r, ok := realm.Load().(string)
+	if !ok || r == "" {
+		return DefaultRealm
+	}
+	return r
+}
+
+// wwwAuthenticate returns the WWW-Authenticate header value for
+// the challenge
+func (c Challenge) wwwAuthenticate() string {
+	var b strings.Builder
+	b.WriteString(`Bearer realm="` + quotable(Realm()) + `"`)
+	if c.Error != "" {
+		b.WriteString(`, error="` + quotable(c.Error) + `"`)
+	}
+	if c.Description != "" {
+		b.WriteString(`, error_description="` + quotable(c.Description) + `"`)
+	}
+	if c.Scope != "" {
+		b.WriteString(`, scope="` + quotable(c.Scope) + `"`)
+	}
+	return b.String()
+}
+
+// quotable removes the characters which RFC 6750 does not allow
+// in challenge attribute values, i.e. double quotes, backslashes
+// and anything outside of printable ASCII
+func quotable(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || r > 0x7e || r == '"' || r == '\\' {
+			return -1
+		}
+		return r
+	}, s)
+}
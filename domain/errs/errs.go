@@ -29,12 +29,15 @@ type Error struct {
 	Param Parameter
 	// Code is a human-readable, short representation of the error
 	Code Code
+	// Challenge is the WWW-Authenticate challenge sent for
+	// Unauthenticated and Unauthorized errors
+	Challenge Challenge
 	// The underlying error that triggered this one, if any.
 	Err error
 }
 
 func (e *Error) isZero() bool {
-	return e.User == "" && e.Kind == 0 && e.Param == "" && e.Code == "" && e.Err == nil
+	return e.User == "" && e.Kind == 0 && e.Param == "" && e.Code == "" && e.Challenge == Challenge{} && e.Err == nil
 }
 
 // Unwrap method allows for unwrapping errors using errors.As
@@ -81,6 +84,9 @@ const (
 	InvalidRequest              // Invalid Request
 	Unauthenticated             // User did not properly authenticate
 	Unauthorized                // User is not authorized for the resource
+	Timeout                     // Operation did not finish before its deadline
+	Canceled                    // Operation was canceled, e.g. the client went away
+	RateLimited                 // Too many requests, the caller should retry later
 )
 
 func (k Kind) String() string {
@@ -115,6 +121,12 @@ func (k Kind) String() string {
 		return "unauthenticated"
 	case Unauthorized:
 		return "unauthorized"
+	case Timeout:
+		return "timeout"
+	case Canceled:
+		return "canceled"
+	case RateLimited:
+		return "rate_limited"
 	}
 	return "unknown_error_kind"
 }
@@ -135,6 +147,9 @@ func (k Kind) String() string {
 //		The class of error, such as permission failure.
 //	error
 //		The underlying error that triggered this one.
+//	Challenge
+//		The WWW-Authenticate challenge sent for an
+//		Unauthenticated or Unauthorized error.
 //
 // If the error is printed, only those items that have been
 // set to non-zero values will appear in the result.
@@ -175,6 +190,8 @@ func E(args ...interface{}) error {
 			e.Code = arg
 		case Parameter:
 			e.Param = arg
+		case Challenge:
+			e.Challenge = arg
 		default:
 			_, file, line, _ := runtime.Caller(1)
 			return fmt.Errorf("errors.E: bad call from %s:%d: %v, unknown type %T, value %v in error call", file, line, args, arg, arg)
@@ -200,6 +217,12 @@ func E(args ...interface{}) error {
 		prev.Code = ""
 	}
 
+	// If this error has no Challenge, pull up the inner one.
+	if e.Challenge == (Challenge{}) {
+		e.Challenge = prev.Challenge
+		prev.Challenge = Challenge{}
+	}
+
 	if prev.Param == e.Param {
 		prev.Param = ""
 	}
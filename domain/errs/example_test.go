@@ -61,7 +61,7 @@ func ExampleHTTPErrorResponse() {
 	fmt.Println(w.Body)
 	// Output:
 	//
-	// {"level":"error","error":"Actual error message","http_statuscode":400,"Kind":"input_validation_error","Parameter":"testParam","Code":"0212","severity":"ERROR","message":"Response Error Sent"}
+	// {"level":"error","http_statuscode":400,"error":{"kind":"input_validation_error","code":"0212","param":"testParam","message":"Actual error message"},"severity":"ERROR","message":"Response Error Sent"}
 	// {"error":{"kind":"input_validation_error","code":"0212","param":"testParam","message":"Actual error message"}}
 }
 
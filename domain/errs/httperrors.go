@@ -22,6 +22,19 @@ type ServiceError struct {
 	Message string `json:"message,omitempty"`
 }
 
+// KindRecorder is implemented by http.ResponseWriters which record
+// the Kind of the error sent in the response, e.g. for metrics
+type KindRecorder interface {
+	RecordKind(k Kind)
+}
+
+// recordKind records the Kind with w, if w is a KindRecorder
+func recordKind(w http.ResponseWriter, k Kind) {
+	if kr, ok := w.(KindRecorder); ok {
+		kr.RecordKind(k)
+	}
+}
+
 // HTTPErrorResponse takes a writer, error and a logger, performs a
 // type switch to determine if the type is an Error (which meets
 // the Error interface as defined in this package), then sends the
@@ -42,11 +55,12 @@ func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error)
 		// the Error interface defined above), then
 		case *Error:
 			httpStatusCode = httpErrorStatusCode(e.Kind)
+			recordKind(w, e.Kind)
 			// We can retrieve the status here and write out a specific
 			// HTTP status code. If the error is empty, just
 			// send the HTTP Status Code as response
 			if e.isZero() {
-				logger.Error().Stack().Int("http_statuscode", httpStatusCode).Msg("empty error")
+				logger.Error().Int("http_statuscode", httpStatusCode).Msg("empty error")
 				sendError(w, "", httpStatusCode)
 			} else if e.Kind == Unauthenticated {
 				// For Unauthenticated and Unauthorized errors,
@@ -58,22 +72,26 @@ func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error)
 				// and a 403 Forbidden response should be used afterwards, when the user is
 				// authenticated but isn’t authorized to perform the requested operation on
 				// the given resource."
-				logger.Error().Stack().Err(e.Err).
+				//
+				// The WWW-Authenticate challenge lets the client
+				// tell a missing token from an invalid or expired one.
+				logger.Error().Err(e).
 					Int("http_statuscode", http.StatusUnauthorized).
+					Str("challenge", e.Challenge.Error).
 					Msg("Unauthenticated Request")
+				w.Header().Set("WWW-Authenticate", e.Challenge.wwwAuthenticate())
 				sendError(w, "", httpStatusCode)
 			} else if e.Kind == Unauthorized {
-				logger.Error().Stack().Err(e.Err).
+				logger.Error().Err(e).
 					Int("http_statuscode", http.StatusForbidden).
+					Str("challenge", e.Challenge.Error).
 					Msg("Unauthorized Request")
+				w.Header().Set("WWW-Authenticate", e.Challenge.wwwAuthenticate())
 				sendError(w, "", httpStatusCode)
 			} else {
-				// log the error with stacktrace
-				logger.Error().Stack().Err(e.Err).
-					Int("http_statuscode", httpStatusCode).
-					Str("Kind", e.Kind.String()).
-					Str("Parameter", string(e.Param)).
-					Str("Code", string(e.Code)).
+				// log the error chain with stacktrace, see
+				// Error.MarshalZerologObject
+				errorEvent(logger, httpStatusCode).Err(e).
 					Msg("Response Error Sent")
 
 				// setup ErrResponse
@@ -96,6 +114,7 @@ func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error)
 			// Any error types we don't specifically look out for default
 			// to serving a HTTP 500
 			cd := http.StatusInternalServerError
+			recordKind(w, Unanticipated)
 			er := ErrResponse{
 				Error: ServiceError{
 					Kind:    Unanticipated.String(),
@@ -104,7 +123,8 @@ func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error)
 				},
 			}
 
-			logger.Error().Msgf("Unknown Error - HTTP %d - %s", cd, err.Error())
+			errorEvent(logger, cd).Stack().Err(err).
+				Msgf("Unknown Error - HTTP %d - %s", cd, err.Error())
 
 			// Marshal errResponse struct to JSON for the response body
 			errJSON, _ := json.Marshal(er)
@@ -113,13 +133,25 @@ func HTTPErrorResponse(w http.ResponseWriter, logger zerolog.Logger, err error)
 		}
 	} else {
 		httpStatusCode = httpErrorStatusCode(Other)
+		recordKind(w, Other)
 		// if a nil error is passed, do not write a response body,
 		// just send the HTTP Status Code
-		logger.Error().Int("HTTP Error StatusCode", httpStatusCode).Msg("nil error - no response body sent")
+		logger.Error().Int("http_statuscode", httpStatusCode).Msg("nil error - no response body sent")
 		sendError(w, "", httpStatusCode)
 	}
 }
 
+// errorEvent starts the error level event for an error sent with the
+// given HTTP status code. Server errors (5xx) are flagged for Google
+// Cloud Error Reporting; client errors are the caller's and are not.
+func errorEvent(logger zerolog.Logger, httpStatusCode int) *zerolog.Event {
+	ev := logger.Error().Int("http_statuscode", httpStatusCode)
+	if httpStatusCode >= http.StatusInternalServerError {
+		ev = ev.Str(gcpTypeKey, GCPErrorReportingType)
+	}
+	return ev
+}
+
 // Taken from standard library, but changed to send application/json as header
 // Error replies to the request with the specified error message and HTTP code.
 // It does not otherwise end the request; the caller should ensure no further
@@ -130,11 +162,6 @@ func sendError(w http.ResponseWriter, errStr string, httpStatusCode int) {
 		w.Header().Set("Content-Type", "application/json")
 	}
 	w.Header().Set("X-Content-Type-Options", "nosniff")
-	// TODO - refactor this package to allow for WWW-Authenticate header on 401/403
-	//if httpStatusCode == 401 || httpStatusCode == 403 {
-	//	br := fmt.Sprintf(`Bearer realm="%s"`, realm)
-	//	w.Header().Set("WWW-Authenticate", br)
-	//}
 	w.WriteHeader(httpStatusCode)
 	// Only write response body if there is an error string populated
 	if errStr != "" {
@@ -151,6 +178,12 @@ func httpErrorStatusCode(k Kind) int {
 		return http.StatusForbidden
 	case Invalid, Exist, NotExist, Private, BrokenLink, Validation, InvalidRequest:
 		return http.StatusBadRequest
+	case RateLimited:
+		return http.StatusTooManyRequests
+	case Timeout:
+		return http.StatusGatewayTimeout
+	case Canceled:
+		return http.StatusServiceUnavailable
 	// the zero value of Kind is Other, so if no Kind is present
 	// in the error, Other is used. Errors should always have a
 	// Kind set, otherwise, a 500 will be returned and no
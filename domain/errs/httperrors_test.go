@@ -36,6 +36,9 @@ func Test_httpErrorStatusCode(t *testing.T) {
 		{"InvalidRequest", args{k: InvalidRequest}, http.StatusBadRequest},
 		{"InvalidRequest", args{k: InvalidRequest}, http.StatusBadRequest},
 		{"InvalidRequest", args{k: InvalidRequest}, http.StatusBadRequest},
+		{"RateLimited", args{k: RateLimited}, http.StatusTooManyRequests},
+		{"Timeout", args{k: Timeout}, http.StatusGatewayTimeout},
+		{"Canceled", args{k: Canceled}, http.StatusServiceUnavailable},
 		{"Other", args{k: Other}, http.StatusInternalServerError},
 		{"IO", args{k: IO}, http.StatusInternalServerError},
 		{"Internal", args{k: Internal}, http.StatusInternalServerError},
@@ -115,3 +118,74 @@ func TestHTTPErrorResponse_Body(t *testing.T) {
 		})
 	}
 }
+
+func TestHTTPErrorResponse_WWWAuthenticate(t *testing.T) {
+	var b bytes.Buffer
+	l := logger.NewLogger(&b, false)
+
+	tests := []struct {
+		name  string
+		realm string
+		err   error
+		want  string
+	}{
+		{"missing token", "", E(Unauthenticated, errors.New("no token")), `Bearer realm="go-api-basic"`},
+		{"invalid token", "", E(Unauthenticated, InvalidToken("The access token expired"), errors.New("expired")), `Bearer realm="go-api-basic", error="invalid_token", error_description="The access token expired"`},
+		{"wrapped", "", E(E(Unauthenticated, InvalidToken("The access token expired"), errors.New("expired"))), `Bearer realm="go-api-basic", error="invalid_token", error_description="The access token expired"`},
+		{"unauthorized", "", E(Unauthorized, errors.New("denied")), `Bearer realm="go-api-basic"`},
+		{"insufficient scope", "", E(Unauthorized, Challenge{Error: InsufficientScopeChallenge, Scope: "movies:write"}, errors.New("denied")), `Bearer realm="go-api-basic", error="insufficient_scope", scope="movies:write"`},
+		{"configured realm", "movies", E(Unauthenticated, errors.New("no token")), `Bearer realm="movies"`},
+		{"quotes removed", "", E(Unauthenticated, InvalidToken(`bad "token"`), errors.New("bad")), `Bearer realm="go-api-basic", error="invalid_token", error_description="bad token"`},
+		{"not sent for other errors", "", E(Validation, errors.New("bad input")), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetRealm(tt.realm)
+			t.Cleanup(func() { SetRealm("") })
+
+			w := httptest.NewRecorder()
+			HTTPErrorResponse(w, l, tt.err)
+			if got := w.Header().Get("WWW-Authenticate"); got != tt.want {
+				t.Errorf("WWW-Authenticate = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// kindRecorder is a ResponseRecorder which records the Kind
+type kindRecorder struct {
+	*httptest.ResponseRecorder
+	kind Kind
+	ok   bool
+}
+
+func (kr *kindRecorder) RecordKind(k Kind) {
+	kr.kind, kr.ok = k, true
+}
+
+func TestHTTPErrorResponse_RecordKind(t *testing.T) {
+	var b bytes.Buffer
+	l := logger.NewLogger(&b, false)
+
+	tests := []struct {
+		name string
+		err  error
+		want Kind
+	}{
+		{"error", E(Validation, errors.New("bad input")), Validation},
+		{"unauthenticated", E(Unauthenticated, errors.New("no token")), Unauthenticated},
+		{"unknown error", errors.New("boom"), Unanticipated},
+		{"nil error", nil, Other},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := &kindRecorder{ResponseRecorder: httptest.NewRecorder()}
+			HTTPErrorResponse(w, l, tt.err)
+			if !w.ok || w.kind != tt.want {
+				t.Errorf("recorded Kind = %v (%v), want %v", w.kind, w.ok, tt.want)
+			}
+		})
+	}
+}
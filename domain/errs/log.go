@@ -0,0 +1,75 @@
+package errs
+
+import (
+	"github.com/rs/zerolog"
+)
+
+// GCPErrorReportingType is the @type which makes Google Cloud Error
+// Reporting pick up a log entry as an error event, even if its
+// message holds no stack trace
+//   https://cloud.google.com/error-reporting/docs/formatting-error-messages
+const GCPErrorReportingType string = "type.googleapis.com/google.devtools.clouderrorreporting.v1beta1.ReportedErrorEvent"
+
+// gcpTypeKey is the log field GCPErrorReportingType is logged in
+const gcpTypeKey string = "@type"
+
+// MarshalZerologObject writes the Error as a log object with its kind,
+// code, param and message. If the underlying error is also an *Error,
+// it is written as the cause, so the whole chain built by nested calls
+// to E is logged. The stack trace of the original error is written
+// as the stack, if zerolog.ErrorStackMarshaler is set, e.g. with
+// logger.WriteErrorStackGlobal.
+func (e *Error) MarshalZerologObject(ev *zerolog.Event) {
+	e.marshalLevel(ev)
+
+	if zerolog.ErrorStackMarshaler == nil {
+		return
+	}
+	if st := zerolog.ErrorStackMarshaler(e.rootErr()); st != nil {
+		ev.Interface(zerolog.ErrorStackFieldName, st)
+	}
+}
+
+// marshalLevel writes the fields of e and its cause, without the
+// stack trace
+func (e *Error) marshalLevel(ev *zerolog.Event) {
+	ev.Str("kind", e.Kind.String())
+	if e.Code != "" {
+		ev.Str("code", string(e.Code))
+	}
+	if e.Param != "" {
+		ev.Str("param", string(e.Param))
+	}
+	if e.Err == nil {
+		return
+	}
+	ev.Str("message", e.Err.Error())
+
+	if cause, ok := e.Err.(*Error); ok {
+		ev.Object("cause", causeMarshaler{cause})
+	}
+}
+
+// rootErr returns the underlying error at the end of the chain of
+// *Error, which holds the stack trace added by E
+func (e *Error) rootErr() error {
+	err := e.Err
+	for {
+		inner, ok := err.(*Error)
+		if !ok {
+			return err
+		}
+		err = inner.Err
+	}
+}
+
+// causeMarshaler writes the cause of an Error without the stack
+// trace, which is written once for the whole chain
+type causeMarshaler struct {
+	e *Error
+}
+
+// MarshalZerologObject writes the cause as a log object
+func (c causeMarshaler) MarshalZerologObject(ev *zerolog.Event) {
+	c.e.marshalLevel(ev)
+}
@@ -0,0 +1,120 @@
+package errs
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+// logEntry logs err with the logger as an error event and returns
+// the decoded log entry
+func logEntry(t *testing.T, err error) map[string]interface{} {
+	t.Helper()
+
+	var b bytes.Buffer
+	l := zerolog.New(&b)
+	l.Error().Err(err).Msg("")
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(b.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestError_MarshalZerologObject(t *testing.T) {
+	t.Run("single", func(t *testing.T) {
+		c := qt.New(t)
+
+		err := E(Validation, Parameter("title"), Code("0212"), errors.New("title is required"))
+
+		c.Assert(logEntry(t, err)["error"], qt.DeepEquals, map[string]interface{}{
+			"kind":    "input_validation_error",
+			"code":    "0212",
+			"param":   "title",
+			"message": "title is required",
+		})
+	})
+
+	t.Run("chain", func(t *testing.T) {
+		c := qt.New(t)
+
+		// E pulls the Kind of the inner error up one level
+		inner := E(Database, Code("db_down"), errors.New("connection refused"))
+		err := E(Internal, Parameter("movie"), E(Code("movie_lookup"), inner))
+
+		c.Assert(logEntry(t, err)["error"], qt.DeepEquals, map[string]interface{}{
+			"kind":    "internal_error",
+			"code":    "movie_lookup",
+			"param":   "movie",
+			"message": "connection refused",
+			"cause": map[string]interface{}{
+				"kind":    "database_error",
+				"message": "connection refused",
+				"cause": map[string]interface{}{
+					"kind":    "other_error",
+					"code":    "db_down",
+					"message": "connection refused",
+				},
+			},
+		})
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		c := qt.New(t)
+
+		c.Assert(logEntry(t, &Error{})["error"], qt.DeepEquals, map[string]interface{}{
+			"kind": "other_error",
+		})
+	})
+
+	t.Run("stack", func(t *testing.T) {
+		c := qt.New(t)
+
+		logger.WriteErrorStackGlobal(true)
+		t.Cleanup(func() { logger.WriteErrorStackGlobal(false) })
+
+		err := E(Internal, E(Database, errors.New("connection refused")))
+
+		e, ok := logEntry(t, err)["error"].(map[string]interface{})
+		c.Assert(ok, qt.IsTrue)
+		st, ok := e[zerolog.ErrorStackFieldName].([]interface{})
+		c.Assert(ok, qt.IsTrue, qt.Commentf("stack: %v", e[zerolog.ErrorStackFieldName]))
+		c.Assert(len(st) > 0, qt.IsTrue)
+		cause, ok := e["cause"].(map[string]interface{})
+		c.Assert(ok, qt.IsTrue)
+		c.Assert(cause[zerolog.ErrorStackFieldName], qt.IsNil, qt.Commentf("stack written once"))
+	})
+}
+
+func TestHTTPErrorResponse_ErrorReportingType(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want interface{}
+	}{
+		{"server error", E(Database, errors.New("connection refused")), GCPErrorReportingType},
+		{"unknown error", errors.New("boom"), GCPErrorReportingType},
+		{"client error", E(Validation, errors.New("bad input")), nil},
+		{"unauthenticated", E(Unauthenticated, errors.New("no token")), nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			HTTPErrorResponse(httptest.NewRecorder(), logger.NewLogger(&b, false), tt.err)
+
+			var m map[string]interface{}
+			err := json.Unmarshal(b.Bytes(), &m)
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, m[gcpTypeKey], qt.Equals, tt.want)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package healthcheck
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// Drainer is a health check which fails once the server starts
+// shutting down, so load balancers and orchestrators stop sending
+// requests while the requests in flight finish
+type Drainer struct {
+	draining int32
+}
+
+// NewDrainer returns a Drainer which passes until Drain is called
+func NewDrainer() *Drainer {
+	return &Drainer{}
+}
+
+// Name returns the name of the check
+func (d *Drainer) Name() string {
+	return "shutdown"
+}
+
+// Drain makes the check fail from now on
+func (d *Drainer) Drain() {
+	atomic.StoreInt32(&d.draining, 1)
+}
+
+// Draining reports whether Drain was called
+func (d *Drainer) Draining() bool {
+	return atomic.LoadInt32(&d.draining) == 1
+}
+
+// CheckHealth returns an error once Drain was called
+func (d *Drainer) CheckHealth() error {
+	if d.Draining() {
+		return errors.New("server is shutting down")
+	}
+	return nil
+}
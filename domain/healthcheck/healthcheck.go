@@ -0,0 +1,200 @@
+// Package healthcheck runs the health checks of the dependencies of
+// the application and keeps the result of each. Checks are
+// gocloud.dev/server/health Checkers, so the same list is used for
+// the gocloud server's readiness endpoint.
+package healthcheck
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gocloud.dev/server/health"
+)
+
+// DefaultTimeout is how long a check may take if the Monitor has no
+// timeout set
+const DefaultTimeout time.Duration = 2 * time.Second
+
+// Namer is implemented by Checkers which name the dependency they
+// check, e.g. "database"
+type Namer interface {
+	Name() string
+}
+
+// Name returns the name of the Checker, or its type if it has none
+func Name(c health.Checker) string {
+	if n, ok := c.(Namer); ok {
+		return n.Name()
+	}
+	return fmt.Sprintf("%T", c)
+}
+
+// WithName returns c named name
+func WithName(name string, c health.Checker) health.Checker {
+	return namedChecker{name: name, Checker: c}
+}
+
+// namedChecker is a Checker with a name
+type namedChecker struct {
+	health.Checker
+	name string
+}
+
+// Name returns the name of the Checker
+func (c namedChecker) Name() string {
+	return c.name
+}
+
+// CheckerFunc is a func used as a Checker
+type CheckerFunc func() error
+
+// CheckHealth calls f
+func (f CheckerFunc) CheckHealth() error {
+	return f()
+}
+
+// Result is the result of a check
+type Result struct {
+	// Name is the name of the check
+	Name string
+	// Healthy is true if the check passed
+	Healthy bool
+	// Latency is how long the check took
+	Latency time.Duration
+	// CheckTime is when the check was run
+	CheckTime time.Time
+	// LastError is the error of the last failed check, kept after
+	// the check passes again. It is nil if the check never failed.
+	LastError error
+	// LastErrorTime is when the check last failed
+	LastErrorTime time.Time
+}
+
+// Monitor runs checks and keeps the last error of each
+type Monitor struct {
+	checkers []health.Checker
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	lastErr []failure
+}
+
+// failure is a failed check
+type failure struct {
+	err  error
+	time time.Time
+}
+
+// NewMonitor returns a Monitor running the given checks. A check
+// which takes longer than timeout fails; if timeout is zero,
+// DefaultTimeout is used.
+func NewMonitor(checkers []health.Checker, timeout time.Duration) *Monitor {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Monitor{checkers: checkers, timeout: timeout, lastErr: make([]failure, len(checkers))}
+}
+
+// Check runs all checks concurrently and returns their results in
+// the order the checks were given
+func (m *Monitor) Check() []Result {
+	results := make([]Result, len(m.checkers))
+
+	var wg sync.WaitGroup
+	for i, c := range m.checkers {
+		wg.Add(1)
+		go func(i int, c health.Checker) {
+			defer wg.Done()
+			results[i] = m.check(i, c)
+		}(i, c)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// check runs one check, giving up after the timeout, and records its
+// error, if any
+func (m *Monitor) check(i int, c health.Checker) Result {
+	res := Result{Name: Name(c), CheckTime: time.Now()}
+
+	// buffered, so the check can still finish after a timeout
+	done := make(chan error, 1)
+	go func() { done <- c.CheckHealth() }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-time.After(m.timeout):
+		err = errors.Errorf("check timed out after %s", m.timeout)
+	}
+	res.Latency = time.Since(res.CheckTime)
+	res.Healthy = err == nil
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err != nil {
+		m.lastErr[i] = failure{err: err, time: res.CheckTime}
+	}
+	res.LastError, res.LastErrorTime = m.lastErr[i].err, m.lastErr[i].time
+
+	return res
+}
+
+// Healthy reports whether all the results are healthy
+func Healthy(results []Result) bool {
+	for _, r := range results {
+		if !r.Healthy {
+			return false
+		}
+	}
+	return true
+}
+
+// DefaultCacheTTL is how long Cached reuses the result of a check if
+// no TTL is given
+const DefaultCacheTTL time.Duration = 10 * time.Second
+
+// Cached returns c with its result reused for ttl, so a check of a
+// remote dependency is not run on every probe. Concurrent calls
+// while the check runs wait for its result rather than running it
+// again. If ttl is zero, DefaultCacheTTL is used.
+func Cached(c health.Checker, ttl time.Duration) health.Checker {
+	if ttl <= 0 {
+		ttl = DefaultCacheTTL
+	}
+	return &cachedChecker{checker: c, name: Name(c), ttl: ttl}
+}
+
+// cachedChecker is a Checker reusing the result of its check
+type cachedChecker struct {
+	checker health.Checker
+	name    string
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	err     error
+	checked time.Time
+}
+
+// Name returns the name of the cached Checker
+func (c *cachedChecker) Name() string {
+	return c.name
+}
+
+// CheckHealth returns the result of the last check if it is more
+// recent than the TTL, otherwise it runs the check
+func (c *cachedChecker) CheckHealth() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.checked.IsZero() && time.Since(c.checked) < c.ttl {
+		return c.err
+	}
+	c.err = c.checker.CheckHealth()
+	c.checked = time.Now()
+
+	return c.err
+}
@@ -0,0 +1,97 @@
+package healthcheck
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+	"gocloud.dev/server/health"
+)
+
+func TestName(t *testing.T) {
+	qt.Assert(t, Name(WithName("database", CheckerFunc(func() error { return nil }))), qt.Equals, "database")
+	qt.Assert(t, Name(CheckerFunc(func() error { return nil })), qt.Equals, "healthcheck.CheckerFunc")
+}
+
+func TestMonitor_Check(t *testing.T) {
+	c := qt.New(t)
+
+	var dbErr error
+	m := NewMonitor([]health.Checker{
+		WithName("database", CheckerFunc(func() error { return dbErr })),
+		WithName("slow", CheckerFunc(func() error {
+			time.Sleep(200 * time.Millisecond)
+			return nil
+		})),
+	}, 50*time.Millisecond)
+
+	dbErr = errors.New("connection refused")
+	results := m.Check()
+	c.Assert(results, qt.HasLen, 2)
+	c.Assert(Healthy(results), qt.IsFalse)
+
+	c.Assert(results[0].Name, qt.Equals, "database")
+	c.Assert(results[0].Healthy, qt.IsFalse)
+	c.Assert(results[0].LastError, qt.ErrorMatches, "connection refused")
+	c.Assert(results[0].LastErrorTime, qt.Equals, results[0].CheckTime)
+
+	c.Assert(results[1].Name, qt.Equals, "slow")
+	c.Assert(results[1].Healthy, qt.IsFalse)
+	c.Assert(results[1].LastError, qt.ErrorMatches, "check timed out after 50ms")
+
+	// the last error is kept once the check passes again
+	dbErr = nil
+	failedAt := results[0].CheckTime
+	results = m.Check()
+	c.Assert(results[0].Healthy, qt.IsTrue)
+	c.Assert(results[0].LastError, qt.ErrorMatches, "connection refused")
+	c.Assert(results[0].LastErrorTime, qt.Equals, failedAt)
+}
+
+func TestHealthy(t *testing.T) {
+	qt.Assert(t, Healthy(nil), qt.IsTrue)
+	qt.Assert(t, Healthy([]Result{{Healthy: true}, {Healthy: true}}), qt.IsTrue)
+	qt.Assert(t, Healthy([]Result{{Healthy: true}, {Healthy: false}}), qt.IsFalse)
+}
+
+func TestDrainer(t *testing.T) {
+	c := qt.New(t)
+
+	d := NewDrainer()
+	c.Assert(Name(d), qt.Equals, "shutdown")
+	c.Assert(d.CheckHealth(), qt.IsNil)
+	c.Assert(d.Draining(), qt.IsFalse)
+
+	d.Drain()
+	c.Assert(d.Draining(), qt.IsTrue)
+	c.Assert(d.CheckHealth(), qt.ErrorMatches, "server is shutting down")
+}
+
+func TestCached(t *testing.T) {
+	c := qt.New(t)
+
+	var calls int32
+	upstream := WithName("google", CheckerFunc(func() error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("google is down")
+	}))
+
+	cached := Cached(upstream, 100*time.Millisecond)
+	c.Assert(Name(cached), qt.Equals, "google")
+
+	// repeated probes reuse the result of the first check
+	m := NewMonitor([]health.Checker{cached}, time.Second)
+	for i := 0; i < 5; i++ {
+		res := m.Check()
+		c.Assert(res[0].Healthy, qt.IsFalse)
+		c.Assert(res[0].LastError, qt.ErrorMatches, "google is down")
+	}
+	c.Assert(atomic.LoadInt32(&calls), qt.Equals, int32(1))
+
+	// the check is run again once the result is stale
+	time.Sleep(150 * time.Millisecond)
+	m.Check()
+	c.Assert(atomic.LoadInt32(&calls), qt.Equals, int32(2))
+}
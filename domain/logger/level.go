@@ -0,0 +1,171 @@
+package logger
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// LevelController holds the level of the request loggers, which can
+// be changed at runtime, optionally reverting to the default level
+// after a TTL
+type LevelController struct {
+	mu       sync.Mutex
+	def      zerolog.Level
+	level    zerolog.Level
+	revertAt time.Time
+	timer    *time.Timer
+}
+
+// LevelState is the state of a LevelController
+type LevelState struct {
+	// Level is the current level
+	Level zerolog.Level
+	// DefaultLevel is the level reverted to
+	DefaultLevel zerolog.Level
+	// RevertAt is when the current level reverts to the default
+	// level, zero if it does not
+	RevertAt time.Time
+}
+
+// NewLevelController returns a LevelController with the given
+// default level
+func NewLevelController(def zerolog.Level) *LevelController {
+	return &LevelController{def: def, level: def}
+}
+
+// Level returns the current level
+func (c *LevelController) Level() zerolog.Level {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.level
+}
+
+// State returns the current state
+func (c *LevelController) State() LevelState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.state()
+}
+
+// SetLevel sets the current level. If ttl is greater than zero, the
+// level reverts to the default level once ttl has passed; otherwise
+// the level becomes the new default level. Any pending revert is
+// cancelled.
+func (c *LevelController) SetLevel(lvl zerolog.Level, ttl time.Duration) LevelState {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.level = lvl
+	c.revertAt = time.Time{}
+
+	if ttl <= 0 {
+		c.def = lvl
+		return c.state()
+	}
+
+	c.revertAt = time.Now().Add(ttl)
+	var t *time.Timer
+	t = time.AfterFunc(ttl, func() {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		// the level may have been set again since
+		if c.timer != t {
+			return
+		}
+		c.level = c.def
+		c.revertAt = time.Time{}
+		c.timer = nil
+	})
+	c.timer = t
+
+	return c.state()
+}
+
+// state returns the current state, c.mu must be held
+func (c *LevelController) state() LevelState {
+	return LevelState{Level: c.level, DefaultLevel: c.def, RevertAt: c.revertAt}
+}
+
+// MaxDebugTokenTTL is the longest a debug token can be valid
+const MaxDebugTokenTTL time.Duration = time.Hour
+
+// DebugTokenSigner signs and verifies the debug tokens which turn on
+// debug logging for a single request. A token is the unix time it
+// expires at and an HMAC-SHA256 signature of it, separated by a dot.
+type DebugTokenSigner struct {
+	// Key is the HMAC key tokens are signed with
+	Key []byte
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+}
+
+// now returns the current time
+func (s DebugTokenSigner) now() time.Time {
+	if s.Now == nil {
+		return time.Now()
+	}
+	return s.Now()
+}
+
+// Sign returns a debug token valid for ttl, which must not be longer
+// than MaxDebugTokenTTL, along with the time it expires at
+func (s DebugTokenSigner) Sign(ttl time.Duration) (string, time.Time, error) {
+	if len(s.Key) == 0 {
+		return "", time.Time{}, errors.New("debug token key is not set")
+	}
+	if ttl <= 0 || ttl > MaxDebugTokenTTL {
+		return "", time.Time{}, errors.Errorf("debug token ttl must be between 0 and %s", MaxDebugTokenTTL)
+	}
+
+	exp := s.now().Add(ttl).Truncate(time.Second)
+	payload := strconv.FormatInt(exp.Unix(), 10)
+
+	return payload + "." + s.signature(payload), exp, nil
+}
+
+// Verify returns an error if the token was not signed with the key
+// or has expired
+func (s DebugTokenSigner) Verify(token string) error {
+	if len(s.Key) == 0 {
+		return errors.New("debug token key is not set")
+	}
+
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return errors.New("malformed debug token")
+	}
+	payload, sig := token[:i], token[i+1:]
+
+	if !hmac.Equal([]byte(sig), []byte(s.signature(payload))) {
+		return errors.New("invalid debug token signature")
+	}
+
+	exp, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return errors.New("malformed debug token")
+	}
+	if !s.now().Before(time.Unix(exp, 0)) {
+		return errors.New("debug token has expired")
+	}
+
+	return nil
+}
+
+// signature returns the base64url encoded signature of the payload
+func (s DebugTokenSigner) signature(payload string) string {
+	mac := hmac.New(sha256.New, s.Key)
+	mac.Write([]byte("debug-log:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog"
+)
+
+func TestLevelController_SetLevel(t *testing.T) {
+	t.Run("permanent", func(t *testing.T) {
+		c := qt.New(t)
+
+		lc := NewLevelController(zerolog.InfoLevel)
+		st := lc.SetLevel(zerolog.WarnLevel, 0)
+
+		c.Assert(st.Level, qt.Equals, zerolog.WarnLevel)
+		c.Assert(st.DefaultLevel, qt.Equals, zerolog.WarnLevel)
+		c.Assert(st.RevertAt.IsZero(), qt.IsTrue)
+		c.Assert(lc.Level(), qt.Equals, zerolog.WarnLevel)
+	})
+
+	t.Run("reverts after ttl", func(t *testing.T) {
+		c := qt.New(t)
+
+		lc := NewLevelController(zerolog.InfoLevel)
+		st := lc.SetLevel(zerolog.DebugLevel, 20*time.Millisecond)
+
+		c.Assert(st.Level, qt.Equals, zerolog.DebugLevel)
+		c.Assert(st.DefaultLevel, qt.Equals, zerolog.InfoLevel)
+		c.Assert(st.RevertAt.IsZero(), qt.IsFalse)
+
+		time.Sleep(100 * time.Millisecond)
+		c.Assert(lc.State(), qt.DeepEquals, LevelState{Level: zerolog.InfoLevel, DefaultLevel: zerolog.InfoLevel})
+	})
+
+	t.Run("set again cancels revert", func(t *testing.T) {
+		c := qt.New(t)
+
+		lc := NewLevelController(zerolog.InfoLevel)
+		lc.SetLevel(zerolog.DebugLevel, 20*time.Millisecond)
+		lc.SetLevel(zerolog.ErrorLevel, 0)
+
+		time.Sleep(100 * time.Millisecond)
+		c.Assert(lc.Level(), qt.Equals, zerolog.ErrorLevel)
+	})
+}
+
+func TestDebugTokenSigner(t *testing.T) {
+	now := time.Date(2021, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := DebugTokenSigner{Key: []byte("debug-key"), Now: func() time.Time { return now }}
+
+	token, exp, err := s.Sign(10 * time.Minute)
+	qt.Assert(t, err, qt.IsNil)
+	qt.Assert(t, exp, qt.Equals, now.Add(10*time.Minute))
+
+	tests := []struct {
+		name    string
+		signer  DebugTokenSigner
+		token   string
+		wantErr bool
+	}{
+		{"valid", s, token, false},
+		{"other key", DebugTokenSigner{Key: []byte("other"), Now: s.Now}, token, true},
+		{"expired", DebugTokenSigner{Key: s.Key, Now: func() time.Time { return exp }}, token, true},
+		{"tampered expiry", s, "9999999999" + token[len("1614600600"):], true},
+		{"malformed", s, "not-a-token", true},
+		{"no key", DebugTokenSigner{}, token, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.signer.Verify(tt.token)
+			qt.Assert(t, err != nil, qt.Equals, tt.wantErr, qt.Commentf("%v", err))
+		})
+	}
+
+	t.Run("ttl too long", func(t *testing.T) {
+		_, _, err := s.Sign(MaxDebugTokenTTL + time.Second)
+		qt.Assert(t, err, qt.Not(qt.IsNil))
+	})
+}
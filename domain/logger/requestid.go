@@ -0,0 +1,38 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/rs/zerolog/hlog"
+)
+
+// upstreamRequestIDKey is the context key for the upstream request ID
+type upstreamRequestIDKey struct{}
+
+// CtxWithUpstreamRequestID sets the request ID sent by an upstream
+// service or proxy to the given context
+func CtxWithUpstreamRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, upstreamRequestIDKey{}, id)
+}
+
+// UpstreamRequestIDFromCtx returns the upstream request ID set to the
+// context, if any
+func UpstreamRequestIDFromCtx(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(upstreamRequestIDKey{}).(string)
+	return id, ok
+}
+
+// RequestIDFromCtx returns the ID of the request the context belongs
+// to: the upstream request ID, if any, otherwise the request ID
+// generated by hlog. It is the ID returned to the caller and forwarded
+// to downstream services, so the ID stays the same across services.
+func RequestIDFromCtx(ctx context.Context) (string, bool) {
+	if id, ok := UpstreamRequestIDFromCtx(ctx); ok {
+		return id, true
+	}
+	id, ok := hlog.IDFromCtx(ctx)
+	if !ok {
+		return "", false
+	}
+	return id.String(), true
+}
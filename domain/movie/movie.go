@@ -43,7 +43,10 @@ func NewMovie(id uuid.UUID, extlID string, u user.User) (*Movie, error) {
 
 // Movie holds details of a movie
 type Movie struct {
-	ID         uuid.UUID
+	ID uuid.UUID
+	// OrgID is the ID of the organization (tenant) whose catalog
+	// the movie belongs to
+	OrgID      uuid.UUID
 	ExternalID string
 	Title      string
 	Rated      string
@@ -51,10 +54,15 @@ type Movie struct {
 	RunTime    int
 	Director   string
 	Writer     string
-	CreateUser user.User
-	CreateTime time.Time
-	UpdateUser user.User
-	UpdateTime time.Time
+	// CreateClientID and UpdateClientID are the IDs of the registered
+	// clients the movie was created and last updated through. They
+	// are uuid.Nil if no client was identified.
+	CreateClientID uuid.UUID
+	CreateUser     user.User
+	CreateTime     time.Time
+	UpdateClientID uuid.UUID
+	UpdateUser     user.User
+	UpdateTime     time.Time
 }
 
 // SetExternalID is a setter for a Movie External ID
@@ -106,6 +114,24 @@ func (m *Movie) SetWriter(w string) *Movie {
 	return m
 }
 
+// SetOrgID is a setter for a Movie organization ID
+func (m *Movie) SetOrgID(id uuid.UUID) *Movie {
+	m.OrgID = id
+	return m
+}
+
+// SetCreateClientID is a setter for a Movie create client ID
+func (m *Movie) SetCreateClientID(id uuid.UUID) *Movie {
+	m.CreateClientID = id
+	return m
+}
+
+// SetUpdateClientID is a setter for a Movie update client ID
+func (m *Movie) SetUpdateClientID(id uuid.UUID) *Movie {
+	m.UpdateClientID = id
+	return m
+}
+
 // SetUpdateUser is a setter for a Movie update user
 func (m *Movie) SetUpdateUser(u user.User) *Movie {
 	m.UpdateUser = u
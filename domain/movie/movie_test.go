@@ -222,6 +222,42 @@ func TestSetWriter(t *testing.T) {
 	}
 }
 
+func TestSetOrgID(t *testing.T) {
+	id := uuid.New()
+
+	gotMovie := newValidMovie()
+
+	gotMovie.SetOrgID(id)
+
+	if gotMovie.OrgID != id {
+		t.Errorf("\nWant: %v\nGot: %v\n\n", id, gotMovie.OrgID)
+	}
+}
+
+func TestSetCreateClientID(t *testing.T) {
+	id := uuid.New()
+
+	gotMovie := newValidMovie()
+
+	gotMovie.SetCreateClientID(id)
+
+	if gotMovie.CreateClientID != id {
+		t.Errorf("\nWant: %v\nGot: %v\n\n", id, gotMovie.CreateClientID)
+	}
+}
+
+func TestSetUpdateClientID(t *testing.T) {
+	id := uuid.New()
+
+	gotMovie := newValidMovie()
+
+	gotMovie.SetUpdateClientID(id)
+
+	if gotMovie.UpdateClientID != id {
+		t.Errorf("\nWant: %v\nGot: %v\n\n", id, gotMovie.UpdateClientID)
+	}
+}
+
 func TestSetUpdateUser(t *testing.T) {
 	gotMovie := newValidMovie()
 
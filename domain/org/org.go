@@ -0,0 +1,99 @@
+// Package org contains the business logic for the organizations
+// (tenants) whose movie catalogs are hosted by the API
+package org
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Org is an organization. Users belong to one or more organizations
+// and each movie belongs to exactly one.
+type Org struct {
+	ID         uuid.UUID
+	Name       string
+	CreateTime time.Time
+	UpdateTime time.Time
+}
+
+// NewOrg initializes an Org with the given name
+func NewOrg(name string) (*Org, error) {
+	now := time.Now().UTC()
+
+	o := &Org{
+		ID:         uuid.New(),
+		Name:       name,
+		CreateTime: now,
+		UpdateTime: now,
+	}
+
+	err := o.IsValid()
+	if err != nil {
+		return nil, err
+	}
+
+	return o, nil
+}
+
+// IsValid performs validation of the Org
+func (o *Org) IsValid() error {
+	if o.Name == "" {
+		return errs.E(errs.Validation, errs.Parameter("name"), errs.MissingField("name"))
+	}
+	return nil
+}
+
+// Finder finds the organizations a user belongs to
+type Finder interface {
+	FindAllByUserID(ctx context.Context, userID uuid.UUID) ([]*Org, error)
+}
+
+// Resolve returns the organization a request acts on (the tenant)
+// from the organizations the user belongs to. If requested is not
+// uuid.Nil, the user must belong to that organization. Otherwise,
+// the user must belong to exactly one organization.
+func Resolve(orgs []*Org, requested uuid.UUID) (Org, error) {
+	if requested != uuid.Nil {
+		for _, o := range orgs {
+			if o.ID == requested {
+				return *o, nil
+			}
+		}
+		return Org{}, errs.E(errs.Unauthorized, errors.New("user is not a member of the requested organization"))
+	}
+
+	switch len(orgs) {
+	case 0:
+		return Org{}, errs.E(errs.Unauthorized, errors.New("user does not belong to an organization"))
+	case 1:
+		return *orgs[0], nil
+	default:
+		return Org{}, errs.E(errs.Validation, errs.Parameter("X-Org-Id"), errors.New("X-Org-Id header is required for users belonging to more than one organization"))
+	}
+}
+
+type contextKey string
+
+const contextKeyOrg = contextKey("org")
+
+// CtxWithOrg sets the Org to the given context
+func CtxWithOrg(ctx context.Context, o Org) context.Context {
+	return context.WithValue(ctx, contextKeyOrg, o)
+}
+
+// FromRequest gets the Org from the request context. The Org is set
+// to the context by the handler tenant middleware, which runs before
+// any handler working with tenant data.
+func FromRequest(r *http.Request) (Org, error) {
+	o, ok := r.Context().Value(contextKeyOrg).(Org)
+	if !ok {
+		return o, errs.E(errs.Internal, errors.New("Org not set properly to context"))
+	}
+	return o, nil
+}
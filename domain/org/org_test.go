@@ -0,0 +1,68 @@
+package org
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestNewOrg(t *testing.T) {
+	c := qt.New(t)
+
+	got, err := NewOrg("Helping Hand Video")
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.ID, qt.Not(qt.Equals), uuid.Nil)
+
+	_, err = NewOrg("")
+	c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+}
+
+func TestResolve(t *testing.T) {
+	o1 := &Org{ID: uuid.New(), Name: "Helping Hand Video"}
+	o2 := &Org{ID: uuid.New(), Name: "Edge City Cinema"}
+
+	tests := []struct {
+		name      string
+		orgs      []*Org
+		requested uuid.UUID
+		want      uuid.UUID
+		wantKind  errs.Kind
+	}{
+		{"single org", []*Org{o1}, uuid.Nil, o1.ID, errs.Other},
+		{"requested org", []*Org{o1, o2}, o2.ID, o2.ID, errs.Other},
+		{"not a member", []*Org{o1}, o2.ID, uuid.Nil, errs.Unauthorized},
+		{"no orgs", nil, uuid.Nil, uuid.Nil, errs.Unauthorized},
+		{"ambiguous", []*Org{o1, o2}, uuid.Nil, uuid.Nil, errs.Validation},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			got, err := Resolve(tt.orgs, tt.requested)
+			if tt.wantKind != errs.Other {
+				c.Assert(errs.KindIs(tt.wantKind, err), qt.IsTrue)
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got.ID, qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestFromRequest(t *testing.T) {
+	c := qt.New(t)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	_, err := FromRequest(req)
+	c.Assert(errs.KindIs(errs.Internal, err), qt.IsTrue)
+
+	want := Org{ID: uuid.New(), Name: "Helping Hand Video"}
+	req = req.WithContext(CtxWithOrg(req.Context(), want))
+	got, err := FromRequest(req)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, want)
+}
@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often a MemoryStore drops its full buckets
+const sweepInterval time.Duration = time.Minute
+
+// MemoryStore is a Store keeping the buckets in memory. Each instance
+// of the server limits requests on its own, so the limits apply per
+// instance. The zero value is ready to use.
+type MemoryStore struct {
+	// Now returns the current time. If nil, time.Now is used.
+	Now func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// bucket is the token bucket of a key
+type bucket struct {
+	tokens float64
+	last   time.Time
+	limit  Limit
+}
+
+// NewMemoryStore returns an empty MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*bucket)}
+}
+
+// now returns the current time
+func (s *MemoryStore) now() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+// Take takes a token from the bucket of key, after refilling it for
+// the time since it was last taken from
+func (s *MemoryStore) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	now := s.now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets == nil {
+		s.buckets = make(map[string]*bucket)
+	}
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok || b.limit != l {
+		b = &bucket{tokens: float64(l.Requests), last: now, limit: l}
+		s.buckets[key] = b
+	}
+	b.refill(now)
+
+	res := Result{Limit: l.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsDuration((1 - b.tokens) / l.rate())
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = secondsDuration((float64(l.Requests) - b.tokens) / l.rate())
+
+	return res, nil
+}
+
+// refill adds the tokens accrued since the bucket was last refilled,
+// up to the limit
+func (b *bucket) refill(now time.Time) {
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens = math.Min(float64(b.limit.Requests), b.tokens+elapsed.Seconds()*b.limit.rate())
+		b.last = now
+	}
+}
+
+// sweep drops the buckets which have refilled since they were last
+// taken from, as a new bucket is full anyway. It runs at most once
+// every sweepInterval.
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.limit.Requests) {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// secondsDuration converts seconds to a Duration
+func secondsDuration(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
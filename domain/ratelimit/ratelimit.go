@@ -0,0 +1,80 @@
+// Package ratelimit limits the rate of requests with token buckets.
+// Buckets are kept in a Store, so they can be shared between
+// instances of the server by adding a Store backed by a shared cache.
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// Limit allows Requests requests every Per. The bucket holds up to
+// Requests tokens and is refilled evenly over Per, so bursts of up to
+// Requests requests are allowed.
+type Limit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// IsZero reports whether l is the zero Limit, which does not limit
+func (l Limit) IsZero() bool {
+	return l.Requests <= 0 || l.Per <= 0
+}
+
+// String returns the Limit as parsed by ParseLimit, e.g. "100/1m0s"
+func (l Limit) String() string {
+	return strconv.Itoa(l.Requests) + "/" + l.Per.String()
+}
+
+// rate returns the tokens added to the bucket per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// ParseLimit parses a Limit written as requests/duration, e.g.
+// "100/1m" for 100 requests a minute
+func ParseLimit(s string) (Limit, error) {
+	i := strings.Index(s, "/")
+	if i < 0 {
+		return Limit{}, errs.E(errs.Validation, errors.Errorf("rate limit %q is not requests/duration", s))
+	}
+
+	n, err := strconv.Atoi(s[:i])
+	if err != nil || n <= 0 {
+		return Limit{}, errs.E(errs.Validation, errors.Errorf("rate limit %q must allow a positive number of requests", s))
+	}
+	d, err := time.ParseDuration(s[i+1:])
+	if err != nil || d <= 0 {
+		return Limit{}, errs.E(errs.Validation, errors.Errorf("rate limit %q must have a positive duration", s))
+	}
+
+	return Limit{Requests: n, Per: d}, nil
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	// Allowed is true if a token was taken
+	Allowed bool
+	// Limit is the number of requests allowed every Limit.Per
+	Limit int
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until a token is available, if the
+	// request was not allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps the token buckets of the keys being limited
+type Store interface {
+	// Take takes a token from the bucket of key, which is limited
+	// by l, creating a full bucket if key has none
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
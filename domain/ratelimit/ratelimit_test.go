@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    Limit
+		wantErr bool
+	}{
+		{"per minute", "100/1m", Limit{Requests: 100, Per: time.Minute}, false},
+		{"per second", "5/1s", Limit{Requests: 5, Per: time.Second}, false},
+		{"no slash", "100", Limit{}, true},
+		{"zero requests", "0/1m", Limit{}, true},
+		{"bad duration", "10/minute", Limit{}, true},
+		{"zero duration", "10/0s", Limit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLimit(tt.s)
+			if tt.wantErr {
+				qt.Assert(t, errs.KindIs(errs.Validation, err), qt.IsTrue)
+				return
+			}
+			qt.Assert(t, err, qt.IsNil)
+			qt.Assert(t, got, qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	c := qt.New(t)
+	ctx := context.Background()
+
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStore()
+	s.Now = func() time.Time { return now }
+
+	l := Limit{Requests: 2, Per: 2 * time.Second}
+
+	// a new bucket is full
+	res, err := s.Take(ctx, "otto", l)
+	c.Assert(err, qt.IsNil)
+	c.Assert(res, qt.Equals, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second})
+
+	res, _ = s.Take(ctx, "otto", l)
+	c.Assert(res, qt.Equals, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second})
+
+	// the bucket is empty
+	res, _ = s.Take(ctx, "otto", l)
+	c.Assert(res, qt.Equals, Result{Allowed: false, Limit: 2, Remaining: 0, Reset: 2 * time.Second, RetryAfter: time.Second})
+
+	// other keys have their own bucket
+	res, _ = s.Take(ctx, "repo", l)
+	c.Assert(res.Allowed, qt.IsTrue)
+
+	// a token is added every second
+	now = now.Add(time.Second)
+	res, _ = s.Take(ctx, "otto", l)
+	c.Assert(res, qt.Equals, Result{Allowed: true, Limit: 2, Remaining: 0, Reset: 2 * time.Second})
+
+	// the bucket never holds more than the limit, and full buckets
+	// are swept
+	now = now.Add(time.Hour)
+	res, _ = s.Take(ctx, "otto", l)
+	c.Assert(res, qt.Equals, Result{Allowed: true, Limit: 2, Remaining: 1, Reset: time.Second})
+	c.Assert(s.buckets, qt.HasLen, 1)
+}
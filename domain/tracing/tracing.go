@@ -0,0 +1,185 @@
+// Package tracing has helpers to setup OpenTelemetry tracing
+//   https://opentelemetry.io/docs/instrumentation/go/
+package tracing
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// ServiceName is the name spans are exported with
+const ServiceName string = "go-api-basic"
+
+// Exporter is the name of a span exporter
+type Exporter string
+
+// Exporters spans can be exported with
+const (
+	// NoExporter does not export spans
+	NoExporter Exporter = "none"
+	// StdoutExporter writes spans as JSON
+	StdoutExporter Exporter = "stdout"
+	// OTLPExporter sends spans to an OpenTelemetry collector using
+	// OTLP over HTTP
+	OTLPExporter Exporter = "otlp"
+)
+
+// Config configures the TracerProvider
+type Config struct {
+	// Exporter is the span exporter. If empty, spans are not
+	// exported.
+	Exporter Exporter
+	// Endpoint is the host:port of the collector for the OTLP
+	// exporter. If empty, the OTEL_EXPORTER_OTLP_ENDPOINT environment
+	// variable or the OTLP default (localhost:4318) is used.
+	Endpoint string
+	// Insecure sends spans to the OTLP collector over plain HTTP
+	Insecure bool
+	// SampleRatio is the ratio of traces started by the server which
+	// are sampled, between 0 and 1. Traces started by a caller are
+	// sampled if the caller sampled them.
+	SampleRatio float64
+	// Writer is where the stdout exporter writes spans
+	Writer io.Writer
+}
+
+// NewTracerProvider returns a TracerProvider exporting spans with the
+// configured exporter and sampling with the configured ratio. The
+// TracerProvider must be shut down to flush the spans not yet
+// exported.
+func NewTracerProvider(ctx context.Context, cfg Config) (*sdktrace.TracerProvider, error) {
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, errs.E(errs.Validation, errs.Parameter("tracesample"), errors.Errorf("sample ratio %v is not between 0 and 1", cfg.SampleRatio))
+	}
+
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceNameKey.String(ServiceName))),
+	}
+
+	exp, err := newExporter(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if exp != nil {
+		opts = append(opts, sdktrace.WithBatcher(exp))
+	}
+
+	return sdktrace.NewTracerProvider(opts...), nil
+}
+
+// newExporter returns the configured span exporter, or nil if spans
+// are not exported
+func newExporter(ctx context.Context, cfg Config) (sdktrace.SpanExporter, error) {
+	switch cfg.Exporter {
+	case NoExporter, "":
+		return nil, nil
+	case StdoutExporter:
+		var opts []stdouttrace.Option
+		if cfg.Writer != nil {
+			opts = append(opts, stdouttrace.WithWriter(cfg.Writer))
+		}
+		exp, err := stdouttrace.New(opts...)
+		if err != nil {
+			return nil, errs.E(errs.Internal, err)
+		}
+		return exp, nil
+	case OTLPExporter:
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exp, err := otlptracehttp.New(ctx, opts...)
+		if err != nil {
+			return nil, errs.E(errs.Internal, err)
+		}
+		return exp, nil
+	default:
+		return nil, errs.E(errs.Validation, errs.Parameter("traceexporter"), errors.Errorf("unknown trace exporter %q", cfg.Exporter))
+	}
+}
+
+// NewPropagator returns the propagator for the W3C Trace Context
+// (traceparent and tracestate) and Baggage headers
+func NewPropagator() propagation.TextMapPropagator {
+	return propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{})
+}
+
+// EndSpan records err with the span, if not nil, and ends the span
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// CloudTraceContextHeader is the header Google Cloud load balancers
+// send the trace of a request in, formatted as
+// TRACE_ID/SPAN_ID;o=OPTIONS with a decimal span ID
+const CloudTraceContextHeader string = "X-Cloud-Trace-Context"
+
+// SpanContextFromHeader returns the span context sent in the W3C
+// traceparent header or, if not sent, the X-Cloud-Trace-Context
+// header. The span ID of the returned span context is not set if the
+// X-Cloud-Trace-Context header has none. ok is false if neither
+// header holds a valid trace ID.
+func SpanContextFromHeader(h http.Header) (sc trace.SpanContext, ok bool) {
+	ctx := propagation.TraceContext{}.Extract(context.Background(), propagation.HeaderCarrier(h))
+	if sc = trace.SpanContextFromContext(ctx); sc.IsValid() {
+		return sc, true
+	}
+	return parseCloudTraceContext(h.Get(CloudTraceContextHeader))
+}
+
+// parseCloudTraceContext parses the value of an X-Cloud-Trace-Context
+// header
+func parseCloudTraceContext(v string) (trace.SpanContext, bool) {
+	var cfg trace.SpanContextConfig
+
+	v, opts := splitOnce(v, ";")
+	tid, sid := splitOnce(v, "/")
+
+	traceID, err := trace.TraceIDFromHex(tid)
+	if err != nil {
+		return trace.SpanContext{}, false
+	}
+	cfg.TraceID = traceID
+
+	if n, err := strconv.ParseUint(sid, 10, 64); err == nil && n != 0 {
+		binary.BigEndian.PutUint64(cfg.SpanID[:], n)
+	}
+	if opts == "o=1" {
+		cfg.TraceFlags = trace.FlagsSampled
+	}
+
+	return trace.NewSpanContext(cfg), true
+}
+
+// splitOnce splits s around the first instance of sep
+func splitOnce(s, sep string) (string, string) {
+	i := strings.Index(s, sep)
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i+len(sep):]
+}
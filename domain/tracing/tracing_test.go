@@ -0,0 +1,109 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/pkg/errors"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+func TestNewTracerProvider(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("invalid sample ratio", func(t *testing.T) {
+		c := qt.New(t)
+		_, err := NewTracerProvider(ctx, Config{SampleRatio: 1.5})
+		c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+	})
+
+	t.Run("unknown exporter", func(t *testing.T) {
+		c := qt.New(t)
+		_, err := NewTracerProvider(ctx, Config{Exporter: "zipkin", SampleRatio: 1})
+		c.Assert(errs.KindIs(errs.Validation, err), qt.IsTrue)
+	})
+
+	t.Run("stdout exporter", func(t *testing.T) {
+		c := qt.New(t)
+		var b bytes.Buffer
+		tp, err := NewTracerProvider(ctx, Config{Exporter: StdoutExporter, SampleRatio: 1, Writer: &b})
+		c.Assert(err, qt.IsNil)
+
+		_, span := tp.Tracer("test").Start(ctx, "find_movie_by_id")
+		span.End()
+		c.Assert(tp.Shutdown(ctx), qt.IsNil)
+
+		c.Assert(strings.Contains(b.String(), `"Name":"find_movie_by_id"`), qt.IsTrue, qt.Commentf("%s", b.String()))
+		c.Assert(strings.Contains(b.String(), ServiceName), qt.IsTrue)
+	})
+
+	t.Run("not sampled", func(t *testing.T) {
+		c := qt.New(t)
+		tp, err := NewTracerProvider(ctx, Config{SampleRatio: 0})
+		c.Assert(err, qt.IsNil)
+
+		_, span := tp.Tracer("test").Start(ctx, "find_movie_by_id")
+		c.Assert(span.SpanContext().IsSampled(), qt.IsFalse)
+	})
+}
+
+func TestEndSpan(t *testing.T) {
+	c := qt.New(t)
+
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	ctx := context.Background()
+
+	_, ok := tp.Tracer("test").Start(ctx, "ok")
+	EndSpan(ok, nil)
+	_, failed := tp.Tracer("test").Start(ctx, "failed")
+	EndSpan(failed, errors.New("boom"))
+
+	spans := sr.Ended()
+	c.Assert(len(spans), qt.Equals, 2)
+	c.Assert(spans[0].Status().Code, qt.Equals, codes.Unset)
+	c.Assert(spans[1].Status().Code, qt.Equals, codes.Error)
+	c.Assert(len(spans[1].Events()), qt.Equals, 1)
+}
+
+func TestSpanContextFromHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		header      string
+		value       string
+		wantOK      bool
+		wantTraceID string
+		wantSpanID  string
+		wantSampled bool
+	}{
+		{"traceparent", "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7", true},
+		{"cloud trace context", CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000/255;o=1", true, "105445aa7843bc8bf206b12000100000", "00000000000000ff", true},
+		{"cloud trace context not sampled", CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000/255;o=0", true, "105445aa7843bc8bf206b12000100000", "00000000000000ff", false},
+		{"cloud trace context without span", CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000", true, "105445aa7843bc8bf206b12000100000", "0000000000000000", false},
+		{"malformed trace ID", CloudTraceContextHeader, "not-a-trace/1;o=1", false, "00000000000000000000000000000000", "0000000000000000", false},
+		{"none", "", "", false, "00000000000000000000000000000000", "0000000000000000", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			h := make(http.Header)
+			if tt.header != "" {
+				h.Set(tt.header, tt.value)
+			}
+			sc, ok := SpanContextFromHeader(h)
+			c.Assert(ok, qt.Equals, tt.wantOK)
+			c.Assert(sc.TraceID().String(), qt.Equals, tt.wantTraceID)
+			c.Assert(sc.SpanID().String(), qt.Equals, tt.wantSpanID)
+			c.Assert(sc.IsSampled(), qt.Equals, tt.wantSampled)
+		})
+	}
+}
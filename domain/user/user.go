@@ -1,8 +1,22 @@
 // Package user holds details about a person who is using the application
 package user
 
+import (
+	"context"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
 // User holds details of a User from Google
 type User struct {
+	// ID: The unique identifier of the user within the application.
+	// ID is only set once the user has been stored.
+	ID uuid.UUID `json:"-"`
+
 	// Email: The user's email address.
 	Email string `json:"email,omitempty"`
 
@@ -42,3 +56,23 @@ func (u User) IsValid() bool {
 	}
 	return true
 }
+
+type contextKey string
+
+const contextKeyUser = contextKey("user")
+
+// CtxWithUser sets the User to the given context
+func CtxWithUser(ctx context.Context, u User) context.Context {
+	return context.WithValue(ctx, contextKeyUser, u)
+}
+
+// FromRequest gets the User from the request context. The User is
+// set to the context by the handler authentication middleware, which
+// runs before any handler requiring a User.
+func FromRequest(r *http.Request) (User, error) {
+	u, ok := r.Context().Value(contextKeyUser).(User)
+	if !ok {
+		return u, errs.E(errs.Unauthenticated, errors.New("User not set properly to context"))
+	}
+	return u, nil
+}
@@ -1,6 +1,14 @@
 package user
 
-import "testing"
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
 
 func TestUser_IsValid(t *testing.T) {
 	type fields struct {
@@ -80,3 +88,19 @@ func TestUser_IsValid(t *testing.T) {
 		})
 	}
 }
+
+func TestFromRequest(t *testing.T) {
+	c := qt.New(t)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/movies", nil)
+
+	_, err := FromRequest(req)
+	c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+
+	want := User{Email: "otto.maddox711@gmail.com", FirstName: "Otto", LastName: "Maddox"}
+	req = req.WithContext(CtxWithUser(req.Context(), want))
+
+	got, err := FromRequest(req)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got, qt.Equals, want)
+}
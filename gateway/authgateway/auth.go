@@ -4,55 +4,172 @@ package authgateway
 
 import (
 	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
 
 	"github.com/gilcrest/go-api-basic/domain/auth"
 	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/tracing"
 	"github.com/gilcrest/go-api-basic/domain/user"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
 	"golang.org/x/oauth2"
+	"google.golang.org/api/googleapi"
 	googleoauth "google.golang.org/api/oauth2/v2"
 	"google.golang.org/api/option"
 )
 
+// tracer is the tracer for the spans of outbound calls
+var tracer = otel.Tracer("github.com/gilcrest/go-api-basic/gateway/authgateway")
+
 // GoogleAccessTokenConverter is used to convert an auth.AccessToken to a User
 // through Google's API
-type GoogleAccessTokenConverter struct{}
+type GoogleAccessTokenConverter struct {
+	// Endpoint is the base URL of the Google API, e.g. the URL of a
+	// fake server in tests. If empty, Google's own is used.
+	Endpoint string
+	// HTTPClient makes the calls to Google. If nil,
+	// http.DefaultClient is used.
+	HTTPClient *http.Client
+	// Scopes are the API scopes granted to every Google token. If
+	// nil, auth.DefaultUserScopes are granted.
+	Scopes auth.Scopes
+}
 
 // Convert calls the Google Userinfo API with the access token and converts
-// the Userinfo struct to a User struct
-func (c GoogleAccessTokenConverter) Convert(ctx context.Context, token auth.AccessToken) (user.User, error) {
-	ui, err := userInfo(ctx, token.NewGoogleOauth2Token())
+// the Userinfo struct to a User struct. The scopes of a Google token are
+// Google's own, not the API's, so Google tokens are granted the
+// configured Scopes. What the user can do is then decided by
+// authorization.
+func (c GoogleAccessTokenConverter) Convert(ctx context.Context, token auth.AccessToken) (user.User, auth.Scopes, error) {
+	ui, err := c.userInfo(ctx, token.NewGoogleOauth2Token())
 	if err != nil {
-		return user.User{}, err
+		return user.User{}, nil, err
 	}
 
-	return newUser(ui), nil
+	if c.Scopes == nil {
+		return newUser(ui), auth.DefaultUserScopes, nil
+	}
+	return newUser(ui), c.Scopes, nil
 }
 
 // userInfo makes an outbound https call to Google using their
 // Oauth2 v2 api and returns a Userinfo struct which has most
-// profile data elements you typically need
-func userInfo(ctx context.Context, token *oauth2.Token) (*googleoauth.Userinfo, error) {
+// profile data elements you typically need. The call is traced, and
+// the trace is propagated to Google.
+func (c GoogleAccessTokenConverter) userInfo(ctx context.Context, token *oauth2.Token) (ui *googleoauth.Userinfo, err error) {
+	ctx, span := tracer.Start(ctx, "google.userinfo", trace.WithSpanKind(trace.SpanKindClient))
+	defer func() { tracing.EndSpan(span, err) }()
 
-	oauthService, err := googleoauth.NewService(ctx, option.WithTokenSource(oauth2.StaticTokenSource(token)))
+	oauthService, err := googleoauth.NewService(ctx, c.clientOptions(token)...)
 	if err != nil {
 		return nil, errs.E(err)
 	}
 
-	userInfo, err := oauthService.Userinfo.Get().Do()
+	userInfo, err := oauthService.Userinfo.Get().Context(ctx).Do()
 	if err != nil {
+		// Google being unavailable says nothing about the token, so
+		// server errors and failed calls are reported as such
+		if gerr, ok := err.(*googleapi.Error); !ok || gerr.Code >= http.StatusInternalServerError {
+			return nil, errs.E(errs.IO, err)
+		}
 		// "In summary, a 401 Unauthorized response should be used for missing or
 		// bad authentication, and a 403 Forbidden response should be used afterwards,
 		// when the user is authenticated but isn’t authorized to perform the
 		// requested operation on the given resource."
 		// In this case, we are getting a bad response from Google service, assume
 		// they are not able to authenticate properly
-		return nil, errs.E(errs.Unauthenticated, err)
+		return nil, errs.E(errs.Unauthenticated, errs.InvalidToken("The access token is invalid or has expired"), err)
 	}
 
 	return userInfo, nil
 }
 
+// clientOptions returns the options for the Google API client. The
+// token is added to every request by wrapping the HTTP client's
+// transport, as option.WithHTTPClient overrides any token source.
+func (c GoogleAccessTokenConverter) clientOptions(token *oauth2.Token) []option.ClientOption {
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	opts := []option.ClientOption{
+		option.WithHTTPClient(&http.Client{
+			Transport: &oauth2.Transport{
+				Source: oauth2.StaticTokenSource(token),
+				Base:   hc.Transport,
+			},
+			CheckRedirect: hc.CheckRedirect,
+			Jar:           hc.Jar,
+			Timeout:       hc.Timeout,
+		}),
+	}
+	if c.Endpoint != "" {
+		opts = append(opts, option.WithEndpoint(c.Endpoint))
+	}
+
+	return opts
+}
+
+// googleEndpoint is the base URL of the Google API
+const googleEndpoint string = "https://www.googleapis.com/"
+
+// userinfoPath is the path of the userinfo API, relative to the
+// endpoint
+const userinfoPath string = "oauth2/v2/userinfo"
+
+// healthCheckTimeout is how long the call made by a health check may
+// take if the HTTP client has no timeout
+const healthCheckTimeout time.Duration = 2 * time.Second
+
+// Name returns the name of the health check
+func (c GoogleAccessTokenConverter) Name() string {
+	return "google"
+}
+
+// CheckHealth checks Google's userinfo API is reachable by calling it
+// without a token. Google answers such a call with 401, so any
+// response other than a server error means Google is up. The call is
+// cancelled after the timeout of the HTTP client.
+func (c GoogleAccessTokenConverter) CheckHealth() error {
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = googleEndpoint
+	}
+
+	timeout := hc.Timeout
+	if timeout <= 0 {
+		timeout = healthCheckTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/"+userinfoPath, nil)
+	if err != nil {
+		return errs.E(errs.Internal, err)
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return errs.E(errs.IO, err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errs.E(errs.IO, errors.Errorf("google userinfo API returned %s", resp.Status))
+	}
+
+	return nil
+}
+
 // newUser initializes the user.User struct given a Userinfo struct
 // from Google
 func newUser(userinfo *googleoauth.Userinfo) user.User {
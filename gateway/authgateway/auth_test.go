@@ -2,12 +2,17 @@ package authgateway
 
 import (
 	"context"
-	"os"
+	"net/http"
 	"reflect"
 	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
 
 	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
 	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/gateway/authgateway/authgatewaytest"
 	googleoauth "google.golang.org/api/oauth2/v2"
 )
 
@@ -53,11 +58,8 @@ func Test_newUser(t *testing.T) {
 }
 
 func TestGoogleToken2User_User(t *testing.T) {
-	// set environment variable NO_INT to skip integration
-	// dependent tests
-	if os.Getenv("SKIP_INT") == "true" {
-		t.Skip("skipping integration test")
-	}
+	srv := authgatewaytest.NewServer(t)
+	defer srv.Close()
 
 	type args struct {
 		ctx   context.Context
@@ -65,22 +67,15 @@ func TestGoogleToken2User_User(t *testing.T) {
 	}
 	ctx := context.Background()
 
-	// use the Google oauth2 playground https://developers.google.com/oauthplayground/
-	// to get a valid Access token to test this function
-	token, ok := os.LookupEnv("GOOGLE_ACCESS_TOKEN")
-	if !ok {
-		t.Fatalf("GOOGLE_ACCESS_TOKEN environment variable not properly set\nSet environment variable SKIP_INT = true to skip integration tests")
-	}
-
 	at := auth.AccessToken{
-		Token:     token,
+		Token:     authgatewaytest.Token,
 		TokenType: auth.BearerTokenType,
 	}
 	u := user.User{Email: "otto.maddox711@gmail.com",
 		LastName:   "Maddox",
 		FirstName:  "Otto",
 		FullName:   "Otto Maddox",
-		PictureURL: "https://lh3.googleusercontent.com/-RYXuWxjLdxo/AAAAAAAAAAI/AAAAAAAAAAA/AMZuucmr33m3QLTjdUatlkppT3NSN5-s8g/s96-c/photo.jpg",
+		PictureURL: "https://example.com/otto.jpg",
 	}
 	bt := auth.AccessToken{
 		Token:     "badToken",
@@ -98,8 +93,8 @@ func TestGoogleToken2User_User(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := GoogleAccessTokenConverter{}
-			got, err := c.Convert(tt.args.ctx, tt.args.token)
+			c := GoogleAccessTokenConverter{Endpoint: srv.Endpoint()}
+			got, _, err := c.Convert(tt.args.ctx, tt.args.token)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("User() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -110,3 +105,90 @@ func TestGoogleToken2User_User(t *testing.T) {
 		})
 	}
 }
+
+func TestGoogleAccessTokenConverter_Convert(t *testing.T) {
+	srv := authgatewaytest.NewServer(t)
+	defer srv.Close()
+
+	bob := &googleoauth.Userinfo{Email: "bob@example.com", Name: "Bob"}
+	srv.AddUser("bobs-token", bob)
+
+	tests := []struct {
+		name      string
+		token     string
+		status    int
+		latency   time.Duration
+		wantEmail string
+		wantKind  errs.Kind
+	}{
+		{"typical", authgatewaytest.Token, 0, 0, authgatewaytest.NewUserinfo().Email, errs.Other},
+		{"added user", "bobs-token", 0, 0, bob.Email, errs.Other},
+		{"unknown token", "badToken", 0, 0, "", errs.Unauthenticated},
+		{"google unavailable", authgatewaytest.Token, http.StatusServiceUnavailable, 0, "", errs.IO},
+		{"slow google", authgatewaytest.Token, 0, time.Second, "", errs.IO},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			srv.SetStatus(tt.status)
+			srv.SetLatency(tt.latency)
+
+			cv := GoogleAccessTokenConverter{
+				Endpoint:   srv.Endpoint(),
+				HTTPClient: &http.Client{Timeout: 100 * time.Millisecond},
+			}
+			got, scopes, err := cv.Convert(context.Background(), auth.AccessToken{Token: tt.token, TokenType: auth.BearerTokenType})
+			if tt.wantKind != errs.Other {
+				c.Assert(errs.KindIs(tt.wantKind, err), qt.IsTrue, qt.Commentf("got %v", err))
+				return
+			}
+			c.Assert(err, qt.IsNil)
+			c.Assert(got.Email, qt.Equals, tt.wantEmail)
+			c.Assert(scopes, qt.DeepEquals, auth.DefaultUserScopes)
+			c.Assert(scopes.Has(auth.AdminScope), qt.IsFalse)
+		})
+	}
+
+	t.Run("configured scopes", func(t *testing.T) {
+		srv.SetStatus(0)
+		srv.SetLatency(0)
+
+		cv := GoogleAccessTokenConverter{Endpoint: srv.Endpoint(), Scopes: auth.Scopes{auth.MoviesReadScope}}
+		_, scopes, err := cv.Convert(context.Background(), auth.AccessToken{Token: authgatewaytest.Token, TokenType: auth.BearerTokenType})
+		qt.Assert(t, err, qt.IsNil)
+		qt.Assert(t, scopes, qt.DeepEquals, auth.Scopes{auth.MoviesReadScope})
+	})
+}
+
+func TestGoogleAccessTokenConverter_CheckHealth(t *testing.T) {
+	srv := authgatewaytest.NewServer(t)
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{"reachable", 0, false},
+		{"client error", http.StatusTooManyRequests, false},
+		{"google unavailable", http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv.SetStatus(tt.status)
+
+			cv := GoogleAccessTokenConverter{Endpoint: srv.Endpoint()}
+			err := cv.CheckHealth()
+			qt.Assert(t, err != nil, qt.Equals, tt.wantErr, qt.Commentf("got %v", err))
+			if tt.wantErr {
+				qt.Assert(t, errs.KindIs(errs.IO, err), qt.IsTrue)
+			}
+		})
+	}
+
+	t.Run("unreachable", func(t *testing.T) {
+		cv := GoogleAccessTokenConverter{Endpoint: "http://127.0.0.1:1/"}
+		qt.Assert(t, errs.KindIs(errs.IO, cv.CheckHealth()), qt.IsTrue)
+	})
+}
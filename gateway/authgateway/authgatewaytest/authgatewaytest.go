@@ -0,0 +1,126 @@
+// Package authgatewaytest provides a fake Google userinfo server for
+// testing authgateway without calling Google
+package authgatewaytest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	googleoauth "google.golang.org/api/oauth2/v2"
+)
+
+// userinfoPath is the path of the userinfo API, relative to the
+// endpoint
+const userinfoPath string = "/oauth2/v2/userinfo"
+
+// Token is the access token of the user added by NewServer
+const Token string = "ya29.fake-google-access-token"
+
+// NewUserinfo returns the Userinfo of the user added by NewServer
+func NewUserinfo() *googleoauth.Userinfo {
+	return &googleoauth.Userinfo{
+		Email:      "otto.maddox711@gmail.com",
+		FamilyName: "Maddox",
+		GivenName:  "Otto",
+		Name:       "Otto Maddox",
+		Picture:    "https://example.com/otto.jpg",
+	}
+}
+
+// Server is a fake Google userinfo server. Users are looked up by
+// the Bearer token of the request; an unknown token gets a 401, as
+// from Google. Every request can instead be made to fail with a
+// status or to be delayed.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	users   map[string]*googleoauth.Userinfo
+	status  int
+	latency time.Duration
+}
+
+// NewServer starts a Server with the user from NewUserinfo for
+// Token. The caller must Close the Server when done.
+func NewServer(t *testing.T) *Server {
+	t.Helper()
+
+	s := &Server{users: map[string]*googleoauth.Userinfo{Token: NewUserinfo()}}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.userinfo))
+
+	return s
+}
+
+// Endpoint returns the base URL to configure the Google API client
+// with
+func (s *Server) Endpoint() string {
+	return s.URL + "/"
+}
+
+// AddUser adds a user for the given access token
+func (s *Server) AddUser(token string, ui *googleoauth.Userinfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[token] = ui
+}
+
+// SetStatus makes every request fail with the given HTTP status.
+// A status of zero resets the server to look up users.
+func (s *Server) SetStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+// SetLatency delays every response by d, or until the request is
+// cancelled
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latency = d
+}
+
+// userinfo handles requests for the userinfo API
+func (s *Server) userinfo(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	status, latency := s.status, s.latency
+	ui, ok := s.users[strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")]
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case r.URL.Path != userinfoPath:
+		writeError(w, http.StatusNotFound, "Not Found")
+	case status != 0:
+		writeError(w, status, http.StatusText(status))
+	case !ok:
+		writeError(w, http.StatusUnauthorized, "Request is missing required authentication credential.")
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(ui)
+	}
+}
+
+// writeError writes an error response in the format of the Google APIs
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    status,
+			"message": message,
+		},
+	})
+}
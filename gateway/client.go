@@ -0,0 +1,295 @@
+package gateway
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+// requestIDHeader is the header the inbound request ID is forwarded
+// in, matching the header it is returned in by the handlers
+const requestIDHeader string = "Request-Id"
+
+// Defaults used for the zero values of Destination fields
+const (
+	DefaultTimeout          time.Duration = 10 * time.Second
+	DefaultRetries          int           = 2
+	DefaultBaseBackoff      time.Duration = 100 * time.Millisecond
+	DefaultMaxBackoff       time.Duration = 2 * time.Second
+	DefaultBreakerThreshold int           = 5
+	DefaultBreakerCooldown  time.Duration = 30 * time.Second
+)
+
+// Destination configures outbound calls to one external system
+type Destination struct {
+	// Name identifies the destination in logs and errors
+	Name string
+	// Timeout is the timeout for each attempt of a call
+	Timeout time.Duration
+	// Retries is the number of times a failed idempotent call is
+	// retried. A negative number disables retries.
+	Retries int
+	// BaseBackoff and MaxBackoff bound the jittered exponential
+	// backoff between retries
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// BreakerThreshold is the number of consecutive failed calls
+	// after which the circuit breaker opens
+	BreakerThreshold int
+	// BreakerCooldown is how long the circuit breaker stays open
+	// before a trial call is let through
+	BreakerCooldown time.Duration
+}
+
+// withDefaults returns the Destination with defaults set for zero
+// values
+func (d Destination) withDefaults() Destination {
+	if d.Timeout == 0 {
+		d.Timeout = DefaultTimeout
+	}
+	if d.Retries == 0 {
+		d.Retries = DefaultRetries
+	}
+	if d.Retries < 0 {
+		d.Retries = 0
+	}
+	if d.BaseBackoff == 0 {
+		d.BaseBackoff = DefaultBaseBackoff
+	}
+	if d.MaxBackoff == 0 {
+		d.MaxBackoff = DefaultMaxBackoff
+	}
+	if d.BreakerThreshold == 0 {
+		d.BreakerThreshold = DefaultBreakerThreshold
+	}
+	if d.BreakerCooldown == 0 {
+		d.BreakerCooldown = DefaultBreakerCooldown
+	}
+	return d
+}
+
+// NewClient returns an *http.Client for calls to the destination.
+// Each attempt of a call has the destination timeout. Idempotent
+// calls which fail with a network error or a 429 or 5xx response are
+// retried with jittered exponential backoff. After BreakerThreshold
+// consecutive failed calls, calls fail fast with an errs.IO error
+// until BreakerCooldown has passed. Every attempt is logged with the
+// logger from the request context, which carries the inbound request
+// ID, and the request ID is forwarded in the Request-Id header along
+// with the trace in the W3C traceparent header.
+func NewClient(d Destination) *http.Client {
+	return &http.Client{Transport: NewTransport(d, nil)}
+}
+
+// NewTransport returns the http.RoundTripper used by NewClient,
+// making the calls with base. If base is nil,
+// http.DefaultTransport is used.
+func NewTransport(d Destination, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{dest: d.withDefaults(), base: base}
+}
+
+// transport is a resilient http.RoundTripper for one destination
+type transport struct {
+	dest Destination
+	base http.RoundTripper
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+	trial     bool
+}
+
+// RoundTrip makes the call, retrying failed attempts of idempotent
+// calls, unless the circuit breaker is open
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	if !t.allow() {
+		return nil, errs.E(errs.IO, errors.Errorf("circuit breaker open for %s", t.dest.Name))
+	}
+
+	attempts := 1
+	if isIdempotent(req) {
+		attempts += t.dest.Retries
+	}
+
+	var (
+		resp *http.Response
+		err  error
+	)
+	for attempt := 1; ; attempt++ {
+		resp, err = t.attempt(req, attempt)
+		if attempt == attempts || !retryable(resp, err) || ctx.Err() != nil {
+			break
+		}
+		if resp != nil {
+			// discard the failed response before retrying
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			resp = nil
+		}
+		if err = t.backoff(ctx, attempt); err != nil {
+			break
+		}
+	}
+
+	t.record(ctx.Err() != nil, retryable(resp, err))
+
+	if err != nil {
+		return nil, errs.E(errs.IO, errors.Wrapf(err, "call to %s failed", t.dest.Name))
+	}
+	return resp, nil
+}
+
+// attempt makes a single attempt of the call with the destination
+// timeout and logs it
+func (t *transport) attempt(req *http.Request, attempt int) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.dest.Timeout)
+
+	r := req.Clone(ctx)
+	if attempt > 1 && req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r.Body = body
+	}
+	if id, ok := logger.RequestIDFromCtx(ctx); ok {
+		r.Header.Set(requestIDHeader, id)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(r.Header))
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(r)
+
+	lgr := zerolog.Ctx(req.Context())
+	var e *zerolog.Event
+	if err != nil {
+		e = lgr.Warn().Err(err)
+	} else {
+		e = lgr.Info().Int("status", resp.StatusCode)
+	}
+	e.Str("destination", t.dest.Name).
+		Str("method", req.Method).
+		Str("host", req.URL.Host).
+		Str("path", req.URL.Path).
+		Int("attempt", attempt).
+		Dur("duration", time.Since(start)).
+		Msg("outbound call")
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	// the attempt timeout must cover reading the body, so it is
+	// cancelled when the body is closed
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+
+	return resp, nil
+}
+
+// backoff waits for the jittered backoff before the given retry
+// (starting at 1), or until the context is done
+func (t *transport) backoff(ctx context.Context, retry int) error {
+	ceiling := t.dest.BaseBackoff << uint(retry-1)
+	if ceiling <= 0 || ceiling > t.dest.MaxBackoff {
+		ceiling = t.dest.MaxBackoff
+	}
+	d := time.Duration(rand.Int63n(int64(ceiling) + 1))
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// allow reports whether a call may be made. While the breaker is
+// open, calls are refused until the cooldown has passed, after which
+// a single trial call is let through.
+func (t *transport) allow() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.failures < t.dest.BreakerThreshold {
+		return true
+	}
+	if t.trial || time.Now().Before(t.openUntil) {
+		return false
+	}
+	t.trial = true
+	return true
+}
+
+// record records the outcome of a call. A success closes the
+// breaker, while a failure opens it once the threshold is reached.
+// Calls cancelled by the caller are not counted either way.
+func (t *transport) record(cancelled, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.trial = false
+	if cancelled {
+		return
+	}
+	if !failed {
+		t.failures = 0
+		return
+	}
+	t.failures++
+	if t.failures >= t.dest.BreakerThreshold {
+		t.openUntil = time.Now().Add(t.dest.BreakerCooldown)
+	}
+}
+
+// isIdempotent reports whether the request can safely be retried
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	default:
+		return false
+	}
+}
+
+// retryable reports whether an attempt failed in a way worth
+// retrying: a network error, a timeout or a 429 or 5xx response
+func retryable(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// cancelBody cancels the attempt context when the body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the attempt context
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
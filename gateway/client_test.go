@@ -0,0 +1,222 @@
+package gateway
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/rs/zerolog/hlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/tracing"
+)
+
+// failingServer responds with status to the first failures requests
+// and with 200 OK afterwards, counting the requests it receives
+func failingServer(failures int32, status int) (*httptest.Server, *int32) {
+	var calls int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	return srv, &calls
+}
+
+// testDestination returns a Destination with short backoffs
+func testDestination() Destination {
+	return Destination{
+		Name:        "test",
+		Timeout:     time.Second,
+		BaseBackoff: time.Millisecond,
+		MaxBackoff:  5 * time.Millisecond,
+	}
+}
+
+func TestClient_Retry(t *testing.T) {
+	tests := []struct {
+		name      string
+		method    string
+		failures  int32
+		status    int
+		wantCode  int
+		wantCalls int32
+	}{
+		{"retried until success", http.MethodGet, 2, http.StatusServiceUnavailable, http.StatusOK, 3},
+		{"retries exhausted", http.MethodGet, 5, http.StatusBadGateway, http.StatusBadGateway, 3},
+		{"client error not retried", http.MethodGet, 1, http.StatusUnauthorized, http.StatusUnauthorized, 1},
+		{"POST not retried", http.MethodPost, 1, http.StatusServiceUnavailable, http.StatusServiceUnavailable, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			srv, calls := failingServer(tt.failures, tt.status)
+			defer srv.Close()
+
+			req, err := http.NewRequest(tt.method, srv.URL, strings.NewReader("{}"))
+			c.Assert(err, qt.IsNil)
+			if tt.method == http.MethodGet {
+				req.Body, req.GetBody = http.NoBody, nil
+			}
+
+			resp, err := NewClient(testDestination()).Do(req)
+			c.Assert(err, qt.IsNil)
+			resp.Body.Close()
+
+			c.Assert(resp.StatusCode, qt.Equals, tt.wantCode)
+			c.Assert(atomic.LoadInt32(calls), qt.Equals, tt.wantCalls)
+		})
+	}
+}
+
+func TestClient_Timeout(t *testing.T) {
+	c := qt.New(t)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(time.Second):
+		case <-r.Context().Done():
+		}
+	}))
+	defer srv.Close()
+
+	d := testDestination()
+	d.Timeout = 20 * time.Millisecond
+	d.Retries = -1
+
+	tr := NewTransport(d, nil)
+	req := httptest.NewRequest(http.MethodGet, srv.URL, nil)
+	req.RequestURI = ""
+	_, err := tr.RoundTrip(req)
+	c.Assert(errs.KindIs(errs.IO, err), qt.IsTrue)
+}
+
+func TestClient_CircuitBreaker(t *testing.T) {
+	c := qt.New(t)
+
+	srv, calls := failingServer(100, http.StatusInternalServerError)
+	defer srv.Close()
+
+	d := testDestination()
+	d.Retries = -1
+	d.BreakerThreshold = 2
+	d.BreakerCooldown = 50 * time.Millisecond
+	tr := NewTransport(d, nil)
+
+	roundTrip := func() error {
+		req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+		c.Assert(err, qt.IsNil)
+		resp, err := tr.RoundTrip(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	// the failed calls are returned to the caller until the
+	// threshold is reached
+	c.Assert(roundTrip(), qt.IsNil)
+	c.Assert(roundTrip(), qt.IsNil)
+
+	// then the breaker is open and calls fail fast
+	err := roundTrip()
+	c.Assert(errs.KindIs(errs.IO, err), qt.IsTrue)
+	c.Assert(atomic.LoadInt32(calls), qt.Equals, int32(2))
+
+	// after the cooldown, a trial call is let through
+	time.Sleep(d.BreakerCooldown)
+	c.Assert(roundTrip(), qt.IsNil)
+	c.Assert(atomic.LoadInt32(calls), qt.Equals, int32(3))
+}
+
+func TestClient_RequestID(t *testing.T) {
+	c := qt.New(t)
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(requestIDHeader)
+	}))
+	defer srv.Close()
+
+	// make the outbound call while handling an inbound request
+	var want string
+	inbound := hlog.RequestIDHandler("request_id", requestIDHeader)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, _ := hlog.IDFromRequest(r)
+		want = id.String()
+
+		req, err := http.NewRequestWithContext(r.Context(), http.MethodGet, srv.URL, nil)
+		c.Assert(err, qt.IsNil)
+
+		resp, err := NewClient(testDestination()).Do(req)
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+	}))
+	inbound.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	c.Assert(want, qt.Not(qt.Equals), "")
+	c.Assert(got, qt.Equals, want)
+}
+
+func TestClient_UpstreamRequestID(t *testing.T) {
+	c := qt.New(t)
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(requestIDHeader)
+	}))
+	defer srv.Close()
+
+	// the request ID propagated from upstream is forwarded rather
+	// than the one generated for the inbound request
+	inbound := hlog.RequestIDHandler("request_id", "")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := logger.CtxWithUpstreamRequestID(r.Context(), "upstream-123")
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		c.Assert(err, qt.IsNil)
+
+		resp, err := NewClient(testDestination()).Do(req)
+		c.Assert(err, qt.IsNil)
+		resp.Body.Close()
+	}))
+	inbound.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	c.Assert(got, qt.Equals, "upstream-123")
+}
+
+func TestClient_TraceContext(t *testing.T) {
+	c := qt.New(t)
+
+	otel.SetTextMapPropagator(tracing.NewPropagator())
+
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get("traceparent")
+	}))
+	defer srv.Close()
+
+	traceID, _ := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	spanID, _ := trace.SpanIDFromHex("00f067aa0ba902b7")
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	}))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	c.Assert(err, qt.IsNil)
+	resp, err := NewClient(testDestination()).Do(req)
+	c.Assert(err, qt.IsNil)
+	resp.Body.Close()
+
+	c.Assert(got, qt.Equals, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+}
@@ -0,0 +1,738 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/datastore/authstore"
+	"github.com/gilcrest/go-api-basic/datastore/clientstore"
+	"github.com/gilcrest/go-api-basic/datastore/orgstore"
+	"github.com/gilcrest/go-api-basic/datastore/userstore"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// DefaultAdminHandlers are the default handlers for managing
+// users, roles, role assignments, API keys and clients. Each method
+// on the struct is a separate handler.
+type DefaultAdminHandlers struct {
+	RandomStringGenerator random.StringGenerator
+	UserTransactor        userstore.Transactor
+	UserSelector          userstore.Selector
+	AuthTransactor        authstore.Transactor
+	AuthSelector          authstore.Selector
+	ClientTransactor      clientstore.Transactor
+	ClientSelector        clientstore.Selector
+	OrgTransactor         orgstore.Transactor
+	OrgSelector           orgstore.Selector
+}
+
+// adminUserRequestBody is the request body to create or update a user
+type adminUserRequestBody struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	FullName  string `json:"full_name"`
+}
+
+// adminUserResponse is the response struct for a user
+type adminUserResponse struct {
+	ID        string `json:"user_id"`
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	FullName  string `json:"full_name"`
+}
+
+// newAdminUserResponse initializes an adminUserResponse from a User
+func newAdminUserResponse(u user.User) adminUserResponse {
+	return adminUserResponse{
+		ID:        u.ID.String(),
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		FullName:  u.FullName,
+	}
+}
+
+// permissionBody is the request and response struct for a role permission
+type permissionBody struct {
+	Effect string `json:"effect"`
+	Path   string `json:"path"`
+	Method string `json:"method"`
+}
+
+// adminRoleRequestBody is the request body to create or update a role
+type adminRoleRequestBody struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Permissions []permissionBody `json:"permissions"`
+}
+
+// adminRoleResponse is the response struct for a role
+type adminRoleResponse struct {
+	ID          string           `json:"role_id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Permissions []permissionBody `json:"permissions"`
+}
+
+// newAdminRoleResponse initializes an adminRoleResponse from a Role
+func newAdminRoleResponse(r *auth.Role) adminRoleResponse {
+	perms := make([]permissionBody, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		perms = append(perms, permissionBody{Effect: string(p.Effect), Path: p.Path, Method: p.Method})
+	}
+	return adminRoleResponse{
+		ID:          r.ID.String(),
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: perms,
+	}
+}
+
+// newRole initializes a Role from the request body
+func newRole(id uuid.UUID, rb *adminRoleRequestBody) *auth.Role {
+	r := &auth.Role{ID: id, Name: rb.Name, Description: rb.Description}
+	for _, p := range rb.Permissions {
+		r.Permissions = append(r.Permissions, auth.Permission{Effect: auth.Effect(p.Effect), Path: p.Path, Method: p.Method})
+	}
+	return r
+}
+
+// adminRoleAssignmentRequestBody is the request body to assign a role to a user
+type adminRoleAssignmentRequestBody struct {
+	RoleID string `json:"role_id"`
+	UserID string `json:"user_id"`
+}
+
+// adminRoleAssignmentResponse is the response struct for a role assignment
+type adminRoleAssignmentResponse struct {
+	RoleID   string `json:"role_id"`
+	RoleName string `json:"role_name"`
+	UserID   string `json:"user_id"`
+	Email    string `json:"email"`
+}
+
+// newAdminRoleAssignmentResponse initializes an adminRoleAssignmentResponse
+// from a RoleAssignment
+func newAdminRoleAssignmentResponse(ra auth.RoleAssignment) adminRoleAssignmentResponse {
+	return adminRoleAssignmentResponse{
+		RoleID:   ra.Role.ID.String(),
+		RoleName: ra.Role.Name,
+		UserID:   ra.User.ID.String(),
+		Email:    ra.User.Email,
+	}
+}
+
+// pathID parses the uuid path variable with the given name
+func pathID(r *http.Request, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(mux.Vars(r)[name])
+	if err != nil {
+		return uuid.Nil, errs.E(errs.Validation, errs.Parameter(name), err)
+	}
+	return id, nil
+}
+
+// bodyID parses a uuid given in a request body field
+func bodyID(s string, name string) (uuid.UUID, error) {
+	if s == "" {
+		return uuid.Nil, errs.E(errs.Validation, errs.Parameter(name), errs.MissingField(name))
+	}
+	id, err := uuid.Parse(s)
+	if err != nil {
+		return uuid.Nil, errs.E(errs.Validation, errs.Parameter(name), err)
+	}
+	return id, nil
+}
+
+// validateUser performs validation of a user maintained
+// through the admin API or by the user themselves
+func validateUser(u user.User) error {
+	switch {
+	case u.Email == "":
+		return errs.E(errs.Validation, errs.Parameter("email"), errs.MissingField("email"))
+	case u.FirstName == "":
+		return errs.E(errs.Validation, errs.Parameter("first_name"), errs.MissingField("first_name"))
+	case u.LastName == "":
+		return errs.E(errs.Validation, errs.Parameter("last_name"), errs.MissingField("last_name"))
+	}
+	return nil
+}
+
+// writeResponse populates a StandardResponse with the data and
+// encodes it to JSON for the response body
+func writeResponse(w http.ResponseWriter, r *http.Request, d interface{}) {
+	logger := *hlog.FromRequest(r)
+
+	response, err := NewStandardResponse(r, d)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Internal, err))
+		return
+	}
+}
+
+// FindAllUsersHandler is a Handler that returns all users
+type FindAllUsersHandler http.Handler
+
+// ProvideFindAllUsersHandler is a provider for the
+// FindAllUsersHandler for wire
+func ProvideFindAllUsersHandler(h DefaultAdminHandlers) FindAllUsersHandler {
+	return http.HandlerFunc(h.FindAllUsers)
+}
+
+// FindAllUsers handles GET requests for the /admin/users endpoint
+func (h DefaultAdminHandlers) FindAllUsers(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	users, err := h.UserSelector.FindAll(r.Context())
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	ur := make([]adminUserResponse, 0, len(users))
+	for _, u := range users {
+		ur = append(ur, newAdminUserResponse(u))
+	}
+
+	writeResponse(w, r, ur)
+}
+
+// CreateUserHandler is a Handler that creates a user
+type CreateUserHandler http.Handler
+
+// ProvideCreateUserHandler is a provider for the
+// CreateUserHandler for wire
+func ProvideCreateUserHandler(h DefaultAdminHandlers) CreateUserHandler {
+	return http.HandlerFunc(h.CreateUser)
+}
+
+// CreateUser handles POST requests for the /admin/users endpoint
+func (h DefaultAdminHandlers) CreateUser(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminUserRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	u := user.User{
+		ID:        uuid.New(),
+		Email:     rb.Email,
+		FirstName: rb.FirstName,
+		LastName:  rb.LastName,
+		FullName:  rb.FullName,
+	}
+
+	err = validateUser(u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.UserTransactor.Create(r.Context(), u, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminUserResponse(u))
+}
+
+// FindUserByIDHandler is a Handler that finds a user by ID
+type FindUserByIDHandler http.Handler
+
+// ProvideFindUserByIDHandler is a provider for the
+// FindUserByIDHandler for wire
+func ProvideFindUserByIDHandler(h DefaultAdminHandlers) FindUserByIDHandler {
+	return http.HandlerFunc(h.FindUserByID)
+}
+
+// FindUserByID handles GET requests for the /admin/users/{userID} endpoint
+func (h DefaultAdminHandlers) FindUserByID(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	id, err := pathID(r, "userID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	u, err := h.UserSelector.FindByID(r.Context(), id)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminUserResponse(u))
+}
+
+// UpdateUserHandler is a Handler that updates a user
+type UpdateUserHandler http.Handler
+
+// ProvideUpdateUserHandler is a provider for the
+// UpdateUserHandler for wire
+func ProvideUpdateUserHandler(h DefaultAdminHandlers) UpdateUserHandler {
+	return http.HandlerFunc(h.UpdateUser)
+}
+
+// UpdateUser handles PUT requests for the /admin/users/{userID} endpoint
+func (h DefaultAdminHandlers) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	id, err := pathID(r, "userID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminUserRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	u := user.User{
+		ID:        id,
+		Email:     rb.Email,
+		FirstName: rb.FirstName,
+		LastName:  rb.LastName,
+		FullName:  rb.FullName,
+	}
+
+	err = validateUser(u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.UserTransactor.Update(r.Context(), u, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminUserResponse(u))
+}
+
+// DeleteUserHandler is a Handler that deletes a user
+type DeleteUserHandler http.Handler
+
+// ProvideDeleteUserHandler is a provider for the
+// DeleteUserHandler for wire
+func ProvideDeleteUserHandler(h DefaultAdminHandlers) DeleteUserHandler {
+	return http.HandlerFunc(h.DeleteUser)
+}
+
+// DeleteUser handles DELETE requests for the /admin/users/{userID} endpoint
+func (h DefaultAdminHandlers) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	type deleteUserResponse struct {
+		ID      string `json:"user_id"`
+		Deleted bool   `json:"deleted"`
+	}
+
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	id, err := pathID(r, "userID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// find the user first so the deleted user is
+	// recorded in the audit trail
+	u, err := h.UserSelector.FindByID(ctx, id)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.UserTransactor.Delete(ctx, u, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, deleteUserResponse{ID: u.ID.String(), Deleted: true})
+}
+
+// FindAllRolesHandler is a Handler that returns all roles
+type FindAllRolesHandler http.Handler
+
+// ProvideFindAllRolesHandler is a provider for the
+// FindAllRolesHandler for wire
+func ProvideFindAllRolesHandler(h DefaultAdminHandlers) FindAllRolesHandler {
+	return http.HandlerFunc(h.FindAllRoles)
+}
+
+// FindAllRoles handles GET requests for the /admin/roles endpoint
+func (h DefaultAdminHandlers) FindAllRoles(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	roles, err := h.AuthSelector.FindAllRoles(r.Context())
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rr := make([]adminRoleResponse, 0, len(roles))
+	for _, role := range roles {
+		rr = append(rr, newAdminRoleResponse(role))
+	}
+
+	writeResponse(w, r, rr)
+}
+
+// CreateRoleHandler is a Handler that creates a role
+type CreateRoleHandler http.Handler
+
+// ProvideCreateRoleHandler is a provider for the
+// CreateRoleHandler for wire
+func ProvideCreateRoleHandler(h DefaultAdminHandlers) CreateRoleHandler {
+	return http.HandlerFunc(h.CreateRole)
+}
+
+// CreateRole handles POST requests for the /admin/roles endpoint
+func (h DefaultAdminHandlers) CreateRole(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminRoleRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	role := newRole(uuid.New(), rb)
+
+	err = role.IsValid()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.AuthTransactor.CreateRole(r.Context(), role, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminRoleResponse(role))
+}
+
+// FindRoleByIDHandler is a Handler that finds a role by ID
+type FindRoleByIDHandler http.Handler
+
+// ProvideFindRoleByIDHandler is a provider for the
+// FindRoleByIDHandler for wire
+func ProvideFindRoleByIDHandler(h DefaultAdminHandlers) FindRoleByIDHandler {
+	return http.HandlerFunc(h.FindRoleByID)
+}
+
+// FindRoleByID handles GET requests for the /admin/roles/{roleID} endpoint
+func (h DefaultAdminHandlers) FindRoleByID(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	id, err := pathID(r, "roleID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	role, err := h.AuthSelector.FindRoleByID(r.Context(), id)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminRoleResponse(role))
+}
+
+// UpdateRoleHandler is a Handler that updates a role
+type UpdateRoleHandler http.Handler
+
+// ProvideUpdateRoleHandler is a provider for the
+// UpdateRoleHandler for wire
+func ProvideUpdateRoleHandler(h DefaultAdminHandlers) UpdateRoleHandler {
+	return http.HandlerFunc(h.UpdateRole)
+}
+
+// UpdateRole handles PUT requests for the /admin/roles/{roleID} endpoint.
+// The role permissions are replaced with the permissions in the request.
+func (h DefaultAdminHandlers) UpdateRole(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	id, err := pathID(r, "roleID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminRoleRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	role := newRole(id, rb)
+
+	err = role.IsValid()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.AuthTransactor.UpdateRole(r.Context(), role, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminRoleResponse(role))
+}
+
+// DeleteRoleHandler is a Handler that deletes a role
+type DeleteRoleHandler http.Handler
+
+// ProvideDeleteRoleHandler is a provider for the
+// DeleteRoleHandler for wire
+func ProvideDeleteRoleHandler(h DefaultAdminHandlers) DeleteRoleHandler {
+	return http.HandlerFunc(h.DeleteRole)
+}
+
+// DeleteRole handles DELETE requests for the /admin/roles/{roleID} endpoint
+func (h DefaultAdminHandlers) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	type deleteRoleResponse struct {
+		ID      string `json:"role_id"`
+		Deleted bool   `json:"deleted"`
+	}
+
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	id, err := pathID(r, "roleID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	// find the role first so the deleted role (and its
+	// permissions) is recorded in the audit trail
+	role, err := h.AuthSelector.FindRoleByID(ctx, id)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.AuthTransactor.DeleteRole(ctx, role, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, deleteRoleResponse{ID: role.ID.String(), Deleted: true})
+}
+
+// FindAllRoleAssignmentsHandler is a Handler that returns all role assignments
+type FindAllRoleAssignmentsHandler http.Handler
+
+// ProvideFindAllRoleAssignmentsHandler is a provider for the
+// FindAllRoleAssignmentsHandler for wire
+func ProvideFindAllRoleAssignmentsHandler(h DefaultAdminHandlers) FindAllRoleAssignmentsHandler {
+	return http.HandlerFunc(h.FindAllRoleAssignments)
+}
+
+// FindAllRoleAssignments handles GET requests for the
+// /admin/role-assignments endpoint
+func (h DefaultAdminHandlers) FindAllRoleAssignments(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	assignments, err := h.AuthSelector.FindAllRoleAssignments(r.Context())
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rar := make([]adminRoleAssignmentResponse, 0, len(assignments))
+	for _, ra := range assignments {
+		rar = append(rar, newAdminRoleAssignmentResponse(ra))
+	}
+
+	writeResponse(w, r, rar)
+}
+
+// CreateRoleAssignmentHandler is a Handler that assigns a role to a user
+type CreateRoleAssignmentHandler http.Handler
+
+// ProvideCreateRoleAssignmentHandler is a provider for the
+// CreateRoleAssignmentHandler for wire
+func ProvideCreateRoleAssignmentHandler(h DefaultAdminHandlers) CreateRoleAssignmentHandler {
+	return http.HandlerFunc(h.CreateRoleAssignment)
+}
+
+// CreateRoleAssignment handles POST requests for the
+// /admin/role-assignments endpoint
+func (h DefaultAdminHandlers) CreateRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminRoleAssignmentRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	roleID, err := bodyID(rb.RoleID, "role_id")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	userID, err := bodyID(rb.UserID, "user_id")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	role, err := h.AuthSelector.FindRoleByID(ctx, roleID)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Parameter("role_id"), err))
+		return
+	}
+
+	u, err := h.UserSelector.FindByID(ctx, userID)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Parameter("user_id"), err))
+		return
+	}
+
+	ra := auth.RoleAssignment{Role: *role, User: u}
+
+	err = h.AuthTransactor.CreateRoleAssignment(ctx, ra, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminRoleAssignmentResponse(ra))
+}
+
+// DeleteRoleAssignmentHandler is a Handler that removes a role from a user
+type DeleteRoleAssignmentHandler http.Handler
+
+// ProvideDeleteRoleAssignmentHandler is a provider for the
+// DeleteRoleAssignmentHandler for wire
+func ProvideDeleteRoleAssignmentHandler(h DefaultAdminHandlers) DeleteRoleAssignmentHandler {
+	return http.HandlerFunc(h.DeleteRoleAssignment)
+}
+
+// DeleteRoleAssignment handles DELETE requests for the
+// /admin/role-assignments/{roleID}/{userID} endpoint
+func (h DefaultAdminHandlers) DeleteRoleAssignment(w http.ResponseWriter, r *http.Request) {
+	type deleteRoleAssignmentResponse struct {
+		RoleID  string `json:"role_id"`
+		UserID  string `json:"user_id"`
+		Deleted bool   `json:"deleted"`
+	}
+
+	logger := *hlog.FromRequest(r)
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	roleID, err := pathID(r, "roleID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	userID, err := pathID(r, "userID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	ra := auth.RoleAssignment{Role: auth.Role{ID: roleID}, User: user.User{ID: userID}}
+
+	err = h.AuthTransactor.DeleteRoleAssignment(r.Context(), ra, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, deleteRoleAssignmentResponse{RoleID: roleID.String(), UserID: userID.String(), Deleted: true})
+}
@@ -0,0 +1,249 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/go-cmp/cmp/cmpopts"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/gilcrest/go-api-basic/datastore/authstore/authstoretest"
+	"github.com/gilcrest/go-api-basic/datastore/clientstore/clientstoretest"
+	"github.com/gilcrest/go-api-basic/datastore/orgstore/orgstoretest"
+	"github.com/gilcrest/go-api-basic/datastore/userstore/userstoretest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/random"
+)
+
+// newMockAdminHandlers initializes DefaultAdminHandlers with mocks
+func newMockAdminHandlers(t *testing.T) DefaultAdminHandlers {
+	t.Helper()
+
+	return DefaultAdminHandlers{
+		RandomStringGenerator: random.DefaultStringGenerator{},
+		UserTransactor:        userstoretest.NewMockTransactor(t),
+		UserSelector:          userstoretest.NewMockSelector(t),
+		AuthTransactor:        authstoretest.NewMockTransactor(t),
+		AuthSelector:          authstoretest.NewMockSelector(t),
+		ClientTransactor:      clientstoretest.NewMockTransactor(t),
+		ClientSelector:        clientstoretest.NewMockSelector(t),
+		OrgTransactor:         orgstoretest.NewMockTransactor(t),
+		OrgSelector:           orgstoretest.NewMockSelector(t),
+	}
+}
+
+// serveAdmin sends the request through the standard admin handler
+// chain and a mux router registered at the route path template
+func serveAdmin(t *testing.T, route string, h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+	mw := Middleware{
+		AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+		Authorizer:           authtest.NewMockAuthorizer(t),
+		UserSelector:         userstoretest.NewMockSelector(t),
+		UserTransactor:       userstoretest.NewMockTransactor(t),
+		ClientFinder:         clientstoretest.NewMockSelector(t),
+	}
+
+	chain := authHandlerChain(mw, LoggerHandlerChain(lgr, alice.New())).Then(h)
+
+	rr := httptest.NewRecorder()
+	router := mux.NewRouter()
+	router.Handle(route, chain)
+	router.ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestDefaultAdminHandlers_CreateUser(t *testing.T) {
+	path := pathPrefix + adminV1PathRoot + "/users"
+
+	t.Run("typical", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(adminUserRequestBody{
+			Email:     "repo.man@example.com",
+			FirstName: "Bud",
+			LastName:  "Repo",
+			FullName:  "Bud Repo",
+		})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateUserHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		type standardResponse struct {
+			Path string            `json:"path"`
+			Data adminUserResponse `json:"data"`
+		}
+		var got standardResponse
+		err = json.NewDecoder(rr.Body).Decode(&got)
+		c.Assert(err, qt.IsNil)
+
+		want := standardResponse{
+			Path: path,
+			Data: adminUserResponse{
+				Email:     "repo.man@example.com",
+				FirstName: "Bud",
+				LastName:  "Repo",
+				FullName:  "Bud Repo",
+			},
+		}
+		c.Assert(got, qt.CmpEquals(cmpopts.IgnoreFields(standardResponse{}, "Data.ID")), want)
+		c.Assert(got.Data.ID, qt.Not(qt.Equals), "")
+	})
+
+	t.Run("missing email", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(adminUserRequestBody{FirstName: "Bud", LastName: "Repo"})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateUserHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+	})
+}
+
+func TestDefaultAdminHandlers_FindUserByID(t *testing.T) {
+	route := pathPrefix + adminV1PathRoot + "/users/{userID}"
+
+	t.Run("typical", func(t *testing.T) {
+		c := qt.New(t)
+
+		path := pathPrefix + adminV1PathRoot + "/users/" + userstoretest.UserID.String()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := serveAdmin(t, route, ProvideFindUserByIDHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		type standardResponse struct {
+			Data adminUserResponse `json:"data"`
+		}
+		var got standardResponse
+		err := json.NewDecoder(rr.Body).Decode(&got)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.Data.ID, qt.Equals, userstoretest.UserID.String())
+	})
+
+	t.Run("invalid id", func(t *testing.T) {
+		c := qt.New(t)
+
+		path := pathPrefix + adminV1PathRoot + "/users/not-a-uuid"
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		rr := serveAdmin(t, route, ProvideFindUserByIDHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+	})
+}
+
+func TestDefaultAdminHandlers_CreateRole(t *testing.T) {
+	path := pathPrefix + adminV1PathRoot + "/roles"
+
+	t.Run("typical", func(t *testing.T) {
+		c := qt.New(t)
+
+		rb := adminRoleRequestBody{
+			Name:        "movie-reader",
+			Description: "Read movies",
+			Permissions: []permissionBody{{Effect: "allow", Path: "/api/v1/movies/**", Method: http.MethodGet}},
+		}
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(rb)
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateRoleHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		type standardResponse struct {
+			Data adminRoleResponse `json:"data"`
+		}
+		var got standardResponse
+		err = json.NewDecoder(rr.Body).Decode(&got)
+		c.Assert(err, qt.IsNil)
+
+		want := adminRoleResponse{Name: rb.Name, Description: rb.Description, Permissions: rb.Permissions}
+		c.Assert(got.Data, qt.CmpEquals(cmpopts.IgnoreFields(adminRoleResponse{}, "ID")), want)
+	})
+
+	t.Run("invalid effect", func(t *testing.T) {
+		c := qt.New(t)
+
+		rb := adminRoleRequestBody{
+			Name:        "movie-reader",
+			Permissions: []permissionBody{{Effect: "perhaps", Path: "/api/v1/movies/**", Method: http.MethodGet}},
+		}
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(rb)
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateRoleHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+	})
+}
+
+func TestDefaultAdminHandlers_CreateRoleAssignment(t *testing.T) {
+	c := qt.New(t)
+
+	path := pathPrefix + adminV1PathRoot + "/role-assignments"
+
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(adminRoleAssignmentRequestBody{
+		RoleID: authstoretest.RoleID.String(),
+		UserID: userstoretest.UserID.String(),
+	})
+	c.Assert(err, qt.IsNil)
+
+	req := httptest.NewRequest(http.MethodPost, path, &buf)
+	rr := serveAdmin(t, path, ProvideCreateRoleAssignmentHandler(newMockAdminHandlers(t)), req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	type standardResponse struct {
+		Data adminRoleAssignmentResponse `json:"data"`
+	}
+	var got standardResponse
+	err = json.NewDecoder(rr.Body).Decode(&got)
+	c.Assert(err, qt.IsNil)
+
+	want := adminRoleAssignmentResponse{
+		RoleID:   authstoretest.RoleID.String(),
+		RoleName: "movie-admin",
+		UserID:   userstoretest.UserID.String(),
+		Email:    "otto.maddox711@gmail.com",
+	}
+	c.Assert(got.Data, qt.Equals, want)
+}
+
+func TestDefaultAdminHandlers_DeleteRoleAssignment(t *testing.T) {
+	c := qt.New(t)
+
+	route := pathPrefix + adminV1PathRoot + "/role-assignments/{roleID}/{userID}"
+	path := pathPrefix + adminV1PathRoot + "/role-assignments/" + authstoretest.RoleID.String() + "/" + userstoretest.UserID.String()
+
+	req := httptest.NewRequest(http.MethodDelete, path, nil)
+	rr := serveAdmin(t, route, ProvideDeleteRoleAssignmentHandler(newMockAdminHandlers(t)), req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+}
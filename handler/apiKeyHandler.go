@@ -0,0 +1,198 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// adminAPIKeyRequestBody is the request body to issue an API key
+type adminAPIKeyRequestBody struct {
+	Name           string   `json:"name"`
+	ServiceAccount string   `json:"service_account"`
+	Scopes         []string `json:"scopes"`
+}
+
+// adminAPIKeyResponse is the response struct for an API key. Key is
+// only populated when the key is issued.
+type adminAPIKeyResponse struct {
+	ID              string   `json:"api_key_id"`
+	Name            string   `json:"name"`
+	Prefix          string   `json:"prefix"`
+	ServiceAccount  string   `json:"service_account"`
+	Scopes          []string `json:"scopes"`
+	CreateTimestamp string   `json:"create_timestamp"`
+	RevokeTimestamp string   `json:"revoke_timestamp,omitempty"`
+	Key             string   `json:"key,omitempty"`
+}
+
+// newAdminAPIKeyResponse initializes an adminAPIKeyResponse from an APIKey
+func newAdminAPIKeyResponse(k *auth.APIKey) adminAPIKeyResponse {
+	akr := adminAPIKeyResponse{
+		ID:              k.ID.String(),
+		Name:            k.Name,
+		Prefix:          k.Prefix,
+		ServiceAccount:  k.User.Email,
+		Scopes:          k.Scopes,
+		CreateTimestamp: k.CreateTime.Format(time.RFC3339),
+	}
+	if k.IsRevoked() {
+		akr.RevokeTimestamp = k.RevokeTime.Format(time.RFC3339)
+	}
+	return akr
+}
+
+// FindAllAPIKeysHandler is a Handler that returns all API keys
+type FindAllAPIKeysHandler http.Handler
+
+// ProvideFindAllAPIKeysHandler is a provider for the
+// FindAllAPIKeysHandler for wire
+func ProvideFindAllAPIKeysHandler(h DefaultAdminHandlers) FindAllAPIKeysHandler {
+	return http.HandlerFunc(h.FindAllAPIKeys)
+}
+
+// FindAllAPIKeys handles GET requests for the /admin/api-keys endpoint
+func (h DefaultAdminHandlers) FindAllAPIKeys(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	keys, err := h.AuthSelector.FindAllAPIKeys(r.Context())
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	akr := make([]adminAPIKeyResponse, 0, len(keys))
+	for _, k := range keys {
+		akr = append(akr, newAdminAPIKeyResponse(k))
+	}
+
+	writeResponse(w, r, akr)
+}
+
+// CreateAPIKeyHandler is a Handler that issues an API key
+type CreateAPIKeyHandler http.Handler
+
+// ProvideCreateAPIKeyHandler is a provider for the
+// CreateAPIKeyHandler for wire
+func ProvideCreateAPIKeyHandler(h DefaultAdminHandlers) CreateAPIKeyHandler {
+	return http.HandlerFunc(h.CreateAPIKey)
+}
+
+// CreateAPIKey handles POST requests for the /admin/api-keys endpoint.
+// The key is issued for the named service account, which is stored as
+// a user if it does not already exist, and is granted the requested
+// scopes. The full key is only returned in this response.
+func (h DefaultAdminHandlers) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminAPIKeyRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	switch {
+	case rb.ServiceAccount == "":
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("service_account"), errs.MissingField("service_account")))
+		return
+	case strings.ContainsAny(rb.ServiceAccount, " \t\n@"):
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("service_account"), errors.New("service_account cannot contain whitespace or @")))
+		return
+	}
+
+	scopes, err := auth.NewScopes(rb.Scopes)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	sa, err := h.UserTransactor.Upsert(ctx, auth.NewServiceAccount(rb.ServiceAccount))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	k, key, err := auth.NewAPIKey(h.RandomStringGenerator, rb.Name, sa, scopes)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.AuthTransactor.CreateAPIKey(ctx, k, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	akr := newAdminAPIKeyResponse(k)
+	akr.Key = key
+
+	writeResponse(w, r, akr)
+}
+
+// RevokeAPIKeyHandler is a Handler that revokes an API key
+type RevokeAPIKeyHandler http.Handler
+
+// ProvideRevokeAPIKeyHandler is a provider for the
+// RevokeAPIKeyHandler for wire
+func ProvideRevokeAPIKeyHandler(h DefaultAdminHandlers) RevokeAPIKeyHandler {
+	return http.HandlerFunc(h.RevokeAPIKey)
+}
+
+// RevokeAPIKey handles DELETE requests for the
+// /admin/api-keys/{apiKeyID} endpoint
+func (h DefaultAdminHandlers) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	id, err := pathID(r, "apiKeyID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	k, err := h.AuthSelector.FindAPIKeyByID(ctx, id)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	if k.IsRevoked() {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("api_key_id"), errors.New("API key has already been revoked")))
+		return
+	}
+
+	k.RevokeTime = time.Now().UTC()
+
+	err = h.AuthTransactor.RevokeAPIKey(ctx, k, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminAPIKeyResponse(k))
+}
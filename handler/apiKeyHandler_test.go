@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/datastore/authstore/authstoretest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+)
+
+func TestDefaultAdminHandlers_CreateAPIKey(t *testing.T) {
+	path := pathPrefix + adminV1PathRoot + "/api-keys"
+
+	t.Run("typical", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(adminAPIKeyRequestBody{
+			Name:           "nightly",
+			ServiceAccount: "nightly-batch",
+			Scopes:         []string{auth.MoviesReadScope},
+		})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateAPIKeyHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		type standardResponse struct {
+			Data adminAPIKeyResponse `json:"data"`
+		}
+		var got standardResponse
+		err = json.NewDecoder(rr.Body).Decode(&got)
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(got.Data.Name, qt.Equals, "nightly")
+		c.Assert(got.Data.ServiceAccount, qt.Equals, "nightly-batch@"+auth.ServiceAccountDomain)
+		c.Assert(got.Data.Scopes, qt.DeepEquals, []string{auth.MoviesReadScope})
+		c.Assert(got.Data.RevokeTimestamp, qt.Equals, "")
+		// the full key is returned once and starts with the prefix
+		c.Assert(strings.HasPrefix(got.Data.Key, got.Data.Prefix+"."), qt.IsTrue)
+	})
+
+	t.Run("invalid service account", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(adminAPIKeyRequestBody{
+			Name:           "nightly",
+			ServiceAccount: "batch@example.com",
+			Scopes:         []string{auth.MoviesReadScope},
+		})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateAPIKeyHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+	})
+
+	t.Run("unknown scope", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(adminAPIKeyRequestBody{
+			Name:           "nightly",
+			ServiceAccount: "nightly-batch",
+			Scopes:         []string{"movies:everything"},
+		})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateAPIKeyHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+	})
+}
+
+func TestDefaultAdminHandlers_RevokeAPIKey(t *testing.T) {
+	c := qt.New(t)
+
+	route := pathPrefix + adminV1PathRoot + "/api-keys/{apiKeyID}"
+	path := pathPrefix + adminV1PathRoot + "/api-keys/" + authstoretest.APIKeyID.String()
+
+	req := httptest.NewRequest(http.MethodDelete, path, nil)
+	rr := serveAdmin(t, route, ProvideRevokeAPIKeyHandler(newMockAdminHandlers(t)), req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	type standardResponse struct {
+		Data adminAPIKeyResponse `json:"data"`
+	}
+	var got standardResponse
+	err := json.NewDecoder(rr.Body).Decode(&got)
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(got.Data.ID, qt.Equals, authstoretest.APIKeyID.String())
+	c.Assert(got.Data.RevokeTimestamp, qt.Not(qt.Equals), "")
+	c.Assert(got.Data.Key, qt.Equals, "")
+}
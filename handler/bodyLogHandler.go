@@ -0,0 +1,213 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+)
+
+const (
+	// defaultBodyLogMaxBytes is the largest body logged if
+	// BodyLogConfig.MaxBytes is not set
+	defaultBodyLogMaxBytes int = 4096
+	// redactedValue replaces redacted JSON fields and headers
+	redactedValue string = "[REDACTED]"
+)
+
+// alwaysRedactedHeaders are the headers holding credentials, which
+// are redacted whatever the BodyLogConfig
+var alwaysRedactedHeaders = []string{"Authorization", "Cookie", "Set-Cookie", apiKeyHeader, debugLogHeader}
+
+// alwaysRedactedPaths are the JSON fields of the responses issuing
+// credentials, which are redacted whatever the BodyLogConfig: API
+// keys, client signing secrets, dev access tokens and debug tokens
+var alwaysRedactedPaths = []string{"data.key", "data.signing_secret", "data.access_token", "data.token"}
+
+// BodyLogConfig configures the BodyLogHandler middleware
+type BodyLogConfig struct {
+	// Enabled turns on body logging
+	Enabled bool
+	// MaxBytes is the largest request or response body logged.
+	// Larger bodies are omitted. If zero, defaultBodyLogMaxBytes is
+	// used.
+	MaxBytes int
+	// RedactPaths are the dot separated paths of the JSON fields
+	// redacted in bodies in addition to alwaysRedactedPaths, e.g.
+	// "user.password". A path applies to each element of the arrays
+	// on its way and "*" matches any field.
+	RedactPaths []string
+	// RedactHeaders are the headers redacted in addition to
+	// alwaysRedactedHeaders
+	RedactHeaders []string
+}
+
+// maxBytes returns the largest body logged
+func (cfg BodyLogConfig) maxBytes() int {
+	if cfg.MaxBytes <= 0 {
+		return defaultBodyLogMaxBytes
+	}
+	return cfg.MaxBytes
+}
+
+// BodyLogHandler middleware logs the request and response headers and
+// JSON bodies at debug level, with the configured JSON fields and
+// headers redacted. It does nothing unless enabled in the
+// BodyLogConfig and the request logger is at debug level, so it must
+// follow LogLevelHandler for requests sent with a debug token to be
+// logged. Bodies larger than the configured limit or which are not
+// JSON are omitted, so fields which should be redacted are never
+// logged.
+func (mw Middleware) BodyLogHandler(h http.Handler) http.Handler {
+	if !mw.BodyLog.Enabled {
+		return h
+	}
+	cfg := mw.BodyLog
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lgr := hlog.FromRequest(r)
+			if lgr.GetLevel() > zerolog.DebugLevel || zerolog.GlobalLevel() > zerolog.DebugLevel {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			// read at most one byte more than the limit to know whether
+			// the body is over it, then give the handler the whole body
+			var reqBody []byte
+			if r.Body != nil && r.Body != http.NoBody {
+				reqBody, _ = ioutil.ReadAll(io.LimitReader(r.Body, int64(cfg.maxBytes())+1))
+				r.Body = multiReadCloser{Reader: io.MultiReader(bytes.NewReader(reqBody), r.Body), Closer: r.Body}
+			}
+
+			rw := newBodyRecorder(w, cfg.maxBytes())
+			h.ServeHTTP(rw, r)
+
+			e := lgr.Debug().
+				Str("method", r.Method).
+				Stringer("url", r.URL).
+				Interface("request_headers", cfg.redactHeaders(r.Header))
+			e = cfg.bodyField(e, "request_body", reqBody)
+			e = e.Int("status", rw.status).
+				Interface("response_headers", cfg.redactHeaders(rw.Header()))
+			e = cfg.bodyField(e, "response_body", rw.body.Bytes())
+			e.Msg("request and response")
+		})
+}
+
+// bodyField adds the redacted body to the event, or the reason it was
+// omitted as key_omitted. Nothing is added for an empty body.
+func (cfg BodyLogConfig) bodyField(e *zerolog.Event, key string, body []byte) *zerolog.Event {
+	if len(body) == 0 {
+		return e
+	}
+	if len(body) > cfg.maxBytes() {
+		return e.Str(key+"_omitted", "too_large")
+	}
+
+	redacted, ok := redactJSON(body, append(append([]string(nil), alwaysRedactedPaths...), cfg.RedactPaths...))
+	if !ok {
+		return e.Str(key+"_omitted", "not_json")
+	}
+
+	return e.RawJSON(key, redacted)
+}
+
+// redactHeaders returns a copy of the headers with the values of the
+// redacted headers replaced
+func (cfg BodyLogConfig) redactHeaders(h http.Header) http.Header {
+	rh := h.Clone()
+	for _, names := range [][]string{alwaysRedactedHeaders, cfg.RedactHeaders} {
+		for _, name := range names {
+			if _, ok := rh[http.CanonicalHeaderKey(name)]; ok {
+				rh.Set(name, redactedValue)
+			}
+		}
+	}
+	return rh
+}
+
+// redactJSON returns body with the fields at the given paths
+// redacted. ok is false if body is not JSON.
+func redactJSON(body []byte, paths []string) (redacted []byte, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	// anything after the first value means body is not a JSON value
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, false
+	}
+
+	for _, p := range paths {
+		redactPath(v, strings.Split(p, "."))
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// redactPath redacts the field at path in v, applying path to each
+// element of an array
+func redactPath(v interface{}, path []string) {
+	switch t := v.(type) {
+	case []interface{}:
+		for _, e := range t {
+			redactPath(e, path)
+		}
+	case map[string]interface{}:
+		for k, fv := range t {
+			if path[0] != "*" && path[0] != k {
+				continue
+			}
+			if len(path) == 1 {
+				t[k] = redactedValue
+				continue
+			}
+			redactPath(fv, path[1:])
+		}
+	}
+}
+
+// multiReadCloser reads from Reader and closes Closer
+type multiReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// bodyRecorder records up to max bytes of the response body, along
+// with the status code and errs.Kind recorded by statusRecorder. Once
+// more than max bytes are written, the body is no longer recorded.
+type bodyRecorder struct {
+	*statusRecorder
+	max  int
+	body bytes.Buffer
+}
+
+// newBodyRecorder returns a bodyRecorder wrapping w
+func newBodyRecorder(w http.ResponseWriter, max int) *bodyRecorder {
+	return &bodyRecorder{statusRecorder: newStatusRecorder(w), max: max}
+}
+
+// Write records b, if the body is not over the limit, and writes it
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	// one byte over the limit is kept to know the body is over it
+	if room := w.max + 1 - w.body.Len(); room > 0 {
+		if len(b) < room {
+			room = len(b)
+		}
+		w.body.Write(b[:room])
+	}
+	return w.statusRecorder.Write(b)
+}
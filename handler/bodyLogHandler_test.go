@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestRedactJSON(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		paths  []string
+		want   string
+		wantOK bool
+	}{
+		{"no paths", `{"b":1,"a":"x"}`, nil, `{"a":"x","b":1}`, true},
+		{"top level", `{"password":"secret","email":"a@b.c"}`, []string{"password"}, `{"email":"a@b.c","password":"[REDACTED]"}`, true},
+		{"nested", `{"user":{"ssn":"123","name":"otto"}}`, []string{"user.ssn"}, `{"user":{"name":"otto","ssn":"[REDACTED]"}}`, true},
+		{"array", `{"cards":[{"number":"4111"},{"number":"5500"}]}`, []string{"cards.number"}, `{"cards":[{"number":"[REDACTED]"},{"number":"[REDACTED]"}]}`, true},
+		{"wildcard", `{"a":{"token":"x"},"b":{"token":"y"}}`, []string{"*.token"}, `{"a":{"token":"[REDACTED]"},"b":{"token":"[REDACTED]"}}`, true},
+		{"missing path", `{"a":1}`, []string{"b.c"}, `{"a":1}`, true},
+		{"large number", `{"id":12345678901234567890}`, nil, `{"id":12345678901234567890}`, true},
+		{"not json", `password=secret`, []string{"password"}, "", false},
+		{"trailing data", `{"a":1} {"password":"secret"}`, []string{"password"}, "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := redactJSON([]byte(tt.body), tt.paths)
+			qt.Assert(t, ok, qt.Equals, tt.wantOK)
+			qt.Assert(t, string(got), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestMiddleware_BodyLogHandler(t *testing.T) {
+	const reqBody = `{"title":"Repo Man","secret":{"password":"hunter2"}}`
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Header().Set("Set-Cookie", "session=abc")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(b)
+	})
+
+	tests := []struct {
+		name     string
+		cfg      BodyLogConfig
+		level    zerolog.Level
+		wantLog  bool
+		wantBody bool
+	}{
+		{"disabled", BodyLogConfig{}, zerolog.DebugLevel, false, false},
+		{"info level", BodyLogConfig{Enabled: true}, zerolog.InfoLevel, false, false},
+		{"logged", BodyLogConfig{Enabled: true, RedactPaths: []string{"secret.password"}, RedactHeaders: []string{"X-Client-ID"}}, zerolog.DebugLevel, true, true},
+		{"too large", BodyLogConfig{Enabled: true, MaxBytes: 10}, zerolog.DebugLevel, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			mw := Middleware{BodyLog: tt.cfg}
+
+			var buf bytes.Buffer
+			lgr := logger.NewLogger(&buf, false).Level(tt.level)
+
+			req := httptest.NewRequest(http.MethodPost, pathPrefix+moviesV1PathRoot, strings.NewReader(reqBody))
+			req.Header.Set("Authorization", "Bearer abc123")
+			req.Header.Set(clientIDHeader, "client-1")
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).
+				Append(mw.BodyLogHandler).
+				Then(echo).
+				ServeHTTP(rr, req)
+
+			// the handler always gets, and echoes, the whole body
+			c.Assert(rr.Code, qt.Equals, http.StatusCreated)
+			c.Assert(rr.Body.String(), qt.Equals, reqBody)
+
+			var entry map[string]interface{}
+			for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+				var m map[string]interface{}
+				c.Assert(json.Unmarshal([]byte(line), &m), qt.IsNil)
+				if m["message"] == "request and response" {
+					entry = m
+				}
+			}
+			if !tt.wantLog {
+				c.Assert(entry, qt.IsNil)
+				return
+			}
+			c.Assert(entry, qt.Not(qt.IsNil))
+			c.Assert(entry["request_id"], qt.Not(qt.Equals), nil)
+			c.Assert(entry["status"], qt.Equals, float64(http.StatusCreated))
+			c.Assert(strings.Contains(buf.String(), "abc123"), qt.IsFalse)
+			c.Assert(strings.Contains(buf.String(), "session=abc"), qt.IsFalse)
+
+			if !tt.wantBody {
+				c.Assert(entry["request_body_omitted"], qt.Equals, "too_large")
+				c.Assert(entry["response_body_omitted"], qt.Equals, "too_large")
+				return
+			}
+			c.Assert(strings.Contains(buf.String(), "hunter2"), qt.IsFalse)
+			c.Assert(strings.Contains(buf.String(), "client-1"), qt.IsFalse)
+			wantBody := map[string]interface{}{
+				"title":  "Repo Man",
+				"secret": map[string]interface{}{"password": redactedValue},
+			}
+			c.Assert(entry["request_body"], qt.DeepEquals, wantBody)
+			c.Assert(entry["response_body"], qt.DeepEquals, wantBody)
+		})
+	}
+}
+
+func TestMiddleware_BodyLogHandler_credentials(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"api key", `{"data":{"key":"s3cret"}}`},
+		{"signing secret", `{"data":{"signing_secret":"s3cret"}}`},
+		{"dev access token", `{"data":{"access_token":"s3cret"}}`},
+		{"debug token", `{"data":{"token":"s3cret"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			// no redaction configured, the credentials are redacted
+			// anyway
+			mw := Middleware{BodyLog: BodyLogConfig{Enabled: true}}
+
+			var buf bytes.Buffer
+			lgr := logger.NewLogger(&buf, false).Level(zerolog.DebugLevel)
+
+			req := httptest.NewRequest(http.MethodPost, pathPrefix+adminV1PathRoot, nil)
+			req.Header.Set(debugLogHeader, "debug-s3cret")
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).
+				Append(mw.BodyLogHandler).
+				ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.Write([]byte(tt.body))
+				}).
+				ServeHTTP(rr, req)
+
+			c.Assert(rr.Body.String(), qt.Equals, tt.body)
+			c.Assert(buf.String(), qt.Contains, redactedValue)
+			c.Assert(strings.Contains(buf.String(), "s3cret"), qt.IsFalse)
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// adminClientRequestBody is the request body to register or update
+// a client. Status defaults to active when a client is registered.
+type adminClientRequestBody struct {
+	Name   string   `json:"name"`
+	Owner  string   `json:"owner"`
+	Status string   `json:"status"`
+	Scopes []string `json:"scopes"`
+}
+
+// adminClientResponse is the response struct for a client
+type adminClientResponse struct {
+	ID              string   `json:"client_id"`
+	Name            string   `json:"name"`
+	Owner           string   `json:"owner"`
+	Status          string   `json:"status"`
+	Scopes          []string `json:"scopes"`
+	CreateTimestamp string   `json:"create_timestamp"`
+	UpdateTimestamp string   `json:"update_timestamp"`
+}
+
+// newAdminClientResponse initializes an adminClientResponse from a Client
+func newAdminClientResponse(c *client.Client) adminClientResponse {
+	scopes := c.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return adminClientResponse{
+		ID:              c.ID.String(),
+		Name:            c.Name,
+		Owner:           c.Owner.Email,
+		Status:          string(c.Status),
+		Scopes:          scopes,
+		CreateTimestamp: c.CreateTime.Format(time.RFC3339),
+		UpdateTimestamp: c.UpdateTime.Format(time.RFC3339),
+	}
+}
+
+// clientOwner finds the stored user given as a client owner
+func (h DefaultAdminHandlers) clientOwner(r *http.Request, email string) (user.User, error) {
+	if email == "" {
+		return user.User{}, errs.E(errs.Validation, errs.Parameter("owner"), errs.MissingField("owner"))
+	}
+
+	u, err := h.UserSelector.FindByEmail(r.Context(), email)
+	if errs.KindIs(errs.NotExist, err) {
+		return user.User{}, errs.E(errs.Validation, errs.Parameter("owner"), errors.New("owner must be an existing user"))
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	return u, nil
+}
+
+// FindAllClientsHandler is a Handler that returns all clients
+type FindAllClientsHandler http.Handler
+
+// ProvideFindAllClientsHandler is a provider for the
+// FindAllClientsHandler for wire
+func ProvideFindAllClientsHandler(h DefaultAdminHandlers) FindAllClientsHandler {
+	return http.HandlerFunc(h.FindAllClients)
+}
+
+// FindAllClients handles GET requests for the /admin/clients endpoint
+func (h DefaultAdminHandlers) FindAllClients(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	clients, err := h.ClientSelector.FindAll(r.Context())
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	cr := make([]adminClientResponse, 0, len(clients))
+	for _, c := range clients {
+		cr = append(cr, newAdminClientResponse(c))
+	}
+
+	writeResponse(w, r, cr)
+}
+
+// CreateClientHandler is a Handler that registers a client
+type CreateClientHandler http.Handler
+
+// ProvideCreateClientHandler is a provider for the
+// CreateClientHandler for wire
+func ProvideCreateClientHandler(h DefaultAdminHandlers) CreateClientHandler {
+	return http.HandlerFunc(h.CreateClient)
+}
+
+// CreateClient handles POST requests for the /admin/clients endpoint.
+// The owner must be an existing user.
+func (h DefaultAdminHandlers) CreateClient(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminClientRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	owner, err := h.clientOwner(r, rb.Owner)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	c, err := client.NewClient(rb.Name, owner, rb.Scopes)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+	if rb.Status != "" {
+		c.Status = client.Status(rb.Status)
+		err = c.IsValid()
+		if err != nil {
+			errs.HTTPErrorResponse(w, logger, err)
+			return
+		}
+	}
+
+	err = h.ClientTransactor.Create(r.Context(), c, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminClientResponse(c))
+}
+
+// FindClientByIDHandler is a Handler that finds a client by ID
+type FindClientByIDHandler http.Handler
+
+// ProvideFindClientByIDHandler is a provider for the
+// FindClientByIDHandler for wire
+func ProvideFindClientByIDHandler(h DefaultAdminHandlers) FindClientByIDHandler {
+	return http.HandlerFunc(h.FindClientByID)
+}
+
+// FindClientByID handles GET requests for the /admin/clients/{clientID} endpoint
+func (h DefaultAdminHandlers) FindClientByID(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	id, err := pathID(r, "clientID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	c, err := h.ClientSelector.FindByID(r.Context(), id)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminClientResponse(c))
+}
+
+// UpdateClientHandler is a Handler that updates a client
+type UpdateClientHandler http.Handler
+
+// ProvideUpdateClientHandler is a provider for the
+// UpdateClientHandler for wire
+func ProvideUpdateClientHandler(h DefaultAdminHandlers) UpdateClientHandler {
+	return http.HandlerFunc(h.UpdateClient)
+}
+
+// UpdateClient handles PUT requests for the /admin/clients/{clientID}
+// endpoint. Clients are never deleted, as movies record the client
+// they were created through; set the status to disabled instead.
+func (h DefaultAdminHandlers) UpdateClient(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	id, err := pathID(r, "clientID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminClientRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	c, err := h.ClientSelector.FindByID(ctx, id)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	owner, err := h.clientOwner(r, rb.Owner)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	c.Name = rb.Name
+	c.Owner = owner
+	c.Status = client.Status(rb.Status)
+	c.Scopes = rb.Scopes
+	c.UpdateTime = time.Now().UTC()
+
+	err = c.IsValid()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.ClientTransactor.Update(ctx, c, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminClientResponse(c))
+}
+
+// signingSecretResponse is the response struct for a rotated signing
+// secret. The secret is only ever returned in this response.
+type signingSecretResponse struct {
+	ClientID      string `json:"client_id"`
+	SigningSecret string `json:"signing_secret"`
+}
+
+// RotateSigningSecretHandler is a Handler that issues a new signing
+// secret for a client
+type RotateSigningSecretHandler http.Handler
+
+// ProvideRotateSigningSecretHandler is a provider for the
+// RotateSigningSecretHandler for wire
+func ProvideRotateSigningSecretHandler(h DefaultAdminHandlers) RotateSigningSecretHandler {
+	return http.HandlerFunc(h.RotateSigningSecret)
+}
+
+// RotateSigningSecret handles POST requests for the
+// /admin/clients/{clientID}/signing-secret endpoint. A new secret is
+// generated for the client, replacing any previous secret, and
+// returned once; it cannot be retrieved again.
+func (h DefaultAdminHandlers) RotateSigningSecret(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	id, err := pathID(r, "clientID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	c, err := h.ClientSelector.FindByID(ctx, id)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	secret, err := h.RandomStringGenerator.CryptoString(32)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.ClientTransactor.SetSigningSecret(ctx, c, []byte(secret), actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, signingSecretResponse{ClientID: c.ID.String(), SigningSecret: secret})
+}
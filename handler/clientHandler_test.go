@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+
+	"github.com/gilcrest/go-api-basic/datastore/clientstore/clientstoretest"
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+func TestDefaultAdminHandlers_CreateClient(t *testing.T) {
+	path := pathPrefix + adminV1PathRoot + "/clients"
+
+	type standardResponse struct {
+		Data adminClientResponse `json:"data"`
+	}
+
+	t.Run("typical", func(t *testing.T) {
+		c := qt.New(t)
+
+		owner := usertest.NewUser(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(adminClientRequestBody{
+			Name:   "Helping Hand Movie App",
+			Owner:  owner.Email,
+			Scopes: []string{"movies:read"},
+		})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateClientHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		var got standardResponse
+		err = json.NewDecoder(rr.Body).Decode(&got)
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(got.Data.ID, qt.Not(qt.Equals), "")
+		c.Assert(got.Data.Name, qt.Equals, "Helping Hand Movie App")
+		c.Assert(got.Data.Owner, qt.Equals, owner.Email)
+		// clients are active unless registered otherwise
+		c.Assert(got.Data.Status, qt.Equals, string(client.Active))
+		c.Assert(got.Data.Scopes, qt.DeepEquals, []string{"movies:read"})
+	})
+
+	t.Run("invalid status", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(adminClientRequestBody{
+			Name:   "Helping Hand Movie App",
+			Owner:  usertest.NewUser(t).Email,
+			Status: "paused",
+		})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateClientHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+	})
+}
+
+func TestDefaultAdminHandlers_UpdateClient(t *testing.T) {
+	c := qt.New(t)
+
+	route := pathPrefix + adminV1PathRoot + "/clients/{clientID}"
+	path := pathPrefix + adminV1PathRoot + "/clients/" + clientstoretest.ClientID.String()
+
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(adminClientRequestBody{
+		Name:   "Helping Hand Movie App",
+		Owner:  usertest.NewUser(t).Email,
+		Status: string(client.Disabled),
+	})
+	c.Assert(err, qt.IsNil)
+
+	req := httptest.NewRequest(http.MethodPut, path, &buf)
+	rr := serveAdmin(t, route, ProvideUpdateClientHandler(newMockAdminHandlers(t)), req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	type standardResponse struct {
+		Data adminClientResponse `json:"data"`
+	}
+	var got standardResponse
+	err = json.NewDecoder(rr.Body).Decode(&got)
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(got.Data.ID, qt.Equals, clientstoretest.ClientID.String())
+	c.Assert(got.Data.Status, qt.Equals, string(client.Disabled))
+	c.Assert(got.Data.Scopes, qt.DeepEquals, []string{})
+}
+
+func TestDefaultAdminHandlers_RotateSigningSecret(t *testing.T) {
+	c := qt.New(t)
+
+	route := pathPrefix + adminV1PathRoot + "/clients/{clientID}/signing-secret"
+	path := pathPrefix + adminV1PathRoot + "/clients/" + clientstoretest.ClientID.String() + "/signing-secret"
+
+	req := httptest.NewRequest(http.MethodPost, path, nil)
+	rr := serveAdmin(t, route, ProvideRotateSigningSecretHandler(newMockAdminHandlers(t)), req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	type standardResponse struct {
+		Data signingSecretResponse `json:"data"`
+	}
+	var got standardResponse
+	err := json.NewDecoder(rr.Body).Decode(&got)
+	c.Assert(err, qt.IsNil)
+
+	c.Assert(got.Data.ClientID, qt.Equals, clientstoretest.ClientID.String())
+	c.Assert(got.Data.SigningSecret, qt.Not(qt.Equals), "")
+}
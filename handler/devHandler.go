@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+)
+
+// DefaultDevHandlers are the handlers only registered when the
+// server runs in DevAuthMode. Each method on the struct is a
+// separate handler.
+type DefaultDevHandlers struct {
+	TokenIssuer auth.DevTokenIssuer
+}
+
+// devTokenRequestBody is the request body to issue a dev token.
+// Scopes default to all scopes and ExpiresIn, in seconds, defaults
+// to auth.DefaultDevTokenTTL.
+type devTokenRequestBody struct {
+	Email     string   `json:"email"`
+	Scopes    []string `json:"scopes"`
+	ExpiresIn int      `json:"expires_in"`
+}
+
+// devTokenResponse is the response struct for an issued dev token
+type devTokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// IssueDevTokenHandler is a Handler that issues dev tokens
+type IssueDevTokenHandler http.Handler
+
+// ProvideIssueDevTokenHandler is a provider for the
+// IssueDevTokenHandler for wire
+func ProvideIssueDevTokenHandler(h DefaultDevHandlers) IssueDevTokenHandler {
+	return http.HandlerFunc(h.IssueDevToken)
+}
+
+// IssueDevToken handles POST requests for the /dev/token endpoint and
+// issues a dev token for any user. It is unauthenticated, so it is
+// only registered when the server runs in DevAuthMode.
+func (h DefaultDevHandlers) IssueDevToken(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	rb := new(devTokenRequestBody)
+	err := json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	scopes := auth.AllScopes
+	if len(rb.Scopes) > 0 {
+		scopes, err = auth.NewScopes(rb.Scopes)
+		if err != nil {
+			errs.HTTPErrorResponse(w, logger, err)
+			return
+		}
+	}
+
+	ttl := time.Duration(rb.ExpiresIn) * time.Second
+	if ttl == 0 {
+		ttl = auth.DefaultDevTokenTTL
+	}
+
+	token, err := h.TokenIssuer.Issue(rb.Email, scopes, ttl)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	logger.Warn().Str("email", rb.Email).Msg("dev token issued")
+
+	writeResponse(w, r, devTokenResponse{
+		AccessToken: token,
+		TokenType:   auth.BearerTokenType,
+		ExpiresIn:   int(ttl.Seconds()),
+		Scope:       scopes.String(),
+	})
+}
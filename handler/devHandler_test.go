@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestDefaultDevHandlers_IssueDevToken(t *testing.T) {
+	issuer := auth.DevTokenIssuer{Key: []byte("dev-key")}
+
+	tests := []struct {
+		name      string
+		body      devTokenRequestBody
+		wantCode  int
+		wantScope string
+	}{
+		{"typical", devTokenRequestBody{Email: "otto.maddox711@gmail.com"}, http.StatusOK, auth.AllScopes.String()},
+		{"scopes", devTokenRequestBody{Email: "otto.maddox711@gmail.com", Scopes: []string{auth.MoviesReadScope}}, http.StatusOK, auth.MoviesReadScope},
+		{"unknown scope", devTokenRequestBody{Email: "otto.maddox711@gmail.com", Scopes: []string{"movies:launch"}}, http.StatusBadRequest, ""},
+		{"no email", devTokenRequestBody{}, http.StatusBadRequest, ""},
+		{"too long", devTokenRequestBody{Email: "otto.maddox711@gmail.com", ExpiresIn: 86400}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var buf bytes.Buffer
+			err := json.NewEncoder(&buf).Encode(tt.body)
+			c.Assert(err, qt.IsNil)
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			req := httptest.NewRequest(http.MethodPost, pathPrefix+"/v1/dev/token", &buf)
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).
+				Then(ProvideIssueDevTokenHandler(DefaultDevHandlers{TokenIssuer: issuer})).
+				ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got struct {
+				Data devTokenResponse `json:"data"`
+			}
+			err = json.NewDecoder(rr.Body).Decode(&got)
+			c.Assert(err, qt.IsNil)
+			c.Assert(got.Data.TokenType, qt.Equals, auth.BearerTokenType)
+			c.Assert(got.Data.ExpiresIn, qt.Equals, int(auth.DefaultDevTokenTTL.Seconds()))
+			c.Assert(got.Data.Scope, qt.Equals, tt.wantScope)
+
+			// the issued token is accepted by the issuer
+			u, _, err := issuer.Convert(context.Background(), auth.AccessToken{Token: got.Data.AccessToken, TokenType: auth.BearerTokenType})
+			c.Assert(err, qt.IsNil)
+			c.Assert(u.Email, qt.Equals, tt.body.Email)
+		})
+	}
+}
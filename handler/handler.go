@@ -9,6 +9,7 @@ import (
 
 	"github.com/gilcrest/go-api-basic/domain/auth"
 	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
 	"github.com/justinas/alice"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -24,6 +25,52 @@ type Handlers struct {
 	UpdateMovieHandler   UpdateMovieHandler
 	DeleteMovieHandler   DeleteMovieHandler
 	PingHandler          PingHandler
+
+	FindMeHandler   FindMeHandler
+	UpdateMeHandler UpdateMeHandler
+
+	FindAllUsersHandler           FindAllUsersHandler
+	CreateUserHandler             CreateUserHandler
+	FindUserByIDHandler           FindUserByIDHandler
+	UpdateUserHandler             UpdateUserHandler
+	DeleteUserHandler             DeleteUserHandler
+	FindAllRolesHandler           FindAllRolesHandler
+	CreateRoleHandler             CreateRoleHandler
+	FindRoleByIDHandler           FindRoleByIDHandler
+	UpdateRoleHandler             UpdateRoleHandler
+	DeleteRoleHandler             DeleteRoleHandler
+	FindAllRoleAssignmentsHandler FindAllRoleAssignmentsHandler
+	CreateRoleAssignmentHandler   CreateRoleAssignmentHandler
+	DeleteRoleAssignmentHandler   DeleteRoleAssignmentHandler
+	FindAllAPIKeysHandler         FindAllAPIKeysHandler
+	CreateAPIKeyHandler           CreateAPIKeyHandler
+	RevokeAPIKeyHandler           RevokeAPIKeyHandler
+	FindAllClientsHandler         FindAllClientsHandler
+	CreateClientHandler           CreateClientHandler
+	FindClientByIDHandler         FindClientByIDHandler
+	UpdateClientHandler           UpdateClientHandler
+	RotateSigningSecretHandler    RotateSigningSecretHandler
+	FindAllOrgsHandler            FindAllOrgsHandler
+	CreateOrgHandler              CreateOrgHandler
+	AddOrgMemberHandler           AddOrgMemberHandler
+	RemoveOrgMemberHandler        RemoveOrgMemberHandler
+	FindLogLevelHandler           FindLogLevelHandler
+	UpdateLogLevelHandler         UpdateLogLevelHandler
+	IssueDebugTokenHandler        IssueDebugTokenHandler
+
+	IssueDevTokenHandler IssueDevTokenHandler
+
+	LoginHandler         LoginHandler
+	LoginCallbackHandler LoginCallbackHandler
+	LogoutHandler        LogoutHandler
+
+	LivenessHandler  LivenessHandler
+	ReadinessHandler ReadinessHandler
+	HealthHandler    HealthHandler
+
+	// MetricsHandler is nil if the metrics are served on a separate
+	// admin port
+	MetricsHandler MetricsHandler
 }
 
 // LoggerHandlerChain returns a handler chain (via alice.Chain)
@@ -51,11 +98,67 @@ func LoggerHandlerChain(logger zerolog.Logger, c alice.Chain) alice.Chain {
 		Append(hlog.RemoteAddrHandler("remote_ip")).
 		Append(hlog.UserAgentHandler("user_agent")).
 		Append(hlog.RefererHandler("referer")).
-		Append(hlog.RequestIDHandler("request_id", "Request-Id"))
+		Append(hlog.RequestIDHandler("request_id", requestIDHeader)).
+		Append(UpstreamRequestIDHandler)
 
 	return c
 }
 
+const (
+	// requestIDHeader is the header the request ID is returned in,
+	// and the first header an upstream request ID is looked for in
+	requestIDHeader string = "Request-Id"
+	// xRequestIDHeader is the other header an upstream request ID
+	// is looked for in
+	xRequestIDHeader string = "X-Request-Id"
+	// maxRequestIDLen is the maximum length of an upstream request ID
+	maxRequestIDLen int = 128
+)
+
+// UpstreamRequestIDHandler middleware reuses the request ID sent by
+// an upstream service or proxy in the Request-Id or X-Request-Id
+// header, if any. The upstream request ID is set to the request
+// context and logged, and it replaces the generated request ID in the
+// Request-Id response header and the StandardResponse. The generated
+// request ID is still logged as request_id. Invalid upstream request
+// IDs are ignored.
+func UpstreamRequestIDHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			id := r.Header.Get(requestIDHeader)
+			if id == "" {
+				id = r.Header.Get(xRequestIDHeader)
+			}
+			if !validRequestID(id) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			lgr := hlog.FromRequest(r)
+			lgr.UpdateContext(func(c zerolog.Context) zerolog.Context {
+				return c.Str("upstream_request_id", id)
+			})
+			w.Header().Set(requestIDHeader, id)
+
+			h.ServeHTTP(w, r.WithContext(logger.CtxWithUpstreamRequestID(r.Context(), id)))
+		})
+}
+
+// validRequestID reports whether id is a usable upstream request ID:
+// not empty, not too long and only printable ASCII without spaces, so
+// it is safe to echo in a header and to log
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
 // JSONContentTypeHandler middleware is used to add the application/json
 // Content-Type Header for responses
 func JSONContentTypeHandler(h http.Handler) http.Handler {
@@ -66,14 +169,27 @@ func JSONContentTypeHandler(h http.Handler) http.Handler {
 		})
 }
 
+// apiKeyHeader is the request header used by service accounts
+// to send an API key
+const apiKeyHeader string = "X-API-Key"
+
 // AccessTokenHandler middleware is used to pull the Bearer token
-// from the Authorization header and set it to the request context
-// as an auth.AccessToken
+// from the Authorization header, or an API key from the X-API-Key
+// header, and set it to the request context as an auth.AccessToken.
+// If both are sent, the Bearer token is used. If an access token was
+// already set to the request context from a session cookie by
+// SessionHandler, it is used as is.
 func AccessTokenHandler(h http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			logger := *hlog.FromRequest(r)
+
+			if _, err := auth.FromRequest(r); err == nil {
+				h.ServeHTTP(w, r)
+				return
+			}
 			var token string
+			tokenType := auth.BearerTokenType
 
 			// retrieve the context from the http.Request
 			ctx := r.Context()
@@ -84,8 +200,19 @@ func AccessTokenHandler(h http.Handler) http.Handler {
 			// format: Authorization: Bearer
 			headerValue, ok := r.Header["Authorization"]
 			if ok && len(headerValue) >= 1 {
-				token = headerValue[0]
-				token = strings.TrimPrefix(token, auth.BearerTokenType+" ")
+				if !strings.HasPrefix(headerValue[0], auth.BearerTokenType+" ") {
+					errs.HTTPErrorResponse(w, logger, errs.E(errs.Unauthenticated,
+						errs.Challenge{Error: errs.InvalidRequestChallenge, Description: "The Authorization header must use the Bearer scheme"},
+						errors.New("Unauthenticated - Authorization header is not a Bearer token")))
+					return
+				}
+				token = strings.TrimPrefix(headerValue[0], auth.BearerTokenType+" ")
+			}
+
+			// If there is no Bearer token, look for an API key
+			if token == "" {
+				token = r.Header.Get(apiKeyHeader)
+				tokenType = auth.APIKeyTokenType
 			}
 
 			// If the token is empty...
@@ -99,12 +226,12 @@ func AccessTokenHandler(h http.Handler) http.Handler {
 				// and a 403 Forbidden response should be used afterwards, when the user is
 				// authenticated but isn’t authorized to perform the requested operation on
 				// the given resource."
-				errs.HTTPErrorResponse(w, logger, errs.E(errs.Unauthenticated, errors.New("Unauthenticated - empty Bearer token")))
+				errs.HTTPErrorResponse(w, logger, errs.E(errs.Unauthenticated, errors.New("Unauthenticated - empty Bearer token and API key")))
 				return
 			}
 
 			// add access token to context
-			ctx = auth.SetAccessToken2Context(ctx, token, auth.BearerTokenType)
+			ctx = auth.SetAccessToken2Context(ctx, token, tokenType)
 
 			// call original, adding access token to request context
 			h.ServeHTTP(w, r.WithContext(ctx))
@@ -123,12 +250,12 @@ type StandardResponse struct {
 func NewStandardResponse(r *http.Request, d interface{}) (*StandardResponse, error) {
 	var sr StandardResponse
 	sr.Path = r.URL.EscapedPath()
-	// gets Trace ID from request
-	id, ok := hlog.IDFromRequest(r)
+	// gets the upstream or generated request ID from request
+	id, ok := logger.RequestIDFromCtx(r.Context())
 	if !ok {
 		return nil, errs.E(errors.New("request ID not properly set to request context"))
 	}
-	sr.RequestID = id.String()
+	sr.RequestID = id
 
 	sr.Data = d
 
@@ -153,3 +280,33 @@ func DecoderErr(err error) error {
 	}
 	return nil
 }
+
+// statusRecorder records the status code of the response and the
+// errs.Kind of the error sent in it, if any, for middleware observing
+// the response. The Kind is also recorded with the wrapped
+// ResponseWriter, so several statusRecorders can be chained.
+type statusRecorder struct {
+	http.ResponseWriter
+	status  int
+	kind    errs.Kind
+	hasKind bool
+}
+
+// newStatusRecorder returns a statusRecorder wrapping w
+func newStatusRecorder(w http.ResponseWriter) *statusRecorder {
+	return &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+// WriteHeader records the status code before writing it
+func (w *statusRecorder) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// RecordKind records the Kind of the error sent in the response
+func (w *statusRecorder) RecordKind(k errs.Kind) {
+	w.kind, w.hasKind = k, true
+	if kr, ok := w.ResponseWriter.(errs.KindRecorder); ok {
+		kr.RecordKind(k)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gilcrest/go-api-basic/domain/healthcheck"
+)
+
+// Statuses of a health check
+const (
+	healthyStatus   string = "ok"
+	unhealthyStatus string = "failing"
+)
+
+// DefaultHealthHandlers are the liveness, readiness and health
+// handlers. Each method on the struct is a separate handler.
+type DefaultHealthHandlers struct {
+	Monitor *healthcheck.Monitor
+}
+
+// healthCheckResponse is the response struct for a health check
+type healthCheckResponse struct {
+	Name               string  `json:"name"`
+	Status             string  `json:"status"`
+	LatencyMillis      float64 `json:"latency_ms"`
+	CheckTimestamp     string  `json:"check_timestamp"`
+	LastError          string  `json:"last_error,omitempty"`
+	LastErrorTimestamp string  `json:"last_error_timestamp,omitempty"`
+}
+
+// healthResponse is the response struct for the health of the
+// application and each of its checks
+type healthResponse struct {
+	Status string                `json:"status"`
+	Checks []healthCheckResponse `json:"checks"`
+}
+
+// newHealthResponse initializes a healthResponse from the results of
+// the health checks
+func newHealthResponse(results []healthcheck.Result) healthResponse {
+	hr := healthResponse{
+		Status: healthStatus(healthcheck.Healthy(results)),
+		Checks: make([]healthCheckResponse, 0, len(results)),
+	}
+	for _, res := range results {
+		cr := healthCheckResponse{
+			Name:           res.Name,
+			Status:         healthStatus(res.Healthy),
+			LatencyMillis:  float64(res.Latency) / float64(time.Millisecond),
+			CheckTimestamp: res.CheckTime.Format(time.RFC3339),
+		}
+		if res.LastError != nil {
+			cr.LastError = res.LastError.Error()
+			cr.LastErrorTimestamp = res.LastErrorTime.Format(time.RFC3339)
+		}
+		hr.Checks = append(hr.Checks, cr)
+	}
+	return hr
+}
+
+// healthStatus returns the status for a check which is healthy or not
+func healthStatus(healthy bool) string {
+	if healthy {
+		return healthyStatus
+	}
+	return unhealthyStatus
+}
+
+// LivenessHandler is a Handler that reports the process is alive
+type LivenessHandler http.Handler
+
+// ProvideLivenessHandler is a provider for the LivenessHandler for wire
+func ProvideLivenessHandler(h DefaultHealthHandlers) LivenessHandler {
+	return http.HandlerFunc(h.Liveness)
+}
+
+// Liveness handles GET requests for the /healthz endpoint. It checks
+// no dependencies: if the process can answer, it is alive.
+func (h DefaultHealthHandlers) Liveness(w http.ResponseWriter, r *http.Request) {
+	writeHealthText(w, http.StatusOK, healthyStatus)
+}
+
+// ReadinessHandler is a Handler that reports whether the application
+// can serve requests
+type ReadinessHandler http.Handler
+
+// ProvideReadinessHandler is a provider for the ReadinessHandler for
+// wire
+func ProvideReadinessHandler(h DefaultHealthHandlers) ReadinessHandler {
+	return http.HandlerFunc(h.Readiness)
+}
+
+// Readiness handles GET requests for the /readyz endpoint. It runs
+// every health check and responds 503 with the names of the failing
+// checks if any fails. Errors are left out as the endpoint is
+// unauthenticated.
+func (h DefaultHealthHandlers) Readiness(w http.ResponseWriter, r *http.Request) {
+	var failing []string
+	for _, res := range h.Monitor.Check() {
+		if !res.Healthy {
+			failing = append(failing, res.Name)
+		}
+	}
+
+	if len(failing) > 0 {
+		writeHealthText(w, http.StatusServiceUnavailable, unhealthyStatus+": "+strings.Join(failing, ", "))
+		return
+	}
+	writeHealthText(w, http.StatusOK, healthyStatus)
+}
+
+// HealthHandler is a Handler that reports the result of each health
+// check
+type HealthHandler http.Handler
+
+// ProvideHealthHandler is a provider for the HealthHandler for wire
+func ProvideHealthHandler(h DefaultHealthHandlers) HealthHandler {
+	return http.HandlerFunc(h.Health)
+}
+
+// Health handles GET requests for the /health endpoint. It runs every
+// health check and returns the status, latency and last error of
+// each.
+func (h DefaultHealthHandlers) Health(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, newHealthResponse(h.Monitor.Check()))
+}
+
+// writeHealthText writes a plain text health response, as expected
+// by load balancers and orchestrators
+func writeHealthText(w http.ResponseWriter, code int, body string) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	_, _ = io.WriteString(w, body)
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+	"gocloud.dev/server/health"
+
+	"github.com/gilcrest/go-api-basic/domain/healthcheck"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+// newTestHealthHandlers returns DefaultHealthHandlers with a passing
+// database check and a google check failing with googleErr
+func newTestHealthHandlers(googleErr error) DefaultHealthHandlers {
+	return DefaultHealthHandlers{Monitor: healthcheck.NewMonitor([]health.Checker{
+		healthcheck.WithName("database", healthcheck.CheckerFunc(func() error { return nil })),
+		healthcheck.WithName("google", healthcheck.CheckerFunc(func() error { return googleErr })),
+	}, 0)}
+}
+
+func TestDefaultHealthHandlers_Liveness(t *testing.T) {
+	c := qt.New(t)
+
+	rr := httptest.NewRecorder()
+	ProvideLivenessHandler(newTestHealthHandlers(errors.New("down"))).
+		ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	// liveness does not depend on the checks
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(rr.Body.String(), qt.Equals, "ok")
+}
+
+func TestDefaultHealthHandlers_Readiness(t *testing.T) {
+	tests := []struct {
+		name      string
+		googleErr error
+		wantCode  int
+		wantBody  string
+	}{
+		{"ready", nil, http.StatusOK, "ok"},
+		{"not ready", errors.New("dial tcp 10.0.0.1:443: connection refused"), http.StatusServiceUnavailable, "failing: google"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			rr := httptest.NewRecorder()
+			ProvideReadinessHandler(newTestHealthHandlers(tt.googleErr)).
+				ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			c.Assert(rr.Body.String(), qt.Equals, tt.wantBody)
+		})
+	}
+}
+
+func TestDefaultHealthHandlers_Health(t *testing.T) {
+	c := qt.New(t)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	rr := httptest.NewRecorder()
+	LoggerHandlerChain(lgr, alice.New()).
+		Then(ProvideHealthHandler(newTestHealthHandlers(errors.New("connection refused")))).
+		ServeHTTP(rr, httptest.NewRequest(http.MethodGet, pathPrefix+"/v1/health", nil))
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	var got struct {
+		Data healthResponse `json:"data"`
+	}
+	err := json.NewDecoder(rr.Body).Decode(&got)
+	c.Assert(err, qt.IsNil)
+	c.Assert(got.Data.Status, qt.Equals, unhealthyStatus)
+	c.Assert(got.Data.Checks, qt.HasLen, 2)
+
+	db, google := got.Data.Checks[0], got.Data.Checks[1]
+	c.Assert(db.Name, qt.Equals, "database")
+	c.Assert(db.Status, qt.Equals, healthyStatus)
+	c.Assert(db.LastError, qt.Equals, "")
+	c.Assert(db.CheckTimestamp, qt.Not(qt.Equals), "")
+	c.Assert(google.Name, qt.Equals, "google")
+	c.Assert(google.Status, qt.Equals, unhealthyStatus)
+	c.Assert(google.LastError, qt.Equals, "connection refused")
+	c.Assert(google.LastErrorTimestamp, qt.Not(qt.Equals), "")
+}
+
+func TestNewMuxRouter_Health(t *testing.T) {
+	h := newTestHealthHandlers(nil)
+	rtr := NewMuxRouter(logger.NewLogger(os.Stdout, true), Middleware{}, Handlers{
+		LivenessHandler:  ProvideLivenessHandler(h),
+		ReadinessHandler: ProvideReadinessHandler(h),
+	})
+
+	for _, path := range []string{"/healthz", "/readyz"} {
+		rr := httptest.NewRecorder()
+		rtr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, path, nil))
+		qt.Assert(t, rr.Code, qt.Equals, http.StatusOK, qt.Commentf(path))
+	}
+}
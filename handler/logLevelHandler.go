@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+// debugLogHeader is the request header holding a debug token, which
+// turns on debug logging for the request
+const debugLogHeader string = "X-Debug-Log"
+
+// DefaultLogLevelHandlers are the handlers to change the log level at
+// runtime and issue debug tokens. Each method on the struct is a
+// separate handler.
+type DefaultLogLevelHandlers struct {
+	LevelController  *logger.LevelController
+	DebugTokenSigner logger.DebugTokenSigner
+}
+
+// logLevelRequestBody is the request body to set the log level. If
+// TTLSeconds is set, the level reverts to the default level after
+// that many seconds; otherwise it becomes the default level.
+type logLevelRequestBody struct {
+	Level      string `json:"level"`
+	TTLSeconds int    `json:"ttl_seconds"`
+}
+
+// logLevelResponse is the response struct for the log level.
+// RevertTimestamp is empty if the level does not revert.
+type logLevelResponse struct {
+	Level           string `json:"level"`
+	DefaultLevel    string `json:"default_level"`
+	RevertTimestamp string `json:"revert_timestamp,omitempty"`
+}
+
+// newLogLevelResponse initializes a logLevelResponse from a LevelState
+func newLogLevelResponse(st logger.LevelState) logLevelResponse {
+	lr := logLevelResponse{
+		Level:        st.Level.String(),
+		DefaultLevel: st.DefaultLevel.String(),
+	}
+	if !st.RevertAt.IsZero() {
+		lr.RevertTimestamp = st.RevertAt.Format(time.RFC3339)
+	}
+	return lr
+}
+
+// debugTokenRequestBody is the request body to issue a debug token.
+// TTLSeconds defaults to 15 minutes.
+type debugTokenRequestBody struct {
+	TTLSeconds int `json:"ttl_seconds"`
+}
+
+// debugTokenResponse is the response struct for an issued debug token
+type debugTokenResponse struct {
+	Token           string `json:"token"`
+	Header          string `json:"header"`
+	ExpiryTimestamp string `json:"expiry_timestamp"`
+}
+
+// defaultDebugTokenTTL is the TTL of a debug token if none is given
+const defaultDebugTokenTTL time.Duration = 15 * time.Minute
+
+// FindLogLevelHandler is a Handler that returns the log level
+type FindLogLevelHandler http.Handler
+
+// ProvideFindLogLevelHandler is a provider for the
+// FindLogLevelHandler for wire
+func ProvideFindLogLevelHandler(h DefaultLogLevelHandlers) FindLogLevelHandler {
+	return http.HandlerFunc(h.FindLogLevel)
+}
+
+// FindLogLevel handles GET requests for the /admin/loglevel endpoint
+func (h DefaultLogLevelHandlers) FindLogLevel(w http.ResponseWriter, r *http.Request) {
+	writeResponse(w, r, newLogLevelResponse(h.LevelController.State()))
+}
+
+// UpdateLogLevelHandler is a Handler that sets the log level
+type UpdateLogLevelHandler http.Handler
+
+// ProvideUpdateLogLevelHandler is a provider for the
+// UpdateLogLevelHandler for wire
+func ProvideUpdateLogLevelHandler(h DefaultLogLevelHandlers) UpdateLogLevelHandler {
+	return http.HandlerFunc(h.UpdateLogLevel)
+}
+
+// UpdateLogLevel handles PUT requests for the /admin/loglevel endpoint
+func (h DefaultLogLevelHandlers) UpdateLogLevel(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	rb := new(logLevelRequestBody)
+	err := json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	if rb.Level == "" {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("level"), errs.MissingField("level")))
+		return
+	}
+	lvl, err := zerolog.ParseLevel(rb.Level)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("level"), err))
+		return
+	}
+	if rb.TTLSeconds < 0 {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("ttl_seconds"), errors.New("ttl_seconds must not be negative")))
+		return
+	}
+
+	st := h.LevelController.SetLevel(lvl, time.Duration(rb.TTLSeconds)*time.Second)
+
+	// logged at warn, so the change is recorded at any level
+	logger.Warn().
+		Str("level", st.Level.String()).
+		Int("ttl_seconds", rb.TTLSeconds).
+		Msg("log level changed")
+
+	writeResponse(w, r, newLogLevelResponse(st))
+}
+
+// IssueDebugTokenHandler is a Handler that issues debug tokens
+type IssueDebugTokenHandler http.Handler
+
+// ProvideIssueDebugTokenHandler is a provider for the
+// IssueDebugTokenHandler for wire
+func ProvideIssueDebugTokenHandler(h DefaultLogLevelHandlers) IssueDebugTokenHandler {
+	return http.HandlerFunc(h.IssueDebugToken)
+}
+
+// IssueDebugToken handles POST requests for the
+// /admin/loglevel/debug-token endpoint. Requests sent with the token
+// in the X-Debug-Log header are logged at debug level until the
+// token expires.
+func (h DefaultLogLevelHandlers) IssueDebugToken(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	rb := new(debugTokenRequestBody)
+	err := json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	ttl := time.Duration(rb.TTLSeconds) * time.Second
+	if ttl == 0 {
+		ttl = defaultDebugTokenTTL
+	}
+
+	token, exp, err := h.DebugTokenSigner.Sign(ttl)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("ttl_seconds"), err))
+		return
+	}
+
+	logger.Warn().Time("expiry", exp).Msg("debug token issued")
+
+	writeResponse(w, r, debugTokenResponse{
+		Token:           token,
+		Header:          debugLogHeader,
+		ExpiryTimestamp: exp.Format(time.RFC3339),
+	})
+}
+
+// LogLevelHandler middleware sets the level of the request logger to
+// the level of the LevelController, or to debug if the request has a
+// valid debug token in the X-Debug-Log header. It must follow the
+// LoggerHandlerChain, as it changes the logger added to the request
+// context, which is also used to write the access log.
+func (mw Middleware) LogLevelHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			lgr := hlog.FromRequest(r)
+			// without a request logger, zerolog returns its shared
+			// disabled logger, which must not be changed
+			if lgr == zerolog.Ctx(context.Background()) {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			lvl := lgr.GetLevel()
+			if mw.LevelController != nil {
+				lvl = mw.LevelController.Level()
+			}
+
+			var tokenErr error
+			if token := r.Header.Get(debugLogHeader); token != "" {
+				tokenErr = mw.DebugTokenSigner.Verify(token)
+				if tokenErr == nil && lvl > zerolog.DebugLevel {
+					lvl = zerolog.DebugLevel
+				}
+			}
+
+			*lgr = lgr.Level(lvl)
+
+			if tokenErr != nil {
+				lgr.Warn().Err(tokenErr).Msg("debug log token rejected")
+			}
+
+			h.ServeHTTP(w, r)
+		})
+}
@@ -0,0 +1,174 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+func TestDefaultLogLevelHandlers_UpdateLogLevel(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        string
+		wantCode    int
+		wantLevel   zerolog.Level
+		wantDefault zerolog.Level
+		wantRevert  bool
+	}{
+		{"permanent", `{"level":"warn"}`, http.StatusOK, zerolog.WarnLevel, zerolog.WarnLevel, false},
+		{"ttl", `{"level":"debug","ttl_seconds":600}`, http.StatusOK, zerolog.DebugLevel, zerolog.InfoLevel, true},
+		{"unknown level", `{"level":"loud"}`, http.StatusBadRequest, zerolog.InfoLevel, zerolog.InfoLevel, false},
+		{"no level", `{}`, http.StatusBadRequest, zerolog.InfoLevel, zerolog.InfoLevel, false},
+		{"negative ttl", `{"level":"debug","ttl_seconds":-1}`, http.StatusBadRequest, zerolog.InfoLevel, zerolog.InfoLevel, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			h := DefaultLogLevelHandlers{LevelController: logger.NewLevelController(zerolog.InfoLevel)}
+
+			lgr := logger.NewLogger(&bytes.Buffer{}, true)
+			req := httptest.NewRequest(http.MethodPut, pathPrefix+adminV1PathRoot+"/loglevel", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).
+				Then(ProvideUpdateLogLevelHandler(h)).
+				ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+
+			st := h.LevelController.State()
+			c.Assert(st.Level, qt.Equals, tt.wantLevel)
+			c.Assert(st.DefaultLevel, qt.Equals, tt.wantDefault)
+			c.Assert(st.RevertAt.IsZero(), qt.Equals, !tt.wantRevert)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got struct {
+				Data logLevelResponse `json:"data"`
+			}
+			err := json.NewDecoder(rr.Body).Decode(&got)
+			c.Assert(err, qt.IsNil)
+			c.Assert(got.Data.Level, qt.Equals, tt.wantLevel.String())
+			c.Assert(got.Data.DefaultLevel, qt.Equals, tt.wantDefault.String())
+			c.Assert(got.Data.RevertTimestamp != "", qt.Equals, tt.wantRevert)
+		})
+	}
+}
+
+func TestDefaultLogLevelHandlers_IssueDebugToken(t *testing.T) {
+	signer := logger.DebugTokenSigner{Key: []byte("debug-key")}
+
+	tests := []struct {
+		name     string
+		body     string
+		wantCode int
+	}{
+		{"default ttl", `{}`, http.StatusOK},
+		{"ttl", `{"ttl_seconds":60}`, http.StatusOK},
+		{"too long", `{"ttl_seconds":86400}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(&bytes.Buffer{}, true)
+			req := httptest.NewRequest(http.MethodPost, pathPrefix+adminV1PathRoot+"/loglevel/debug-token", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).
+				Then(ProvideIssueDebugTokenHandler(DefaultLogLevelHandlers{DebugTokenSigner: signer})).
+				ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got struct {
+				Data debugTokenResponse `json:"data"`
+			}
+			err := json.NewDecoder(rr.Body).Decode(&got)
+			c.Assert(err, qt.IsNil)
+			c.Assert(got.Data.Header, qt.Equals, debugLogHeader)
+			c.Assert(signer.Verify(got.Data.Token), qt.IsNil)
+		})
+	}
+}
+
+func TestMiddleware_LogLevelHandler(t *testing.T) {
+	signer := logger.DebugTokenSigner{Key: []byte("debug-key")}
+	token, _, err := signer.Sign(time.Minute)
+	qt.Assert(t, err, qt.IsNil)
+
+	tests := []struct {
+		name      string
+		level     zerolog.Level
+		header    string
+		wantDebug bool
+		wantWarn  bool
+	}{
+		{"info", zerolog.InfoLevel, "", false, false},
+		{"debug", zerolog.DebugLevel, "", true, false},
+		{"debug token", zerolog.InfoLevel, token, true, false},
+		{"invalid debug token", zerolog.InfoLevel, "1.bad", false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			mw := Middleware{
+				LevelController:  logger.NewLevelController(tt.level),
+				DebugTokenSigner: signer,
+			}
+
+			var buf bytes.Buffer
+			lgr := logger.NewLogger(&buf, false)
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				l := zerolog.Ctx(r.Context())
+				l.Debug().Msg("debug message")
+			})
+
+			req := httptest.NewRequest(http.MethodGet, pathPrefix+"/v1/ping", nil)
+			if tt.header != "" {
+				req.Header.Set(debugLogHeader, tt.header)
+			}
+			LoggerHandlerChain(lgr, alice.New()).
+				Append(mw.LogLevelHandler).
+				Then(h).
+				ServeHTTP(httptest.NewRecorder(), req)
+
+			c.Assert(strings.Contains(buf.String(), "debug message"), qt.Equals, tt.wantDebug)
+			c.Assert(strings.Contains(buf.String(), "debug log token rejected"), qt.Equals, tt.wantWarn)
+		})
+	}
+
+	t.Run("runtime change", func(t *testing.T) {
+		c := qt.New(t)
+
+		mw := Middleware{LevelController: logger.NewLevelController(zerolog.InfoLevel)}
+
+		var buf bytes.Buffer
+		chain := LoggerHandlerChain(logger.NewLogger(&buf, false), alice.New()).
+			Append(mw.LogLevelHandler)
+
+		chain.Then(http.NotFoundHandler()).
+			ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		c.Assert(buf.Len() > 0, qt.IsTrue, qt.Commentf("access log written at info"))
+
+		buf.Reset()
+		mw.LevelController.SetLevel(zerolog.WarnLevel, 0)
+		chain.Then(http.NotFoundHandler()).
+			ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		c.Assert(buf.Len(), qt.Equals, 0, qt.Commentf("access log dropped at warn"))
+	})
+}
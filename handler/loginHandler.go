@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+	"golang.org/x/oauth2"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/random"
+)
+
+const (
+	// sessionCookieName is the name of the encrypted session cookie
+	sessionCookieName string = "session"
+	// csrfCookieName is the name of the cookie holding the CSRF token
+	// of the session. Unlike the session cookie, it is readable by
+	// the front-end, which must echo it in the X-CSRF-Token header.
+	csrfCookieName string = "csrf_token"
+	// csrfHeader is the request header the CSRF token is echoed in
+	csrfHeader string = "X-CSRF-Token"
+	// loginStateCookieName is the name of the encrypted cookie holding
+	// the auth.LoginState during the login flow
+	loginStateCookieName string = "oauth_state"
+	// loginStateTTL is how long the user has to complete the login
+	// flow with the identity provider
+	loginStateTTL time.Duration = 10 * time.Minute
+)
+
+// LoginRedirectURL is the URL the browser is redirected to after
+// logging in or out, typically the web front-end
+type LoginRedirectURL string
+
+// DefaultLoginHandlers are the handlers for the OAuth2
+// authorization-code login flow used by browsers. Each method on the
+// struct is a separate handler.
+type DefaultLoginHandlers struct {
+	// OAuth2Config is nil if the login flow is not configured
+	OAuth2Config          *oauth2.Config
+	SessionCodec          auth.SessionCodec
+	RandomStringGenerator random.StringGenerator
+	LoginRedirectURL      LoginRedirectURL
+}
+
+// oauth2Config returns the OAuth2Config or an error if the login flow
+// is not configured
+func (h DefaultLoginHandlers) oauth2Config() (*oauth2.Config, error) {
+	if h.OAuth2Config == nil || h.OAuth2Config.ClientID == "" {
+		return nil, errs.E(errs.Internal, errors.New("browser login is not configured"))
+	}
+	return h.OAuth2Config, nil
+}
+
+// secureCookies reports whether the cookies of the login flow are
+// only sent over HTTPS. They are unless the OAuth2 redirect URL is
+// plain HTTP, e.g. http://localhost for local development, as the
+// browser would otherwise never send them back to the callback.
+func (h DefaultLoginHandlers) secureCookies() bool {
+	if h.OAuth2Config == nil {
+		return true
+	}
+	u, err := url.Parse(h.OAuth2Config.RedirectURL)
+	if err != nil {
+		return true
+	}
+	return u.Scheme != "http"
+}
+
+// LoginHandler is a Handler that starts the login flow
+type LoginHandler http.Handler
+
+// ProvideLoginHandler is a provider for the
+// LoginHandler for wire
+func ProvideLoginHandler(h DefaultLoginHandlers) LoginHandler {
+	return http.HandlerFunc(h.Login)
+}
+
+// Login handles GET requests for the /auth/login endpoint. It
+// redirects the browser to the identity provider with a PKCE code
+// challenge and keeps the state and code verifier in an encrypted
+// cookie for the callback.
+func (h DefaultLoginHandlers) Login(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	cfg, err := h.oauth2Config()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	state, err := h.RandomStringGenerator.CryptoString(24)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+	verifier, err := auth.NewCodeVerifier()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	v, err := h.SessionCodec.Seal(auth.LoginState{State: state, CodeVerifier: verifier})
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginStateCookieName,
+		Value:    v,
+		Path:     pathPrefix + authV1PathRoot,
+		MaxAge:   int(loginStateTTL.Seconds()),
+		HttpOnly: true,
+		Secure:   h.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	url := cfg.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", auth.CodeChallenge(verifier)),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"))
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// LoginCallbackHandler is a Handler that completes the login flow
+type LoginCallbackHandler http.Handler
+
+// ProvideLoginCallbackHandler is a provider for the
+// LoginCallbackHandler for wire
+func ProvideLoginCallbackHandler(h DefaultLoginHandlers) LoginCallbackHandler {
+	return http.HandlerFunc(h.LoginCallback)
+}
+
+// LoginCallback handles GET requests for the /auth/callback endpoint,
+// which the identity provider redirects the browser to. The
+// authorization code is exchanged for an access token using the PKCE
+// code verifier, and the token is stored in a new session cookie
+// before the browser is redirected to the LoginRedirectURL.
+func (h DefaultLoginHandlers) LoginCallback(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	cfg, err := h.oauth2Config()
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	ls, err := h.loginState(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+	// the login state is single use
+	clearCookie(w, loginStateCookieName, pathPrefix+authV1PathRoot, true, h.secureCookies())
+
+	q := r.URL.Query()
+	if e := q.Get("error"); e != "" {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Unauthenticated, errors.Errorf("login failed: %s", e)))
+		return
+	}
+	if q.Get("state") != ls.State {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Unauthenticated, errors.New("login state does not match")))
+		return
+	}
+
+	token, err := cfg.Exchange(r.Context(), q.Get("code"), oauth2.SetAuthURLParam("code_verifier", ls.CodeVerifier))
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Unauthenticated, errs.InvalidToken("The authorization code is invalid or has expired"), err))
+		return
+	}
+
+	err = h.setSession(w, token)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	http.Redirect(w, r, string(h.LoginRedirectURL), http.StatusFound)
+}
+
+// loginState reads the auth.LoginState from its cookie
+func (h DefaultLoginHandlers) loginState(r *http.Request) (auth.LoginState, error) {
+	var ls auth.LoginState
+
+	c, err := r.Cookie(loginStateCookieName)
+	if err != nil {
+		return ls, errs.E(errs.Unauthenticated, errors.New("login state cookie not found, start the login again"))
+	}
+
+	err = h.SessionCodec.Open(c.Value, &ls)
+	if err != nil {
+		return ls, err
+	}
+
+	return ls, nil
+}
+
+// setSession sets the session cookie for the token, along with the
+// cookie holding its CSRF token
+func (h DefaultLoginHandlers) setSession(w http.ResponseWriter, token *oauth2.Token) error {
+	csrf, err := h.RandomStringGenerator.CryptoString(24)
+	if err != nil {
+		return err
+	}
+
+	expiry := token.Expiry
+	if expiry.IsZero() {
+		expiry = time.Now().Add(auth.DefaultSessionTTL)
+	}
+
+	v, err := h.SessionCodec.Seal(auth.Session{AccessToken: token.AccessToken, Expiry: expiry, CSRFToken: csrf})
+	if err != nil {
+		return err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    v,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		Secure:   h.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    csrf,
+		Path:     "/",
+		Expires:  expiry,
+		Secure:   h.secureCookies(),
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	return nil
+}
+
+// LogoutHandler is a Handler that ends the session
+type LogoutHandler http.Handler
+
+// ProvideLogoutHandler is a provider for the
+// LogoutHandler for wire
+func ProvideLogoutHandler(h DefaultLoginHandlers) LogoutHandler {
+	return http.HandlerFunc(h.Logout)
+}
+
+// Logout handles POST requests for the /auth/logout endpoint. The
+// request must echo the session's CSRF token in the X-CSRF-Token
+// header, so other sites cannot log the user out. The session and
+// CSRF cookies are cleared and the browser is redirected to the
+// LoginRedirectURL. Without a valid session, there is nothing to
+// protect, so the cookies are cleared all the same.
+func (h DefaultLoginHandlers) Logout(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	if c, err := r.Cookie(sessionCookieName); err == nil {
+		var s auth.Session
+		if h.SessionCodec.Open(c.Value, &s) == nil {
+			err = verifyCSRF(r, s)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+		}
+	}
+
+	clearCookie(w, sessionCookieName, "/", true, h.secureCookies())
+	clearCookie(w, csrfCookieName, "/", false, h.secureCookies())
+
+	http.Redirect(w, r, string(h.LoginRedirectURL), http.StatusSeeOther)
+}
+
+// verifyCSRF returns an error unless the request echoes the CSRF
+// token of the session in the X-CSRF-Token header
+func verifyCSRF(r *http.Request, s auth.Session) error {
+	v := r.Header.Get(csrfHeader)
+	if v == "" || subtle.ConstantTimeCompare([]byte(v), []byte(s.CSRFToken)) != 1 {
+		return errs.E(errs.Unauthorized, errors.New("missing or invalid CSRF token"))
+	}
+	return nil
+}
+
+// clearCookie tells the browser to delete the named cookie
+func clearCookie(w http.ResponseWriter, name, path string, httpOnly, secure bool) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    "",
+		Path:     path,
+		MaxAge:   -1,
+		HttpOnly: httpOnly,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
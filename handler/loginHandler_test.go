@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+	"golang.org/x/oauth2"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/random"
+)
+
+// newTestSessionCodec returns a SessionCodec with a fixed key
+func newTestSessionCodec(t *testing.T) auth.SessionCodec {
+	t.Helper()
+	codec, err := auth.NewSessionCodec(bytes.Repeat([]byte("k"), auth.SessionKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codec
+}
+
+// newTokenServer returns a fake OAuth2 token endpoint which issues
+// an access token for the given code if the request carries the code
+// verifier matching challenge
+func newTokenServer(t *testing.T, code string, challenge *string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("code") != code || auth.CodeChallenge(r.FormValue("code_verifier")) != *challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_grant"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"access_token":"ya29.browser-token","token_type":"Bearer","expires_in":3600}`))
+	}))
+}
+
+// serveLogin serves the request with the login handler chain
+func serveLogin(h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	lgr := logger.NewLogger(os.Stdout, true)
+	rr := httptest.NewRecorder()
+	LoggerHandlerChain(lgr, alice.New()).Then(h).ServeHTTP(rr, req)
+	return rr
+}
+
+// responseCookie returns the named cookie set by the response
+func responseCookie(rr *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestDefaultLoginHandlers_LoginFlow(t *testing.T) {
+	c := qt.New(t)
+
+	var challenge string
+	tokenSrv := newTokenServer(t, "auth-code", &challenge)
+	defer tokenSrv.Close()
+
+	h := DefaultLoginHandlers{
+		OAuth2Config: &oauth2.Config{
+			ClientID:    "client-id",
+			Endpoint:    oauth2.Endpoint{AuthURL: "https://accounts.example.com/auth", TokenURL: tokenSrv.URL},
+			RedirectURL: "https://movies.example.com/api/v1/auth/callback",
+		},
+		SessionCodec:          newTestSessionCodec(t),
+		RandomStringGenerator: random.DefaultStringGenerator{},
+		LoginRedirectURL:      "/app",
+	}
+
+	// login redirects to the identity provider with a PKCE challenge
+	rr := serveLogin(ProvideLoginHandler(h), httptest.NewRequest(http.MethodGet, pathPrefix+authV1PathRoot+"/login", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusFound)
+
+	loc, err := url.Parse(rr.Header().Get("Location"))
+	c.Assert(err, qt.IsNil)
+	c.Assert(loc.Host, qt.Equals, "accounts.example.com")
+	c.Assert(loc.Query().Get("code_challenge_method"), qt.Equals, "S256")
+	challenge = loc.Query().Get("code_challenge")
+	state := loc.Query().Get("state")
+
+	stateCookie := responseCookie(rr, loginStateCookieName)
+	c.Assert(stateCookie, qt.Not(qt.IsNil))
+	c.Assert(stateCookie.HttpOnly, qt.IsTrue)
+	c.Assert(stateCookie.Secure, qt.IsTrue)
+
+	callback := func(state string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, pathPrefix+authV1PathRoot+"/callback?code=auth-code&state="+state, nil)
+		req.AddCookie(stateCookie)
+		return serveLogin(ProvideLoginCallbackHandler(h), req)
+	}
+
+	// the callback is rejected if the state does not match
+	rr = callback("forged-state")
+	c.Assert(rr.Code, qt.Equals, http.StatusUnauthorized)
+
+	// otherwise the code is exchanged and the session is set
+	rr = callback(state)
+	c.Assert(rr.Code, qt.Equals, http.StatusFound)
+	c.Assert(rr.Header().Get("Location"), qt.Equals, "/app")
+
+	sessionCookie := responseCookie(rr, sessionCookieName)
+	c.Assert(sessionCookie, qt.Not(qt.IsNil))
+	c.Assert(sessionCookie.HttpOnly, qt.IsTrue)
+	c.Assert(sessionCookie.Secure, qt.IsTrue)
+
+	var s auth.Session
+	err = h.SessionCodec.Open(sessionCookie.Value, &s)
+	c.Assert(err, qt.IsNil)
+	c.Assert(s.AccessToken, qt.Equals, "ya29.browser-token")
+
+	csrfCookie := responseCookie(rr, csrfCookieName)
+	c.Assert(csrfCookie, qt.Not(qt.IsNil))
+	c.Assert(csrfCookie.HttpOnly, qt.IsFalse)
+	c.Assert(csrfCookie.Value, qt.Equals, s.CSRFToken)
+
+	logout := func(csrf string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, pathPrefix+authV1PathRoot+"/logout", nil)
+		req.AddCookie(sessionCookie)
+		if csrf != "" {
+			req.Header.Set(csrfHeader, csrf)
+		}
+		return serveLogin(ProvideLogoutHandler(h), req)
+	}
+
+	// logout requires the session's CSRF token
+	rr = logout("")
+	c.Assert(rr.Code, qt.Equals, http.StatusForbidden)
+	c.Assert(responseCookie(rr, sessionCookieName), qt.IsNil)
+	rr = logout("forged-token")
+	c.Assert(rr.Code, qt.Equals, http.StatusForbidden)
+
+	// logout clears the session
+	rr = logout(s.CSRFToken)
+	c.Assert(rr.Code, qt.Equals, http.StatusSeeOther)
+	c.Assert(responseCookie(rr, sessionCookieName).MaxAge, qt.Equals, -1)
+
+	// without a session, there is nothing to protect
+	rr = serveLogin(ProvideLogoutHandler(h), httptest.NewRequest(http.MethodPost, pathPrefix+authV1PathRoot+"/logout", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusSeeOther)
+}
+
+func TestDefaultLoginHandlers_secureCookies(t *testing.T) {
+	tests := []struct {
+		name        string
+		redirectURL string
+		want        bool
+	}{
+		{"https", "https://movies.example.com/api/v1/auth/callback", true},
+		{"http localhost", "http://localhost:8080/api/v1/auth/callback", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			h := DefaultLoginHandlers{
+				OAuth2Config: &oauth2.Config{
+					ClientID:    "client-id",
+					Endpoint:    oauth2.Endpoint{AuthURL: "https://accounts.example.com/auth"},
+					RedirectURL: tt.redirectURL,
+				},
+				SessionCodec:          newTestSessionCodec(t),
+				RandomStringGenerator: random.DefaultStringGenerator{},
+			}
+
+			rr := serveLogin(ProvideLoginHandler(h), httptest.NewRequest(http.MethodGet, pathPrefix+authV1PathRoot+"/login", nil))
+			c.Assert(rr.Code, qt.Equals, http.StatusFound)
+			c.Assert(responseCookie(rr, loginStateCookieName).Secure, qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestDefaultLoginHandlers_NotConfigured(t *testing.T) {
+	c := qt.New(t)
+
+	h := DefaultLoginHandlers{SessionCodec: newTestSessionCodec(t), RandomStringGenerator: random.DefaultStringGenerator{}}
+
+	rr := serveLogin(ProvideLoginHandler(h), httptest.NewRequest(http.MethodGet, pathPrefix+authV1PathRoot+"/login", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusInternalServerError)
+}
@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/datastore/userstore"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// DefaultMeHandlers are the default handlers for the current user
+// to view and maintain their own profile. Each method on the struct
+// is a separate handler.
+type DefaultMeHandlers struct {
+	UserTransactor userstore.Transactor
+	RoleFinder     auth.RoleFinder
+}
+
+// meResponse is the response struct for the current user
+type meResponse struct {
+	ID           string   `json:"user_id"`
+	Email        string   `json:"email"`
+	FirstName    string   `json:"first_name"`
+	LastName     string   `json:"last_name"`
+	FullName     string   `json:"full_name"`
+	HostedDomain string   `json:"hosted_domain,omitempty"`
+	PictureURL   string   `json:"picture_url,omitempty"`
+	ProfileLink  string   `json:"profile_link,omitempty"`
+	Roles        []string `json:"roles"`
+}
+
+// newMeResponse initializes a meResponse from the User and the
+// roles assigned to them
+func (h DefaultMeHandlers) newMeResponse(r *http.Request, u user.User) (meResponse, error) {
+	roles, err := h.RoleFinder.FindRolesByUserEmail(r.Context(), u.Email)
+	if err != nil {
+		return meResponse{}, err
+	}
+
+	names := make([]string, 0, len(roles))
+	for _, role := range roles {
+		names = append(names, role.Name)
+	}
+
+	return meResponse{
+		ID:           u.ID.String(),
+		Email:        u.Email,
+		FirstName:    u.FirstName,
+		LastName:     u.LastName,
+		FullName:     u.FullName,
+		HostedDomain: u.HostedDomain,
+		PictureURL:   u.PictureURL,
+		ProfileLink:  u.ProfileLink,
+		Roles:        names,
+	}, nil
+}
+
+// FindMeHandler is a Handler that returns the current user
+type FindMeHandler http.Handler
+
+// ProvideFindMeHandler is a provider for the
+// FindMeHandler for wire
+func ProvideFindMeHandler(h DefaultMeHandlers) FindMeHandler {
+	return http.HandlerFunc(h.FindMe)
+}
+
+// FindMe handles GET requests for the /me endpoint and returns
+// the stored profile and roles of the current user
+func (h DefaultMeHandlers) FindMe(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	u, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	mr, err := h.newMeResponse(r, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, mr)
+}
+
+// UpdateMeHandler is a Handler that updates the current user
+type UpdateMeHandler http.Handler
+
+// ProvideUpdateMeHandler is a provider for the
+// UpdateMeHandler for wire
+func ProvideUpdateMeHandler(h DefaultMeHandlers) UpdateMeHandler {
+	return http.HandlerFunc(h.UpdateMe)
+}
+
+// UpdateMe handles PUT requests for the /me endpoint and updates
+// the display fields of the current user. The email cannot be
+// changed as it identifies the user.
+func (h DefaultMeHandlers) UpdateMe(w http.ResponseWriter, r *http.Request) {
+	// updateMeRequestBody is the request struct for UpdateMe
+	type updateMeRequestBody struct {
+		FirstName string `json:"first_name"`
+		LastName  string `json:"last_name"`
+		FullName  string `json:"full_name"`
+	}
+
+	logger := *hlog.FromRequest(r)
+
+	u, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(updateMeRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	u.FirstName = rb.FirstName
+	u.LastName = rb.LastName
+	u.FullName = rb.FullName
+
+	err = validateUser(u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.UserTransactor.Update(r.Context(), u, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	mr, err := h.newMeResponse(r, u)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, mr)
+}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/justinas/alice"
+
+	"github.com/gilcrest/go-api-basic/datastore/authstore/authstoretest"
+	"github.com/gilcrest/go-api-basic/datastore/userstore/userstoretest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+)
+
+// serveMe sends the request through the user handler chain used
+// for the /me routes
+func serveMe(t *testing.T, h http.Handler, req *http.Request) *httptest.ResponseRecorder {
+	t.Helper()
+
+	lgr := logger.NewLogger(os.Stdout, true)
+
+	mw := Middleware{
+		AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+		UserSelector:         userstoretest.NewMockSelector(t),
+		UserTransactor:       userstoretest.NewMockTransactor(t),
+	}
+
+	req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+	rr := httptest.NewRecorder()
+	userHandlerChain(mw, LoggerHandlerChain(lgr, alice.New())).
+		Append(JSONContentTypeHandler).
+		Then(h).
+		ServeHTTP(rr, req)
+
+	return rr
+}
+
+func TestDefaultMeHandlers_FindMe(t *testing.T) {
+	c := qt.New(t)
+
+	dmh := DefaultMeHandlers{
+		UserTransactor: userstoretest.NewMockTransactor(t),
+		RoleFinder:     authstoretest.NewMockSelector(t),
+	}
+
+	req := httptest.NewRequest(http.MethodGet, pathPrefix+meV1PathRoot, nil)
+	rr := serveMe(t, ProvideFindMeHandler(dmh), req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	type standardResponse struct {
+		Data meResponse `json:"data"`
+	}
+	var got standardResponse
+	err := json.NewDecoder(rr.Body).Decode(&got)
+	c.Assert(err, qt.IsNil)
+
+	want := meResponse{
+		ID:        userstoretest.UserID.String(),
+		Email:     "otto.maddox711@gmail.com",
+		FirstName: "Otto",
+		LastName:  "Maddox",
+		FullName:  "Otto Maddox",
+		Roles:     []string{"movie-admin"},
+	}
+	c.Assert(got.Data, qt.DeepEquals, want)
+}
+
+func TestDefaultMeHandlers_UpdateMe(t *testing.T) {
+	dmh := DefaultMeHandlers{
+		UserTransactor: userstoretest.NewMockTransactor(t),
+		RoleFinder:     authstoretest.NewMockSelector(t),
+	}
+
+	t.Run("typical", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(map[string]string{
+			"first_name": "Bud",
+			"last_name":  "Repo",
+			"full_name":  "Bud Repo",
+			// email is not updatable and is ignored
+			"email": "someone.else@example.com",
+		})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPut, pathPrefix+meV1PathRoot, &buf)
+		rr := serveMe(t, ProvideUpdateMeHandler(dmh), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		type standardResponse struct {
+			Data meResponse `json:"data"`
+		}
+		var got standardResponse
+		err = json.NewDecoder(rr.Body).Decode(&got)
+		c.Assert(err, qt.IsNil)
+		c.Assert(got.Data.Email, qt.Equals, "otto.maddox711@gmail.com")
+		c.Assert(got.Data.FirstName, qt.Equals, "Bud")
+		c.Assert(got.Data.LastName, qt.Equals, "Repo")
+		c.Assert(got.Data.FullName, qt.Equals, "Bud Repo")
+	})
+
+	t.Run("missing last name", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(map[string]string{"first_name": "Bud"})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPut, pathPrefix+meV1PathRoot, &buf)
+		rr := serveMe(t, ProvideUpdateMeHandler(dmh), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+	})
+}
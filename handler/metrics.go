@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// unmatchedRoute is the route label for requests without a mux route
+const unmatchedRoute string = "unmatched"
+
+// Metrics are the Prometheus RED (rate, errors, duration) metrics of
+// the API, labeled by mux route template and method, along with the
+// outcome of access token conversions
+type Metrics struct {
+	requests    *prometheus.CounterVec
+	errors      *prometheus.CounterVec
+	duration    *prometheus.HistogramVec
+	conversions *prometheus.CounterVec
+}
+
+// NewMetrics returns Metrics registered with reg
+func NewMetrics(reg prometheus.Registerer) (*Metrics, error) {
+	m := &Metrics{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_requests_total",
+			Help: "Number of HTTP requests by route, method and status code.",
+		}, []string{"route", "method", "code"}),
+		errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "http_request_errors_total",
+			Help: "Number of HTTP requests which failed, by route, method and error kind.",
+		}, []string{"route", "method", "kind"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "http_request_duration_seconds",
+			Help:    "Latency of HTTP requests by route and method.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		conversions: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "auth_token_conversions_total",
+			Help: "Number of access token conversions by token type and result.",
+		}, []string{"token_type", "result"}),
+	}
+
+	for _, c := range []prometheus.Collector{m.requests, m.errors, m.duration, m.conversions} {
+		if err := reg.Register(c); err != nil {
+			return nil, errs.E(errs.Internal, err)
+		}
+	}
+
+	return m, nil
+}
+
+// MetricsHandler is a Handler that serves the metrics in the
+// Prometheus exposition format
+type MetricsHandler http.Handler
+
+// NewMetricsHandler returns a MetricsHandler serving the metrics
+// gathered from g
+func NewMetricsHandler(g prometheus.Gatherer) MetricsHandler {
+	return promhttp.HandlerFor(g, promhttp.HandlerOpts{})
+}
+
+// MetricsHandler middleware records the request count, error count by
+// errs.Kind and latency of requests. It must be in the chain of a
+// mux route, so requests are labeled by route template rather than
+// raw path.
+func (mw Middleware) MetricsHandler(h http.Handler) http.Handler {
+	if mw.Metrics == nil {
+		return h
+	}
+	m := mw.Metrics
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := newStatusRecorder(w)
+
+			h.ServeHTTP(rw, r)
+
+			route, err := routePathTemplate(r)
+			if err != nil {
+				route = unmatchedRoute
+			}
+			m.requests.WithLabelValues(route, r.Method, strconv.Itoa(rw.status)).Inc()
+			m.duration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+			if rw.hasKind {
+				m.errors.WithLabelValues(route, r.Method, rw.kind.String()).Inc()
+			}
+		})
+}
+
+// InstrumentConverter returns c with the outcome of each conversion
+// counted by token type. If m is nil, c is returned as is.
+func (m *Metrics) InstrumentConverter(c auth.AccessTokenConverter) auth.AccessTokenConverter {
+	if m == nil {
+		return c
+	}
+	return instrumentedConverter{converter: c, conversions: m.conversions}
+}
+
+// instrumentedConverter counts the conversions of an
+// auth.AccessTokenConverter
+type instrumentedConverter struct {
+	converter   auth.AccessTokenConverter
+	conversions *prometheus.CounterVec
+}
+
+// Convert converts the token and counts the result, either "ok" or
+// the errs.Kind of the error
+func (c instrumentedConverter) Convert(ctx context.Context, token auth.AccessToken) (user.User, auth.Scopes, error) {
+	u, scopes, err := c.converter.Convert(ctx, token)
+
+	result := "ok"
+	if err != nil {
+		result = errs.Unanticipated.String()
+		var e *errs.Error
+		if errors.As(err, &e) {
+			result = e.Kind.String()
+		}
+	}
+	c.conversions.WithLabelValues(token.TokenType, result).Inc()
+
+	return u, scopes, err
+}
@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+func TestMiddleware_MetricsHandler(t *testing.T) {
+	c := qt.New(t)
+
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(reg)
+	c.Assert(err, qt.IsNil)
+	mw := Middleware{Metrics: m}
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	chain := LoggerHandlerChain(lgr, alice.New()).Append(mw.MetricsHandler)
+
+	rtr := mux.NewRouter()
+	rtr.Handle(moviesV1PathRoot+"/{extlID}",
+		chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+			if mux.Vars(r)["extlID"] == "missing" {
+				errs.HTTPErrorResponse(w, *hlog.FromRequest(r), errs.E(errs.NotExist, errors.New("movie not found")))
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+		})).Methods(http.MethodGet)
+
+	for _, id := range []string{"a", "b", "missing"} {
+		rr := httptest.NewRecorder()
+		rtr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, moviesV1PathRoot+"/"+id, nil))
+	}
+
+	route := moviesV1PathRoot + "/{extlID}"
+	c.Assert(testutil.ToFloat64(m.requests.WithLabelValues(route, http.MethodGet, "200")), qt.Equals, float64(2))
+	c.Assert(testutil.ToFloat64(m.requests.WithLabelValues(route, http.MethodGet, "400")), qt.Equals, float64(1))
+	c.Assert(testutil.ToFloat64(m.errors.WithLabelValues(route, http.MethodGet, errs.NotExist.String())), qt.Equals, float64(1))
+	c.Assert(testutil.CollectAndCount(m.duration), qt.Equals, 1)
+}
+
+// failingConverter fails every conversion with an Unauthenticated error
+type failingConverter struct{}
+
+func (failingConverter) Convert(ctx context.Context, token auth.AccessToken) (user.User, auth.Scopes, error) {
+	return user.User{}, nil, errs.E(errs.Unauthenticated, errors.New("invalid token"))
+}
+
+func TestMetrics_InstrumentConverter(t *testing.T) {
+	c := qt.New(t)
+
+	m, err := NewMetrics(prometheus.NewRegistry())
+	c.Assert(err, qt.IsNil)
+
+	ctx := context.Background()
+	token := authtest.NewAccessToken(t)
+
+	_, _, err = m.InstrumentConverter(authtest.NewMockAccessTokenConverter(t)).Convert(ctx, token)
+	c.Assert(err, qt.IsNil)
+	_, _, err = m.InstrumentConverter(failingConverter{}).Convert(ctx, token)
+	c.Assert(errs.KindIs(errs.Unauthenticated, err), qt.IsTrue)
+
+	c.Assert(testutil.ToFloat64(m.conversions.WithLabelValues(auth.BearerTokenType, "ok")), qt.Equals, float64(1))
+	c.Assert(testutil.ToFloat64(m.conversions.WithLabelValues(auth.BearerTokenType, errs.Unauthenticated.String())), qt.Equals, float64(1))
+
+	// a nil Metrics leaves the converter as is
+	var nilMetrics *Metrics
+	conv := authtest.NewMockAccessTokenConverter(t)
+	c.Assert(nilMetrics.InstrumentConverter(conv), qt.Equals, auth.AccessTokenConverter(conv))
+}
+
+func TestNewMuxRouter_Metrics(t *testing.T) {
+	c := qt.New(t)
+
+	reg := prometheus.NewRegistry()
+	m, err := NewMetrics(reg)
+	c.Assert(err, qt.IsNil)
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	rtr := NewMuxRouter(lgr, Middleware{Metrics: m}, Handlers{
+		PingHandler:    http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+		MetricsHandler: NewMetricsHandler(reg),
+	})
+
+	rtr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, pathPrefix+"/v1/ping", nil))
+
+	rr := httptest.NewRecorder()
+	rtr.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(strings.Contains(rr.Body.String(), `http_requests_total{code="200",method="GET",route="/api/v1/ping"} 1`), qt.IsTrue)
+}
@@ -0,0 +1,458 @@
+package handler
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/datastore/userstore"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/org"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+const (
+	// clientIDHeader is the request header used by registered clients
+	// to identify themselves
+	clientIDHeader string = "X-Client-ID"
+	// orgIDHeader is the request header used to choose the
+	// organization (tenant) a request acts on
+	orgIDHeader string = "X-Org-Id"
+	// signatureHeader is the request header holding the HMAC
+	// signature of a signed request
+	signatureHeader string = "X-Signature"
+	// signatureTimestampHeader is the request header holding the
+	// time a signed request was signed, in Unix seconds
+	signatureTimestampHeader string = "X-Signature-Timestamp"
+	// signatureNonceHeader is the request header holding the nonce
+	// of a signed request, a value the client never reuses within
+	// the replay window
+	signatureNonceHeader string = "X-Signature-Nonce"
+	// maxSignedBodyBytes is the largest request body accepted for
+	// a signed request, as the body is read into memory to verify
+	// the signature
+	maxSignedBodyBytes int64 = 1 << 20
+)
+
+// AuthMode is the way users authenticate to the API
+type AuthMode string
+
+const (
+	// TokenAuthMode authenticates users with a Bearer token or API key
+	TokenAuthMode AuthMode = "token"
+	// MTLSAuthMode authenticates users with the TLS client certificate
+	// verified by the server
+	MTLSAuthMode AuthMode = "mtls"
+	// DevAuthMode authenticates users with dev tokens issued by the
+	// server itself, for local development without Google
+	DevAuthMode AuthMode = "dev"
+)
+
+// Middleware holds the dependencies needed by the middleware
+// which authenticate and authorize requests. Each method on the
+// struct is an alice.Constructor.
+type Middleware struct {
+	AuthMode             AuthMode
+	AccessTokenConverter auth.AccessTokenConverter
+	Authorizer           auth.Authorizer
+	UserSelector         userstore.Selector
+	UserTransactor       userstore.Transactor
+	ClientFinder         client.Finder
+	OrgFinder            org.Finder
+	SignatureVerifier    auth.SignatureVerifier
+	SessionCodec         auth.SessionCodec
+	Metrics              *Metrics
+	GCPProjectID         GCPProjectID
+	LevelController      *logger.LevelController
+	DebugTokenSigner     logger.DebugTokenSigner
+	BodyLog              BodyLogConfig
+	Timeouts             TimeoutConfig
+	RateLimit            RateLimitConfig
+}
+
+// ClientHandler middleware identifies the registered client calling
+// the API from the X-Client-ID header and sets the Client to the
+// request context. The header is optional, but if given, the client
+// must be registered and active.
+func (mw Middleware) ClientHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			logger := *hlog.FromRequest(r)
+
+			v := r.Header.Get(clientIDHeader)
+			if v == "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			c, err := mw.findClient(r, v)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			// call original, adding client to request context
+			h.ServeHTTP(w, r.WithContext(client.CtxWithClient(r.Context(), *c)))
+		})
+}
+
+// findClient finds the active Client for the client ID header value
+func (mw Middleware) findClient(r *http.Request, v string) (*client.Client, error) {
+	id, err := uuid.Parse(v)
+	if err != nil {
+		return nil, errs.E(errs.Unauthenticated, errors.New("invalid client ID"))
+	}
+
+	c, err := mw.ClientFinder.FindByID(r.Context(), id)
+	if errs.KindIs(errs.NotExist, err) {
+		return nil, errs.E(errs.Unauthenticated, errors.New("client not registered"))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !c.IsActive() {
+		return nil, errs.E(errs.Unauthenticated, errors.New("client is not active"))
+	}
+
+	return c, nil
+}
+
+// authenticationHandler returns the middleware which sets the
+// access token to the request context for the AuthMode
+func (mw Middleware) authenticationHandler() alice.Constructor {
+	if mw.AuthMode == MTLSAuthMode {
+		return CertificateHandler
+	}
+	return AccessTokenHandler
+}
+
+// CertificateHandler middleware authenticates a request with the TLS
+// client certificate verified by the server. The certificate is set
+// to the request context as an auth.AccessToken with the Certificate
+// token type, which UserHandler converts to a user. It is used in
+// place of AccessTokenHandler when the server runs in MTLSAuthMode.
+func CertificateHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			logger := *hlog.FromRequest(r)
+
+			// only trust certificates which the TLS handshake verified
+			// against the client CA
+			if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 || len(r.TLS.VerifiedChains[0]) == 0 {
+				errs.HTTPErrorResponse(w, logger, errs.E(errs.Unauthenticated, errors.New("Unauthenticated - no verified client certificate")))
+				return
+			}
+			cert := r.TLS.VerifiedChains[0][0]
+
+			// call original, adding the certificate as the access
+			// token to request context
+			ctx := auth.SetAccessToken2Context(r.Context(), auth.CertificateToken(cert), auth.CertificateTokenType)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+}
+
+// SessionHandler middleware authenticates a browser request with the
+// encrypted session cookie set by the login flow, setting the access
+// token held by the session to the request context as a Bearer
+// token. Requests with an unsafe method must echo the session's CSRF
+// token in the X-CSRF-Token header. Requests sending a Bearer token
+// or API key, or without a session cookie, are passed through to
+// AccessTokenHandler, which must be chained after SessionHandler.
+func (mw Middleware) SessionHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			logger := *hlog.FromRequest(r)
+
+			// tokens sent explicitly take precedence over the session
+			if r.Header.Get("Authorization") != "" || r.Header.Get(apiKeyHeader) != "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			c, err := r.Cookie(sessionCookieName)
+			if err != nil {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			s, err := mw.session(r, c)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			// call original, adding the session access token to
+			// request context
+			ctx := auth.SetAccessToken2Context(r.Context(), s.AccessToken, auth.BearerTokenType)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+}
+
+// session opens the session cookie and checks the session has not
+// expired and, for unsafe methods, that the request carries the
+// session's CSRF token
+func (mw Middleware) session(r *http.Request, c *http.Cookie) (auth.Session, error) {
+	var s auth.Session
+	err := mw.SessionCodec.Open(c.Value, &s)
+	if err != nil {
+		return s, err
+	}
+
+	if s.IsExpired(time.Now()) {
+		return s, errs.E(errs.Unauthenticated, errs.InvalidToken("The session has expired"), errors.New("session expired"))
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+	default:
+		err = verifyCSRF(r, s)
+		if err != nil {
+			return s, err
+		}
+	}
+
+	return s, nil
+}
+
+// SignatureHandler middleware authenticates a request signed by a
+// registered client with its shared secret. It is an alternative to
+// AccessTokenHandler for partners who cannot obtain an access token.
+// The X-Signature header must hold the HMAC-SHA256 of the method,
+// path, query, X-Signature-Timestamp header, X-Signature-Nonce header
+// and body. Once verified, the client ID is set to the request
+// context as an auth.AccessToken with the Signature token type, which
+// UserHandler converts to the client's owner. SignatureHandler must
+// be chained after ClientHandler.
+func (mw Middleware) SignatureHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			logger := *hlog.FromRequest(r)
+
+			c, ok := client.FromRequest(r)
+			if !ok {
+				errs.HTTPErrorResponse(w, logger, errs.E(errs.Unauthenticated, errors.New("signed requests must identify the client with the "+clientIDHeader+" header")))
+				return
+			}
+
+			body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxSignedBodyBytes+1))
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, errs.E(errs.InvalidRequest, err))
+				return
+			}
+			r.Body.Close()
+			if int64(len(body)) > maxSignedBodyBytes {
+				errs.HTTPErrorResponse(w, logger, errs.E(errs.InvalidRequest, errors.New("request body is too large for a signed request")))
+				return
+			}
+			// replace the consumed body for the handler
+			r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+			err = mw.SignatureVerifier.Verify(r.Context(), auth.SignedRequest{
+				ClientID:  c.ID,
+				Method:    r.Method,
+				Path:      r.URL.EscapedPath(),
+				Query:     r.URL.RawQuery,
+				Timestamp: r.Header.Get(signatureTimestampHeader),
+				Nonce:     r.Header.Get(signatureNonceHeader),
+				Body:      body,
+				Signature: r.Header.Get(signatureHeader),
+			})
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			// call original, adding the signing client as the access
+			// token to request context
+			ctx := auth.SetAccessToken2Context(r.Context(), c.ID.String(), auth.SignatureTokenType)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+}
+
+// UserHandler middleware converts the access token in the request
+// context to a user.User and sets the stored User and the scopes
+// granted to the token to the request context. The user is stored on
+// their first authenticated request. If the request was made by a
+// registered client, the scopes are limited to those the client is
+// allowed. UserHandler must be chained after ClientHandler and
+// AccessTokenHandler (or another middleware setting the access
+// token, e.g. CertificateHandler).
+func (mw Middleware) UserHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			logger := *hlog.FromRequest(r)
+			ctx := r.Context()
+
+			accessToken, err := auth.FromRequest(r)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			u, scopes, err := mw.AccessTokenConverter.Convert(ctx, accessToken)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			if c, ok := client.FromRequest(r); ok {
+				scopes = scopes.Intersect(c.Scopes)
+			}
+
+			u, err = mw.storedUser(r, u)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			// call original, adding user and scopes to request context
+			ctx = auth.CtxWithScopes(user.CtxWithUser(ctx, u), scopes)
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+}
+
+// storedUser returns the stored User for u, storing u if this is
+// the user's first request. Users returned by converters which read
+// from the database (e.g. API key service accounts) already have an
+// ID and are returned as is.
+func (mw Middleware) storedUser(r *http.Request, u user.User) (user.User, error) {
+	if u.ID != uuid.Nil {
+		return u, nil
+	}
+
+	stored, err := mw.UserSelector.FindByEmail(r.Context(), u.Email)
+	if err == nil {
+		return stored, nil
+	}
+	if !errs.KindIs(errs.NotExist, err) {
+		return user.User{}, err
+	}
+
+	return mw.UserTransactor.Upsert(r.Context(), u)
+}
+
+// AuthorizeUserHandler middleware authorizes the User in the request
+// context for the request method and the path template of the matched
+// mux route (e.g. /api/v1/movies/{extlID}), so that authorization rules
+// are written against routes rather than individual resources.
+// AuthorizeUserHandler must be chained after UserHandler.
+func (mw Middleware) AuthorizeUserHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			logger := *hlog.FromRequest(r)
+
+			u, err := user.FromRequest(r)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			path, err := routePathTemplate(r)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			err = mw.Authorizer.Authorize(r.Context(), u, path, r.Method)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			h.ServeHTTP(w, r)
+		})
+}
+
+// OrgHandler middleware resolves the organization (tenant) the
+// request acts on and sets the Org to the request context. The org
+// is chosen with the X-Org-Id header, which is only required if the
+// user belongs to more than one org. OrgHandler must be chained after
+// UserHandler.
+func (mw Middleware) OrgHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			logger := *hlog.FromRequest(r)
+
+			o, err := mw.resolveOrg(r)
+			if err != nil {
+				errs.HTTPErrorResponse(w, logger, err)
+				return
+			}
+
+			// call original, adding org to request context
+			h.ServeHTTP(w, r.WithContext(org.CtxWithOrg(r.Context(), o)))
+		})
+}
+
+// resolveOrg resolves the Org for the request from the orgs the user
+// in the request context belongs to and the X-Org-Id header
+func (mw Middleware) resolveOrg(r *http.Request) (org.Org, error) {
+	u, err := user.FromRequest(r)
+	if err != nil {
+		return org.Org{}, err
+	}
+
+	var requested uuid.UUID
+	if v := r.Header.Get(orgIDHeader); v != "" {
+		requested, err = uuid.Parse(v)
+		if err != nil {
+			return org.Org{}, errs.E(errs.Validation, errs.Parameter(orgIDHeader), err)
+		}
+	}
+
+	orgs, err := mw.OrgFinder.FindAllByUserID(r.Context(), u.ID)
+	if err != nil {
+		return org.Org{}, err
+	}
+
+	return org.Resolve(orgs, requested)
+}
+
+// ScopeHandler returns middleware which requires the access token
+// for the request to have been granted all of the given scopes. It
+// is used when registering routes to declare the scopes each route
+// requires and must be chained after UserHandler.
+func ScopeHandler(scopes ...string) alice.Constructor {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(
+			func(w http.ResponseWriter, r *http.Request) {
+				logger := *hlog.FromRequest(r)
+
+				err := auth.RequireScopes(auth.ScopesFromRequest(r), scopes...)
+				if err != nil {
+					errs.HTTPErrorResponse(w, logger, err)
+					return
+				}
+
+				h.ServeHTTP(w, r)
+			})
+	}
+}
+
+// routePathTemplate returns the path template of the mux route
+// matched for the request
+func routePathTemplate(r *http.Request) (string, error) {
+	route := mux.CurrentRoute(r)
+	if route == nil {
+		return "", errs.E(errs.Internal, errors.New("no mux route matched for request"))
+	}
+
+	path, err := route.GetPathTemplate()
+	if err != nil {
+		return "", errs.E(errs.Internal, err)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,517 @@
+package handler
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+
+	"github.com/gilcrest/go-api-basic/datastore/clientstore/clientstoretest"
+	"github.com/gilcrest/go-api-basic/datastore/orgstore/orgstoretest"
+	"github.com/gilcrest/go-api-basic/datastore/userstore"
+	"github.com/gilcrest/go-api-basic/datastore/userstore/userstoretest"
+	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/org"
+	"github.com/gilcrest/go-api-basic/domain/user"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+// recordingAuthorizer records the object and action it was asked
+// to authorize and denies access if deny is true
+type recordingAuthorizer struct {
+	obj  string
+	act  string
+	deny bool
+}
+
+func (a *recordingAuthorizer) Authorize(ctx context.Context, sub user.User, obj string, act string) error {
+	a.obj = obj
+	a.act = act
+	if a.deny {
+		return errs.E(errs.Unauthorized, errors.New("denied"))
+	}
+	return nil
+}
+
+func TestMiddleware_AuthorizeUserHandler(t *testing.T) {
+	const route = pathPrefix + moviesV1PathRoot + "/{extlID}"
+
+	tests := []struct {
+		name     string
+		deny     bool
+		wantCode int
+	}{
+		{"allowed", false, http.StatusOK},
+		{"denied", true, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			authorizer := &recordingAuthorizer{deny: tt.deny}
+			mw := Middleware{
+				AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+				Authorizer:           authorizer,
+				UserSelector:         userstoretest.NewMockSelector(t),
+				UserTransactor:       userstoretest.NewMockTransactor(t),
+			}
+
+			// the final handler asserts the user is set to the
+			// request context by the middleware
+			var gotUser user.User
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				u, err := user.FromRequest(r)
+				c.Assert(err, qt.IsNil)
+				gotUser = u
+			})
+
+			router := mux.NewRouter()
+			router.Handle(route, authHandlerChain(mw, LoggerHandlerChain(lgr, alice.New())).Then(h))
+
+			req := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot+"/abc123", nil)
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			// authorization is against the route template, not the raw path
+			c.Assert(authorizer.obj, qt.Equals, route)
+			c.Assert(authorizer.act, qt.Equals, http.MethodGet)
+			if !tt.deny {
+				want := usertest.NewUser(t)
+				want.ID = userstoretest.UserID
+				c.Assert(gotUser, qt.Equals, want)
+			}
+		})
+	}
+}
+
+// newUserSelector finds no users, so that every user is new
+type newUserSelector struct {
+	userstoretest.MockSelector
+}
+
+func (s newUserSelector) FindByEmail(ctx context.Context, email string) (user.User, error) {
+	return user.User{}, errs.E(errs.NotExist, "No record found for given email")
+}
+
+// recordingTransactor records the users it is asked to upsert
+type recordingTransactor struct {
+	userstoretest.MockTransactor
+	upserted []user.User
+}
+
+func (rt *recordingTransactor) Upsert(ctx context.Context, u user.User) (user.User, error) {
+	rt.upserted = append(rt.upserted, u)
+	return rt.MockTransactor.Upsert(ctx, u)
+}
+
+func TestMiddleware_UserHandler(t *testing.T) {
+	tests := []struct {
+		name         string
+		selector     userstore.Selector
+		wantUpserted int
+	}{
+		{"existing user", userstoretest.NewMockSelector(t), 0},
+		{"new user", newUserSelector{userstoretest.NewMockSelector(t)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			transactor := &recordingTransactor{MockTransactor: userstoretest.NewMockTransactor(t)}
+			mw := Middleware{
+				AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+				UserSelector:         tt.selector,
+				UserTransactor:       transactor,
+			}
+
+			var gotUser user.User
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				u, err := user.FromRequest(r)
+				c.Assert(err, qt.IsNil)
+				gotUser = u
+			})
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			req := httptest.NewRequest(http.MethodGet, pathPrefix+meV1PathRoot, nil)
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+			rr := httptest.NewRecorder()
+			userHandlerChain(mw, LoggerHandlerChain(lgr, alice.New())).Then(h).ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, http.StatusOK)
+			c.Assert(len(transactor.upserted), qt.Equals, tt.wantUpserted)
+			// the stored user, with its ID, is set to the context
+			c.Assert(gotUser.ID, qt.Equals, userstoretest.UserID)
+		})
+	}
+}
+
+func TestMiddleware_AuthorizeUserHandler_NoRoute(t *testing.T) {
+	c := qt.New(t)
+
+	mw := Middleware{Authorizer: &recordingAuthorizer{}}
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler should not be called without a matched route")
+	})
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	req := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot, nil)
+	req = req.WithContext(user.CtxWithUser(req.Context(), usertest.NewUser(t)))
+	rr := httptest.NewRecorder()
+	LoggerHandlerChain(lgr, alice.New()).Append(mw.AuthorizeUserHandler).Then(h).ServeHTTP(rr, req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusInternalServerError)
+}
+
+func TestMiddleware_ClientHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		clientID   string
+		wantCode   int
+		wantClient uuid.UUID
+	}{
+		{"no client", "", http.StatusOK, uuid.Nil},
+		{"active client", clientstoretest.ClientID.String(), http.StatusOK, clientstoretest.ClientID},
+		{"disabled client", clientstoretest.DisabledClientID.String(), http.StatusUnauthorized, uuid.Nil},
+		{"unregistered client", uuid.New().String(), http.StatusUnauthorized, uuid.Nil},
+		{"invalid client ID", "not-a-uuid", http.StatusUnauthorized, uuid.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			mw := Middleware{ClientFinder: clientstoretest.NewMockSelector(t)}
+
+			var gotClient uuid.UUID
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if cl, ok := client.FromRequest(r); ok {
+					gotClient = cl.ID
+				}
+			})
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			req := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot, nil)
+			if tt.clientID != "" {
+				req.Header.Add(clientIDHeader, tt.clientID)
+			}
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).Append(mw.ClientHandler).Then(h).ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			c.Assert(gotClient, qt.Equals, tt.wantClient)
+		})
+	}
+}
+
+func TestMiddleware_SessionHandler(t *testing.T) {
+	codec := newTestSessionCodec(t)
+
+	seal := func(s auth.Session) string {
+		v, err := codec.Seal(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	valid := seal(auth.Session{AccessToken: "ya29.browser-token", Expiry: time.Now().Add(time.Hour), CSRFToken: "csrf"})
+	expired := seal(auth.Session{AccessToken: "ya29.browser-token", Expiry: time.Now().Add(-time.Minute), CSRFToken: "csrf"})
+
+	tests := []struct {
+		name      string
+		method    string
+		cookie    string
+		csrf      string
+		bearer    string
+		wantCode  int
+		wantToken string
+	}{
+		{"safe method", http.MethodGet, valid, "", "", http.StatusOK, "ya29.browser-token"},
+		{"unsafe method with CSRF token", http.MethodPost, valid, "csrf", "", http.StatusOK, "ya29.browser-token"},
+		{"unsafe method without CSRF token", http.MethodPost, valid, "", "", http.StatusForbidden, ""},
+		{"unsafe method with wrong CSRF token", http.MethodDelete, valid, "forged", "", http.StatusForbidden, ""},
+		{"expired session", http.MethodGet, expired, "", "", http.StatusUnauthorized, ""},
+		{"forged session", http.MethodGet, "forged", "", "", http.StatusUnauthorized, ""},
+		{"Bearer token takes precedence", http.MethodPost, valid, "", "abc123def1", http.StatusOK, "abc123def1"},
+		{"no session", http.MethodGet, "", "", "", http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			mw := Middleware{SessionCodec: codec}
+
+			var got auth.AccessToken
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = auth.FromRequest(r)
+			})
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			req := httptest.NewRequest(tt.method, pathPrefix+moviesV1PathRoot, nil)
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: sessionCookieName, Value: tt.cookie})
+			}
+			if tt.csrf != "" {
+				req.Header.Add(csrfHeader, tt.csrf)
+			}
+			if tt.bearer != "" {
+				req.Header.Add("Authorization", auth.BearerTokenType+" "+tt.bearer)
+			}
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).
+				Append(mw.SessionHandler, AccessTokenHandler).
+				Then(h).ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			c.Assert(got.Token, qt.Equals, tt.wantToken)
+		})
+	}
+}
+
+func TestCertificateHandler(t *testing.T) {
+	cert := &x509.Certificate{Raw: []byte("verified-cert")}
+
+	tests := []struct {
+		name     string
+		state    *tls.ConnectionState
+		wantCode int
+	}{
+		{"verified certificate", &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}, http.StatusOK},
+		{"unverified certificate", &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}, http.StatusUnauthorized},
+		{"no TLS", nil, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var got auth.AccessToken
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = auth.FromRequest(r)
+			})
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			req := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot, nil)
+			req.TLS = tt.state
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).Append(CertificateHandler).Then(h).ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			if tt.wantCode == http.StatusOK {
+				c.Assert(got, qt.Equals, auth.AccessToken{Token: auth.CertificateToken(cert), TokenType: auth.CertificateTokenType})
+			}
+		})
+	}
+}
+
+func TestMiddleware_SignatureHandler(t *testing.T) {
+	const (
+		body  = `{"title":"Repo Man"}`
+		query = "org=helping-hand"
+		nonce = "6f1c0e2a9b7d4c35"
+	)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	sign := func(secret []byte) string {
+		sr := auth.SignedRequest{
+			Method:    http.MethodPost,
+			Path:      pathPrefix + moviesV1PathRoot,
+			Query:     query,
+			Timestamp: ts,
+			Nonce:     nonce,
+			Body:      []byte(body),
+		}
+		return sr.Sign(secret)
+	}
+
+	// the nonce cache is shared by the subtests, as for the requests
+	// to a running server
+	mw := Middleware{
+		ClientFinder:      clientstoretest.NewMockSelector(t),
+		SignatureVerifier: auth.HMACSignatureVerifier{SecretFinder: clientstoretest.NewMockSelector(t), Nonces: auth.NewMemoryNonceCache()},
+	}
+
+	tests := []struct {
+		name      string
+		clientID  string
+		query     string
+		signature string
+		wantCode  int
+	}{
+		{"invalid signature", clientstoretest.ClientID.String(), query, sign([]byte("not-the-secret")), http.StatusUnauthorized},
+		{"tampered query", clientstoretest.ClientID.String(), "org=another-org", sign(clientstoretest.SigningSecret), http.StatusUnauthorized},
+		{"no client", "", query, sign(clientstoretest.SigningSecret), http.StatusUnauthorized},
+		{"valid signature", clientstoretest.ClientID.String(), query, sign(clientstoretest.SigningSecret), http.StatusOK},
+		{"replayed", clientstoretest.ClientID.String(), query, sign(clientstoretest.SigningSecret), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var (
+				gotToken auth.AccessToken
+				gotBody  []byte
+			)
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotToken, _ = auth.FromRequest(r)
+				gotBody, _ = ioutil.ReadAll(r.Body)
+			})
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			req := httptest.NewRequest(http.MethodPost, pathPrefix+moviesV1PathRoot+"?"+tt.query, strings.NewReader(body))
+			if tt.clientID != "" {
+				req.Header.Add(clientIDHeader, tt.clientID)
+			}
+			req.Header.Add(signatureTimestampHeader, ts)
+			req.Header.Add(signatureNonceHeader, nonce)
+			req.Header.Add(signatureHeader, tt.signature)
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).
+				Append(mw.ClientHandler, mw.SignatureHandler).
+				Then(h).ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+			c.Assert(gotToken.Token, qt.Equals, clientstoretest.ClientID.String())
+			c.Assert(gotToken.TokenType, qt.Equals, auth.SignatureTokenType)
+			// the body is still readable by the handler after verification
+			c.Assert(string(gotBody), qt.Equals, body)
+		})
+	}
+}
+
+func TestScopeHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		granted  auth.Scopes
+		required []string
+		wantCode int
+	}{
+		{"granted", auth.Scopes{auth.MoviesReadScope}, []string{auth.MoviesReadScope}, http.StatusOK},
+		{"insufficient scope", auth.Scopes{auth.MoviesReadScope}, []string{auth.MoviesWriteScope}, http.StatusForbidden},
+		{"no scopes", nil, []string{auth.MoviesReadScope}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			req := httptest.NewRequest(http.MethodPost, pathPrefix+moviesV1PathRoot, nil)
+			req = req.WithContext(auth.CtxWithScopes(req.Context(), tt.granted))
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).Append(ScopeHandler(tt.required...)).Then(h).ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			if tt.wantCode == http.StatusForbidden {
+				c.Assert(rr.Header().Get("WWW-Authenticate"), qt.Contains, `error="insufficient_scope"`)
+			}
+		})
+	}
+}
+
+func TestMiddleware_UserHandler_ClientScopes(t *testing.T) {
+	c := qt.New(t)
+
+	mw := Middleware{
+		AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+		UserSelector:         userstoretest.NewMockSelector(t),
+		UserTransactor:       userstoretest.NewMockTransactor(t),
+	}
+
+	var got auth.Scopes
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = auth.ScopesFromRequest(r)
+	})
+
+	cl := clientstoretest.NewClient(t, clientstoretest.ClientID)
+	cl.Scopes = []string{auth.MoviesReadScope}
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	req := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot, nil)
+	req = req.WithContext(client.CtxWithClient(req.Context(), *cl))
+	req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+	rr := httptest.NewRecorder()
+	LoggerHandlerChain(lgr, alice.New()).
+		Append(AccessTokenHandler, mw.UserHandler).
+		Then(h).ServeHTTP(rr, req)
+
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	// the token's scopes are capped by the scopes the client is allowed
+	c.Assert(got, qt.DeepEquals, auth.Scopes{auth.MoviesReadScope})
+}
+
+// otherOrgID is the ID of the second org returned by multiOrgFinder
+var otherOrgID = uuid.MustParse("3f2b9a7c-6d1e-4c5b-8a09-2e7d4f6b1c38")
+
+// multiOrgFinder returns two orgs for every user
+type multiOrgFinder struct {
+	t *testing.T
+}
+
+func (f multiOrgFinder) FindAllByUserID(ctx context.Context, userID uuid.UUID) ([]*org.Org, error) {
+	return []*org.Org{orgstoretest.NewOrg(f.t), {ID: otherOrgID, Name: "Edge City Cinema"}}, nil
+}
+
+func TestMiddleware_OrgHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		finder   org.Finder
+		orgID    string
+		wantCode int
+		wantOrg  uuid.UUID
+	}{
+		{"single org", orgstoretest.NewMockSelector(t), "", http.StatusOK, orgstoretest.OrgID},
+		{"requested org", multiOrgFinder{t}, otherOrgID.String(), http.StatusOK, otherOrgID},
+		{"ambiguous", multiOrgFinder{t}, "", http.StatusBadRequest, uuid.Nil},
+		{"not a member", orgstoretest.NewMockSelector(t), otherOrgID.String(), http.StatusForbidden, uuid.Nil},
+		{"invalid org ID", orgstoretest.NewMockSelector(t), "not-a-uuid", http.StatusBadRequest, uuid.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			mw := Middleware{OrgFinder: tt.finder}
+
+			var gotOrg uuid.UUID
+			h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				o, err := org.FromRequest(r)
+				c.Assert(err, qt.IsNil)
+				gotOrg = o.ID
+			})
+
+			lgr := logger.NewLogger(os.Stdout, true)
+			req := httptest.NewRequest(http.MethodGet, pathPrefix+moviesV1PathRoot, nil)
+			req = req.WithContext(user.CtxWithUser(req.Context(), usertest.NewUser(t)))
+			if tt.orgID != "" {
+				req.Header.Add(orgIDHeader, tt.orgID)
+			}
+			rr := httptest.NewRecorder()
+			LoggerHandlerChain(lgr, alice.New()).Append(mw.OrgHandler).Then(h).ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			c.Assert(gotOrg, qt.Equals, tt.wantOrg)
+		})
+	}
+}
@@ -6,10 +6,12 @@ import (
 	"time"
 
 	"github.com/gilcrest/go-api-basic/datastore/moviestore"
-	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/client"
 	"github.com/gilcrest/go-api-basic/domain/errs"
 	"github.com/gilcrest/go-api-basic/domain/movie"
+	"github.com/gilcrest/go-api-basic/domain/org"
 	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/user"
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/hlog"
@@ -27,8 +29,6 @@ func ProvideCreateMovieHandler(h DefaultMovieHandlers) CreateMovieHandler {
 // DefaultMovieHandlers are the default handlers for CRUD operations
 // for a Movie. Each method on the struct is a separate handler.
 type DefaultMovieHandlers struct {
-	AccessTokenConverter  auth.AccessTokenConverter
-	Authorizer            auth.Authorizer
 	RandomStringGenerator random.StringGenerator
 	Transactor            moviestore.Transactor
 	Selector              moviestore.Selector
@@ -55,8 +55,10 @@ func (h DefaultMovieHandlers) CreateMovie(w http.ResponseWriter, r *http.Request
 		RunTime         int    `json:"run_time"`
 		Director        string `json:"director"`
 		Writer          string `json:"writer"`
+		CreateClientID  string `json:"create_client_id,omitempty"`
 		CreateUsername  string `json:"create_username"`
 		CreateTimestamp string `json:"create_timestamp"`
+		UpdateClientID  string `json:"update_client_id,omitempty"`
 		UpdateUsername  string `json:"update_username"`
 		UpdateTimestamp string `json:"update_timestamp"`
 	}
@@ -64,19 +66,17 @@ func (h DefaultMovieHandlers) CreateMovie(w http.ResponseWriter, r *http.Request
 	logger := *hlog.FromRequest(r)
 	ctx := r.Context()
 
-	accessToken, err := auth.FromRequest(r)
+	// retrieve the User set to the request context by the
+	// authentication middleware
+	u, err := user.FromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
+	// retrieve the Org (tenant) set to the request context by the
+	// tenant middleware
+	o, err := org.FromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
@@ -110,6 +110,13 @@ func (h DefaultMovieHandlers) CreateMovie(w http.ResponseWriter, r *http.Request
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
+	m.SetOrgID(o.ID)
+
+	// record the client the movie is created through, if the
+	// client middleware identified one
+	if c, ok := client.FromRequest(r); ok {
+		m.SetCreateClientID(c.ID).SetUpdateClientID(c.ID)
+	}
 
 	m, err = m.SetReleased(rb.Released)
 	if err != nil {
@@ -145,8 +152,10 @@ func (h DefaultMovieHandlers) CreateMovie(w http.ResponseWriter, r *http.Request
 		RunTime:         m.RunTime,
 		Director:        m.Director,
 		Writer:          m.Writer,
+		CreateClientID:  clientIDString(m.CreateClientID),
 		CreateUsername:  m.CreateUser.Email,
 		CreateTimestamp: m.CreateTime.Format(time.RFC3339),
+		UpdateClientID:  clientIDString(m.UpdateClientID),
 		UpdateUsername:  m.UpdateUser.Email,
 		UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
 	}
@@ -197,8 +206,10 @@ func (h DefaultMovieHandlers) UpdateMovie(w http.ResponseWriter, r *http.Request
 		RunTime         int    `json:"run_time"`
 		Director        string `json:"director"`
 		Writer          string `json:"writer"`
+		CreateClientID  string `json:"create_client_id,omitempty"`
 		CreateUsername  string `json:"create_username"`
 		CreateTimestamp string `json:"create_timestamp"`
+		UpdateClientID  string `json:"update_client_id,omitempty"`
 		UpdateUsername  string `json:"update_username"`
 		UpdateTimestamp string `json:"update_timestamp"`
 	}
@@ -206,19 +217,17 @@ func (h DefaultMovieHandlers) UpdateMovie(w http.ResponseWriter, r *http.Request
 	logger := *hlog.FromRequest(r)
 	ctx := r.Context()
 
-	accessToken, err := auth.FromRequest(r)
+	// retrieve the User set to the request context by the
+	// authentication middleware
+	u, err := user.FromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
+	// retrieve the Org (tenant) set to the request context by the
+	// tenant middleware
+	o, err := org.FromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
@@ -247,6 +256,7 @@ func (h DefaultMovieHandlers) UpdateMovie(w http.ResponseWriter, r *http.Request
 
 	// Convert request into a Movie struct
 	m := new(movie.Movie)
+	m.SetOrgID(o.ID)
 	m.SetExternalID(extlid)
 	m.SetTitle(rb.Title)
 	m.SetRated(rb.Rated)
@@ -260,6 +270,9 @@ func (h DefaultMovieHandlers) UpdateMovie(w http.ResponseWriter, r *http.Request
 	m.SetWriter(rb.Writer)
 	m.SetUpdateUser(u)
 	m.SetUpdateTime()
+	if c, ok := client.FromRequest(r); ok {
+		m.SetUpdateClientID(c.ID)
+	}
 
 	err = m.IsValid()
 	if err != nil {
@@ -283,8 +296,10 @@ func (h DefaultMovieHandlers) UpdateMovie(w http.ResponseWriter, r *http.Request
 		RunTime:         m.RunTime,
 		Director:        m.Director,
 		Writer:          m.Writer,
+		CreateClientID:  clientIDString(m.CreateClientID),
 		CreateUsername:  m.CreateUser.Email,
 		CreateTimestamp: m.CreateTime.Format(time.RFC3339),
+		UpdateClientID:  clientIDString(m.UpdateClientID),
 		UpdateUsername:  m.UpdateUser.Email,
 		UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
 	}
@@ -325,19 +340,9 @@ func (h DefaultMovieHandlers) DeleteMovie(w http.ResponseWriter, r *http.Request
 	logger := *hlog.FromRequest(r)
 	ctx := r.Context()
 
-	accessToken, err := auth.FromRequest(r)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
+	// retrieve the Org (tenant) set to the request context by the
+	// tenant middleware
+	o, err := org.FromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
@@ -354,7 +359,7 @@ func (h DefaultMovieHandlers) DeleteMovie(w http.ResponseWriter, r *http.Request
 	// the external ID to the database Transactor directly instead,
 	// (I'd have to rework it slightly) but this way works as an
 	// example
-	m, err := h.Selector.FindByID(ctx, extlid)
+	m, err := h.Selector.FindByID(ctx, o.ID, extlid)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
@@ -409,8 +414,10 @@ func (h DefaultMovieHandlers) FindByID(w http.ResponseWriter, r *http.Request) {
 		RunTime         int    `json:"run_time"`
 		Director        string `json:"director"`
 		Writer          string `json:"writer"`
+		CreateClientID  string `json:"create_client_id,omitempty"`
 		CreateUsername  string `json:"create_username"`
 		CreateTimestamp string `json:"create_timestamp"`
+		UpdateClientID  string `json:"update_client_id,omitempty"`
 		UpdateUsername  string `json:"update_username"`
 		UpdateTimestamp string `json:"update_timestamp"`
 	}
@@ -418,19 +425,9 @@ func (h DefaultMovieHandlers) FindByID(w http.ResponseWriter, r *http.Request) {
 	logger := *hlog.FromRequest(r)
 	ctx := r.Context()
 
-	accessToken, err := auth.FromRequest(r)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
+	// retrieve the Org (tenant) set to the request context by the
+	// tenant middleware
+	o, err := org.FromRequest(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
@@ -443,7 +440,7 @@ func (h DefaultMovieHandlers) FindByID(w http.ResponseWriter, r *http.Request) {
 	extlid := vars["id"]
 
 	// Find the Movie by ID using the selector.FindByID method
-	m, err := h.Selector.FindByID(ctx, extlid)
+	m, err := h.Selector.FindByID(ctx, o.ID, extlid)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
@@ -457,8 +454,10 @@ func (h DefaultMovieHandlers) FindByID(w http.ResponseWriter, r *http.Request) {
 		RunTime:         m.RunTime,
 		Director:        m.Director,
 		Writer:          m.Writer,
+		CreateClientID:  clientIDString(m.CreateClientID),
 		CreateUsername:  m.CreateUser.Email,
 		CreateTimestamp: m.CreateTime.Format(time.RFC3339),
+		UpdateClientID:  clientIDString(m.UpdateClientID),
 		UpdateUsername:  m.UpdateUser.Email,
 		UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
 	}
@@ -488,7 +487,8 @@ func ProvideFindAllMoviesHandler(h DefaultMovieHandlers) FindAllMoviesHandler {
 type FindAllMoviesHandler http.Handler
 
 // FindAllMovies handles GET requests for the /movies endpoint and finds
-// all movies
+// all movies, or only the movies created through a client if the
+// client_id query parameter is given
 func (h DefaultMovieHandlers) FindAllMovies(w http.ResponseWriter, r *http.Request) {
 	// movieResponse is the response struct for a Movie
 	type movieResponse struct {
@@ -499,41 +499,23 @@ func (h DefaultMovieHandlers) FindAllMovies(w http.ResponseWriter, r *http.Reque
 		RunTime         int    `json:"run_time"`
 		Director        string `json:"director"`
 		Writer          string `json:"writer"`
+		CreateClientID  string `json:"create_client_id,omitempty"`
 		CreateUsername  string `json:"create_username"`
 		CreateTimestamp string `json:"create_timestamp"`
+		UpdateClientID  string `json:"update_client_id,omitempty"`
 		UpdateUsername  string `json:"update_username"`
 		UpdateTimestamp string `json:"update_timestamp"`
 	}
 
 	logger := *hlog.FromRequest(r)
-	ctx := r.Context()
-
-	accessToken, err := auth.FromRequest(r)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	u, err := h.AccessTokenConverter.Convert(ctx, accessToken)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
-
-	err = h.Authorizer.Authorize(ctx, u, r.URL.Path, r.Method)
-	if err != nil {
-		errs.HTTPErrorResponse(w, logger, err)
-		return
-	}
 
-	// Find the list of all Movies using the selector.FindAll method
-	movies, err := h.Selector.FindAll(ctx)
+	movies, err := h.findAllMovies(r)
 	if err != nil {
 		errs.HTTPErrorResponse(w, logger, err)
 		return
 	}
 
-	var smr []movieResponse
+	smr := make([]movieResponse, 0, len(movies))
 	for _, m := range movies {
 		mr := movieResponse{
 			ExternalID:      m.ExternalID,
@@ -543,8 +525,10 @@ func (h DefaultMovieHandlers) FindAllMovies(w http.ResponseWriter, r *http.Reque
 			RunTime:         m.RunTime,
 			Director:        m.Director,
 			Writer:          m.Writer,
+			CreateClientID:  clientIDString(m.CreateClientID),
 			CreateUsername:  m.CreateUser.Email,
 			CreateTimestamp: m.CreateTime.Format(time.RFC3339),
+			UpdateClientID:  clientIDString(m.UpdateClientID),
 			UpdateUsername:  m.UpdateUser.Email,
 			UpdateTimestamp: m.UpdateTime.Format(time.RFC3339),
 		}
@@ -565,3 +549,38 @@ func (h DefaultMovieHandlers) FindAllMovies(w http.ResponseWriter, r *http.Reque
 		return
 	}
 }
+
+// findAllMovies finds all movies in the org's catalog, or the movies
+// created through the client given in the client_id query parameter
+func (h DefaultMovieHandlers) findAllMovies(r *http.Request) ([]*movie.Movie, error) {
+	const clientIDParam string = "client_id"
+
+	ctx := r.Context()
+
+	o, err := org.FromRequest(r)
+	if err != nil {
+		return nil, err
+	}
+
+	s := r.URL.Query().Get(clientIDParam)
+	if s == "" {
+		// Find the list of all Movies using the selector.FindAll method
+		return h.Selector.FindAll(ctx, o.ID)
+	}
+
+	clientID, err := uuid.Parse(s)
+	if err != nil {
+		return nil, errs.E(errs.Validation, errs.Parameter(clientIDParam), err)
+	}
+
+	return h.Selector.FindAllByClientID(ctx, o.ID, clientID)
+}
+
+// clientIDString returns the client ID as a string, or an empty
+// string if no client was recorded
+func clientIDString(id uuid.UUID) string {
+	if id == uuid.Nil {
+		return ""
+	}
+	return id.String()
+}
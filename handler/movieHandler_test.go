@@ -18,6 +18,7 @@ import (
 
 	"github.com/gilcrest/go-api-basic/datastore/moviestore/moviestoretest"
 
+	"github.com/gilcrest/go-api-basic/datastore/userstore/userstoretest"
 	"github.com/gilcrest/go-api-basic/domain/auth"
 
 	qt "github.com/frankban/quicktest"
@@ -25,8 +26,10 @@ import (
 	"github.com/justinas/alice"
 	"github.com/rs/zerolog/hlog"
 
+	"github.com/gilcrest/go-api-basic/datastore/clientstore/clientstoretest"
 	"github.com/gilcrest/go-api-basic/datastore/datastoretest"
 	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/orgstore/orgstoretest"
 	"github.com/gilcrest/go-api-basic/domain/auth/authtest"
 	"github.com/gilcrest/go-api-basic/domain/logger"
 )
@@ -63,11 +66,18 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		// initialize DefaultStringGenerator
 		randomStringGenerator := random.DefaultStringGenerator{}
 
+		// initialize Middleware with mocks
+		mw := Middleware{
+			AccessTokenConverter: mockAccessTokenConverter,
+			Authorizer:           authtest.NewMockAuthorizer(t),
+			UserSelector:         userstoretest.NewMockSelector(t),
+			UserTransactor:       userstoretest.NewMockTransactor(t),
+			OrgFinder:            orgstoretest.NewMockSelector(t),
+		}
+
 		// initialize DefaultMovieHandlers
 		dmh := DefaultMovieHandlers{
 			RandomStringGenerator: randomStringGenerator,
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
 			Transactor:            transactor,
 			Selector:              selector,
 		}
@@ -132,15 +142,18 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		ac := alice.New()
 
 		// setup full handler chain needed for request
-		h := LoggerHandlerChain(lgr, ac).
-			Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
+		h := tenantHandlerChain(mw, LoggerHandlerChain(lgr, ac)).
 			Append(requestIDMiddleware).
 			Then(createMovieHandler)
 
-		// call the handler ServeHTTP method to execute the request
+		// the authorization middleware uses the matched route
+		// path template, so we need to use mux router
+		router := mux.NewRouter()
+		router.Handle(path, h)
+
+		// call the router ServeHTTP method to execute the request
 		// and record the response
-		h.ServeHTTP(rr, req)
+		router.ServeHTTP(rr, req)
 
 		// Assert that Response Status Code equals 200 (StatusOK)
 		c.Assert(rr.Code, qt.Equals, http.StatusOK)
@@ -174,7 +187,7 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		}
 
 		// retrieve the mock User that is used for testing
-		u, _ := mockAccessTokenConverter.Convert(req.Context(), authtest.NewAccessToken(t))
+		u, _, _ := mockAccessTokenConverter.Convert(req.Context(), authtest.NewAccessToken(t))
 
 		// setup the expected response data
 		wantBody := standardResponse{
@@ -238,11 +251,18 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		// initialize mockAccessTokenConverter
 		mockAccessTokenConverter := authtest.NewMockAccessTokenConverter(t)
 
+		// initialize Middleware with mocks
+		mw := Middleware{
+			AccessTokenConverter: mockAccessTokenConverter,
+			Authorizer:           authtest.NewMockAuthorizer(t),
+			UserSelector:         userstoretest.NewMockSelector(t),
+			UserTransactor:       userstoretest.NewMockTransactor(t),
+			OrgFinder:            orgstoretest.NewMockSelector(t),
+		}
+
 		// initialize DefaultMovieHandlers
 		dmh := DefaultMovieHandlers{
 			RandomStringGenerator: randomtest.NewMockStringGenerator(t),
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
 			Transactor:            mockTransactor,
 			Selector:              mockSelector,
 		}
@@ -307,15 +327,18 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		ac := alice.New()
 
 		// setup full handler chain needed for request
-		h := LoggerHandlerChain(lgr, ac).
-			Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
+		h := tenantHandlerChain(mw, LoggerHandlerChain(lgr, ac)).
 			Append(requestIDMiddleware).
 			Then(createMovieHandler)
 
-		// call the handler ServeHTTP method to execute the request
+		// the authorization middleware uses the matched route
+		// path template, so we need to use mux router
+		router := mux.NewRouter()
+		router.Handle(path, h)
+
+		// call the router ServeHTTP method to execute the request
 		// and record the response
-		h.ServeHTTP(rr, req)
+		router.ServeHTTP(rr, req)
 
 		// Assert that Response Status Code equals 200 (StatusOK)
 		c.Assert(rr.Code, qt.Equals, http.StatusOK)
@@ -349,7 +372,7 @@ func TestDefaultMovieHandlers_CreateMovie(t *testing.T) {
 		}
 
 		// retrieve the mock User that is used for testing
-		u, _ := mockAccessTokenConverter.Convert(req.Context(), authtest.NewAccessToken(t))
+		u, _, _ := mockAccessTokenConverter.Convert(req.Context(), authtest.NewAccessToken(t))
 
 		// setup the expected response data
 		wantBody := standardResponse{
@@ -434,11 +457,18 @@ func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 		// initialize DefaultStringGenerator
 		randomStringGenerator := random.DefaultStringGenerator{}
 
+		// initialize Middleware with mocks
+		mw := Middleware{
+			AccessTokenConverter: mockAccessTokenConverter,
+			Authorizer:           authtest.NewMockAuthorizer(t),
+			UserSelector:         userstoretest.NewMockSelector(t),
+			UserTransactor:       userstoretest.NewMockTransactor(t),
+			OrgFinder:            orgstoretest.NewMockSelector(t),
+		}
+
 		// initialize DefaultMovieHandlers
 		dmh := DefaultMovieHandlers{
 			RandomStringGenerator: randomStringGenerator,
-			AccessTokenConverter:  mockAccessTokenConverter,
-			Authorizer:            authtest.NewMockAuthorizer(t),
 			Transactor:            transactor,
 			Selector:              selector,
 		}
@@ -503,9 +533,7 @@ func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 		ac := alice.New()
 
 		// setup full handler chain needed for request
-		h := LoggerHandlerChain(lgr, ac).
-			Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
+		h := tenantHandlerChain(mw, LoggerHandlerChain(lgr, ac)).
 			Append(requestIDMiddleware).
 			Then(updateMovieHandler)
 
@@ -549,7 +577,7 @@ func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 		}
 
 		// retrieve the mock User that is used for testing
-		u, _ := mockAccessTokenConverter.Convert(req.Context(), authtest.NewAccessToken(t))
+		u, _, _ := mockAccessTokenConverter.Convert(req.Context(), authtest.NewAccessToken(t))
 
 		// setup the expected response data
 		wantBody := standardResponse{
@@ -597,3 +625,66 @@ func TestDefaultMovieHandlers_UpdateMovie(t *testing.T) {
 		c.Assert(gotBody, qt.CmpEquals(ignoreFields), wantBody)
 	})
 }
+
+func TestDefaultMovieHandlers_FindAllMovies_ByClient(t *testing.T) {
+	tests := []struct {
+		name     string
+		clientID string
+		wantCode int
+	}{
+		{"typical", clientstoretest.ClientID.String(), http.StatusOK},
+		{"invalid client ID", "not-a-uuid", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			lgr := logger.NewLogger(os.Stdout, true)
+
+			mw := Middleware{
+				AccessTokenConverter: authtest.NewMockAccessTokenConverter(t),
+				Authorizer:           authtest.NewMockAuthorizer(t),
+				UserSelector:         userstoretest.NewMockSelector(t),
+				UserTransactor:       userstoretest.NewMockTransactor(t),
+				ClientFinder:         clientstoretest.NewMockSelector(t),
+				OrgFinder:            orgstoretest.NewMockSelector(t),
+			}
+
+			dmh := DefaultMovieHandlers{
+				RandomStringGenerator: random.DefaultStringGenerator{},
+				Transactor:            moviestoretest.NewMockTransactor(t),
+				Selector:              moviestoretest.NewMockSelector(t),
+			}
+
+			path := pathPrefix + moviesV1PathRoot
+			req := httptest.NewRequest(http.MethodGet, path+"?client_id="+tt.clientID, nil)
+			req.Header.Add("Authorization", auth.BearerTokenType+" abc123def1")
+
+			rr := httptest.NewRecorder()
+			router := mux.NewRouter()
+			router.Handle(path, tenantHandlerChain(mw, LoggerHandlerChain(lgr, alice.New())).
+				Then(ProvideFindAllMoviesHandler(dmh)))
+			router.ServeHTTP(rr, req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			type standardResponse struct {
+				Data []struct {
+					ExternalID     string `json:"external_id"`
+					CreateClientID string `json:"create_client_id"`
+				} `json:"data"`
+			}
+			var got standardResponse
+			err := json.NewDecoder(rr.Body).Decode(&got)
+			c.Assert(err, qt.IsNil)
+
+			c.Assert(len(got.Data), qt.Equals, 2)
+			for _, m := range got.Data {
+				c.Assert(m.CreateClientID, qt.Equals, tt.clientID)
+			}
+		})
+	}
+}
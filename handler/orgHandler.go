@@ -0,0 +1,238 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/org"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// adminOrgRequestBody is the request body to create an org
+type adminOrgRequestBody struct {
+	Name string `json:"name"`
+}
+
+// adminOrgResponse is the response struct for an org
+type adminOrgResponse struct {
+	ID              string `json:"org_id"`
+	Name            string `json:"name"`
+	CreateTimestamp string `json:"create_timestamp"`
+	UpdateTimestamp string `json:"update_timestamp"`
+}
+
+// newAdminOrgResponse initializes an adminOrgResponse from an Org
+func newAdminOrgResponse(o *org.Org) adminOrgResponse {
+	return adminOrgResponse{
+		ID:              o.ID.String(),
+		Name:            o.Name,
+		CreateTimestamp: o.CreateTime.Format(time.RFC3339),
+		UpdateTimestamp: o.UpdateTime.Format(time.RFC3339),
+	}
+}
+
+// adminOrgMemberRequestBody is the request body to add a user to an org
+type adminOrgMemberRequestBody struct {
+	Email string `json:"email"`
+}
+
+// adminOrgMemberResponse is the response struct for an org member
+type adminOrgMemberResponse struct {
+	OrgID  string `json:"org_id"`
+	UserID string `json:"user_id"`
+	Email  string `json:"email,omitempty"`
+}
+
+// FindAllOrgsHandler is a Handler that returns all orgs
+type FindAllOrgsHandler http.Handler
+
+// ProvideFindAllOrgsHandler is a provider for the
+// FindAllOrgsHandler for wire
+func ProvideFindAllOrgsHandler(h DefaultAdminHandlers) FindAllOrgsHandler {
+	return http.HandlerFunc(h.FindAllOrgs)
+}
+
+// FindAllOrgs handles GET requests for the /admin/orgs endpoint
+func (h DefaultAdminHandlers) FindAllOrgs(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	orgs, err := h.OrgSelector.FindAll(r.Context())
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	or := make([]adminOrgResponse, 0, len(orgs))
+	for _, o := range orgs {
+		or = append(or, newAdminOrgResponse(o))
+	}
+
+	writeResponse(w, r, or)
+}
+
+// CreateOrgHandler is a Handler that creates an org
+type CreateOrgHandler http.Handler
+
+// ProvideCreateOrgHandler is a provider for the
+// CreateOrgHandler for wire
+func ProvideCreateOrgHandler(h DefaultAdminHandlers) CreateOrgHandler {
+	return http.HandlerFunc(h.CreateOrg)
+}
+
+// CreateOrg handles POST requests for the /admin/orgs endpoint
+func (h DefaultAdminHandlers) CreateOrg(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminOrgRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	o, err := org.NewOrg(rb.Name)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.OrgTransactor.Create(r.Context(), o, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, newAdminOrgResponse(o))
+}
+
+// AddOrgMemberHandler is a Handler that adds a user to an org
+type AddOrgMemberHandler http.Handler
+
+// ProvideAddOrgMemberHandler is a provider for the
+// AddOrgMemberHandler for wire
+func ProvideAddOrgMemberHandler(h DefaultAdminHandlers) AddOrgMemberHandler {
+	return http.HandlerFunc(h.AddOrgMember)
+}
+
+// AddOrgMember handles POST requests for the /admin/orgs/{orgID}/members
+// endpoint. The member must be an existing user.
+func (h DefaultAdminHandlers) AddOrgMember(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	orgID, err := pathID(r, "orgID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	rb := new(adminOrgMemberRequestBody)
+	err = json.NewDecoder(r.Body).Decode(&rb)
+	defer r.Body.Close()
+	err = DecoderErr(err)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+	if rb.Email == "" {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("email"), errs.MissingField("email")))
+		return
+	}
+
+	o, err := h.OrgSelector.FindByID(ctx, orgID)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	u, err := h.UserSelector.FindByEmail(ctx, rb.Email)
+	if errs.KindIs(errs.NotExist, err) {
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.Validation, errs.Parameter("email"), errors.New("member must be an existing user")))
+		return
+	}
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.OrgTransactor.AddMember(ctx, o, u, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, adminOrgMemberResponse{OrgID: o.ID.String(), UserID: u.ID.String(), Email: u.Email})
+}
+
+// RemoveOrgMemberHandler is a Handler that removes a user from an org
+type RemoveOrgMemberHandler http.Handler
+
+// ProvideRemoveOrgMemberHandler is a provider for the
+// RemoveOrgMemberHandler for wire
+func ProvideRemoveOrgMemberHandler(h DefaultAdminHandlers) RemoveOrgMemberHandler {
+	return http.HandlerFunc(h.RemoveOrgMember)
+}
+
+// RemoveOrgMember handles DELETE requests for the
+// /admin/orgs/{orgID}/members/{userID} endpoint
+func (h DefaultAdminHandlers) RemoveOrgMember(w http.ResponseWriter, r *http.Request) {
+	logger := *hlog.FromRequest(r)
+	ctx := r.Context()
+
+	actor, err := user.FromRequest(r)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	orgID, err := pathID(r, "orgID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	userID, err := pathID(r, "userID")
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	o, err := h.OrgSelector.FindByID(ctx, orgID)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	u, err := h.UserSelector.FindByID(ctx, userID)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	err = h.OrgTransactor.RemoveMember(ctx, o, u, actor)
+	if err != nil {
+		errs.HTTPErrorResponse(w, logger, err)
+		return
+	}
+
+	writeResponse(w, r, adminOrgMemberResponse{OrgID: o.ID.String(), UserID: u.ID.String(), Email: u.Email})
+}
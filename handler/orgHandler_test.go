@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+
+	"github.com/gilcrest/go-api-basic/datastore/orgstore/orgstoretest"
+	"github.com/gilcrest/go-api-basic/datastore/userstore/userstoretest"
+	"github.com/gilcrest/go-api-basic/domain/user/usertest"
+)
+
+func TestDefaultAdminHandlers_CreateOrg(t *testing.T) {
+	path := pathPrefix + adminV1PathRoot + "/orgs"
+
+	type standardResponse struct {
+		Data adminOrgResponse `json:"data"`
+	}
+
+	t.Run("typical", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(adminOrgRequestBody{Name: "Edge City Cinema"})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateOrgHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+		var got standardResponse
+		err = json.NewDecoder(rr.Body).Decode(&got)
+		c.Assert(err, qt.IsNil)
+
+		c.Assert(got.Data.ID, qt.Not(qt.Equals), "")
+		c.Assert(got.Data.Name, qt.Equals, "Edge City Cinema")
+	})
+
+	t.Run("missing name", func(t *testing.T) {
+		c := qt.New(t)
+
+		var buf bytes.Buffer
+		err := json.NewEncoder(&buf).Encode(adminOrgRequestBody{})
+		c.Assert(err, qt.IsNil)
+
+		req := httptest.NewRequest(http.MethodPost, path, &buf)
+		rr := serveAdmin(t, path, ProvideCreateOrgHandler(newMockAdminHandlers(t)), req)
+
+		c.Assert(rr.Code, qt.Equals, http.StatusBadRequest)
+	})
+}
+
+func TestDefaultAdminHandlers_AddOrgMember(t *testing.T) {
+	route := pathPrefix + adminV1PathRoot + "/orgs/{orgID}/members"
+
+	tests := []struct {
+		name     string
+		orgID    uuid.UUID
+		email    string
+		wantCode int
+	}{
+		{"typical", orgstoretest.OrgID, usertest.NewUser(t).Email, http.StatusOK},
+		{"missing email", orgstoretest.OrgID, "", http.StatusBadRequest},
+		{"unknown org", uuid.New(), usertest.NewUser(t).Email, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var buf bytes.Buffer
+			err := json.NewEncoder(&buf).Encode(adminOrgMemberRequestBody{Email: tt.email})
+			c.Assert(err, qt.IsNil)
+
+			path := pathPrefix + adminV1PathRoot + "/orgs/" + tt.orgID.String() + "/members"
+			req := httptest.NewRequest(http.MethodPost, path, &buf)
+			rr := serveAdmin(t, route, ProvideAddOrgMemberHandler(newMockAdminHandlers(t)), req)
+
+			c.Assert(rr.Code, qt.Equals, tt.wantCode)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var got struct {
+				Data adminOrgMemberResponse `json:"data"`
+			}
+			err = json.NewDecoder(rr.Body).Decode(&got)
+			c.Assert(err, qt.IsNil)
+			c.Assert(got.Data.OrgID, qt.Equals, orgstoretest.OrgID.String())
+			c.Assert(got.Data.UserID, qt.Equals, userstoretest.UserID.String())
+		})
+	}
+}
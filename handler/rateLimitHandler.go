@@ -0,0 +1,165 @@
+package handler
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/ratelimit"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+// defaultRateLimitScope is the scope of the buckets shared by the
+// routes without their own limit
+const defaultRateLimitScope string = "*"
+
+// ipRateLimitScope is the scope of the buckets of IPRateLimitHandler
+const ipRateLimitScope string = "ip"
+
+// RateLimitConfig configures the RateLimitHandler middleware
+type RateLimitConfig struct {
+	// Store keeps the token buckets. If nil, requests are not limited.
+	Store ratelimit.Store
+	// Default is the limit of routes without their own. The routes
+	// share a bucket for each caller. The zero Limit does not limit.
+	Default ratelimit.Limit
+	// Routes are the limits of routes by path template, e.g.
+	// "/api/v1/movies", the same as the route label of the metrics.
+	// A route with its own limit has its own bucket for each caller.
+	Routes map[string]ratelimit.Limit
+	// IP is the limit of each remote IP, applied to every request
+	// before it is authenticated. The zero Limit does not limit.
+	IP ratelimit.Limit
+}
+
+// enabled reports whether any route is limited
+func (cfg RateLimitConfig) enabled() bool {
+	return cfg.Store != nil && (!cfg.Default.IsZero() || len(cfg.Routes) > 0)
+}
+
+// ipEnabled reports whether requests are limited per remote IP
+func (cfg RateLimitConfig) ipEnabled() bool {
+	return cfg.Store != nil && !cfg.IP.IsZero()
+}
+
+// limit returns the limit of the route with the given path template
+// and the scope of its buckets
+func (cfg RateLimitConfig) limit(route string) (ratelimit.Limit, string) {
+	if l, ok := cfg.Routes[route]; ok && !l.IsZero() {
+		return l, route
+	}
+	return cfg.Default, defaultRateLimitScope
+}
+
+// RateLimitHandler middleware limits the rate of requests of each
+// caller with a token bucket. Callers are told apart by the email of
+// the authenticated user, else by the registered client ID, else by
+// the remote IP, so the middleware must follow UserHandler and
+// ClientHandler for requests to be limited per user or client. The
+// RateLimit-Limit, RateLimit-Remaining and RateLimit-Reset headers
+// are set on every limited response. A request over the limit gets
+// an errs.RateLimited error (429) with a Retry-After header. If the
+// Store fails, the request is let through.
+func (mw Middleware) RateLimitHandler(h http.Handler) http.Handler {
+	if !mw.RateLimit.enabled() {
+		return h
+	}
+	cfg := mw.RateLimit
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			// a request without a route gets the default limit
+			route, _ := routePathTemplate(r)
+			l, scope := cfg.limit(route)
+			if l.IsZero() {
+				h.ServeHTTP(w, r)
+				return
+			}
+
+			if cfg.take(w, r, l, scope+"|"+rateLimitKey(r)) {
+				h.ServeHTTP(w, r)
+			}
+		})
+}
+
+// IPRateLimitHandler middleware limits the rate of requests of each
+// remote IP with a token bucket, before the request is authenticated,
+// so failed attempts to authenticate, such as guessed API keys or
+// forged signatures, are limited too. It must be chained ahead of
+// the authentication middleware. Headers and errors are as for
+// RateLimitHandler, which overwrites the headers of a request it
+// limits as well.
+func (mw Middleware) IPRateLimitHandler(h http.Handler) http.Handler {
+	if !mw.RateLimit.ipEnabled() {
+		return h
+	}
+	cfg := mw.RateLimit
+
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if cfg.take(w, r, cfg.IP, ipRateLimitScope+"|ip:"+remoteIP(r)) {
+				h.ServeHTTP(w, r)
+			}
+		})
+}
+
+// take takes a token for the request from the bucket with the given
+// key and sets the rate limit headers. It reports whether the request
+// may go on; if not, the error response has been written. If the
+// Store fails, the request may go on.
+func (cfg RateLimitConfig) take(w http.ResponseWriter, r *http.Request, l ratelimit.Limit, key string) bool {
+	logger := *hlog.FromRequest(r)
+
+	res, err := cfg.Store.Take(r.Context(), key, l)
+	if err != nil {
+		logger.Error().Err(err).Msg("rate limit store failed, request not limited")
+		return true
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	w.Header().Set("RateLimit-Reset", ceilSeconds(res.Reset))
+
+	if !res.Allowed {
+		w.Header().Set("Retry-After", ceilSeconds(res.RetryAfter))
+		errs.HTTPErrorResponse(w, logger, errs.E(errs.RateLimited, errs.Code("rate_limited"), errors.Errorf("rate limit of %d requests per %s exceeded", l.Requests, l.Per)))
+		return false
+	}
+
+	return true
+}
+
+// rateLimitKey returns the key of the caller of the request: the
+// authenticated user, the registered client or the remote IP, as
+// logged in remote_ip without the port
+func rateLimitKey(r *http.Request) string {
+	if u, err := user.FromRequest(r); err == nil && u.Email != "" {
+		return "user:" + u.Email
+	}
+	if c, ok := client.FromRequest(r); ok {
+		return "client:" + c.ID.String()
+	}
+	return "ip:" + remoteIP(r)
+}
+
+// remoteIP returns the remote IP of the request, as logged in
+// remote_ip without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// ceilSeconds returns d as a whole number of seconds, rounded up
+func ceilSeconds(d time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(d.Seconds())))
+}
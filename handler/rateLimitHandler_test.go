@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/ratelimit"
+	"github.com/gilcrest/go-api-basic/domain/user"
+)
+
+func TestRateLimitKey(t *testing.T) {
+	clientID := uuid.MustParse("0d3b7f0e-3c4e-4d2a-9d8c-7b1f3e5a6c21")
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "203.0.113.7:52113"
+	qt.Assert(t, rateLimitKey(r), qt.Equals, "ip:203.0.113.7")
+
+	r = r.WithContext(client.CtxWithClient(r.Context(), client.Client{ID: clientID}))
+	qt.Assert(t, rateLimitKey(r), qt.Equals, "client:"+clientID.String())
+
+	r = r.WithContext(user.CtxWithUser(r.Context(), user.User{Email: "otto@repo.man"}))
+	qt.Assert(t, rateLimitKey(r), qt.Equals, "user:otto@repo.man")
+}
+
+func TestMiddleware_RateLimitHandler(t *testing.T) {
+	c := qt.New(t)
+
+	store := ratelimit.NewMemoryStore()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	store.Now = func() time.Time { return now }
+
+	mw := Middleware{RateLimit: RateLimitConfig{
+		Store:   store,
+		Default: ratelimit.Limit{Requests: 2, Per: time.Minute},
+		Routes:  map[string]ratelimit.Limit{moviesV1PathRoot + "/{extlID}": {Requests: 1, Per: time.Minute}},
+	}}
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	chain := LoggerHandlerChain(lgr, alice.New()).Append(mw.RateLimitHandler)
+	ok := chain.ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	rtr := mux.NewRouter()
+	rtr.Handle(moviesV1PathRoot+"/{extlID}", ok)
+	rtr.Handle(moviesV1PathRoot, ok)
+	rtr.Handle(meV1PathRoot, ok)
+
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		rr := httptest.NewRecorder()
+		rtr.ServeHTTP(rr, req)
+		return rr
+	}
+
+	// routes without their own limit share the default bucket
+	rr := get(moviesV1PathRoot, "203.0.113.7:1")
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(rr.Header().Get("RateLimit-Limit"), qt.Equals, "2")
+	c.Assert(rr.Header().Get("RateLimit-Remaining"), qt.Equals, "1")
+	c.Assert(rr.Header().Get("RateLimit-Reset"), qt.Equals, "30")
+
+	rr = get(meV1PathRoot, "203.0.113.7:2")
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(rr.Header().Get("RateLimit-Remaining"), qt.Equals, "0")
+
+	rr = get(moviesV1PathRoot, "203.0.113.7:3")
+	c.Assert(rr.Code, qt.Equals, http.StatusTooManyRequests)
+	c.Assert(rr.Header().Get("Retry-After"), qt.Equals, "30")
+	c.Assert(rr.Body.String(), qt.Contains, `"kind":"rate_limited"`)
+
+	// other callers are not limited by the bucket of another
+	rr = get(moviesV1PathRoot, "198.51.100.1:1")
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+
+	// a route with its own limit has its own bucket
+	rr = get(moviesV1PathRoot+"/abc", "203.0.113.7:4")
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+	c.Assert(rr.Header().Get("RateLimit-Limit"), qt.Equals, "1")
+	rr = get(moviesV1PathRoot+"/abc", "203.0.113.7:5")
+	c.Assert(rr.Code, qt.Equals, http.StatusTooManyRequests)
+	c.Assert(rr.Header().Get("Retry-After"), qt.Equals, "60")
+
+	// tokens are added over time
+	now = now.Add(30 * time.Second)
+	rr = get(moviesV1PathRoot, "203.0.113.7:6")
+	c.Assert(rr.Code, qt.Equals, http.StatusOK)
+}
+
+func TestMiddleware_RateLimitHandler_disabled(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mw := Middleware{RateLimit: RateLimitConfig{Store: ratelimit.NewMemoryStore()}}
+
+	rr := httptest.NewRecorder()
+	mw.RateLimitHandler(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	qt.Assert(t, rr.Header().Get("RateLimit-Limit"), qt.Equals, "")
+}
+
+func TestMiddleware_IPRateLimitHandler(t *testing.T) {
+	c := qt.New(t)
+
+	store := ratelimit.NewMemoryStore()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	store.Now = func() time.Time { return now }
+
+	mw := Middleware{RateLimit: RateLimitConfig{
+		Store:   store,
+		Default: ratelimit.Limit{Requests: 100, Per: time.Minute},
+		IP:      ratelimit.Limit{Requests: 2, Per: time.Minute},
+	}}
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	tests := []struct {
+		name  string
+		chain alice.Chain
+		ip    string
+	}{
+		{"user", userHandlerChain(mw, LoggerHandlerChain(lgr, alice.New())), "203.0.113.7"},
+		{"signed", signedHandlerChain(mw, LoggerHandlerChain(lgr, alice.New())), "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		c.Run(tt.name, func(c *qt.C) {
+			get := func() *httptest.ResponseRecorder {
+				req := httptest.NewRequest(http.MethodGet, moviesV1PathRoot, nil)
+				req.RemoteAddr = tt.ip + ":52113"
+				rr := httptest.NewRecorder()
+				tt.chain.Then(h).ServeHTTP(rr, req)
+				return rr
+			}
+
+			// requests which fail authentication are limited, as the
+			// limit is taken before authentication
+			for i := 0; i < 2; i++ {
+				rr := get()
+				c.Assert(rr.Code, qt.Equals, http.StatusUnauthorized)
+				c.Assert(rr.Header().Get("RateLimit-Limit"), qt.Equals, "2")
+			}
+			rr := get()
+			c.Assert(rr.Code, qt.Equals, http.StatusTooManyRequests)
+			c.Assert(rr.Header().Get("Retry-After"), qt.Equals, "30")
+		})
+	}
+}
+
+func TestMiddleware_IPRateLimitHandler_disabled(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mw := Middleware{RateLimit: RateLimitConfig{
+		Store:   ratelimit.NewMemoryStore(),
+		Default: ratelimit.Limit{Requests: 1, Per: time.Minute},
+	}}
+
+	rr := httptest.NewRecorder()
+	mw.IPRateLimitHandler(h).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	qt.Assert(t, rr.Header().Get("RateLimit-Limit"), qt.Equals, "")
+}
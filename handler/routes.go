@@ -6,18 +6,23 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/justinas/alice"
 	"github.com/rs/zerolog"
+
+	"github.com/gilcrest/go-api-basic/domain/auth"
 )
 
 const (
 	pathPrefix       string = "/api"
 	moviesV1PathRoot string = "/v1/movies"
+	meV1PathRoot     string = "/v1/me"
+	adminV1PathRoot  string = "/v1/admin"
+	authV1PathRoot   string = "/v1/auth"
 )
 
 // NewMuxRouter sets up the mux.Router and registers routes to URL paths
 // using the available handlers
-func NewMuxRouter(logger zerolog.Logger, handlers Handlers) *mux.Router {
+func NewMuxRouter(logger zerolog.Logger, mw Middleware, handlers Handlers) *mux.Router {
 	// create a new gorilla/mux router
-	rtr := mux.NewRouter()
+	root := mux.NewRouter()
 
 	// Start a new alice handler chain
 	c := alice.New()
@@ -25,57 +30,332 @@ func NewMuxRouter(logger zerolog.Logger, handlers Handlers) *mux.Router {
 	// add LoggerHandlerChain handler chain and zerolog logger to Context
 	c = LoggerHandlerChain(logger, c)
 
+	// set the level of the request logger, which can be changed at
+	// runtime or raised to debug for a single request, then log the
+	// request and response bodies at debug level, if enabled
+	c = c.Append(mw.LogLevelHandler, mw.BodyLogHandler)
+
+	// trace, correlate logs with the trace and record RED metrics
+	// for every route
+	c = c.Append(TracingHandler, mw.TraceLogHandler, mw.MetricsHandler)
+
+	// set the deadline of the request, so slow queries and outbound
+	// calls are cut off
+	c = c.Append(mw.TimeoutHandler)
+
+	// serve the metrics at /metrics, outside the /api path prefix,
+	// unless they are served on a separate admin port
+	if handlers.MetricsHandler != nil {
+		root.Handle("/metrics", handlers.MetricsHandler).
+			Methods(http.MethodGet)
+	}
+
+	// serve liveness and readiness at /healthz and /readyz, outside
+	// the /api path prefix and without the logging chain, as they are
+	// called every few seconds by load balancers and orchestrators
+	root.Handle("/healthz", handlers.LivenessHandler).
+		Methods(http.MethodGet)
+	root.Handle("/readyz", handlers.ReadinessHandler).
+		Methods(http.MethodGet)
+
 	// send Router through PathPrefix method to validate any standard
 	// subroutes you may want for your APIs. e.g. I always want to be
 	// sure that every request has "/api" as part of it's path prefix
 	// without having to put it into every handle path in my various
 	// routing functions
-	rtr = rtr.PathPrefix(pathPrefix).Subrouter()
+	rtr := root.PathPrefix(pathPrefix).Subrouter()
+
+	// authChain is used for all routes which require an authenticated
+	// and authorized user. The user is resolved once and set to the
+	// request context for the handler.
+	authChain := authHandlerChain(mw, c)
+
+	// tenantChain is used for routes working with the data of an
+	// organization (tenant). The org is resolved after the user and
+	// set to the request context for the handler.
+	tenantChain := tenantHandlerChain(mw, c)
+
+	// readMoviesChain and writeMoviesChain declare the scopes
+	// required by the movie routes
+	readMoviesChain := tenantChain.Append(ScopeHandler(auth.MoviesReadScope))
+	writeMoviesChain := tenantChain.Append(ScopeHandler(auth.MoviesWriteScope))
+
+	// signedWriteMoviesChain is used for requests signed by a
+	// partner's registered client instead of sent with an access
+	// token. Signed routes are registered first and matched by the
+	// presence of the X-Signature header.
+	signedWriteMoviesChain := signedHandlerChain(mw, c).
+		Append(ScopeHandler(auth.MoviesWriteScope))
+
+	// Match only signed POST requests at /api/v1/movies
+	// with Content-Type header = application/json
+	rtr.Handle(moviesV1PathRoot,
+		signedWriteMoviesChain.Then(handlers.CreateMovieHandler)).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json", signatureHeader, "")
+
+	// Match only signed PUT requests having an ID at
+	// /api/v1/movies/{id} with Content-Type header = application/json
+	rtr.Handle(moviesV1PathRoot+"/{extlID}",
+		signedWriteMoviesChain.Then(handlers.UpdateMovieHandler)).
+		Methods(http.MethodPut).
+		Headers("Content-Type", "application/json", signatureHeader, "")
 
 	// Match only POST requests at /api/v1/movies
 	// with Content-Type header = application/json
 	rtr.Handle(moviesV1PathRoot,
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.CreateMovieHandler)).
+		writeMoviesChain.Then(handlers.CreateMovieHandler)).
 		Methods(http.MethodPost).
 		Headers("Content-Type", "application/json")
 
 	// Match only PUT requests having an ID at /api/v1/movies/{id}
 	// with the Content-Type header = application/json
 	rtr.Handle(moviesV1PathRoot+"/{extlID}",
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.UpdateMovieHandler)).
+		writeMoviesChain.Then(handlers.UpdateMovieHandler)).
 		Methods(http.MethodPut).
 		Headers("Content-Type", "application/json")
 
 	// Match only DELETE requests having an ID at /api/v1/movies/{id}
 	rtr.Handle(moviesV1PathRoot+"/{extlID}",
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.DeleteMovieHandler)).
+		writeMoviesChain.Then(handlers.DeleteMovieHandler)).
 		Methods(http.MethodDelete)
 
 	// Match only GET requests having an ID at /api/v1/movies/{id}
 	rtr.Handle(moviesV1PathRoot+"/{extlID}",
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.FindMovieByIDHandler)).
+		readMoviesChain.Then(handlers.FindMovieByIDHandler)).
 		Methods(http.MethodGet)
 
 	// Match only GET requests /api/v1/movies
 	rtr.Handle(moviesV1PathRoot,
-		c.Append(AccessTokenHandler).
-			Append(JSONContentTypeHandler).
-			Then(handlers.FindAllMoviesHandler)).
+		readMoviesChain.Then(handlers.FindAllMoviesHandler)).
+		Methods(http.MethodGet)
+
+	// userChain is used for routes which require an authenticated
+	// user, but where any user is allowed, e.g. to view or update
+	// themselves
+	userChain := userHandlerChain(mw, c).Append(JSONContentTypeHandler)
+
+	// Match only GET requests at /api/v1/me
+	rtr.Handle(meV1PathRoot,
+		userChain.Then(handlers.FindMeHandler)).
+		Methods(http.MethodGet)
+
+	// Match only PUT requests at /api/v1/me
+	// with the Content-Type header = application/json
+	rtr.Handle(meV1PathRoot,
+		userChain.Then(handlers.UpdateMeHandler)).
+		Methods(http.MethodPut).
+		Headers("Content-Type", "application/json")
+
+	// register the admin routes for managing users, roles,
+	// role assignments, API keys, clients, orgs and the log
+	// level, which all require the admin scope
+	registerAdminRoutes(rtr, authChain.Append(ScopeHandler(auth.AdminScope)), handlers)
+
+	// publicChain is used for unauthenticated routes. Requests are
+	// rate limited by remote IP, as there is no user.
+	publicChain := c.Append(mw.RateLimitHandler)
+
+	// Browser login flow at /api/v1/auth. These routes are
+	// unauthenticated, they create and end sessions. Logout checks
+	// the CSRF token of the session itself.
+	rtr.Handle(authV1PathRoot+"/login",
+		publicChain.Then(handlers.LoginHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(authV1PathRoot+"/callback",
+		publicChain.Then(handlers.LoginCallbackHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(authV1PathRoot+"/logout",
+		publicChain.Then(handlers.LogoutHandler)).
+		Methods(http.MethodPost)
+
+	// Match only POST requests at /api/v1/dev/token. Dev tokens are
+	// issued to anyone who asks, so the route is only registered in
+	// dev auth mode.
+	if mw.AuthMode == DevAuthMode {
+		rtr.Handle("/v1/dev/token",
+			publicChain.Append(JSONContentTypeHandler).
+				Then(handlers.IssueDevTokenHandler)).
+			Methods(http.MethodPost).
+			Headers("Content-Type", "application/json")
+	}
+
+	// Match only GET requests at /api/v1/health. The last error of
+	// each check may reveal internals, so the admin scope is required.
+	rtr.Handle("/v1/health",
+		authChain.Append(ScopeHandler(auth.AdminScope), JSONContentTypeHandler).
+			Then(handlers.HealthHandler)).
 		Methods(http.MethodGet)
 
 	// Match only GET requests at /api/v1/ping
 	rtr.Handle("/v1/ping",
-		c.Append(JSONContentTypeHandler).
+		publicChain.Append(JSONContentTypeHandler).
 			Then(handlers.PingHandler)).
 		Methods(http.MethodGet)
 
-	return rtr
+	return root
+}
+
+// userHandlerChain appends the middleware needed to identify the
+// calling client and authenticate the user for a request to the
+// given chain. The user is authenticated according to the
+// Middleware AuthMode, or with a browser session cookie. Requests
+// are rate limited per remote IP before they are authenticated, so
+// failed attempts are limited too, and then per user.
+func userHandlerChain(mw Middleware, c alice.Chain) alice.Chain {
+	return c.Append(mw.IPRateLimitHandler).
+		Append(mw.ClientHandler).
+		Append(mw.SessionHandler).
+		Append(mw.authenticationHandler()).
+		Append(mw.UserHandler).
+		Append(mw.RateLimitHandler)
+}
+
+// authHandlerChain appends the middleware needed to authenticate and
+// authorize the user for a request to the given chain
+func authHandlerChain(mw Middleware, c alice.Chain) alice.Chain {
+	return userHandlerChain(mw, c).
+		Append(mw.AuthorizeUserHandler).
+		Append(JSONContentTypeHandler)
+}
+
+// tenantHandlerChain appends the middleware needed to authenticate and
+// authorize the user and resolve the organization (tenant) for a
+// request to the given chain
+func tenantHandlerChain(mw Middleware, c alice.Chain) alice.Chain {
+	return authHandlerChain(mw, c).
+		Append(mw.OrgHandler)
+}
+
+// signedHandlerChain appends the middleware needed to authenticate a
+// request signed by a registered client, authorize the client's owner
+// and resolve the organization (tenant) to the given chain. It uses
+// SignatureHandler in place of AccessTokenHandler. Requests are rate
+// limited per remote IP before the signature is verified, and then
+// per user, i.e. the client's owner.
+func signedHandlerChain(mw Middleware, c alice.Chain) alice.Chain {
+	return c.Append(mw.IPRateLimitHandler).
+		Append(mw.ClientHandler).
+		Append(mw.SignatureHandler).
+		Append(mw.UserHandler).
+		Append(mw.RateLimitHandler).
+		Append(mw.AuthorizeUserHandler).
+		Append(JSONContentTypeHandler).
+		Append(mw.OrgHandler)
+}
+
+// registerAdminRoutes registers the admin routes used to manage
+// users, roles, role assignments, API keys, clients, orgs and the
+// log level. All admin routes require an access token and are
+// authorized like any other route, so c is expected to be the
+// authenticated handler chain.
+func registerAdminRoutes(rtr *mux.Router, c alice.Chain, handlers Handlers) {
+	// /api/v1/admin/users
+	rtr.Handle(adminV1PathRoot+"/users",
+		c.Then(handlers.FindAllUsersHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/users",
+		c.Then(handlers.CreateUserHandler)).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/users/{userID}",
+		c.Then(handlers.FindUserByIDHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/users/{userID}",
+		c.Then(handlers.UpdateUserHandler)).
+		Methods(http.MethodPut).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/users/{userID}",
+		c.Then(handlers.DeleteUserHandler)).
+		Methods(http.MethodDelete)
+
+	// /api/v1/admin/roles
+	rtr.Handle(adminV1PathRoot+"/roles",
+		c.Then(handlers.FindAllRolesHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/roles",
+		c.Then(handlers.CreateRoleHandler)).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/roles/{roleID}",
+		c.Then(handlers.FindRoleByIDHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/roles/{roleID}",
+		c.Then(handlers.UpdateRoleHandler)).
+		Methods(http.MethodPut).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/roles/{roleID}",
+		c.Then(handlers.DeleteRoleHandler)).
+		Methods(http.MethodDelete)
+
+	// /api/v1/admin/role-assignments
+	rtr.Handle(adminV1PathRoot+"/role-assignments",
+		c.Then(handlers.FindAllRoleAssignmentsHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/role-assignments",
+		c.Then(handlers.CreateRoleAssignmentHandler)).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/role-assignments/{roleID}/{userID}",
+		c.Then(handlers.DeleteRoleAssignmentHandler)).
+		Methods(http.MethodDelete)
+
+	// /api/v1/admin/api-keys
+	rtr.Handle(adminV1PathRoot+"/api-keys",
+		c.Then(handlers.FindAllAPIKeysHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/api-keys",
+		c.Then(handlers.CreateAPIKeyHandler)).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/api-keys/{apiKeyID}",
+		c.Then(handlers.RevokeAPIKeyHandler)).
+		Methods(http.MethodDelete)
+
+	// /api/v1/admin/clients
+	rtr.Handle(adminV1PathRoot+"/clients",
+		c.Then(handlers.FindAllClientsHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/clients",
+		c.Then(handlers.CreateClientHandler)).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/clients/{clientID}",
+		c.Then(handlers.FindClientByIDHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/clients/{clientID}",
+		c.Then(handlers.UpdateClientHandler)).
+		Methods(http.MethodPut).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/clients/{clientID}/signing-secret",
+		c.Then(handlers.RotateSigningSecretHandler)).
+		Methods(http.MethodPost)
+
+	// /api/v1/admin/orgs
+	rtr.Handle(adminV1PathRoot+"/orgs",
+		c.Then(handlers.FindAllOrgsHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/orgs",
+		c.Then(handlers.CreateOrgHandler)).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/orgs/{orgID}/members",
+		c.Then(handlers.AddOrgMemberHandler)).
+		Methods(http.MethodPost).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/orgs/{orgID}/members/{userID}",
+		c.Then(handlers.RemoveOrgMemberHandler)).
+		Methods(http.MethodDelete)
+
+	// /api/v1/admin/loglevel
+	rtr.Handle(adminV1PathRoot+"/loglevel",
+		c.Then(handlers.FindLogLevelHandler)).
+		Methods(http.MethodGet)
+	rtr.Handle(adminV1PathRoot+"/loglevel",
+		c.Then(handlers.UpdateLogLevelHandler)).
+		Methods(http.MethodPut).
+		Headers("Content-Type", "application/json")
+	rtr.Handle(adminV1PathRoot+"/loglevel/debug-token",
+		c.Then(handlers.IssueDebugTokenHandler)).
+		Methods(http.MethodPost)
 }
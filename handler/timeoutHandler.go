@@ -0,0 +1,52 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// defaultRequestTimeout is the deadline of a request if
+// TimeoutConfig.Default is not set
+const defaultRequestTimeout time.Duration = 30 * time.Second
+
+// TimeoutConfig configures the TimeoutHandler middleware
+type TimeoutConfig struct {
+	// Default is the timeout of routes without their own. If zero,
+	// defaultRequestTimeout is used.
+	Default time.Duration
+	// Routes are the timeouts of routes by path template, e.g.
+	// "/api/v1/movies/{extlID}", the same as the route label of the
+	// metrics
+	Routes map[string]time.Duration
+}
+
+// timeout returns the timeout of the route with the given path
+// template
+func (cfg TimeoutConfig) timeout(route string) time.Duration {
+	if d, ok := cfg.Routes[route]; ok && d > 0 {
+		return d
+	}
+	if cfg.Default <= 0 {
+		return defaultRequestTimeout
+	}
+	return cfg.Default
+}
+
+// TimeoutHandler middleware sets a deadline on the request context,
+// using the timeout configured for the matched route. Database
+// queries and outbound calls made with the context are cut off at the
+// deadline and reported as an errs.Timeout error (504). The response
+// is still written by the handler, so handlers must pass the request
+// context down for the deadline to have any effect.
+func (mw Middleware) TimeoutHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			// a request without a route gets the default timeout
+			route, _ := routePathTemplate(r)
+			ctx, cancel := context.WithTimeout(r.Context(), mw.Timeouts.timeout(route))
+			defer cancel()
+
+			h.ServeHTTP(w, r.WithContext(ctx))
+		})
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+)
+
+func TestTimeoutConfig_timeout(t *testing.T) {
+	route := moviesV1PathRoot + "/{extlID}"
+
+	tests := []struct {
+		name  string
+		cfg   TimeoutConfig
+		route string
+		want  time.Duration
+	}{
+		{"unset", TimeoutConfig{}, route, defaultRequestTimeout},
+		{"default", TimeoutConfig{Default: time.Second}, route, time.Second},
+		{"route", TimeoutConfig{Default: time.Second, Routes: map[string]time.Duration{route: 2 * time.Second}}, route, 2 * time.Second},
+		{"other route", TimeoutConfig{Default: time.Second, Routes: map[string]time.Duration{route: 2 * time.Second}}, moviesV1PathRoot, time.Second},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			qt.Assert(t, tt.cfg.timeout(tt.route), qt.Equals, tt.want)
+		})
+	}
+}
+
+func TestMiddleware_TimeoutHandler(t *testing.T) {
+	c := qt.New(t)
+
+	mw := Middleware{Timeouts: TimeoutConfig{
+		Default: time.Hour,
+		Routes:  map[string]time.Duration{moviesV1PathRoot + "/{extlID}": time.Minute},
+	}}
+
+	var deadline time.Time
+	h := mw.TimeoutHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var ok bool
+		deadline, ok = r.Context().Deadline()
+		c.Assert(ok, qt.IsTrue)
+	}))
+
+	rtr := mux.NewRouter()
+	rtr.Handle(moviesV1PathRoot+"/{extlID}", h)
+	rtr.Handle(moviesV1PathRoot, h)
+
+	start := time.Now()
+	rtr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, moviesV1PathRoot+"/abc", nil))
+	c.Assert(deadline.Sub(start) < 2*time.Minute, qt.IsTrue)
+
+	rtr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, moviesV1PathRoot, nil))
+	c.Assert(deadline.Sub(start) > 2*time.Minute, qt.IsTrue)
+}
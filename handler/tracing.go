@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/hlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gilcrest/go-api-basic/domain/tracing"
+)
+
+// tracer is the tracer for the spans of the handlers
+var tracer = otel.Tracer("github.com/gilcrest/go-api-basic/handler")
+
+// TracingHandler middleware starts a span around the handler. The
+// trace is continued from the W3C traceparent header, if sent. Like
+// MetricsHandler, it must be in the chain of a mux route, so the span
+// is named by route template rather than raw path.
+func TracingHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+			route, err := routePathTemplate(r)
+			if err != nil {
+				route = unmatchedRoute
+			}
+			ctx, span := tracer.Start(ctx, fmt.Sprintf("%s %s", r.Method, route),
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					semconv.HTTPMethodKey.String(r.Method),
+					semconv.HTTPRouteKey.String(route),
+					semconv.HTTPTargetKey.String(r.URL.RequestURI()),
+				))
+			defer span.End()
+
+			rw := newStatusRecorder(w)
+			h.ServeHTTP(rw, r.WithContext(ctx))
+
+			span.SetAttributes(semconv.HTTPStatusCodeKey.Int(rw.status))
+			if rw.hasKind {
+				span.SetAttributes(attribute.String("error.kind", rw.kind.String()))
+			}
+			// client errors are the caller's, not the server's, so
+			// only server errors mark the span as failed
+			if rw.status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(rw.status))
+			}
+		})
+}
+
+// Log fields Google Cloud Logging correlates log entries with
+// Cloud Trace traces by
+const (
+	gcpTraceKey        string = "logging.googleapis.com/trace"
+	gcpSpanIDKey       string = "logging.googleapis.com/spanId"
+	gcpTraceSampledKey string = "logging.googleapis.com/trace_sampled"
+)
+
+// GCPProjectID is the Google Cloud project traces are logged for
+type GCPProjectID string
+
+// TraceLogHandler middleware adds the trace and span of the request
+// to the logger in the fields Google Cloud Logging correlates logs
+// with Cloud Trace by. The span started by TracingHandler is used if
+// there is one; otherwise the trace is taken from the traceparent or
+// X-Cloud-Trace-Context header. If the GCPProjectID is set, the trace
+// is logged as projects/PROJECT_ID/traces/TRACE_ID.
+func (mw Middleware) TraceLogHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			sc := trace.SpanContextFromContext(r.Context())
+			if !sc.IsValid() {
+				var ok bool
+				if sc, ok = tracing.SpanContextFromHeader(r.Header); !ok {
+					h.ServeHTTP(w, r)
+					return
+				}
+			}
+
+			traceValue := sc.TraceID().String()
+			if mw.GCPProjectID != "" {
+				traceValue = fmt.Sprintf("projects/%s/traces/%s", mw.GCPProjectID, traceValue)
+			}
+
+			lgr := hlog.FromRequest(r)
+			lgr.UpdateContext(func(c zerolog.Context) zerolog.Context {
+				c = c.Str(gcpTraceKey, traceValue).
+					Bool(gcpTraceSampledKey, sc.IsSampled())
+				if sc.HasSpanID() {
+					c = c.Str(gcpSpanIDKey, sc.SpanID().String())
+				}
+				return c
+			})
+
+			h.ServeHTTP(w, r)
+		})
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	qt "github.com/frankban/quicktest"
+	"github.com/gorilla/mux"
+	"github.com/justinas/alice"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/hlog"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/logger"
+	"github.com/gilcrest/go-api-basic/domain/tracing"
+)
+
+func TestTracingHandler(t *testing.T) {
+	// the package tracer delegates to the first global
+	// TracerProvider set, so it is only set once for the package tests
+	sr := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)))
+	otel.SetTextMapPropagator(tracing.NewPropagator())
+
+	lgr := logger.NewLogger(os.Stdout, true)
+	var handlerSpan trace.SpanContext
+	rtr := mux.NewRouter()
+	rtr.Handle(moviesV1PathRoot+"/{extlID}",
+		LoggerHandlerChain(lgr, alice.New()).Append(TracingHandler).
+			ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+				handlerSpan = trace.SpanContextFromContext(r.Context())
+				if mux.Vars(r)["extlID"] == "broken" {
+					errs.HTTPErrorResponse(w, *hlog.FromRequest(r), errs.E(errs.Database, errors.New("connection refused")))
+				}
+			}))
+
+	const (
+		traceID  = "4bf92f3577b34da6a3ce929d0e0e4736"
+		parentID = "00f067aa0ba902b7"
+	)
+
+	t.Run("continues trace", func(t *testing.T) {
+		c := qt.New(t)
+
+		req := httptest.NewRequest(http.MethodGet, moviesV1PathRoot+"/abc", nil)
+		req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+		rtr.ServeHTTP(httptest.NewRecorder(), req)
+
+		spans := sr.Ended()
+		span := spans[len(spans)-1]
+		c.Assert(span.Name(), qt.Equals, "GET "+moviesV1PathRoot+"/{extlID}")
+		c.Assert(span.SpanKind(), qt.Equals, trace.SpanKindServer)
+		c.Assert(span.SpanContext().TraceID().String(), qt.Equals, traceID)
+		c.Assert(span.Parent().SpanID().String(), qt.Equals, parentID)
+		c.Assert(span.Status().Code, qt.Equals, codes.Unset)
+		c.Assert(handlerSpan.SpanID(), qt.Equals, span.SpanContext().SpanID())
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		c := qt.New(t)
+
+		rtr.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, moviesV1PathRoot+"/broken", nil))
+
+		spans := sr.Ended()
+		span := spans[len(spans)-1]
+		c.Assert(span.Parent().IsValid(), qt.IsFalse)
+		c.Assert(span.Status().Code, qt.Equals, codes.Error)
+
+		attrs := make(map[attribute.Key]attribute.Value)
+		for _, kv := range span.Attributes() {
+			attrs[kv.Key] = kv.Value
+		}
+		c.Assert(attrs["http.status_code"].AsInt64(), qt.Equals, int64(http.StatusInternalServerError))
+		c.Assert(attrs["error.kind"].AsString(), qt.Equals, errs.Database.String())
+	})
+}
+
+func TestMiddleware_TraceLogHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		project GCPProjectID
+		header  string
+		value   string
+		want    map[string]interface{}
+	}{
+		{"traceparent", "", "traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", map[string]interface{}{
+			gcpTraceKey:        "4bf92f3577b34da6a3ce929d0e0e4736",
+			gcpSpanIDKey:       "00f067aa0ba902b7",
+			gcpTraceSampledKey: true,
+		}},
+		{"cloud trace context", "movies-prod", tracing.CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000/1;o=1", map[string]interface{}{
+			gcpTraceKey:        "projects/movies-prod/traces/105445aa7843bc8bf206b12000100000",
+			gcpSpanIDKey:       "0000000000000001",
+			gcpTraceSampledKey: true,
+		}},
+		{"cloud trace context without span", "", tracing.CloudTraceContextHeader, "105445aa7843bc8bf206b12000100000;o=0", map[string]interface{}{
+			gcpTraceKey:        "105445aa7843bc8bf206b12000100000",
+			gcpTraceSampledKey: false,
+		}},
+		{"no trace", "", "", "", map[string]interface{}{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := qt.New(t)
+
+			var b bytes.Buffer
+			lgr := logger.NewLogger(&b, false)
+			mw := Middleware{GCPProjectID: tt.project}
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			alice.New(hlog.NewHandler(lgr), mw.TraceLogHandler).
+				ThenFunc(func(w http.ResponseWriter, r *http.Request) {
+					hlog.FromRequest(r).Info().Msg("")
+				}).ServeHTTP(httptest.NewRecorder(), req)
+
+			var entry map[string]interface{}
+			c.Assert(json.Unmarshal(b.Bytes(), &entry), qt.IsNil)
+			got := make(map[string]interface{})
+			for _, k := range []string{gcpTraceKey, gcpSpanIDKey, gcpTraceSampledKey} {
+				if v, ok := entry[k]; ok {
+					got[k] = v
+				}
+			}
+			c.Assert(got, qt.DeepEquals, tt.want)
+		})
+	}
+}
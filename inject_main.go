@@ -1,23 +1,32 @@
-//+build wireinject
+//go:build wireinject
+// +build wireinject
 
 package main
 
 import (
 	"context"
-	"database/sql"
 	"net/http"
 
 	"github.com/gilcrest/go-api-basic/domain/random"
 
+	"github.com/gilcrest/go-api-basic/datastore/authstore"
+	"github.com/gilcrest/go-api-basic/datastore/clientstore"
 	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/orgstore"
 	"github.com/gilcrest/go-api-basic/datastore/pingstore"
+	"github.com/gilcrest/go-api-basic/datastore/userstore"
 
 	"github.com/gilcrest/go-api-basic/domain/auth"
-	"github.com/gilcrest/go-api-basic/gateway/authgateway"
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/healthcheck"
+	"github.com/gilcrest/go-api-basic/domain/org"
+	"github.com/gilcrest/go-api-basic/domain/ratelimit"
 
 	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/gateway/authgateway"
 	"github.com/gilcrest/go-api-basic/handler"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"go.opencensus.io/trace"
 
@@ -25,7 +34,6 @@ import (
 	"gocloud.dev/server"
 	"gocloud.dev/server/driver"
 	"gocloud.dev/server/health"
-	"gocloud.dev/server/health/sqlhealth"
 )
 
 var pingHandlerSet = wire.NewSet(
@@ -38,10 +46,16 @@ var pingHandlerSet = wire.NewSet(
 var movieHandlerSet = wire.NewSet(
 	wire.Struct(new(random.DefaultStringGenerator), "*"),
 	wire.Bind(new(random.StringGenerator), new(random.DefaultStringGenerator)),
-	wire.Struct(new(authgateway.GoogleAccessTokenConverter), "*"),
-	wire.Bind(new(auth.AccessTokenConverter), new(authgateway.GoogleAccessTokenConverter)),
-	wire.Struct(new(auth.DefaultAuthorizer), "*"),
-	wire.Bind(new(auth.Authorizer), new(auth.DefaultAuthorizer)),
+	newGoogleAccessTokenConverter,
+	wire.Struct(new(auth.APIKeyConverter), "*"),
+	wire.Struct(new(auth.SignedClientConverter), "*"),
+	wire.Struct(new(auth.SubjectCertificateConverter)),
+	wire.Bind(new(auth.CertificateConverter), new(auth.SubjectCertificateConverter)),
+	wire.Struct(new(auth.CertificateTokenConverter), "*"),
+	newDevTokenIssuer,
+	newAccessTokenConverter,
+	newSignatureVerifier,
+	newAuthorizer,
 	moviestore.NewDefaultTransactor,
 	wire.Bind(new(moviestore.Transactor), new(moviestore.DefaultTransactor)),
 	moviestore.NewDefaultSelector,
@@ -52,7 +66,94 @@ var movieHandlerSet = wire.NewSet(
 	handler.ProvideFindAllMoviesHandler,
 	handler.ProvideUpdateMovieHandler,
 	handler.ProvideDeleteMovieHandler,
-	wire.Struct(new(handler.Handlers), "*"),
+)
+
+var adminHandlerSet = wire.NewSet(
+	userstore.NewDefaultTransactor,
+	wire.Bind(new(userstore.Transactor), new(userstore.DefaultTransactor)),
+	userstore.NewDefaultSelector,
+	wire.Bind(new(userstore.Selector), new(userstore.DefaultSelector)),
+	authstore.NewDefaultTransactor,
+	wire.Bind(new(authstore.Transactor), new(authstore.DefaultTransactor)),
+	authstore.NewDefaultSelector,
+	wire.Bind(new(authstore.Selector), new(authstore.DefaultSelector)),
+	wire.Bind(new(auth.RoleFinder), new(authstore.DefaultSelector)),
+	wire.Bind(new(auth.APIKeyFinder), new(authstore.DefaultSelector)),
+	clientstore.NewDefaultTransactor,
+	wire.Bind(new(clientstore.Transactor), new(clientstore.DefaultTransactor)),
+	clientstore.NewDefaultSelector,
+	wire.Bind(new(clientstore.Selector), new(clientstore.DefaultSelector)),
+	wire.Bind(new(client.Finder), new(clientstore.DefaultSelector)),
+	wire.Bind(new(auth.SigningSecretFinder), new(clientstore.DefaultSelector)),
+	orgstore.NewDefaultTransactor,
+	wire.Bind(new(orgstore.Transactor), new(orgstore.DefaultTransactor)),
+	orgstore.NewDefaultSelector,
+	wire.Bind(new(orgstore.Selector), new(orgstore.DefaultSelector)),
+	wire.Bind(new(org.Finder), new(orgstore.DefaultSelector)),
+	wire.Struct(new(handler.DefaultAdminHandlers), "*"),
+	handler.ProvideFindAllUsersHandler,
+	handler.ProvideCreateUserHandler,
+	handler.ProvideFindUserByIDHandler,
+	handler.ProvideUpdateUserHandler,
+	handler.ProvideDeleteUserHandler,
+	handler.ProvideFindAllRolesHandler,
+	handler.ProvideCreateRoleHandler,
+	handler.ProvideFindRoleByIDHandler,
+	handler.ProvideUpdateRoleHandler,
+	handler.ProvideDeleteRoleHandler,
+	handler.ProvideFindAllRoleAssignmentsHandler,
+	handler.ProvideCreateRoleAssignmentHandler,
+	handler.ProvideDeleteRoleAssignmentHandler,
+	handler.ProvideFindAllAPIKeysHandler,
+	handler.ProvideCreateAPIKeyHandler,
+	handler.ProvideRevokeAPIKeyHandler,
+	handler.ProvideFindAllClientsHandler,
+	handler.ProvideCreateClientHandler,
+	handler.ProvideFindClientByIDHandler,
+	handler.ProvideUpdateClientHandler,
+	handler.ProvideRotateSigningSecretHandler,
+	handler.ProvideFindAllOrgsHandler,
+	handler.ProvideCreateOrgHandler,
+	handler.ProvideAddOrgMemberHandler,
+	handler.ProvideRemoveOrgMemberHandler,
+)
+
+var devHandlerSet = wire.NewSet(
+	wire.Struct(new(handler.DefaultDevHandlers), "*"),
+	handler.ProvideIssueDevTokenHandler,
+)
+
+var loginHandlerSet = wire.NewSet(
+	newOAuth2Config,
+	newLoginRedirectURL,
+	wire.Struct(new(handler.DefaultLoginHandlers), "*"),
+	handler.ProvideLoginHandler,
+	handler.ProvideLoginCallbackHandler,
+	handler.ProvideLogoutHandler,
+)
+
+var meHandlerSet = wire.NewSet(
+	wire.Struct(new(handler.DefaultMeHandlers), "*"),
+	handler.ProvideFindMeHandler,
+	handler.ProvideUpdateMeHandler,
+)
+
+var logLevelHandlerSet = wire.NewSet(
+	newLevelController,
+	newDebugTokenSigner,
+	wire.Struct(new(handler.DefaultLogLevelHandlers), "*"),
+	handler.ProvideFindLogLevelHandler,
+	handler.ProvideUpdateLogLevelHandler,
+	handler.ProvideIssueDebugTokenHandler,
+)
+
+var healthHandlerSet = wire.NewSet(
+	appHealthChecks,
+	newHealthMonitor,
+	wire.Struct(new(handler.DefaultHealthHandlers), "*"),
+	handler.ProvideLivenessHandler,
+	handler.ProvideReadinessHandler,
+	handler.ProvideHealthHandler,
 )
 
 var datastoreSet = wire.NewSet(
@@ -61,32 +162,55 @@ var datastoreSet = wire.NewSet(
 	wire.Bind(new(datastore.Datastorer), new(datastore.DefaultDatastore)),
 )
 
-// goCloudServerSet
+// goCloudServerSet is the Wire provider set for the gocloud server.
+// Tracing is done with OpenTelemetry, so the server's OpenCensus
+// spans are never sampled.
 var goCloudServerSet = wire.NewSet(
-	trace.AlwaysSample,
+	trace.NeverSample,
 	server.New,
-	server.NewDefaultDriver,
+	newServerDriver,
 	wire.Bind(new(driver.Server), new(*server.DefaultDriver)),
 )
 
+var metricsSet = wire.NewSet(
+	newMetrics,
+	newMetricsHandler,
+)
+
 var routerSet = wire.NewSet(
+	newAuthMode,
+	newSessionCodec,
+	newGCPProjectID,
+	newBodyLogConfig,
+	newTimeoutConfig,
+	ratelimit.NewMemoryStore,
+	wire.Bind(new(ratelimit.Store), new(*ratelimit.MemoryStore)),
+	newRateLimitConfig,
+	wire.Struct(new(handler.Middleware), "*"),
 	handler.NewMuxRouter,
 	wire.Bind(new(http.Handler), new(*mux.Router)),
 )
 
 // newServer is a Wire injector function that sets up the
 // application using a PostgreSQL implementation
-func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatasourceName) (*server.Server, func(), error) {
+func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatasourceName, flags *cliFlags, reg *prometheus.Registry, drainer *healthcheck.Drainer) (*server.Server, func(), error) {
 	// This will be filled in by Wire with providers from the provider sets in
 	// wire.Build.
 	wire.Build(
 		wire.InterfaceValue(new(trace.Exporter), trace.Exporter(nil)),
 		goCloudServerSet,
-		appHealthChecks,
+		healthHandlerSet,
 		wire.Struct(new(server.Options), "HealthChecks", "TraceExporter", "DefaultSamplingPolicy", "Driver"),
 		datastoreSet,
 		movieHandlerSet,
+		adminHandlerSet,
+		meHandlerSet,
+		devHandlerSet,
+		loginHandlerSet,
 		pingHandlerSet,
+		logLevelHandlerSet,
+		metricsSet,
+		wire.Struct(new(handler.Handlers), "*"),
 		routerSet,
 	)
 	return nil, nil, nil
@@ -119,13 +243,38 @@ func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatas
 //	return nil, nil, nil
 //}
 
-// appHealthChecks returns a health check for the database. This will signal
-// to Kubernetes or other orchestrators that the server should not receive
-// traffic until the server is able to connect to its database.
-func appHealthChecks(db *sql.DB) ([]health.Checker, func()) {
-	dbCheck := sqlhealth.New(db)
-	list := []health.Checker{dbCheck}
-	return list, func() {
-		dbCheck.Stop()
+// appHealthChecks returns the health checks of the application: the
+// drainer, which fails once the server is shutting down, and the
+// dependencies of the application: the database, its schema and,
+// if the auth mode converts Bearer tokens with Google, Google.
+// This will signal to Kubernetes or other orchestrators that the
+// server should not receive traffic until the server is able to
+// reach its dependencies. Add checks for new dependencies here.
+//
+// Google is checked with a plain client, so probes are not retried
+// and do not trip the circuit breaker used to convert tokens, and
+// the result is cached, so probes do not call Google every time.
+func appHealthChecks(drainer *healthcheck.Drainer, ds datastore.Datastorer, mode handler.AuthMode, google authgateway.GoogleAccessTokenConverter, flags *cliFlags) []health.Checker {
+	list := []health.Checker{
+		drainer,
+		datastore.PingChecker{Datastorer: ds},
+		datastore.SchemaChecker{Datastorer: ds, Tables: datastore.SchemaTables},
+	}
+	if usesGoogle(mode) {
+		check := authgateway.GoogleAccessTokenConverter{
+			Endpoint:   google.Endpoint,
+			HTTPClient: &http.Client{Timeout: flags.healthTTL},
+		}
+		list = append(list, healthcheck.Cached(check, healthcheck.DefaultCacheTTL))
 	}
+	return list
+}
+
+// usesGoogle reports whether requests are authenticated with Google
+// access tokens in the auth mode. Only token mode converts Bearer
+// tokens with Google: dev mode converts them with the dev token
+// issuer and mTLS mode authenticates every user request with its
+// client certificate, session cookies included.
+func usesGoogle(mode handler.AuthMode) bool {
+	return mode == handler.TokenAuthMode
 }
@@ -2,18 +2,44 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/pkg/errors"
 
 	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/domain/auth"
 	"github.com/gilcrest/go-api-basic/domain/errs"
+	"github.com/gilcrest/go-api-basic/domain/healthcheck"
 	"github.com/gilcrest/go-api-basic/domain/logger"
-
+	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/ratelimit"
+	"github.com/gilcrest/go-api-basic/domain/tracing"
+	"github.com/gilcrest/go-api-basic/gateway"
+	"github.com/gilcrest/go-api-basic/gateway/authgateway"
+	"github.com/gilcrest/go-api-basic/handler"
+
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"gocloud.dev/server"
+	"gocloud.dev/server/health"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	googleoauth "google.golang.org/api/oauth2/v2"
 )
 
 // cliFlags are the command line flags parsed at startup
@@ -25,6 +51,41 @@ type cliFlags struct {
 	dbname     string
 	dbuser     string
 	dbpassword string
+	policy     string
+	policyTest string
+	roleAuthz  bool
+	realm      string
+	sigWindow  time.Duration
+	authMode   string
+	tlsCert    string
+	tlsKey     string
+	clientCA   string
+	devKey     string
+	devToken   string
+	googleURL  string
+	googleTTL  time.Duration
+	googleScps string
+	sessionKey string
+	callback   string
+	loginURL   string
+	adminPort  int
+	traceExp   string
+	traceURL   string
+	traceRatio float64
+	traceHTTP  bool
+	gcpProject string
+	debugKey   string
+	logBodies  bool
+	bodyMax    int
+	redactJSON string
+	redactHdrs string
+	healthTTL  time.Duration
+	drainTTL   time.Duration
+	reqTTL     time.Duration
+	routeTTLs  string
+	rateLimit  string
+	routeRates string
+	ipRate     string
 }
 
 func main() {
@@ -54,19 +115,190 @@ func main() {
 	// dbname is the database name
 	flag.StringVar(&cf.dbpassword, "dbpassword", "", "postgresql database password")
 
+	// policy is the path to a JSON or YAML policy file. If set,
+	// requests are authorized using the policy file instead of the
+	// default authorizer. The file is reloaded when it changes.
+	flag.StringVar(&cf.policy, "policy", "", "path to JSON or YAML authorization policy file")
+
+	// policytest tests a user,path,method triple against the policy
+	// file and exits, e.g. -policytest=otto.maddox711@gmail.com,/api/v1/movies,GET
+	flag.StringVar(&cf.policyTest, "policytest", "", "test a user,path,method triple against the policy file and exit")
+
+	// roleauthz authorizes requests using the roles and permissions
+	// maintained through the /api/v1/admin APIs. The policy flag
+	// takes precedence if both are set.
+	flag.BoolVar(&cf.roleAuthz, "roleauthz", false, "authorize requests using roles maintained through the admin API")
+
+	// realm is the protection space sent in the WWW-Authenticate
+	// challenge of 401 and 403 responses
+	flag.StringVar(&cf.realm, "realm", errs.DefaultRealm, "realm sent in WWW-Authenticate challenges")
+
+	// signaturewindow is the replay window for requests signed by
+	// registered clients
+	flag.DurationVar(&cf.sigWindow, "signaturewindow", auth.DefaultSignatureWindow, "replay window for signed requests")
+
+	// auth is the authentication mode: token (Bearer tokens and API
+	// keys), mtls (TLS client certificates) or dev (dev tokens issued
+	// by the server, for local development only)
+	flag.StringVar(&cf.authMode, "auth", string(handler.TokenAuthMode), "authentication mode (token, mtls, dev)")
+
+	// devkey is the key dev tokens are signed with. If not set, the
+	// DEV_TOKEN_KEY environment variable is used, and if neither is
+	// set, a random key is generated at startup.
+	flag.StringVar(&cf.devKey, "devkey", "", "key to sign dev tokens with (dev auth mode)")
+
+	// devtoken prints a dev token for the given email signed with the
+	// dev key and exits, e.g. -devtoken=otto.maddox711@gmail.com
+	flag.StringVar(&cf.devToken, "devtoken", "", "print a dev token for the given email and exit")
+
+	// tlscert and tlskey are the server certificate and key files.
+	// If set, the server terminates TLS itself.
+	flag.StringVar(&cf.tlsCert, "tlscert", "", "path to PEM encoded TLS server certificate")
+	flag.StringVar(&cf.tlsKey, "tlskey", "", "path to PEM encoded TLS server key")
+
+	// clientca is the CA bundle client certificates are verified
+	// against in mtls auth mode
+	flag.StringVar(&cf.clientCA, "clientca", "", "path to PEM encoded CA bundle for verifying client certificates (mtls auth mode)")
+
+	// googleendpoint is the base URL of the Google API used to
+	// validate Bearer tokens, e.g. a fake server for local testing
+	flag.StringVar(&cf.googleURL, "googleendpoint", "", "base URL of the Google API (defaults to Google's)")
+
+	// googletimeout is the timeout for each attempt of a call to
+	// the Google API
+	flag.DurationVar(&cf.googleTTL, "googletimeout", 5*time.Second, "timeout for each attempt of a call to the Google API")
+
+	// googlescopes are the API scopes granted to Google access
+	// tokens, space-delimited. The admin scope is not granted unless
+	// listed, and users granted it are still authorized by the
+	// policy file or their roles.
+	flag.StringVar(&cf.googleScps, "googlescopes", auth.DefaultUserScopes.String(), "space-delimited API scopes granted to Google access tokens")
+
+	// sessionkey is the base64 encoded 32 byte key session cookies
+	// are encrypted with. If not set, the SESSION_KEY environment
+	// variable is used, and if neither is set, a random key is
+	// generated at startup and sessions do not survive a restart.
+	flag.StringVar(&cf.sessionKey, "sessionkey", "", "base64 encoded 32 byte key to encrypt session cookies with")
+
+	// oauthcallback is the URL of the /api/v1/auth/callback route
+	// registered with Google for the browser login flow
+	flag.StringVar(&cf.callback, "oauthcallback", "http://localhost:8080/api/v1/auth/callback", "OAuth2 redirect URL for the browser login flow")
+
+	// loginredirect is the URL browsers are redirected to after
+	// logging in or out
+	flag.StringVar(&cf.loginURL, "loginredirect", "/", "URL to redirect browsers to after login and logout")
+
+	// adminport is the port the metrics are served on at /metrics.
+	// If not set, they are served on the API port.
+	flag.IntVar(&cf.adminPort, "adminport", 0, "network port to serve /metrics on, separately from the API")
+
+	// traceexporter is where the OpenTelemetry spans are exported:
+	// none, stdout, or otlp (an OpenTelemetry collector)
+	flag.StringVar(&cf.traceExp, "traceexporter", string(tracing.NoExporter), "trace span exporter (none, stdout, otlp)")
+
+	// traceendpoint is the host:port of the collector the otlp
+	// exporter sends spans to. If not set, the
+	// OTEL_EXPORTER_OTLP_ENDPOINT environment variable is used.
+	flag.StringVar(&cf.traceURL, "traceendpoint", "", "host:port of the OpenTelemetry collector (otlp trace exporter)")
+
+	// traceinsecure sends spans to the collector over plain HTTP
+	flag.BoolVar(&cf.traceHTTP, "traceinsecure", false, "send spans to the OpenTelemetry collector without TLS")
+
+	// tracesample is the ratio of new traces which are sampled.
+	// Traces continued from a traceparent header follow the
+	// caller's sampling decision.
+	flag.Float64Var(&cf.traceRatio, "tracesample", 1, "ratio of new traces to sample, between 0 and 1")
+
+	// gcpproject is the Google Cloud project logs are correlated
+	// with Cloud Trace traces for. If not set, the
+	// GOOGLE_CLOUD_PROJECT environment variable is used.
+	flag.StringVar(&cf.gcpProject, "gcpproject", "", "Google Cloud project to correlate logs with Cloud Trace for")
+
+	// debugkey is the key debug tokens are signed with. A request
+	// with a valid debug token in the X-Debug-Log header is logged at
+	// debug level. If not set, the DEBUG_LOG_KEY environment variable
+	// is used, and if neither is set, a random key is generated at
+	// startup.
+	flag.StringVar(&cf.debugKey, "debugkey", "", "key to sign debug log tokens with")
+
+	// logbodies logs the request and response headers and JSON bodies
+	// of requests logged at debug level. Bodies over logbodymax bytes
+	// are omitted. The comma separated JSON fields in redactfields
+	// (e.g. user.password) and headers in redactheaders are redacted,
+	// as are the credentials issued in responses and the
+	// Authorization, Cookie, Set-Cookie, X-API-Key and X-Debug-Log
+	// headers.
+	flag.BoolVar(&cf.logBodies, "logbodies", false, "log request and response bodies at debug level")
+	flag.IntVar(&cf.bodyMax, "logbodymax", 4096, "largest request or response body logged, in bytes")
+	flag.StringVar(&cf.redactJSON, "redactfields", "", "comma separated JSON fields to redact in logged bodies")
+	flag.StringVar(&cf.redactHdrs, "redactheaders", "", "comma separated headers to redact in logged requests and responses")
+
+	// healthtimeout is how long each health check may take before it
+	// is considered failed
+	flag.DurationVar(&cf.healthTTL, "healthtimeout", healthcheck.DefaultTimeout, "timeout of each health check")
+
+	// shutdowntimeout is how long requests in flight are given to
+	// finish after a SIGTERM or SIGINT. Cloud Run kills the container
+	// 10 seconds after SIGTERM, so the default leaves time to close
+	// the database and flush the spans.
+	flag.DurationVar(&cf.drainTTL, "shutdowntimeout", 8*time.Second, "time given to requests in flight to finish on shutdown")
+
+	// requesttimeout is the deadline of a request, after which its
+	// database queries and outbound calls are cut off and a 504 is
+	// returned. routetimeouts overrides it for the given routes as
+	// comma separated path template=duration pairs, e.g.
+	// /api/v1/movies=5s,/api/v1/movies/{extlID}=2s
+	flag.DurationVar(&cf.reqTTL, "requesttimeout", 30*time.Second, "deadline of a request")
+	flag.StringVar(&cf.routeTTLs, "routetimeouts", "", "comma separated path template=duration request deadlines by route")
+
+	// ratelimit limits the requests of each user, client or remote IP
+	// to a token bucket, written as requests/duration, e.g. 100/1m.
+	// routeratelimits gives routes their own limits as comma
+	// separated path template=requests/duration pairs, e.g.
+	// /api/v1/movies=10/1s. Requests are not limited if neither is
+	// set. ipratelimit limits the requests of each remote IP before
+	// they are authenticated, so failed attempts are limited as well.
+	flag.StringVar(&cf.rateLimit, "ratelimit", "", "requests/duration allowed per user, client or IP")
+	flag.StringVar(&cf.routeRates, "routeratelimits", "", "comma separated path template=requests/duration rate limits by route")
+	flag.StringVar(&cf.ipRate, "ipratelimit", "", "requests/duration allowed per remote IP before authentication")
+
 	// Parse the command line flags from above
 	flag.Parse()
 
+	// if policytest is set, evaluate the triple against the policy
+	// file and exit without starting the server
+	if cf.policyTest != "" {
+		os.Exit(testPolicy(os.Stdout, cf))
+	}
+
+	// if devtoken is set, print a dev token and exit without
+	// starting the server
+	if cf.devToken != "" {
+		os.Exit(printDevToken(os.Stdout, cf))
+	}
+
+	// write the stack trace of logged errors
+	logger.WriteErrorStackGlobal(true)
+
 	// setup logger with appropriate defaults
 	logger := logger.NewLogger(os.Stdout, true)
 
 	// determine logging level
 	loglvl := newLogLevel(cf)
 
-	// set global logging level based on flag input
-	zerolog.SetGlobalLevel(loglvl)
+	// set the logging level based on flag input. The level is set on
+	// the logger rather than globally, as zerolog drops events below
+	// the global level whatever the level of the logger, which would
+	// rule out raising the level of a single request to debug. The
+	// level of request loggers is set by the LogLevelHandler
+	// middleware and can be changed at runtime.
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	logger = logger.Level(loglvl)
 	logger.Info().Msgf("logging level set to %s", loglvl)
 
+	// set the realm for WWW-Authenticate challenges
+	errs.SetRealm(cf.realm)
+
 	// set global logging time field format to Unix timestamp
 	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 
@@ -74,6 +306,9 @@ func main() {
 	if cf.port < 0 || cf.port > 65535 {
 		logger.Fatal().Msgf("port %d is not within valid port range (0 to 65535", cf.port)
 	}
+	if cf.adminPort < 0 || cf.adminPort > 65535 {
+		logger.Fatal().Msgf("adminport %d is not within valid port range (0 to 65535", cf.adminPort)
+	}
 
 	dsn, err := newPGDatasourceName(cf)
 	if err != nil {
@@ -83,16 +318,124 @@ func main() {
 	// initialize a non-nil, empty context
 	ctx := context.Background()
 
+	// set the global OpenTelemetry TracerProvider and the W3C
+	// traceparent propagator
+	tp, err := newTracerProvider(ctx, cf)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Error returned from newTracerProvider")
+	}
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(tracing.NewPropagator())
+
+	// reg is the Prometheus registry for the application metrics
+	reg := newMetricsRegistry()
+
+	// drainer fails the readiness checks once shutdown starts
+	drainer := healthcheck.NewDrainer()
+
 	// newServer function returns a pointer to a gocloud server, a
 	// cleanup function and an error
-	srv, cleanup, err := newServer(ctx, logger, dsn)
+	srv, cleanup, err := newServer(ctx, logger, dsn, cf, reg, drainer)
 	if err != nil {
 		logger.Fatal().Err(err).Msg("Error returned from newServer")
 	}
-	defer cleanup()
 
-	// Listen and serve HTTP
-	logger.Fatal().Err(srv.ListenAndServe(fmt.Sprintf(":%d", cf.port))).Msg("Fatal Server Error")
+	// serve the metrics on the admin port, if set. adminErrc stays
+	// nil otherwise, so it is never selected below.
+	var (
+		admin     *http.Server
+		adminErrc <-chan error
+	)
+	if cf.adminPort != 0 {
+		admin, adminErrc = serveAdmin(logger, cf.adminPort, reg)
+	}
+
+	// serve until the server fails or is told to stop
+	errc := make(chan error, 1)
+	go func() {
+		errc <- listenAndServe(srv, cf)
+	}()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGTERM, os.Interrupt)
+
+	exitCode := 0
+	select {
+	case err = <-errc:
+		logger.Error().Err(err).Msg("Fatal Server Error")
+		exitCode = 1
+	case err = <-adminErrc:
+		// the API server is still serving, so it is drained
+		// like on a signal
+		logger.Error().Err(err).Msgf("Fatal Admin Server Error, shutting down, draining requests for up to %s", cf.drainTTL)
+		exitCode = 1
+		if err = shutdown(ctx, srv, admin, drainer, cf.drainTTL); err != nil {
+			logger.Error().Err(err).Msg("requests still in flight at the shutdown deadline were cut off")
+		}
+	case sig := <-sigc:
+		logger.Info().Str("signal", sig.String()).Msgf("shutting down, draining requests for up to %s", cf.drainTTL)
+
+		// a second signal stops the server without waiting
+		go func() {
+			sig := <-sigc
+			logger.Warn().Str("signal", sig.String()).Msg("second signal received, exiting without draining")
+			os.Exit(1)
+		}()
+
+		if err = shutdown(ctx, srv, admin, drainer, cf.drainTTL); err != nil {
+			logger.Error().Err(err).Msg("requests still in flight at the shutdown deadline were cut off")
+			exitCode = 1
+		}
+	}
+
+	// close the database pool once no request can use it, then flush
+	// the spans not yet exported. The metrics need no flushing, as
+	// Prometheus pulls them.
+	cleanup()
+	if err = tp.Shutdown(ctx); err != nil {
+		logger.Error().Err(err).Msg("Error flushing spans")
+	}
+
+	logger.Info().Msg("server stopped")
+	os.Exit(exitCode)
+}
+
+// listenAndServe listens and serves HTTPS if a server certificate was
+// given, otherwise HTTP. It returns when the server fails or is shut
+// down.
+func listenAndServe(srv *server.Server, flags *cliFlags) error {
+	addr := fmt.Sprintf(":%d", flags.port)
+
+	var err error
+	if flags.tlsCert != "" {
+		err = srv.ListenAndServeTLS(addr, flags.tlsCert, flags.tlsKey)
+	} else {
+		err = srv.ListenAndServe(addr)
+	}
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// shutdown stops the server gracefully. The readiness checks fail from
+// then on, no new connections are accepted and requests in flight are
+// given until timeout to finish. The admin server is shut down last,
+// so the metrics can be scraped while the requests are drained.
+func shutdown(ctx context.Context, srv *server.Server, admin *http.Server, drainer *healthcheck.Drainer, timeout time.Duration) error {
+	drainer.Drain()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := srv.Shutdown(ctx)
+	if admin != nil {
+		if aerr := admin.Shutdown(ctx); err == nil {
+			err = aerr
+		}
+	}
+
+	return err
 }
 
 // newLogLevel sets up the logging level (e.g. Debug, Info, Error, etc.)
@@ -124,6 +467,466 @@ func newLogLevel(flags *cliFlags) zerolog.Level {
 	return lvl
 }
 
+// newGoogleAccessTokenConverter returns the converter for Google
+// access tokens, calling the Google API at the googleendpoint flag
+// if set through a resilient outbound client and granting the scopes
+// of the googlescopes flag
+func newGoogleAccessTokenConverter(flags *cliFlags) (authgateway.GoogleAccessTokenConverter, error) {
+	scopes, err := auth.NewScopes(auth.ParseScopes(flags.googleScps))
+	if err != nil {
+		return authgateway.GoogleAccessTokenConverter{}, err
+	}
+
+	return authgateway.GoogleAccessTokenConverter{
+		Endpoint:   flags.googleURL,
+		HTTPClient: gateway.NewClient(gateway.Destination{Name: "google", Timeout: flags.googleTTL}),
+		Scopes:     scopes,
+	}, nil
+}
+
+// newAccessTokenConverter returns the auth.AccessTokenConverter for
+// the application, which converts Bearer tokens using Google (or, in
+// dev auth mode, the dev token issuer), API keys using the keys
+// issued through the admin API, signed requests using the registered
+// client which signed them and client certificates using their
+// subject. Conversions are counted in the metrics.
+func newAccessTokenConverter(mode handler.AuthMode, google authgateway.GoogleAccessTokenConverter, dev auth.DevTokenIssuer, apiKeys auth.APIKeyConverter, signed auth.SignedClientConverter, certs auth.CertificateTokenConverter, m *handler.Metrics) auth.AccessTokenConverter {
+	var bearer auth.AccessTokenConverter = google
+	if mode == handler.DevAuthMode {
+		bearer = dev
+	}
+
+	return m.InstrumentConverter(auth.TokenTypeConverter{
+		auth.BearerTokenType:      bearer,
+		auth.APIKeyTokenType:      apiKeys,
+		auth.SignatureTokenType:   signed,
+		auth.CertificateTokenType: certs,
+	})
+}
+
+// newTracerProvider returns the OpenTelemetry TracerProvider
+// configured by the trace flags
+func newTracerProvider(ctx context.Context, flags *cliFlags) (*sdktrace.TracerProvider, error) {
+	return tracing.NewTracerProvider(ctx, tracing.Config{
+		Exporter:    tracing.Exporter(flags.traceExp),
+		Endpoint:    flags.traceURL,
+		Insecure:    flags.traceHTTP,
+		SampleRatio: flags.traceRatio,
+		Writer:      os.Stdout,
+	})
+}
+
+// newMetricsRegistry returns the Prometheus registry for the
+// application metrics, with the Go runtime and process metrics
+// registered
+func newMetricsRegistry() *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	return reg
+}
+
+// newMetrics returns the handler.Metrics registered with reg, and
+// registers the connection pool stats of the database along with them
+func newMetrics(reg *prometheus.Registry, ds datastore.Datastorer) (*handler.Metrics, error) {
+	err := reg.Register(datastore.NewStatsCollector(ds))
+	if err != nil {
+		return nil, errs.E(errs.Internal, err)
+	}
+	return handler.NewMetrics(reg)
+}
+
+// newMetricsHandler returns the handler.MetricsHandler registered at
+// /metrics on the API port, or nil if the metrics are served on the
+// admin port
+func newMetricsHandler(flags *cliFlags, reg *prometheus.Registry) handler.MetricsHandler {
+	if flags.adminPort != 0 {
+		return nil
+	}
+	return handler.NewMetricsHandler(reg)
+}
+
+// serveAdmin serves the metrics at /metrics on the admin port. If
+// the admin server fails, e.g. as the port is taken, the error is sent
+// on the returned channel, so main can shut down the API server
+// gracefully.
+func serveAdmin(logger zerolog.Logger, port int, reg *prometheus.Registry) (*http.Server, <-chan error) {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", handler.NewMetricsHandler(reg))
+
+	admin := &http.Server{Addr: fmt.Sprintf(":%d", port), Handler: mux}
+	logger.Info().Msgf("serving metrics on %s", admin.Addr)
+
+	errc := make(chan error, 1)
+	go func() {
+		if err := admin.ListenAndServe(); err != http.ErrServerClosed {
+			errc <- err
+		}
+	}()
+
+	return admin, errc
+}
+
+// newAuthMode returns the handler.AuthMode given by the auth flag
+func newAuthMode(flags *cliFlags) (handler.AuthMode, error) {
+	switch m := handler.AuthMode(flags.authMode); m {
+	case handler.TokenAuthMode, handler.MTLSAuthMode, handler.DevAuthMode:
+		return m, nil
+	default:
+		return "", errs.E(errs.Validation, errs.Parameter("auth"), errors.Errorf("unknown auth mode %q", flags.authMode))
+	}
+}
+
+// devTokenKeyEnv is the environment variable holding the dev token key
+const devTokenKeyEnv string = "DEV_TOKEN_KEY"
+
+// devTokenKey returns the key dev tokens are signed with from the
+// devkey flag or the DEV_TOKEN_KEY environment variable
+func devTokenKey(flags *cliFlags) []byte {
+	if flags.devKey != "" {
+		return []byte(flags.devKey)
+	}
+	return []byte(os.Getenv(devTokenKeyEnv))
+}
+
+// newDevTokenIssuer returns the auth.DevTokenIssuer used in dev auth
+// mode. If no dev token key is set, a random key is generated, so
+// tokens can only be issued through the /dev/token endpoint. Outside
+// of dev auth mode, an issuer without a key is returned, which
+// accepts no tokens.
+func newDevTokenIssuer(logger zerolog.Logger, flags *cliFlags, mode handler.AuthMode) (auth.DevTokenIssuer, error) {
+	if mode != handler.DevAuthMode {
+		return auth.DevTokenIssuer{}, nil
+	}
+
+	logger.Warn().Msg("dev auth mode: dev tokens are accepted instead of Google tokens, never use in production")
+
+	key := devTokenKey(flags)
+	if len(key) == 0 {
+		b, err := random.GenerateRandomBytes(32)
+		if err != nil {
+			return auth.DevTokenIssuer{}, err
+		}
+		key = b
+		logger.Info().Msg("no dev token key set, generated a random key")
+	}
+
+	return auth.DevTokenIssuer{Key: key}, nil
+}
+
+// printDevToken writes a dev token for the email given by the
+// devtoken flag to w and returns the process exit code: 0 if the
+// token was issued and 2 otherwise. The token has all scopes and
+// the default lifetime.
+func printDevToken(w io.Writer, flags *cliFlags) int {
+	key := devTokenKey(flags)
+	if len(key) == 0 {
+		fmt.Fprintf(w, "devkey flag or %s environment variable is required with devtoken\n", devTokenKeyEnv)
+		return 2
+	}
+
+	token, err := auth.DevTokenIssuer{Key: key}.Issue(flags.devToken, auth.AllScopes, auth.DefaultDevTokenTTL)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 2
+	}
+
+	fmt.Fprintln(w, token)
+	return 0
+}
+
+// Environment variables for the browser login flow
+const (
+	sessionKeyEnv         string = "SESSION_KEY"
+	googleClientIDEnv     string = "GOOGLE_CLIENT_ID"
+	googleClientSecretEnv string = "GOOGLE_CLIENT_SECRET"
+)
+
+// newSessionCodec returns the auth.SessionCodec session cookies are
+// encrypted with, using the key from the sessionkey flag or the
+// SESSION_KEY environment variable, or a random key if neither is set
+func newSessionCodec(logger zerolog.Logger, flags *cliFlags) (auth.SessionCodec, error) {
+	v := flags.sessionKey
+	if v == "" {
+		v = os.Getenv(sessionKeyEnv)
+	}
+
+	if v == "" {
+		key, err := random.GenerateRandomBytes(auth.SessionKeySize)
+		if err != nil {
+			return auth.SessionCodec{}, err
+		}
+		logger.Info().Msg("no session key set, generated a random key")
+		return auth.NewSessionCodec(key)
+	}
+
+	key, err := base64.StdEncoding.DecodeString(v)
+	if err != nil {
+		return auth.SessionCodec{}, errs.E(errs.Validation, errs.Parameter("sessionkey"), err)
+	}
+
+	return auth.NewSessionCodec(key)
+}
+
+// newOAuth2Config returns the Google OAuth2 config for the browser
+// login flow, using the client ID and secret from the environment.
+// If no client ID is set, nil is returned and the login routes
+// respond with an error.
+func newOAuth2Config(flags *cliFlags) *oauth2.Config {
+	id := os.Getenv(googleClientIDEnv)
+	if id == "" {
+		return nil
+	}
+
+	return &oauth2.Config{
+		ClientID:     id,
+		ClientSecret: os.Getenv(googleClientSecretEnv),
+		Endpoint:     google.Endpoint,
+		RedirectURL:  flags.callback,
+		Scopes:       []string{googleoauth.UserinfoEmailScope, googleoauth.UserinfoProfileScope},
+	}
+}
+
+// newGCPProjectID returns the Google Cloud project from the
+// gcpproject flag or, if not set, the GOOGLE_CLOUD_PROJECT
+// environment variable
+func newGCPProjectID(flags *cliFlags) handler.GCPProjectID {
+	if flags.gcpProject != "" {
+		return handler.GCPProjectID(flags.gcpProject)
+	}
+	return handler.GCPProjectID(os.Getenv("GOOGLE_CLOUD_PROJECT"))
+}
+
+// newLevelController returns the logger.LevelController for the
+// request loggers, starting at the level of the loglvl flag
+func newLevelController(flags *cliFlags) *logger.LevelController {
+	return logger.NewLevelController(newLogLevel(flags))
+}
+
+// debugLogKeyEnv is the environment variable holding the debug token key
+const debugLogKeyEnv string = "DEBUG_LOG_KEY"
+
+// newDebugTokenSigner returns the logger.DebugTokenSigner for debug
+// tokens, using the key from the debugkey flag or the DEBUG_LOG_KEY
+// environment variable, or a random key if neither is set
+func newDebugTokenSigner(lgr zerolog.Logger, flags *cliFlags) (logger.DebugTokenSigner, error) {
+	key := flags.debugKey
+	if key == "" {
+		key = os.Getenv(debugLogKeyEnv)
+	}
+
+	if key == "" {
+		b, err := random.GenerateRandomBytes(32)
+		if err != nil {
+			return logger.DebugTokenSigner{}, err
+		}
+		lgr.Info().Msg("no debug token key set, generated a random key")
+		return logger.DebugTokenSigner{Key: b}, nil
+	}
+
+	return logger.DebugTokenSigner{Key: []byte(key)}, nil
+}
+
+// newBodyLogConfig returns the handler.BodyLogConfig from the
+// logbodies, logbodymax, redactfields and redactheaders flags
+func newBodyLogConfig(flags *cliFlags) handler.BodyLogConfig {
+	return handler.BodyLogConfig{
+		Enabled:       flags.logBodies,
+		MaxBytes:      flags.bodyMax,
+		RedactPaths:   splitList(flags.redactJSON),
+		RedactHeaders: splitList(flags.redactHdrs),
+	}
+}
+
+// newTimeoutConfig returns the handler.TimeoutConfig from the
+// requesttimeout and routetimeouts flags
+func newTimeoutConfig(flags *cliFlags) (handler.TimeoutConfig, error) {
+	cfg := handler.TimeoutConfig{Default: flags.reqTTL, Routes: make(map[string]time.Duration)}
+	for _, rt := range splitList(flags.routeTTLs) {
+		i := strings.LastIndex(rt, "=")
+		if i < 0 {
+			return handler.TimeoutConfig{}, errs.E(errs.Validation, errors.Errorf("routetimeouts: %q is not a path template=duration pair", rt))
+		}
+		d, err := time.ParseDuration(rt[i+1:])
+		if err != nil || d <= 0 {
+			return handler.TimeoutConfig{}, errs.E(errs.Validation, errors.Errorf("routetimeouts: invalid duration for %s", rt[:i]))
+		}
+		cfg.Routes[rt[:i]] = d
+	}
+	return cfg, nil
+}
+
+// newRateLimitConfig returns the handler.RateLimitConfig from the
+// ratelimit, routeratelimits and ipratelimit flags, keeping the
+// buckets in store
+func newRateLimitConfig(flags *cliFlags, store ratelimit.Store) (handler.RateLimitConfig, error) {
+	cfg := handler.RateLimitConfig{Store: store, Routes: make(map[string]ratelimit.Limit)}
+
+	if flags.rateLimit != "" {
+		l, err := ratelimit.ParseLimit(flags.rateLimit)
+		if err != nil {
+			return handler.RateLimitConfig{}, err
+		}
+		cfg.Default = l
+	}
+
+	if flags.ipRate != "" {
+		l, err := ratelimit.ParseLimit(flags.ipRate)
+		if err != nil {
+			return handler.RateLimitConfig{}, err
+		}
+		cfg.IP = l
+	}
+
+	for _, rl := range splitList(flags.routeRates) {
+		i := strings.LastIndex(rl, "=")
+		if i < 0 {
+			return handler.RateLimitConfig{}, errs.E(errs.Validation, errors.Errorf("routeratelimits: %q is not a path template=requests/duration pair", rl))
+		}
+		l, err := ratelimit.ParseLimit(rl[i+1:])
+		if err != nil {
+			return handler.RateLimitConfig{}, err
+		}
+		cfg.Routes[rl[:i]] = l
+	}
+
+	return cfg, nil
+}
+
+// splitList splits a comma separated flag value, dropping blanks
+func splitList(v string) []string {
+	var l []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			l = append(l, s)
+		}
+	}
+	return l
+}
+
+// newHealthMonitor returns the healthcheck.Monitor running the health
+// checks with the timeout from the healthtimeout flag
+func newHealthMonitor(checks []health.Checker, flags *cliFlags) *healthcheck.Monitor {
+	return healthcheck.NewMonitor(checks, flags.healthTTL)
+}
+
+// newLoginRedirectURL returns the URL browsers are redirected to
+// after login and logout from the loginredirect flag
+func newLoginRedirectURL(flags *cliFlags) handler.LoginRedirectURL {
+	return handler.LoginRedirectURL(flags.loginURL)
+}
+
+// newServerDriver returns the gocloud server driver. In mtls auth
+// mode, the driver is configured to require a client certificate
+// verified against the clientca bundle during the TLS handshake.
+func newServerDriver(flags *cliFlags, mode handler.AuthMode) (*server.DefaultDriver, error) {
+	d := server.NewDefaultDriver()
+	if mode != handler.MTLSAuthMode {
+		return d, nil
+	}
+
+	if flags.tlsCert == "" || flags.tlsKey == "" || flags.clientCA == "" {
+		return nil, errs.E(errs.Validation, errors.New("tlscert, tlskey and clientca flags are required for mtls auth mode"))
+	}
+
+	b, err := ioutil.ReadFile(flags.clientCA)
+	if err != nil {
+		return nil, errs.E(errs.IO, errors.Wrap(err, "unable to read client CA bundle"))
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, errs.E(errs.Validation, errors.Errorf("no PEM certificates found in %s", flags.clientCA))
+	}
+
+	d.Server.TLSConfig = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  pool,
+		MinVersion: tls.VersionTLS12,
+	}
+
+	return d, nil
+}
+
+// newSignatureVerifier returns the auth.SignatureVerifier for signed
+// requests, using the replay window from the command line flags and
+// remembering nonces in memory
+func newSignatureVerifier(flags *cliFlags, f auth.SigningSecretFinder) auth.SignatureVerifier {
+	return auth.HMACSignatureVerifier{SecretFinder: f, Nonces: auth.NewMemoryNonceCache(), Window: flags.sigWindow}
+}
+
+// newAuthorizer returns the auth.Authorizer for the application. If
+// a policy file was given, a PolicyAuthorizer is returned which
+// watches the file for changes until the cleanup function is called.
+// If the roleauthz flag is set, a RoleAuthorizer using the roles
+// maintained through the admin API is returned. Otherwise, the
+// DefaultAuthorizer is returned.
+func newAuthorizer(ctx context.Context, logger zerolog.Logger, flags *cliFlags, rf auth.RoleFinder) (auth.Authorizer, func(), error) {
+	if flags.policy == "" {
+		if flags.roleAuthz {
+			logger.Info().Msg("authorizing requests using roles")
+			return auth.RoleAuthorizer{RoleFinder: rf}, func() {}, nil
+		}
+		return auth.DefaultAuthorizer{}, func() {}, nil
+	}
+
+	a, err := auth.NewPolicyAuthorizer(flags.policy)
+	if err != nil {
+		return nil, nil, err
+	}
+	logger.Info().Msgf("authorization policy loaded from %s", flags.policy)
+
+	ctx, cancel := context.WithCancel(ctx)
+	go a.Watch(ctx, logger, policyWatchInterval)
+
+	return a, cancel, nil
+}
+
+// policyWatchInterval is how often the policy file is checked for changes
+const policyWatchInterval = 5 * time.Second
+
+// testPolicy evaluates the user,path,method triple given by the
+// policytest flag against the policy file, writes the decision to w
+// and returns the process exit code: 0 if allowed, 1 if denied and
+// 2 if the policy or triple is invalid
+func testPolicy(w io.Writer, flags *cliFlags) int {
+	if flags.policy == "" {
+		fmt.Fprintln(w, "policy flag is required with policytest")
+		return 2
+	}
+
+	sub, obj, act, err := auth.ParsePolicyTest(flags.policyTest)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 2
+	}
+
+	a, err := auth.NewPolicyAuthorizer(flags.policy)
+	if err != nil {
+		fmt.Fprintln(w, err)
+		return 2
+	}
+
+	d := a.Policy().Evaluate(sub, obj, act)
+
+	var rule string
+	switch d.Rule {
+	case -1:
+		rule = "no rule matched"
+	default:
+		rule = fmt.Sprintf("rule %d", d.Rule)
+	}
+
+	if !d.Allowed {
+		fmt.Fprintf(w, "deny: %s %s %s (%s)\n", sub.Email, act, obj, rule)
+		return 1
+	}
+
+	fmt.Fprintf(w, "allow: %s %s %s (%s)\n", sub.Email, act, obj, rule)
+	return 0
+}
+
 func newPGDatasourceName(flags *cliFlags) (datastore.PGDatasourceName, error) {
 
 	// Constants for the PostgreSQL Database connection
@@ -10,6 +10,7 @@ create table demo.movie
     movie_id uuid not null
         constraint movie_pk
             primary key,
+    org_id uuid not null,
     extl_id varchar(250) not null,
     title varchar(1000) not null,
     rated varchar(10),
@@ -17,18 +18,24 @@ create table demo.movie
     run_time integer,
     director varchar(1000),
     writer varchar(1000),
+    create_client_id uuid,
+    create_user_id uuid,
     create_username varchar,
     create_timestamp timestamp with time zone,
+    update_client_id uuid,
+    update_user_id uuid,
     update_username varchar,
     update_timestamp timestamp with time zone
 );
 
 alter table demo.movie owner to postgres;
 
-create unique index movie_extl_id_uindex
-    on demo.movie (extl_id);
+-- external IDs are unique within the catalog of an org, so one org
+-- never sees, or is blocked by, the movies of another
+create unique index movie_org_id_extl_id_uindex
+    on demo.movie (org_id, extl_id);
 
-create function demo.create_movie(p_id uuid, p_extl_id character varying, p_title character varying, p_rated character varying, p_released date, p_run_time integer, p_director character varying, p_writer character varying, p_create_client_id uuid, p_create_username character varying)
+create function demo.create_movie(p_id uuid, p_org_id uuid, p_extl_id character varying, p_title character varying, p_rated character varying, p_released date, p_run_time integer, p_director character varying, p_writer character varying, p_create_client_id uuid, p_create_user_id uuid, p_create_username character varying)
     returns TABLE(o_create_timestamp timestamp without time zone, o_update_timestamp timestamp without time zone)
     language plpgsql
 as
@@ -42,6 +49,7 @@ BEGIN
     v_dml_timestamp := now() at time zone 'utc';
 
     INSERT INTO demo.movie (movie_id,
+                            org_id,
                             extl_id,
                             title,
                             rated,
@@ -49,13 +57,16 @@ BEGIN
                             run_time,
                             director,
                             writer,
---                           create_client_id,
+                            create_client_id,
+                            create_user_id,
                             create_username,
                             create_timestamp,
---                           update_client_id,
+                            update_client_id,
+                            update_user_id,
                             update_username,
                             update_timestamp)
     VALUES (p_id,
+            p_org_id,
             p_extl_id,
             p_title,
             p_rated,
@@ -63,10 +74,12 @@ BEGIN
             p_run_time,
             p_director,
             p_writer,
---           p_create_client_id,
+            p_create_client_id,
+            p_create_user_id,
             p_create_username,
             v_dml_timestamp,
---           p_create_client_id,
+            p_create_client_id,
+            p_create_user_id,
             p_create_username,
             v_dml_timestamp)
     RETURNING create_timestamp, update_timestamp
@@ -81,4 +94,270 @@ END;
 
 $$;
 
-alter function demo.create_movie(uuid, varchar, varchar, varchar, date, integer, varchar, varchar, uuid, varchar) owner to postgres;
+alter function demo.create_movie(uuid, uuid, varchar, varchar, varchar, date, integer, varchar, varchar, uuid, uuid, varchar) owner to postgres;
+
+-- Application users, roles, role permissions and role assignments
+-- used for authorization and maintained through the admin APIs
+create table demo.app_user
+(
+    user_id uuid not null
+        constraint app_user_pk
+            primary key,
+    email varchar(320) not null,
+    first_name varchar(250),
+    last_name varchar(250),
+    full_name varchar(500),
+    hosted_domain varchar(250),
+    picture_url varchar(2000),
+    profile_link varchar(2000),
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    update_username varchar,
+    update_timestamp timestamp with time zone
+);
+
+alter table demo.app_user owner to postgres;
+
+create unique index app_user_email_uindex
+    on demo.app_user (lower(email));
+
+-- movies are linked to the users who created and last updated them
+alter table demo.movie
+    add constraint movie_create_user_fk
+        foreign key (create_user_id) references demo.app_user
+            on delete set null;
+
+alter table demo.movie
+    add constraint movie_update_user_fk
+        foreign key (update_user_id) references demo.app_user
+            on delete set null;
+
+create table demo.app_role
+(
+    role_id uuid not null
+        constraint app_role_pk
+            primary key,
+    role_name varchar(100) not null,
+    description varchar(1000),
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    update_username varchar,
+    update_timestamp timestamp with time zone
+);
+
+alter table demo.app_role owner to postgres;
+
+create unique index app_role_role_name_uindex
+    on demo.app_role (role_name);
+
+create table demo.app_role_permission
+(
+    permission_id uuid not null
+        constraint app_role_permission_pk
+            primary key,
+    role_id uuid not null
+        constraint app_role_permission_app_role_fk
+            references demo.app_role
+            on delete cascade,
+    effect varchar(5) not null
+        constraint app_role_permission_effect_ck
+            check (effect in ('allow', 'deny')),
+    path_pattern varchar(1000) not null,
+    http_method varchar(10) not null
+);
+
+alter table demo.app_role_permission owner to postgres;
+
+create index app_role_permission_role_id_index
+    on demo.app_role_permission (role_id);
+
+create table demo.app_role_assignment
+(
+    role_id uuid not null
+        constraint app_role_assignment_app_role_fk
+            references demo.app_role
+            on delete cascade,
+    user_id uuid not null
+        constraint app_role_assignment_app_user_fk
+            references demo.app_user
+            on delete cascade,
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    constraint app_role_assignment_pk
+        primary key (role_id, user_id)
+);
+
+alter table demo.app_role_assignment owner to postgres;
+
+-- audit trail of all changes made through the admin APIs
+create table demo.audit_event
+(
+    audit_event_id uuid not null
+        constraint audit_event_pk
+            primary key,
+    action varchar(10) not null,
+    entity varchar(100) not null,
+    entity_id varchar(250) not null,
+    actor_username varchar not null,
+    event_timestamp timestamp with time zone not null,
+    detail jsonb
+);
+
+alter table demo.audit_event owner to postgres;
+
+create index audit_event_entity_index
+    on demo.audit_event (entity, entity_id);
+
+-- API keys issued to service accounts. Only a prefix (for lookup)
+-- and a SHA-256 hash of the full key are stored.
+create table demo.api_key
+(
+    api_key_id uuid not null
+        constraint api_key_pk
+            primary key,
+    key_name varchar(250) not null,
+    key_prefix varchar(50) not null,
+    key_hash bytea not null,
+    scopes varchar(100)[] not null default '{}',
+    user_id uuid not null
+        constraint api_key_app_user_fk
+            references demo.app_user
+                on delete cascade,
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    revoke_username varchar,
+    revoke_timestamp timestamp with time zone
+);
+
+alter table demo.api_key owner to postgres;
+
+create unique index api_key_key_prefix_uindex
+    on demo.api_key (key_prefix);
+
+-- clients (applications) registered to call the API. Clients
+-- identify themselves with the X-Client-ID header.
+create table demo.app_client
+(
+    client_id uuid not null
+        constraint app_client_pk
+            primary key,
+    client_name varchar(250) not null,
+    -- a user who owns clients cannot be deleted, the clients must be
+    -- given another owner first
+    owner_user_id uuid not null
+        constraint app_client_app_user_fk
+            references demo.app_user
+            on delete restrict,
+    status varchar(10) not null
+        constraint app_client_status_ck
+            check (status in ('active', 'disabled')),
+    scopes varchar(100)[] not null default '{}',
+    -- signing_secret is the shared secret the client signs requests
+    -- with. It is needed in plaintext to verify HMAC signatures.
+    signing_secret varchar(100),
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    update_username varchar,
+    update_timestamp timestamp with time zone
+);
+
+alter table demo.app_client owner to postgres;
+
+create unique index app_client_client_name_uindex
+    on demo.app_client (client_name);
+
+-- movies are linked to the clients they were created and last
+-- updated through
+alter table demo.movie
+    add constraint movie_create_client_fk
+        foreign key (create_client_id) references demo.app_client;
+
+alter table demo.movie
+    add constraint movie_update_client_fk
+        foreign key (update_client_id) references demo.app_client;
+
+create index movie_create_client_id_index
+    on demo.movie (create_client_id);
+
+-- organizations (tenants) whose movie catalogs are hosted by the
+-- API and the users who are members of each
+create table demo.org
+(
+    org_id uuid not null
+        constraint org_pk
+            primary key,
+    org_name varchar(250) not null,
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    update_username varchar,
+    update_timestamp timestamp with time zone
+);
+
+alter table demo.org owner to postgres;
+
+create unique index org_org_name_uindex
+    on demo.org (org_name);
+
+create table demo.org_member
+(
+    org_id uuid not null
+        constraint org_member_org_fk
+            references demo.org
+            on delete cascade,
+    user_id uuid not null
+        constraint org_member_app_user_fk
+            references demo.app_user
+            on delete cascade,
+    create_username varchar,
+    create_timestamp timestamp with time zone,
+    constraint org_member_pk
+        primary key (org_id, user_id)
+);
+
+alter table demo.org_member owner to postgres;
+
+create index org_member_user_id_index
+    on demo.org_member (user_id);
+
+-- each movie belongs to the catalog of one org
+alter table demo.movie
+    add constraint movie_org_fk
+        foreign key (org_id) references demo.org;
+
+create index movie_org_id_index
+    on demo.movie (org_id);
+
+-- row-level security on movies is a second line of defense behind
+-- the org filter in every moviestore query. The application sets
+-- app.current_org_id at the start of each transaction; if it is not
+-- set, no movies are visible. Superusers and roles with BYPASSRLS
+-- are not subject to these policies, so the application should
+-- connect as a regular role for them to take effect.
+alter table demo.movie enable row level security;
+
+alter table demo.movie force row level security;
+
+create policy movie_org_isolation on demo.movie
+    using (org_id = nullif(current_setting('app.current_org_id', true), '')::uuid)
+    with check (org_id = nullif(current_setting('app.current_org_id', true), '')::uuid);
+
+-- bootstrap an admin role with access to all APIs and assign
+-- it to an initial user. Change the email to your own.
+insert into demo.app_user (user_id, email, first_name, last_name, full_name, create_username, create_timestamp, update_username, update_timestamp)
+values ('a4d08ba5-d8a7-4b83-8ad8-2bbc72d5b3d4', 'otto.maddox711@gmail.com', 'Otto', 'Maddox', 'Otto Maddox', 'ddl', now(), 'ddl', now());
+
+insert into demo.app_role (role_id, role_name, description, create_username, create_timestamp, update_username, update_timestamp)
+values ('0a9e5a8e-1b8e-4d4e-9a64-3a6f1e1c7d01', 'admin', 'Full access to all APIs', 'ddl', now(), 'ddl', now());
+
+insert into demo.app_role_permission (permission_id, role_id, effect, path_pattern, http_method)
+values ('5d0b1f3a-7a8e-4a6c-b2a1-9a8d2c0f6e11', '0a9e5a8e-1b8e-4d4e-9a64-3a6f1e1c7d01', 'allow', '/api/v1/**', '*');
+
+insert into demo.app_role_assignment (role_id, user_id, create_username, create_timestamp)
+values ('0a9e5a8e-1b8e-4d4e-9a64-3a6f1e1c7d01', 'a4d08ba5-d8a7-4b83-8ad8-2bbc72d5b3d4', 'ddl', now());
+
+-- bootstrap a demo org with the initial user as a member
+insert into demo.org (org_id, org_name, create_username, create_timestamp, update_username, update_timestamp)
+values ('7c1e4b2a-9f3d-4e8a-b6c5-1d2e3f4a5b60', 'Helping Hand Video', 'ddl', now(), 'ddl', now());
+
+insert into demo.org_member (org_id, user_id, create_username, create_timestamp)
+values ('7c1e4b2a-9f3d-4e8a-b6c5-1d2e3f4a5b60', 'a4d08ba5-d8a7-4b83-8ad8-2bbc72d5b3d4', 'ddl', now());
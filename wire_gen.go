@@ -7,44 +7,132 @@ package main
 
 import (
 	"context"
-	"database/sql"
 	"github.com/gilcrest/go-api-basic/datastore"
+	"github.com/gilcrest/go-api-basic/datastore/authstore"
+	"github.com/gilcrest/go-api-basic/datastore/clientstore"
 	"github.com/gilcrest/go-api-basic/datastore/moviestore"
+	"github.com/gilcrest/go-api-basic/datastore/orgstore"
 	"github.com/gilcrest/go-api-basic/datastore/pingstore"
+	"github.com/gilcrest/go-api-basic/datastore/userstore"
 	"github.com/gilcrest/go-api-basic/domain/auth"
+	"github.com/gilcrest/go-api-basic/domain/client"
+	"github.com/gilcrest/go-api-basic/domain/healthcheck"
+	"github.com/gilcrest/go-api-basic/domain/org"
 	"github.com/gilcrest/go-api-basic/domain/random"
+	"github.com/gilcrest/go-api-basic/domain/ratelimit"
 	"github.com/gilcrest/go-api-basic/gateway/authgateway"
 	"github.com/gilcrest/go-api-basic/handler"
 	"github.com/google/wire"
 	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 	"go.opencensus.io/trace"
 	"gocloud.dev/server"
 	"gocloud.dev/server/driver"
 	"gocloud.dev/server/health"
-	"gocloud.dev/server/health/sqlhealth"
 	"net/http"
 )
 
 // Injectors from inject_main.go:
 
-func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatasourceName) (*server.Server, func(), error) {
-	googleAccessTokenConverter := authgateway.GoogleAccessTokenConverter{}
-	defaultAuthorizer := auth.DefaultAuthorizer{}
-	defaultStringGenerator := random.DefaultStringGenerator{}
+func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatasourceName, flags *cliFlags, reg *prometheus.Registry, drainer *healthcheck.Drainer) (*server.Server, func(), error) {
+	authMode, err := newAuthMode(flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	googleAccessTokenConverter, err := newGoogleAccessTokenConverter(flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	devTokenIssuer, err := newDevTokenIssuer(logger, flags, authMode)
+	if err != nil {
+		return nil, nil, err
+	}
 	db, cleanup, err := datastore.NewDB(dsn, logger)
 	if err != nil {
 		return nil, nil, err
 	}
 	defaultDatastore := datastore.NewDefaultDatastore(db)
-	defaultTransactor := moviestore.NewDefaultTransactor(defaultDatastore)
-	defaultSelector := moviestore.NewDefaultSelector(defaultDatastore)
+	defaultSelector := authstore.NewDefaultSelector(defaultDatastore)
+	apiKeyConverter := auth.APIKeyConverter{
+		APIKeyFinder: defaultSelector,
+	}
+	clientstoreDefaultSelector := clientstore.NewDefaultSelector(defaultDatastore)
+	signedClientConverter := auth.SignedClientConverter{
+		ClientFinder: clientstoreDefaultSelector,
+	}
+	subjectCertificateConverter := auth.SubjectCertificateConverter{}
+	certificateTokenConverter := auth.CertificateTokenConverter{
+		CertificateConverter: subjectCertificateConverter,
+	}
+	metrics, err := newMetrics(reg, defaultDatastore)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	accessTokenConverter := newAccessTokenConverter(authMode, googleAccessTokenConverter, devTokenIssuer, apiKeyConverter, signedClientConverter, certificateTokenConverter, metrics)
+	authorizer, cleanup2, err := newAuthorizer(ctx, logger, flags, defaultSelector)
+	if err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	userstoreDefaultSelector := userstore.NewDefaultSelector(defaultDatastore)
+	defaultTransactor := userstore.NewDefaultTransactor(defaultDatastore)
+	orgstoreDefaultSelector := orgstore.NewDefaultSelector(defaultDatastore)
+	signatureVerifier := newSignatureVerifier(flags, clientstoreDefaultSelector)
+	sessionCodec, err := newSessionCodec(logger, flags)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	gcpProjectID := newGCPProjectID(flags)
+	levelController := newLevelController(flags)
+	debugTokenSigner, err := newDebugTokenSigner(logger, flags)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	bodyLogConfig := newBodyLogConfig(flags)
+	timeoutConfig, err := newTimeoutConfig(flags)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	memoryStore := ratelimit.NewMemoryStore()
+	rateLimitConfig, err := newRateLimitConfig(flags, memoryStore)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
+	middleware := handler.Middleware{
+		AuthMode:             authMode,
+		AccessTokenConverter: accessTokenConverter,
+		Authorizer:           authorizer,
+		UserSelector:         userstoreDefaultSelector,
+		UserTransactor:       defaultTransactor,
+		ClientFinder:         clientstoreDefaultSelector,
+		OrgFinder:            orgstoreDefaultSelector,
+		SignatureVerifier:    signatureVerifier,
+		SessionCodec:         sessionCodec,
+		Metrics:              metrics,
+		GCPProjectID:         gcpProjectID,
+		LevelController:      levelController,
+		DebugTokenSigner:     debugTokenSigner,
+		BodyLog:              bodyLogConfig,
+		Timeouts:             timeoutConfig,
+		RateLimit:            rateLimitConfig,
+	}
+	defaultStringGenerator := random.DefaultStringGenerator{}
+	moviestoreDefaultTransactor := moviestore.NewDefaultTransactor(defaultDatastore)
+	moviestoreDefaultSelector := moviestore.NewDefaultSelector(defaultDatastore)
 	defaultMovieHandlers := handler.DefaultMovieHandlers{
-		AccessTokenConverter:  googleAccessTokenConverter,
-		Authorizer:            defaultAuthorizer,
 		RandomStringGenerator: defaultStringGenerator,
-		Transactor:            defaultTransactor,
-		Selector:              defaultSelector,
+		Transactor:            moviestoreDefaultTransactor,
+		Selector:              moviestoreDefaultSelector,
 	}
 	createMovieHandler := handler.ProvideCreateMovieHandler(defaultMovieHandlers)
 	findMovieByIDHandler := handler.ProvideFindMovieByIDHandler(defaultMovieHandlers)
@@ -56,19 +144,137 @@ func newServer(ctx context.Context, logger zerolog.Logger, dsn datastore.PGDatas
 		Pinger: defaultPinger,
 	}
 	pingHandler := handler.ProvidePingHandler(defaultPingHandler)
+	defaultMeHandlers := handler.DefaultMeHandlers{
+		UserTransactor: defaultTransactor,
+		RoleFinder:     defaultSelector,
+	}
+	findMeHandler := handler.ProvideFindMeHandler(defaultMeHandlers)
+	updateMeHandler := handler.ProvideUpdateMeHandler(defaultMeHandlers)
+	authstoreDefaultTransactor := authstore.NewDefaultTransactor(defaultDatastore)
+	clientstoreDefaultTransactor := clientstore.NewDefaultTransactor(defaultDatastore)
+	orgstoreDefaultTransactor := orgstore.NewDefaultTransactor(defaultDatastore)
+	defaultAdminHandlers := handler.DefaultAdminHandlers{
+		RandomStringGenerator: defaultStringGenerator,
+		UserTransactor:        defaultTransactor,
+		UserSelector:          userstoreDefaultSelector,
+		AuthTransactor:        authstoreDefaultTransactor,
+		AuthSelector:          defaultSelector,
+		ClientTransactor:      clientstoreDefaultTransactor,
+		ClientSelector:        clientstoreDefaultSelector,
+		OrgTransactor:         orgstoreDefaultTransactor,
+		OrgSelector:           orgstoreDefaultSelector,
+	}
+	findAllUsersHandler := handler.ProvideFindAllUsersHandler(defaultAdminHandlers)
+	createUserHandler := handler.ProvideCreateUserHandler(defaultAdminHandlers)
+	findUserByIDHandler := handler.ProvideFindUserByIDHandler(defaultAdminHandlers)
+	updateUserHandler := handler.ProvideUpdateUserHandler(defaultAdminHandlers)
+	deleteUserHandler := handler.ProvideDeleteUserHandler(defaultAdminHandlers)
+	findAllRolesHandler := handler.ProvideFindAllRolesHandler(defaultAdminHandlers)
+	createRoleHandler := handler.ProvideCreateRoleHandler(defaultAdminHandlers)
+	findRoleByIDHandler := handler.ProvideFindRoleByIDHandler(defaultAdminHandlers)
+	updateRoleHandler := handler.ProvideUpdateRoleHandler(defaultAdminHandlers)
+	deleteRoleHandler := handler.ProvideDeleteRoleHandler(defaultAdminHandlers)
+	findAllRoleAssignmentsHandler := handler.ProvideFindAllRoleAssignmentsHandler(defaultAdminHandlers)
+	createRoleAssignmentHandler := handler.ProvideCreateRoleAssignmentHandler(defaultAdminHandlers)
+	deleteRoleAssignmentHandler := handler.ProvideDeleteRoleAssignmentHandler(defaultAdminHandlers)
+	findAllAPIKeysHandler := handler.ProvideFindAllAPIKeysHandler(defaultAdminHandlers)
+	createAPIKeyHandler := handler.ProvideCreateAPIKeyHandler(defaultAdminHandlers)
+	revokeAPIKeyHandler := handler.ProvideRevokeAPIKeyHandler(defaultAdminHandlers)
+	findAllClientsHandler := handler.ProvideFindAllClientsHandler(defaultAdminHandlers)
+	createClientHandler := handler.ProvideCreateClientHandler(defaultAdminHandlers)
+	findClientByIDHandler := handler.ProvideFindClientByIDHandler(defaultAdminHandlers)
+	updateClientHandler := handler.ProvideUpdateClientHandler(defaultAdminHandlers)
+	rotateSigningSecretHandler := handler.ProvideRotateSigningSecretHandler(defaultAdminHandlers)
+	findAllOrgsHandler := handler.ProvideFindAllOrgsHandler(defaultAdminHandlers)
+	createOrgHandler := handler.ProvideCreateOrgHandler(defaultAdminHandlers)
+	addOrgMemberHandler := handler.ProvideAddOrgMemberHandler(defaultAdminHandlers)
+	removeOrgMemberHandler := handler.ProvideRemoveOrgMemberHandler(defaultAdminHandlers)
+	defaultLogLevelHandlers := handler.DefaultLogLevelHandlers{
+		LevelController:  levelController,
+		DebugTokenSigner: debugTokenSigner,
+	}
+	findLogLevelHandler := handler.ProvideFindLogLevelHandler(defaultLogLevelHandlers)
+	updateLogLevelHandler := handler.ProvideUpdateLogLevelHandler(defaultLogLevelHandlers)
+	issueDebugTokenHandler := handler.ProvideIssueDebugTokenHandler(defaultLogLevelHandlers)
+	defaultDevHandlers := handler.DefaultDevHandlers{
+		TokenIssuer: devTokenIssuer,
+	}
+	issueDevTokenHandler := handler.ProvideIssueDevTokenHandler(defaultDevHandlers)
+	config := newOAuth2Config(flags)
+	loginRedirectURL := newLoginRedirectURL(flags)
+	defaultLoginHandlers := handler.DefaultLoginHandlers{
+		OAuth2Config:          config,
+		SessionCodec:          sessionCodec,
+		RandomStringGenerator: defaultStringGenerator,
+		LoginRedirectURL:      loginRedirectURL,
+	}
+	loginHandler := handler.ProvideLoginHandler(defaultLoginHandlers)
+	loginCallbackHandler := handler.ProvideLoginCallbackHandler(defaultLoginHandlers)
+	logoutHandler := handler.ProvideLogoutHandler(defaultLoginHandlers)
+	v := appHealthChecks(drainer, defaultDatastore, authMode, googleAccessTokenConverter, flags)
+	monitor := newHealthMonitor(v, flags)
+	defaultHealthHandlers := handler.DefaultHealthHandlers{
+		Monitor: monitor,
+	}
+	livenessHandler := handler.ProvideLivenessHandler(defaultHealthHandlers)
+	readinessHandler := handler.ProvideReadinessHandler(defaultHealthHandlers)
+	healthHandler := handler.ProvideHealthHandler(defaultHealthHandlers)
+	metricsHandler := newMetricsHandler(flags, reg)
 	handlers := handler.Handlers{
-		CreateMovieHandler:   createMovieHandler,
-		FindMovieByIDHandler: findMovieByIDHandler,
-		FindAllMoviesHandler: findAllMoviesHandler,
-		UpdateMovieHandler:   updateMovieHandler,
-		DeleteMovieHandler:   deleteMovieHandler,
-		PingHandler:          pingHandler,
-	}
-	router := handler.NewMuxRouter(logger, handlers)
-	v, cleanup2 := appHealthChecks(db)
+		CreateMovieHandler:            createMovieHandler,
+		FindMovieByIDHandler:          findMovieByIDHandler,
+		FindAllMoviesHandler:          findAllMoviesHandler,
+		UpdateMovieHandler:            updateMovieHandler,
+		DeleteMovieHandler:            deleteMovieHandler,
+		PingHandler:                   pingHandler,
+		FindMeHandler:                 findMeHandler,
+		UpdateMeHandler:               updateMeHandler,
+		FindAllUsersHandler:           findAllUsersHandler,
+		CreateUserHandler:             createUserHandler,
+		FindUserByIDHandler:           findUserByIDHandler,
+		UpdateUserHandler:             updateUserHandler,
+		DeleteUserHandler:             deleteUserHandler,
+		FindAllRolesHandler:           findAllRolesHandler,
+		CreateRoleHandler:             createRoleHandler,
+		FindRoleByIDHandler:           findRoleByIDHandler,
+		UpdateRoleHandler:             updateRoleHandler,
+		DeleteRoleHandler:             deleteRoleHandler,
+		FindAllRoleAssignmentsHandler: findAllRoleAssignmentsHandler,
+		CreateRoleAssignmentHandler:   createRoleAssignmentHandler,
+		DeleteRoleAssignmentHandler:   deleteRoleAssignmentHandler,
+		FindAllAPIKeysHandler:         findAllAPIKeysHandler,
+		CreateAPIKeyHandler:           createAPIKeyHandler,
+		RevokeAPIKeyHandler:           revokeAPIKeyHandler,
+		FindAllClientsHandler:         findAllClientsHandler,
+		CreateClientHandler:           createClientHandler,
+		FindClientByIDHandler:         findClientByIDHandler,
+		UpdateClientHandler:           updateClientHandler,
+		RotateSigningSecretHandler:    rotateSigningSecretHandler,
+		FindAllOrgsHandler:            findAllOrgsHandler,
+		CreateOrgHandler:              createOrgHandler,
+		AddOrgMemberHandler:           addOrgMemberHandler,
+		RemoveOrgMemberHandler:        removeOrgMemberHandler,
+		FindLogLevelHandler:           findLogLevelHandler,
+		UpdateLogLevelHandler:         updateLogLevelHandler,
+		IssueDebugTokenHandler:        issueDebugTokenHandler,
+		IssueDevTokenHandler:          issueDevTokenHandler,
+		LoginHandler:                  loginHandler,
+		LoginCallbackHandler:          loginCallbackHandler,
+		LogoutHandler:                 logoutHandler,
+		LivenessHandler:               livenessHandler,
+		ReadinessHandler:              readinessHandler,
+		HealthHandler:                 healthHandler,
+		MetricsHandler:                metricsHandler,
+	}
+	router := handler.NewMuxRouter(logger, middleware, handlers)
 	exporter := _wireExporterValue
-	sampler := trace.AlwaysSample()
-	defaultDriver := server.NewDefaultDriver()
+	sampler := trace.NeverSample()
+	defaultDriver, err := newServerDriver(flags, authMode)
+	if err != nil {
+		cleanup2()
+		cleanup()
+		return nil, nil, err
+	}
 	options := &server.Options{
 		HealthChecks:          v,
 		TraceExporter:         exporter,
@@ -90,22 +296,83 @@ var (
 
 var pingHandlerSet = wire.NewSet(pingstore.NewDefaultPinger, wire.Bind(new(pingstore.Pinger), new(pingstore.DefaultPinger)), wire.Struct(new(handler.DefaultPingHandler), "*"), handler.ProvidePingHandler)
 
-var movieHandlerSet = wire.NewSet(wire.Struct(new(random.DefaultStringGenerator), "*"), wire.Bind(new(random.StringGenerator), new(random.DefaultStringGenerator)), wire.Struct(new(authgateway.GoogleAccessTokenConverter), "*"), wire.Bind(new(auth.AccessTokenConverter), new(authgateway.GoogleAccessTokenConverter)), wire.Struct(new(auth.DefaultAuthorizer), "*"), wire.Bind(new(auth.Authorizer), new(auth.DefaultAuthorizer)), moviestore.NewDefaultTransactor, wire.Bind(new(moviestore.Transactor), new(moviestore.DefaultTransactor)), moviestore.NewDefaultSelector, wire.Bind(new(moviestore.Selector), new(moviestore.DefaultSelector)), wire.Struct(new(handler.DefaultMovieHandlers), "*"), handler.ProvideCreateMovieHandler, handler.ProvideFindMovieByIDHandler, handler.ProvideFindAllMoviesHandler, handler.ProvideUpdateMovieHandler, handler.ProvideDeleteMovieHandler, wire.Struct(new(handler.Handlers), "*"))
+var movieHandlerSet = wire.NewSet(wire.Struct(new(random.DefaultStringGenerator), "*"), wire.Bind(new(random.StringGenerator), new(random.DefaultStringGenerator)), newGoogleAccessTokenConverter, wire.Struct(new(auth.APIKeyConverter), "*"), wire.Struct(new(auth.SignedClientConverter), "*"), wire.Struct(new(auth.SubjectCertificateConverter)), wire.Bind(new(auth.CertificateConverter), new(auth.SubjectCertificateConverter)), wire.Struct(new(auth.CertificateTokenConverter), "*"), newDevTokenIssuer,
+	newAccessTokenConverter,
+	newSignatureVerifier,
+	newAuthorizer, moviestore.NewDefaultTransactor, wire.Bind(new(moviestore.Transactor), new(moviestore.DefaultTransactor)), moviestore.NewDefaultSelector, wire.Bind(new(moviestore.Selector), new(moviestore.DefaultSelector)), wire.Struct(new(handler.DefaultMovieHandlers), "*"), handler.ProvideCreateMovieHandler, handler.ProvideFindMovieByIDHandler, handler.ProvideFindAllMoviesHandler, handler.ProvideUpdateMovieHandler, handler.ProvideDeleteMovieHandler,
+)
+
+var adminHandlerSet = wire.NewSet(userstore.NewDefaultTransactor, wire.Bind(new(userstore.Transactor), new(userstore.DefaultTransactor)), userstore.NewDefaultSelector, wire.Bind(new(userstore.Selector), new(userstore.DefaultSelector)), authstore.NewDefaultTransactor, wire.Bind(new(authstore.Transactor), new(authstore.DefaultTransactor)), authstore.NewDefaultSelector, wire.Bind(new(authstore.Selector), new(authstore.DefaultSelector)), wire.Bind(new(auth.RoleFinder), new(authstore.DefaultSelector)), wire.Bind(new(auth.APIKeyFinder), new(authstore.DefaultSelector)), clientstore.NewDefaultTransactor, wire.Bind(new(clientstore.Transactor), new(clientstore.DefaultTransactor)), clientstore.NewDefaultSelector, wire.Bind(new(clientstore.Selector), new(clientstore.DefaultSelector)), wire.Bind(new(client.Finder), new(clientstore.DefaultSelector)), wire.Bind(new(auth.SigningSecretFinder), new(clientstore.DefaultSelector)), orgstore.NewDefaultTransactor, wire.Bind(new(orgstore.Transactor), new(orgstore.DefaultTransactor)), orgstore.NewDefaultSelector, wire.Bind(new(orgstore.Selector), new(orgstore.DefaultSelector)), wire.Bind(new(org.Finder), new(orgstore.DefaultSelector)), wire.Struct(new(handler.DefaultAdminHandlers), "*"), handler.ProvideFindAllUsersHandler, handler.ProvideCreateUserHandler, handler.ProvideFindUserByIDHandler, handler.ProvideUpdateUserHandler, handler.ProvideDeleteUserHandler, handler.ProvideFindAllRolesHandler, handler.ProvideCreateRoleHandler, handler.ProvideFindRoleByIDHandler, handler.ProvideUpdateRoleHandler, handler.ProvideDeleteRoleHandler, handler.ProvideFindAllRoleAssignmentsHandler, handler.ProvideCreateRoleAssignmentHandler, handler.ProvideDeleteRoleAssignmentHandler, handler.ProvideFindAllAPIKeysHandler, handler.ProvideCreateAPIKeyHandler, handler.ProvideRevokeAPIKeyHandler, handler.ProvideFindAllClientsHandler, handler.ProvideCreateClientHandler, handler.ProvideFindClientByIDHandler, handler.ProvideUpdateClientHandler, handler.ProvideRotateSigningSecretHandler, handler.ProvideFindAllOrgsHandler, handler.ProvideCreateOrgHandler, handler.ProvideAddOrgMemberHandler, handler.ProvideRemoveOrgMemberHandler)
+
+var devHandlerSet = wire.NewSet(wire.Struct(new(handler.DefaultDevHandlers), "*"), handler.ProvideIssueDevTokenHandler)
+
+var loginHandlerSet = wire.NewSet(
+	newOAuth2Config,
+	newLoginRedirectURL, wire.Struct(new(handler.DefaultLoginHandlers), "*"), handler.ProvideLoginHandler, handler.ProvideLoginCallbackHandler, handler.ProvideLogoutHandler,
+)
+
+var meHandlerSet = wire.NewSet(wire.Struct(new(handler.DefaultMeHandlers), "*"), handler.ProvideFindMeHandler, handler.ProvideUpdateMeHandler)
+
+var logLevelHandlerSet = wire.NewSet(
+	newLevelController,
+	newDebugTokenSigner, wire.Struct(new(handler.DefaultLogLevelHandlers), "*"), handler.ProvideFindLogLevelHandler, handler.ProvideUpdateLogLevelHandler, handler.ProvideIssueDebugTokenHandler,
+)
+
+var healthHandlerSet = wire.NewSet(
+	appHealthChecks,
+	newHealthMonitor, wire.Struct(new(handler.DefaultHealthHandlers), "*"), handler.ProvideLivenessHandler, handler.ProvideReadinessHandler, handler.ProvideHealthHandler,
+)
 
 var datastoreSet = wire.NewSet(datastore.NewDB, datastore.NewDefaultDatastore, wire.Bind(new(datastore.Datastorer), new(datastore.DefaultDatastore)))
 
-// goCloudServerSet
-var goCloudServerSet = wire.NewSet(trace.AlwaysSample, server.New, server.NewDefaultDriver, wire.Bind(new(driver.Server), new(*server.DefaultDriver)))
+// goCloudServerSet is the Wire provider set for the gocloud server.
+// Tracing is done with OpenTelemetry, so the server's OpenCensus
+// spans are never sampled.
+var goCloudServerSet = wire.NewSet(trace.NeverSample, server.New, newServerDriver, wire.Bind(new(driver.Server), new(*server.DefaultDriver)))
 
-var routerSet = wire.NewSet(handler.NewMuxRouter, wire.Bind(new(http.Handler), new(*mux.Router)))
+var metricsSet = wire.NewSet(
+	newMetrics,
+	newMetricsHandler,
+)
 
-// appHealthChecks returns a health check for the database. This will signal
-// to Kubernetes or other orchestrators that the server should not receive
-// traffic until the server is able to connect to its database.
-func appHealthChecks(db *sql.DB) ([]health.Checker, func()) {
-	dbCheck := sqlhealth.New(db)
-	list := []health.Checker{dbCheck}
-	return list, func() {
-		dbCheck.Stop()
+var routerSet = wire.NewSet(
+	newAuthMode,
+	newSessionCodec,
+	newGCPProjectID,
+	newBodyLogConfig,
+	newTimeoutConfig, ratelimit.NewMemoryStore, wire.Bind(new(ratelimit.Store), new(*ratelimit.MemoryStore)), newRateLimitConfig, wire.Struct(new(handler.Middleware), "*"), handler.NewMuxRouter, wire.Bind(new(http.Handler), new(*mux.Router)),
+)
+
+// appHealthChecks returns the health checks of the application: the
+// drainer, which fails once the server is shutting down, and the
+// dependencies of the application: the database, its schema and,
+// if the auth mode converts Bearer tokens with Google, Google.
+// This will signal to Kubernetes or other orchestrators that the
+// server should not receive traffic until the server is able to
+// reach its dependencies. Add checks for new dependencies here.
+//
+// Google is checked with a plain client, so probes are not retried
+// and do not trip the circuit breaker used to convert tokens, and
+// the result is cached, so probes do not call Google every time.
+func appHealthChecks(drainer *healthcheck.Drainer, ds datastore.Datastorer, mode handler.AuthMode, google authgateway.GoogleAccessTokenConverter, flags *cliFlags) []health.Checker {
+	list := []health.Checker{
+		drainer, datastore.PingChecker{Datastorer: ds}, datastore.SchemaChecker{Datastorer: ds, Tables: datastore.SchemaTables},
+	}
+	if usesGoogle(mode) {
+		check := authgateway.GoogleAccessTokenConverter{
+			Endpoint:   google.Endpoint,
+			HTTPClient: &http.Client{Timeout: flags.healthTTL},
+		}
+		list = append(list, healthcheck.Cached(check, healthcheck.DefaultCacheTTL))
 	}
+	return list
+}
+
+// usesGoogle reports whether requests are authenticated with Google
+// access tokens in the auth mode. Only token mode converts Bearer
+// tokens with Google: dev mode converts them with the dev token
+// issuer and mTLS mode authenticates every user request with its
+// client certificate, session cookies included.
+func usesGoogle(mode handler.AuthMode) bool {
+	return mode == handler.TokenAuthMode
 }